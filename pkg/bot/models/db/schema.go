@@ -14,25 +14,34 @@ type Subscriptions struct {
 	bun.BaseModel `bun:"subscriptions"`
 
 	ID           uuid.UUID `bun:"id,pk"`
-	ChatID       int64     `bun:"chat_id,notnull,unique:subscriptions_chat_id_topic_pattern_key"`
-	TopicPattern string    `bun:"topic_pattern,notnull,unique:subscriptions_chat_id_topic_pattern_key"`
+	ChatID       int64     `bun:"chat_id,notnull,unique:subscriptions_target_topic_pattern_key"`
+	TopicPattern string    `bun:"topic_pattern,notnull,unique:subscriptions_target_topic_pattern_key"`
+	MinPriority  int       `bun:"min_priority,notnull,default:0"`
+	Digest       string    `bun:"digest,notnull,default:''"`
+	MutedUntil   time.Time `bun:"muted_until,nullzero"`
+	ExpiresAt    time.Time `bun:"expires_at,nullzero"`
+	CreatedBy    int64     `bun:"created_by,notnull,default:0"`
+	Sink         string    `bun:"sink,notnull,default:'',unique:subscriptions_target_topic_pattern_key"`
+	Destination  string    `bun:"destination,notnull,default:'',unique:subscriptions_target_topic_pattern_key"`
 }
 
 type WeatherPollingLocations struct {
 	bun.BaseModel `bun:"weather_polling_locations"`
 
-	ID      int                   `bun:"id,pk,autoincrement"`
-	Name    string                `bun:"name,notnull"`
-	Country string                `bun:"country,notnull"`
-	ZipCode string                `bun:"zip_code,notnull,unique:weather_polling_locations_zip_code_key"`
-	Lon     float64               `bun:"lon,notnull"`
-	Lat     float64               `bun:"lat,notnull"`
-	Polling bool                  `bun:"polling,notnull,default:true"`
-	Chats   []*WeatherPollerChats `bun:"rel:has-many,join:id=poller_location_id"`
+	ID      int    `bun:"id,pk,autoincrement"`
+	Name    string `bun:"name,notnull"`
+	Country string `bun:"country,notnull"`
+	// Key identifies the location in weather topics, e.g. us-90210 for a postal code or geo-2981n_9531w for coordinates
+	Key        string                `bun:"location_key,notnull,unique:weather_polling_locations_location_key_key"`
+	PostalCode string                `bun:"postal_code,notnull,default:''"`
+	Lon        float64               `bun:"lon,notnull"`
+	Lat        float64               `bun:"lat,notnull"`
+	Polling    bool                  `bun:"polling,notnull,default:true"`
+	Chats      []*WeatherPollerChats `bun:"rel:has-many,join:id=poller_location_id"`
 }
 
 func (w WeatherPollingLocations) IsEmpty() bool {
-	return w.ID == 0 && w.Name == "" && w.Country == "" && w.ZipCode == "" && w.Lon == 0 && w.Lat == 0
+	return w.ID == 0 && w.Name == "" && w.Country == "" && w.Key == "" && w.Lon == 0 && w.Lat == 0
 }
 
 type WeatherPollerChats struct {
@@ -44,6 +53,75 @@ type WeatherPollerChats struct {
 	WeatherPollingLocation *WeatherPollingLocations `bun:"rel:belongs-to,join:poller_location_id=id"`
 }
 
+// WeatherChatSettings are a chat's weather preferences, chats without a row use the defaults
+type WeatherChatSettings struct {
+	bun.BaseModel `bun:"weather_chat_settings"`
+
+	ChatID int64 `bun:"chat_id,pk"`
+	// Units is metric or imperial
+	Units string `bun:"units,notnull,default:'metric'"`
+}
+
+// WeatherAlertSettings are how a chat wants weather alerts filtered and shown, chats without a row get every alert
+// in the default template
+type WeatherAlertSettings struct {
+	bun.BaseModel `bun:"weather_alert_settings"`
+
+	ChatID int64 `bun:"chat_id,pk"`
+	// IncludeEvents are the only events the chat gets if set, ExcludeEvents are left out
+	IncludeEvents []string `bun:"include_events"`
+	ExcludeEvents []string `bun:"exclude_events"`
+	// MinCategory is the lowest event type the chat gets, warning, watch or advisory. Empty for all of them.
+	MinCategory string `bun:"min_category,notnull,default:''"`
+	// DescriptionLength is how much of the description is shown, zero for the default
+	DescriptionLength int `bun:"description_length,notnull,default:0"`
+	// Template replaces the alert template if set
+	Template string `bun:"template,notnull,default:''"`
+}
+
+// WeatherBriefings are the chats' daily briefing schedules, one per chat and location
+type WeatherBriefings struct {
+	bun.BaseModel `bun:"weather_briefings"`
+
+	ID         int   `bun:"id,pk,autoincrement"`
+	ChatID     int64 `bun:"chat_id,notnull,unique:weather_briefings_chat_id_key"`
+	LocationID int   `bun:"location_id,notnull,unique:weather_briefings_chat_id_key"`
+	// Time is the HH:MM the briefing is sent at in TZ, the location's timezone
+	Time       string                   `bun:"briefing_time,notnull"`
+	TZ         string                   `bun:"tz,notnull"`
+	LastSentAt time.Time                `bun:"last_sent_at,nullzero"`
+	Location   *WeatherPollingLocations `bun:"rel:belongs-to,join:location_id=id"`
+}
+
+// WeatherActiveAlerts are the alerts published for each polled location that haven't ended yet, compared against each
+// poll to publish when they're updated and when they end
+type WeatherActiveAlerts struct {
+	bun.BaseModel `bun:"weather_active_alerts"`
+
+	ID         int    `bun:"id,pk,autoincrement"`
+	LocationID int    `bun:"location_id,notnull,unique:weather_active_alerts_location_id_key"`
+	EventID    string `bun:"event_id,notnull,unique:weather_active_alerts_location_id_key"`
+	Event      string `bun:"event,notnull"`
+	// Category is the event type the alert was published as, warning, watch, advisory or unknown
+	Category string    `bun:"category,notnull"`
+	EndsAt   time.Time `bun:"ends_at,notnull"`
+	// Checksum is of the alert's text, to tell when it's reworded
+	Checksum  string    `bun:"checksum,notnull"`
+	FirstSeen time.Time `bun:"first_seen,notnull"`
+	LastSeen  time.Time `bun:"last_seen,notnull"`
+	// Missed counts the polls in a row the alert was missing from, a single miss can be a provider hiccup
+	Missed int `bun:"missed,notnull,default:0"`
+}
+
+// WeatherAPIUsage is the number of calls made to a weather provider's api on a day, UTC
+type WeatherAPIUsage struct {
+	bun.BaseModel `bun:"weather_api_usage"`
+
+	Provider string `bun:"provider,pk"`
+	Day      string `bun:"day,pk"`
+	Calls    int    `bun:"calls,notnull"`
+}
+
 type NotificationsDupeCache struct {
 	bun.BaseModel `bun:"notifications_dupe_cache"`
 
@@ -53,6 +131,70 @@ type NotificationsDupeCache struct {
 	DupeTTLEnd time.Time `bun:"dupe_ttl_end,notnull"`
 }
 
+type NotificationsQuietHours struct {
+	bun.BaseModel `bun:"notifications_quiet_hours"`
+
+	ChatID int64  `bun:"chat_id,pk"`
+	Start  string `bun:"start_time,notnull"`
+	End    string `bun:"end_time,notnull"`
+	TZ     string `bun:"tz,notnull,default:'America/New_York'"`
+}
+
+type NotificationsDeferred struct {
+	bun.BaseModel `bun:"notifications_deferred"`
+
+	ID         int       `bun:"id,pk,autoincrement"`
+	CreatedAt  time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	ChatID     int64     `bun:"chat_id,notnull"`
+	Topic      string    `bun:"topic,notnull"`
+	Message    string    `bun:"message,notnull"`
+	DupeKey    string    `bun:"dupe_key,notnull"`
+	DupeTTLEnd time.Time `bun:"dupe_ttl_end,notnull"`
+	Priority   int       `bun:"priority,notnull,default:0"`
+}
+
+// NotificationsInbox holds published messages until they've been fanned out to the outbox, so a message Publish
+// accepted survives the bot stopping before it's handled
+type NotificationsInbox struct {
+	bun.BaseModel `bun:"notifications_inbox"`
+
+	ID        int       `bun:"id,pk,autoincrement"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	Topic     string    `bun:"topic,notnull"`
+	Message   string    `bun:"message,notnull"`
+	DupeKey   string    `bun:"dupe_key,notnull,default:''"`
+	// DupeTTL is in nanoseconds
+	DupeTTL  int64  `bun:"dupe_ttl,notnull,default:0"`
+	Priority int    `bun:"priority,notnull,default:0"`
+	Options  string `bun:"options,notnull,default:'{}'"`
+	// Data is the message's Data as json, empty if it had none
+	Data string `bun:"data,notnull,default:''"`
+	// Attempts counts the failed tries at handling the message, it's tried again at NextAttemptAt
+	Attempts      int       `bun:"attempts,notnull,default:0"`
+	NextAttemptAt time.Time `bun:"next_attempt_at,nullzero"`
+}
+
+type NotificationsOutbox struct {
+	bun.BaseModel `bun:"notifications_outbox"`
+
+	ID            int       `bun:"id,pk,autoincrement"`
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt     time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+	ChatID        int64     `bun:"chat_id,notnull"`
+	Sink          string    `bun:"sink,notnull,default:''"`
+	Destination   string    `bun:"destination,notnull,default:''"`
+	Topic         string    `bun:"topic,notnull"`
+	Message       string    `bun:"message,notnull"`
+	DupeKey       string    `bun:"dupe_key,notnull"`
+	Priority      int       `bun:"priority,notnull,default:0"`
+	Options       string    `bun:"options,notnull,default:'{}'"`
+	Status        string    `bun:"status,notnull"`
+	Attempts      int       `bun:"attempts,notnull,default:0"`
+	NextAttemptAt time.Time `bun:"next_attempt_at,notnull"`
+	LastError     string    `bun:"last_error"`
+	DeliveredAt   time.Time `bun:"delivered_at,nullzero"`
+}
+
 type Birthdays struct {
 	bun.BaseModel `bun:"birthdays"`
 
@@ -95,3 +237,60 @@ func (c *ChatLogs) BeforeAppendModel(ctx context.Context, query schema.Query) er
 
 	return nil
 }
+
+type NotificationsDigestItems struct {
+	bun.BaseModel `bun:"notifications_digest_items"`
+
+	ID             int       `bun:"id,pk,autoincrement"`
+	CreatedAt      time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	ChatID         int64     `bun:"chat_id,notnull"`
+	SubscriptionID uuid.UUID `bun:"subscription_id,notnull"`
+	Topic          string    `bun:"topic,notnull"`
+	Message        string    `bun:"message,notnull"`
+	DupeKey        string    `bun:"dupe_key,notnull"`
+	Priority       int       `bun:"priority,notnull,default:0"`
+}
+
+type NotificationsSentEvents struct {
+	bun.BaseModel `bun:"notifications_sent_events"`
+
+	ID            int       `bun:"id,pk,autoincrement"`
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt     time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+	ChatID        int64     `bun:"chat_id,notnull,unique:notifications_sent_events_chat_id_event_id_key"`
+	EventID       string    `bun:"event_id,notnull,unique:notifications_sent_events_chat_id_event_id_key"`
+	MessageID     int       `bun:"message_id,notnull"`
+	Text          string    `bun:"text,notnull"`
+	ParseMode     string    `bun:"parse_mode,notnull,default:''"`
+	HasAttachment bool      `bun:"has_attachment,notnull,default:false"`
+}
+
+type NotificationsTopicStats struct {
+	bun.BaseModel `bun:"notifications_topic_stats"`
+
+	Topic     string    `bun:"topic,pk"`
+	FirstSeen time.Time `bun:"first_seen,notnull"`
+	LastSeen  time.Time `bun:"last_seen,notnull"`
+	Count     int       `bun:"count,notnull,default:0"`
+}
+
+type NotificationsHistory struct {
+	bun.BaseModel `bun:"notifications_history"`
+
+	ID                int       `bun:"id,pk,autoincrement"`
+	CreatedAt         time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt         time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+	ChatID            int64     `bun:"chat_id,notnull"`
+	Sink              string    `bun:"sink,notnull,default:''"`
+	Destination       string    `bun:"destination,notnull,default:''"`
+	Topic             string    `bun:"topic,notnull"`
+	MessageHash       string    `bun:"message_hash,notnull"`
+	Preview           string    `bun:"preview,notnull,default:''"`
+	Priority          int       `bun:"priority,notnull,default:0"`
+	Status            string    `bun:"status,notnull"`
+	Detail            string    `bun:"detail,notnull,default:''"`
+	OutboxID          int       `bun:"outbox_id,nullzero"`
+	TelegramMessageID int       `bun:"telegram_message_id,nullzero"`
+	// Repeats counts the further times a duplicate was left out after this row was recorded
+	Repeats int `bun:"repeats,notnull,default:0"`
+}
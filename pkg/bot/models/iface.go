@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/tomato3017/tomatobot/pkg/bot/models/tgapi"
 	"github.com/tomato3017/tomatobot/pkg/command"
 )
@@ -10,4 +11,6 @@ type TomatobotInstance interface {
 	RegisterCommand(name string, command command.TomatobotCommand) error
 	RegisterSimpleCommand(name, desc, help string, callback command.CommandCallback) error
 	RegisterChatCallback(name string, handler func(ctx context.Context, msg tgapi.TGBotMsg)) error
+	// RegisterCallbackQueryHandler handles the inline button presses whose callback data starts with "<prefix>:"
+	RegisterCallbackQueryHandler(prefix string, handler func(ctx context.Context, query *tgbotapi.CallbackQuery) error) error
 }
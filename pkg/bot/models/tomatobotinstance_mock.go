@@ -10,6 +10,8 @@ import (
 	mock "github.com/stretchr/testify/mock"
 
 	tgapi "github.com/tomato3017/tomatobot/pkg/bot/models/tgapi"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 // MockTomatobotInstance is an autogenerated mock type for the TomatobotInstance type
@@ -25,6 +27,53 @@ func (_m *MockTomatobotInstance) EXPECT() *MockTomatobotInstance_Expecter {
 	return &MockTomatobotInstance_Expecter{mock: &_m.Mock}
 }
 
+// RegisterCallbackQueryHandler provides a mock function with given fields: prefix, handler
+func (_m *MockTomatobotInstance) RegisterCallbackQueryHandler(prefix string, handler func(context.Context, *tgbotapi.CallbackQuery) error) error {
+	ret := _m.Called(prefix, handler)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RegisterCallbackQueryHandler")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, func(context.Context, *tgbotapi.CallbackQuery) error) error); ok {
+		r0 = rf(prefix, handler)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockTomatobotInstance_RegisterCallbackQueryHandler_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RegisterCallbackQueryHandler'
+type MockTomatobotInstance_RegisterCallbackQueryHandler_Call struct {
+	*mock.Call
+}
+
+// RegisterCallbackQueryHandler is a helper method to define mock.On call
+//   - prefix string
+//   - handler func(context.Context , *tgbotapi.CallbackQuery) error
+func (_e *MockTomatobotInstance_Expecter) RegisterCallbackQueryHandler(prefix interface{}, handler interface{}) *MockTomatobotInstance_RegisterCallbackQueryHandler_Call {
+	return &MockTomatobotInstance_RegisterCallbackQueryHandler_Call{Call: _e.mock.On("RegisterCallbackQueryHandler", prefix, handler)}
+}
+
+func (_c *MockTomatobotInstance_RegisterCallbackQueryHandler_Call) Run(run func(prefix string, handler func(context.Context, *tgbotapi.CallbackQuery) error)) *MockTomatobotInstance_RegisterCallbackQueryHandler_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(func(context.Context, *tgbotapi.CallbackQuery) error))
+	})
+	return _c
+}
+
+func (_c *MockTomatobotInstance_RegisterCallbackQueryHandler_Call) Return(_a0 error) *MockTomatobotInstance_RegisterCallbackQueryHandler_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockTomatobotInstance_RegisterCallbackQueryHandler_Call) RunAndReturn(run func(string, func(context.Context, *tgbotapi.CallbackQuery) error) error) *MockTomatobotInstance_RegisterCallbackQueryHandler_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // RegisterChatCallback provides a mock function with given fields: name, handler
 func (_m *MockTomatobotInstance) RegisterChatCallback(name string, handler func(context.Context, tgapi.TGBotMsg)) error {
 	ret := _m.Called(name, handler)
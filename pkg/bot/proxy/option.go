@@ -1,6 +1,9 @@
 package proxy
 
-import "github.com/rs/zerolog"
+import (
+	"github.com/rs/zerolog"
+	"github.com/tomato3017/tomatobot/pkg/config"
+)
 
 func WithSendToChatChannels(sendToChatChannels bool) ProxyOption {
 	return func(tgBotProxy *TGBotProxy) error {
@@ -15,3 +18,10 @@ func WithLogger(logger zerolog.Logger) ProxyOption {
 		return nil
 	}
 }
+
+func WithConfig(cfg config.TomatoBot) ProxyOption {
+	return func(tgBotProxy *TGBotProxy) error {
+		tgBotProxy.cfg = cfg
+		return nil
+	}
+}
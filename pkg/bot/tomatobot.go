@@ -13,12 +13,14 @@ import (
 	cmdmdls "github.com/tomato3017/tomatobot/pkg/command/models"
 	"github.com/tomato3017/tomatobot/pkg/config"
 	"github.com/tomato3017/tomatobot/pkg/db"
+	"github.com/tomato3017/tomatobot/pkg/httpapi"
 	"github.com/tomato3017/tomatobot/pkg/modules"
 	"github.com/tomato3017/tomatobot/pkg/modules/birthday"
 	"github.com/tomato3017/tomatobot/pkg/modules/myid"
 	"github.com/tomato3017/tomatobot/pkg/modules/topic"
 	"github.com/tomato3017/tomatobot/pkg/modules/weather"
 	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/notifications/sinks"
 	"github.com/tomato3017/tomatobot/pkg/sqlmigrate"
 	"github.com/tomato3017/tomatobot/pkg/util"
 	"github.com/uptrace/bun"
@@ -45,6 +47,7 @@ type Tomatobot struct {
 	loadedModules   map[string]modules.BotModule
 	commandRegistry map[string]command.TomatobotCommand
 	chatCallbacks   map[string]func(ctx context.Context, msg tgapi.TGBotMsg)
+	callbackQueries map[string]func(ctx context.Context, query *tgbotapi.CallbackQuery) error
 
 	notiPublisher *notifications.NotificationPublisher
 	botProxy      proxy.TGBotImplementation
@@ -68,6 +71,20 @@ func (t *Tomatobot) RegisterChatCallback(name string, handler func(ctx context.C
 	return nil
 }
 
+func (t *Tomatobot) RegisterCallbackQueryHandler(prefix string, handler func(ctx context.Context, query *tgbotapi.CallbackQuery) error) error {
+	if strings.Contains(prefix, ":") {
+		return fmt.Errorf("callback query prefix %s can't contain a colon", prefix)
+	}
+	if _, ok := t.callbackQueries[prefix]; ok {
+		return fmt.Errorf("callback query handler %s already registered", prefix)
+	}
+
+	t.callbackQueries[prefix] = handler
+
+	t.logger.Debug().Msgf("Registered callback query handler: %s", prefix)
+	return nil
+}
+
 func (t *Tomatobot) RegisterCommand(name string, commandHandler command.TomatobotCommand) error {
 	t.logger.Debug().Msgf("Registering command: %s", name)
 	if _, ok := t.commandRegistry[name]; ok {
@@ -100,8 +117,11 @@ func (t *Tomatobot) Run(ctx context.Context) error {
 	t.logger.Info().Msg("Telegram bot authorized successfully")
 
 	// Initialize the notification publisher
-	t.notiPublisher = notifications.NewNotificationPublisher(tgbot, t.dbConn,
-		notifications.WithLogger(t.logger.With().Str("module", "notifications").Logger()))
+	publisherOpts := append([]notifications.PublisherOptions{
+		notifications.WithLogger(t.logger.With().Str("module", "notifications").Logger()),
+		notifications.WithBotAdminCheck(t.cfg.TomatoBot.IsBotAdmin),
+	}, sinks.Options(t.cfg.TomatoBot.Sinks)...)
+	t.notiPublisher = notifications.NewNotificationPublisher(tgbot, t.dbConn, publisherOpts...)
 
 	// Initialize the chat logger
 	t.chatLogger = NewDBChatLogger(t.dbConn, t.logger.With().Str("module", "chat_logger").Logger())
@@ -110,7 +130,8 @@ func (t *Tomatobot) Run(ctx context.Context) error {
 
 	botProxy, err := proxy.NewTGBotProxy(tgbot,
 		proxy.WithLogger(t.logger.With().Str("module", "proxy").Logger()),
-		proxy.WithSendToChatChannels(t.cfg.TomatoBot.SendProxiedResponsesToChannel))
+		proxy.WithSendToChatChannels(t.cfg.TomatoBot.SendProxiedResponsesToChannel),
+		proxy.WithConfig(t.cfg.TomatoBot))
 	if err != nil {
 		return fmt.Errorf("failed to create bot proxy: %w", err)
 	}
@@ -129,6 +150,19 @@ func (t *Tomatobot) Run(ctx context.Context) error {
 	}
 	defer util.CloseSafely(t.notiPublisher)
 
+	if t.cfg.HTTPAPI.Enabled {
+		apiServer, err := httpapi.NewServer(t.notiPublisher, t.cfg.HTTPAPI,
+			httpapi.WithLogger(t.logger.With().Str("module", "httpapi").Logger()))
+		if err != nil {
+			return fmt.Errorf("failed to create http api: %w", err)
+		}
+		go func() {
+			if err := apiServer.Run(ctx); err != nil {
+				t.logger.Error().Err(err).Msg("HTTP API stopped with error")
+			}
+		}()
+	}
+
 	for name, module := range t.loadedModules {
 		t.logger.Trace().Msgf("Starting module: %s", name)
 		err := module.Start(ctx)
@@ -230,6 +264,9 @@ func (t *Tomatobot) runMainLoop(ctx context.Context) error {
 
 func (t *Tomatobot) handleUpdate(ctx context.Context, update tgbotapi.Update) error {
 	t.logger.Trace().Msgf("Received update: %+v", update)
+	if update.CallbackQuery != nil {
+		return t.handleCallbackQuery(ctx, update.CallbackQuery)
+	}
 	if update.Message == nil {
 		return nil
 	}
@@ -321,6 +358,30 @@ func (t *Tomatobot) handleCommandThread(ctx context.Context, msg tgapi.TGBotMsg)
 	return cmdHandler.Execute(ctx, params)
 }
 
+// handleCallbackQuery passes an inline button press to the handler registered for its prefix, then answers it so the
+// client stops showing it as loading. Errors are shown to the user as an alert.
+func (t *Tomatobot) handleCallbackQuery(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	ctx, cancel := context.WithTimeout(ctx, t.cfg.TomatoBot.CommandTimeout)
+	defer cancel()
+
+	prefix, _, _ := strings.Cut(query.Data, ":")
+	answer := tgbotapi.NewCallback(query.ID, "")
+
+	handler, ok := t.callbackQueries[prefix]
+	if !ok {
+		t.logger.Warn().Msgf("No callback query handler for %s", prefix)
+	} else if err := handler(ctx, query); err != nil {
+		t.logger.Error().Err(err).Msgf("Failed to handle callback query %s", query.Data)
+		answer = tgbotapi.NewCallbackWithAlert(query.ID, fmt.Sprintf("Error: %s", err.Error()))
+	}
+
+	if _, err := t.tgbot.Request(answer); err != nil {
+		return fmt.Errorf("failed to answer callback query: %w", err)
+	}
+
+	return nil
+}
+
 func (t *Tomatobot) handleChatMessage(ctx context.Context, msg tgapi.TGBotMsg) error {
 	for name, handler := range t.chatCallbacks {
 		t.logger.Trace().Msgf("Running chat callback: %s", name)
@@ -471,6 +532,7 @@ func NewTomatobot(cfg config.Config, logger zerolog.Logger) *Tomatobot {
 		loadedModules:   make(map[string]modules.BotModule),
 		commandRegistry: make(map[string]command.TomatobotCommand),
 		chatCallbacks:   make(map[string]func(ctx context.Context, msg tgapi.TGBotMsg)),
+		callbackQueries: make(map[string]func(ctx context.Context, query *tgbotapi.CallbackQuery) error),
 		sudoers:         make(map[int64]sudoer),
 	}
 }
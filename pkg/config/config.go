@@ -43,6 +43,8 @@ type TomatoBot struct {
 	Database                      Database      `yaml:"database"`
 	Modules                       ModuleConfig  `yaml:"modules"`
 	Heartbeat                     Heartbeat     `yaml:"heartbeat"`
+	HTTPAPI                       HTTPAPI       `yaml:"http_api"`
+	Sinks                         Sinks         `yaml:"sinks" ignored:"true"` // envconfig would allocate the nil sink pointers
 }
 
 type Heartbeat struct {
@@ -51,6 +53,77 @@ type Heartbeat struct {
 	URL      string        `yaml:"url"`
 }
 
+// HTTPAPI is the inbound api external systems use to publish to topics
+type HTTPAPI struct {
+	Enabled bool       `yaml:"enabled" envconfig:"HTTP_API_ENABLED"`
+	Listen  string     `yaml:"listen" envconfig:"HTTP_API_LISTEN"`
+	Tokens  []APIToken `yaml:"tokens" validate:"dive"`
+	// RateLimit is the sustained requests per minute allowed for each token, Burst how many may arrive at once.
+	// Both default to the httpapi package defaults when zero.
+	RateLimit int      `yaml:"rate_limit"`
+	Burst     int      `yaml:"burst"`
+	Webhooks  Webhooks `yaml:"webhooks" ignored:"true"` // envconfig would allocate the nil webhook pointers
+}
+
+// Webhooks are the receivers for well known webhook payloads, each enabled by configuring it
+type Webhooks struct {
+	GitHub       *WebhookSource `yaml:"github"`
+	Alertmanager *WebhookSource `yaml:"alertmanager"`
+	Grafana      *WebhookSource `yaml:"grafana"`
+}
+
+type WebhookSource struct {
+	// Secret signs the payloads, or for alertmanager is sent as its bearer token
+	Secret string `yaml:"secret" validate:"required,min=16"`
+	// Template is the path of a Go template replacing the built in message template
+	Template string `yaml:"template"`
+}
+
+// Sinks are where subscriptions can deliver besides telegram, each enabled by configuring it
+type Sinks struct {
+	Webhook *WebhookSink     `yaml:"webhook"`
+	Slack   *ChatWebhookSink `yaml:"slack"`
+	Discord *ChatWebhookSink `yaml:"discord"`
+	SMTP    *SMTPSink        `yaml:"smtp"`
+}
+
+// SinkRetry overrides the notification retry policy for a sink. Zero values keep the publisher's defaults.
+type SinkRetry struct {
+	MaxAttempts int           `yaml:"max_attempts"`
+	BaseDelay   time.Duration `yaml:"base_delay"`
+	MaxDelay    time.Duration `yaml:"max_delay"`
+}
+
+// WebhookSink posts notifications as json to the subscription's url, signed with the secret
+type WebhookSink struct {
+	Secret  string        `yaml:"secret" validate:"required,min=16"`
+	Timeout time.Duration `yaml:"timeout"`
+	Retry   SinkRetry     `yaml:"retry"`
+}
+
+// ChatWebhookSink posts notifications to Slack or Discord incoming webhook urls
+type ChatWebhookSink struct {
+	Timeout time.Duration `yaml:"timeout"`
+	Retry   SinkRetry     `yaml:"retry"`
+}
+
+// SMTPSink emails notifications to the subscription's address
+type SMTPSink struct {
+	Host     string    `yaml:"host" validate:"required"`
+	Port     int       `yaml:"port"`
+	Username string    `yaml:"username"`
+	Password string    `yaml:"password"`
+	From     string    `yaml:"from" validate:"required,email"`
+	Retry    SinkRetry `yaml:"retry"`
+}
+
+// APIToken is a bearer token allowed to publish to topics starting with any of its prefixes, * allows every topic
+type APIToken struct {
+	Name          string   `yaml:"name" validate:"required"`
+	Token         string   `yaml:"token" validate:"required,min=16"`
+	TopicPrefixes []string `yaml:"topic_prefixes" validate:"required,min=1"`
+}
+
 func (t *TomatoBot) IsBotAdmin(id int64) bool {
 	return slices.Contains(t.BotAdminIds, id)
 }
@@ -66,8 +139,28 @@ type ModuleConfig struct {
 }
 
 type WeatherConfig struct {
-	APIKey          string        `yaml:"api_key" envconfig:"WEATHER_API_KEY" validate:"required"`
+	// Provider is where weather data comes from, owm (OpenWeatherMap, the default) or nws (api.weather.gov, US only)
+	Provider        string        `yaml:"provider" envconfig:"WEATHER_PROVIDER" validate:"omitempty,oneof=owm nws"`
+	APIKey          string        `yaml:"api_key" envconfig:"WEATHER_API_KEY" validate:"required_unless=Provider nws"`
 	PollingInterval time.Duration `yaml:"polling_interval" envconfig:"WEATHER_POLLING_INTERVAL" default:"5m"`
+	// ActivePollingInterval is how often locations with an active alert are polled, to catch updates sooner
+	ActivePollingInterval time.Duration `yaml:"active_polling_interval" envconfig:"WEATHER_ACTIVE_POLLING_INTERVAL" default:"1m"`
+	// PollConcurrency is how many locations are polled at once
+	PollConcurrency int `yaml:"poll_concurrency" envconfig:"WEATHER_POLL_CONCURRENCY" default:"4" validate:"gte=0"`
+	// MaxPollBackoff caps how long a location whose polls keep failing waits before it's polled again
+	MaxPollBackoff time.Duration `yaml:"max_poll_backoff" envconfig:"WEATHER_MAX_POLL_BACKOFF" default:"1h"`
+	// DailyCallLimit and MinuteCallLimit cap the OpenWeatherMap One Call requests, which are billed per call.
+	// Polling is stretched to stay within them. Zero means no limit.
+	DailyCallLimit  int `yaml:"daily_call_limit" envconfig:"WEATHER_DAILY_CALL_LIMIT" default:"1000" validate:"gte=0"`
+	MinuteCallLimit int `yaml:"minute_call_limit" envconfig:"WEATHER_MINUTE_CALL_LIMIT" default:"60" validate:"gte=0"`
+	// UserAgent identifies the bot to the NWS api, which asks for contact details such as "(tomatobot, me@example.com)"
+	UserAgent string `yaml:"user_agent" envconfig:"WEATHER_USER_AGENT" validate:"required_if=Provider nws"`
+	// AlertCategories maps alert events such as "Red Flag Warning" onto the warning, watch or advisory topic they're
+	// published to, overriding how they would be classified otherwise
+	AlertCategories map[string]string `yaml:"alert_categories" validate:"dive,oneof=warning watch advisory"`
+	// UnknownAlerts is what happens to alerts that can't be classified. advisory (the default) publishes them as
+	// advisories, unknown to the weather.<location>.unknown topic and drop discards them.
+	UnknownAlerts string `yaml:"unknown_alerts" envconfig:"WEATHER_UNKNOWN_ALERTS" validate:"omitempty,oneof=advisory unknown drop"`
 }
 
 func (c *Config) Validate() error {
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/require"
 	"testing"
 	"time"
@@ -75,3 +76,23 @@ func TestNewConfig_KeepsMissingEnvironmentVariables(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "postgres://${POSTGRES_USER}@postgres:5432/tomatobot?sslmode=disable", cfg.Database.ConnectionString)
 }
+
+func TestWeatherConfig_Validate_Provider(t *testing.T) {
+	validate := validator.New()
+
+	require.NoError(t, validate.Struct(WeatherConfig{APIKey: "12345"}))
+	require.Error(t, validate.Struct(WeatherConfig{}))
+	require.NoError(t, validate.Struct(WeatherConfig{Provider: "nws", UserAgent: "(tomatobot, me@example.com)"}))
+	require.Error(t, validate.Struct(WeatherConfig{Provider: "nws"}))
+	require.Error(t, validate.Struct(WeatherConfig{Provider: "darksky", APIKey: "12345"}))
+}
+
+func TestWeatherConfig_Validate_Alerts(t *testing.T) {
+	validate := validator.New()
+
+	require.NoError(t, validate.Struct(WeatherConfig{APIKey: "12345", UnknownAlerts: "drop",
+		AlertCategories: map[string]string{"Red Flag Warning": "watch"}}))
+	require.Error(t, validate.Struct(WeatherConfig{APIKey: "12345", UnknownAlerts: "ignore"}))
+	require.Error(t, validate.Struct(WeatherConfig{APIKey: "12345",
+		AlertCategories: map[string]string{"Red Flag Warning": "unknown"}}))
+}
@@ -0,0 +1,194 @@
+package httpapi
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed alertmanager.tmpl
+var alertmanagerTemplateStr string
+
+//go:embed grafana.tmpl
+var grafanaTemplateStr string
+
+var alertsTopicTemplate = notifications.TopicTemplate{
+	Template:    "alerts.{receiver}.{severity}",
+	Description: "Alertmanager and Grafana alerts, updated in place when resolved",
+	Params: []notifications.TopicParam{
+		{Name: "receiver", Description: "Receiver or contact point the alert was routed to", Example: "oncall"},
+		{Name: "severity", Description: "The alert's severity label", Example: "critical"},
+	},
+}
+
+// alertPayload is the webhook body sent by Alertmanager. Grafana sends the same shape with a few extra fields.
+type alertPayload struct {
+	Receiver string  `json:"receiver"`
+	Status   string  `json:"status"`
+	Alerts   []alert `json:"alerts"`
+}
+
+type alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+
+	// grafana only
+	SilenceURL   string `json:"silenceURL"`
+	DashboardURL string `json:"dashboardURL"`
+	ValueString  string `json:"valueString"`
+}
+
+type alertTemplateData struct {
+	Receiver string
+	Alert    alert
+}
+
+// alertWebhook translates alert notifications into alerts.<receiver>.<severity> topics. Each alert is an event, so
+// its resolution edits the message sent when it fired.
+type alertWebhook struct {
+	name   string
+	tmpl   *template.Template
+	verify func(r *http.Request, body []byte) bool
+}
+
+// newAlertmanagerWebhook receives Alertmanager notifications. Alertmanager can't sign its payloads, so the secret is
+// checked as the bearer token set in the receiver's http_config.
+func newAlertmanagerWebhook(cfg config.WebhookSource) (*alertWebhook, error) {
+	tmpl, err := loadWebhookTemplate("alertmanager", alertmanagerTemplateStr, cfg.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	return &alertWebhook{
+		name: "alertmanager",
+		tmpl: tmpl,
+		verify: func(r *http.Request, body []byte) bool {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			return ok && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Secret)) == 1
+		},
+	}, nil
+}
+
+// grafanaTimestampTolerance is how far a Grafana timestamp can be from now, older signed requests may be replays
+const grafanaTimestampTolerance = 5 * time.Minute
+
+// newGrafanaWebhook receives Grafana alerting notifications signed with the contact point's HMAC secret. When
+// Grafana sends a timestamp header the signature covers "<timestamp>:<body>", and the timestamp has to be within
+// a few minutes of now.
+func newGrafanaWebhook(cfg config.WebhookSource) (*alertWebhook, error) {
+	tmpl, err := loadWebhookTemplate("grafana", grafanaTemplateStr, cfg.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	return &alertWebhook{
+		name: "grafana",
+		tmpl: tmpl,
+		verify: func(r *http.Request, body []byte) bool {
+			signature := r.Header.Get("X-Grafana-Alerting-Signature")
+			if signature == "" {
+				return false
+			}
+
+			expected := hmacSHA256Hex(cfg.Secret, body)
+			if timestamp := r.Header.Get("X-Grafana-Alerting-Timestamp"); timestamp != "" {
+				if !grafanaTimestampFresh(timestamp, time.Now()) {
+					return false
+				}
+				expected = hmacSHA256Hex(cfg.Secret, []byte(timestamp+":"), body)
+			}
+
+			return hmac.Equal([]byte(signature), []byte(expected))
+		},
+	}, nil
+}
+
+// grafanaTimestampFresh checks the unix timestamp in seconds is within the tolerance of now
+func grafanaTimestampFresh(timestamp string, now time.Time) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	age := now.Sub(time.Unix(seconds, 0))
+	return age <= grafanaTimestampTolerance && age >= -grafanaTimestampTolerance
+}
+
+func alertPriority(severity string) notifications.Priority {
+	switch strings.ToLower(severity) {
+	case "critical", "page", "error":
+		return notifications.PriorityCritical
+	case "info", "informational", "none":
+		return notifications.PriorityInfo
+	default:
+		return notifications.PriorityWarning
+	}
+}
+
+func (a *alertWebhook) messages(body []byte) ([]notifications.Message, error) {
+	payload := alertPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	msgs := make([]notifications.Message, 0, len(payload.Alerts))
+	for _, alert := range payload.Alerts {
+		if alert.Fingerprint == "" {
+			return nil, fmt.Errorf("alert without a fingerprint")
+		}
+
+		text, err := renderWebhookTemplate(a.tmpl, alertTemplateData{Receiver: payload.Receiver, Alert: alert})
+		if err != nil {
+			return nil, err
+		}
+
+		severity := alert.Labels["severity"]
+		msgs = append(msgs, notifications.Message{
+			Topic: fmt.Sprintf("alerts.%s.%s", topicSegment(payload.Receiver), topicSegment(severity)),
+			Msg:   text,
+			// alertmanager resends firing alerts every repeat interval, only the first is worth a message
+			DupeKey: fmt.Sprintf("%s-%s-%s-%d", a.name, alert.Fingerprint, alert.Status, alert.StartsAt.Unix()),
+			// resolving keeps the firing priority so it reaches every chat that saw the alert fire
+			Priority:           alertPriority(severity),
+			ParseMode:          tgbotapi.ModeHTML,
+			DisableLinkPreview: true,
+			EventID:            fmt.Sprintf("%s-%s-%d", a.name, alert.Fingerprint, alert.StartsAt.Unix()),
+		})
+	}
+
+	return msgs, nil
+}
+
+// handleAlertWebhook publishes each alert in an Alertmanager or Grafana notification
+// POST /api/v1/webhooks/alertmanager
+// POST /api/v1/webhooks/grafana
+func (s *Server) handleAlertWebhook(w http.ResponseWriter, r *http.Request, webhook *alertWebhook) {
+	body, ok := s.readWebhook(w, r, webhook.name, webhook.verify)
+	if !ok {
+		return
+	}
+
+	msgs, err := webhook.messages(body)
+	if err != nil {
+		s.logger.Error().Err(err).Msgf("failed to translate %s notification", webhook.name)
+		s.writeError(w, http.StatusBadRequest, "invalid payload: %s", err)
+		return
+	}
+
+	s.publishWebhookMessages(w, r, webhook.name, msgs)
+}
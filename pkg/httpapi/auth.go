@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"net/http"
+	"strings"
+)
+
+// apiToken is a configured token and the topic prefixes it may publish to
+type apiToken struct {
+	name          string
+	token         []byte
+	topicPrefixes []string
+}
+
+func newAPITokens(cfgTokens []config.APIToken) []apiToken {
+	tokens := make([]apiToken, 0, len(cfgTokens))
+	for _, cfgToken := range cfgTokens {
+		tokens = append(tokens, apiToken{
+			name:          cfgToken.Name,
+			token:         []byte(cfgToken.Token),
+			topicPrefixes: cfgToken.TopicPrefixes,
+		})
+	}
+
+	return tokens
+}
+
+// canPublish reports whether the token's scopes cover the topic. A prefix of * allows every topic.
+func (a apiToken) canPublish(topic string) bool {
+	for _, prefix := range a.topicPrefixes {
+		if prefix == "*" || strings.HasPrefix(topic, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authenticate finds the token sent as a bearer token in the request
+func (s *Server) authenticate(r *http.Request) (apiToken, bool) {
+	presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || presented == "" {
+		return apiToken{}, false
+	}
+
+	// every token is compared so the time taken doesn't reveal which one nearly matched
+	found := apiToken{}
+	matched := false
+	for _, token := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(presented), token.token) == 1 {
+			found = token
+			matched = true
+		}
+	}
+
+	return found, matched
+}
@@ -0,0 +1,128 @@
+package httpapi
+
+import (
+	"crypto/hmac"
+	_ "embed"
+	"encoding/json"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+//go:embed github.tmpl
+var githubTemplateStr string
+
+var githubTopicTemplate = notifications.TopicTemplate{
+	Template:    "github.{repo}.{event}",
+	Description: "GitHub webhook events",
+	Params: []notifications.TopicParam{
+		{Name: "repo", Description: "Repository name, lowercase", Example: "tomatobot"},
+		{Name: "event", Description: "GitHub event, e.g. push, pull_request or workflow_run", Example: "push"},
+	},
+}
+
+// githubWebhook translates GitHub webhook events into github.<repo>.<event> topics
+type githubWebhook struct {
+	secret string
+	tmpl   *template.Template
+}
+
+// githubTemplateData is what the github template renders. Payload is the event payload as decoded json.
+type githubTemplateData struct {
+	Event   string
+	Action  string
+	Repo    string
+	Sender  string
+	Payload map[string]any
+}
+
+type githubPayload struct {
+	Action     string `json:"action"`
+	Repository struct {
+		Name     string `json:"name"`
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+func newGitHubWebhook(cfg config.WebhookSource) (*githubWebhook, error) {
+	tmpl, err := loadWebhookTemplate("github", githubTemplateStr, cfg.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	return &githubWebhook{secret: cfg.Secret, tmpl: tmpl}, nil
+}
+
+// verify checks the X-Hub-Signature-256 header is the HMAC of the body with the webhook secret
+func (g *githubWebhook) verify(r *http.Request, body []byte) bool {
+	signature, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(hmacSHA256Hex(g.secret, body)))
+}
+
+func (g *githubWebhook) message(event string, deliveryId string, body []byte) (notifications.Message, error) {
+	payload := githubPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return notifications.Message{}, err
+	}
+	data := githubTemplateData{
+		Event:  event,
+		Action: payload.Action,
+		Repo:   payload.Repository.FullName,
+		Sender: payload.Sender.Login,
+	}
+	if err := json.Unmarshal(body, &data.Payload); err != nil {
+		return notifications.Message{}, err
+	}
+
+	text, err := renderWebhookTemplate(g.tmpl, data)
+	if err != nil {
+		return notifications.Message{}, err
+	}
+
+	return notifications.Message{
+		Topic:              "github." + topicSegment(payload.Repository.Name) + "." + topicSegment(event),
+		Msg:                text,
+		DupeKey:            deliveryId,
+		Priority:           notifications.PriorityInfo,
+		ParseMode:          tgbotapi.ModeHTML,
+		DisableLinkPreview: true,
+	}, nil
+}
+
+// handleGitHubWebhook publishes a GitHub webhook event
+// POST /api/v1/webhooks/github
+func (s *Server) handleGitHubWebhook(w http.ResponseWriter, r *http.Request, webhook *githubWebhook) {
+	body, ok := s.readWebhook(w, r, "github", webhook.verify)
+	if !ok {
+		return
+	}
+
+	event := r.Header.Get("X-GitHub-Event")
+	switch event {
+	case "":
+		s.writeError(w, http.StatusBadRequest, "missing X-GitHub-Event header")
+		return
+	case "ping":
+		s.writeJSON(w, http.StatusOK, publishResponse{Status: "pong"})
+		return
+	}
+
+	msg, err := webhook.message(event, r.Header.Get("X-GitHub-Delivery"), body)
+	if err != nil {
+		s.logger.Error().Err(err).Msgf("failed to translate github %s event", event)
+		s.writeError(w, http.StatusBadRequest, "invalid payload: %s", err)
+		return
+	}
+
+	s.publishWebhookMessages(w, r, "github", []notifications.Message{msg})
+}
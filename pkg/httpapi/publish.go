@@ -0,0 +1,123 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+const (
+	maxPublishBodySize = 64 * 1024
+	maxTopicLength     = 256
+	maxDupeTTL         = 7 * 24 * time.Hour
+)
+
+// publishTopicRegex matches concrete topics, dot separated words without wildcards
+var publishTopicRegex = regexp.MustCompile(`^[\w-]+(\.[\w-]+)*$`)
+
+// publishRequest is the json body of POST /api/v1/publish
+type publishRequest struct {
+	Topic string `json:"topic"`
+	Text  string `json:"text"`
+	// Priority is debug, info, warning or critical, defaults to info
+	Priority string `json:"priority"`
+	// DedupeKey suppresses repeats of the same key on the topic for DedupeTTL, e.g. 30m or 1d
+	DedupeKey string `json:"dedupe_key"`
+	DedupeTTL string `json:"dedupe_ttl"`
+}
+
+type publishResponse struct {
+	Status string `json:"status"`
+}
+
+// message validates the request and converts it to the message to publish
+func (p publishRequest) message() (notifications.Message, error) {
+	msg := notifications.Message{
+		Topic:    p.Topic,
+		Msg:      p.Text,
+		DupeKey:  p.DedupeKey,
+		Priority: notifications.PriorityInfo,
+	}
+
+	if len(p.Topic) > maxTopicLength || !publishTopicRegex.MatchString(p.Topic) {
+		return msg, fmt.Errorf("topic must be dot separated words, e.g. ci.builds.failed")
+	}
+
+	if p.Text == "" {
+		return msg, fmt.Errorf("text is required")
+	}
+	if len([]rune(p.Text)) > util.TelegramMaxMessageLength {
+		return msg, fmt.Errorf("text is longer than %d characters", util.TelegramMaxMessageLength)
+	}
+
+	if p.Priority != "" {
+		priority, err := notifications.ParsePriority(p.Priority)
+		if err != nil {
+			return msg, err
+		}
+		msg.Priority = priority
+	}
+
+	if p.DedupeTTL != "" {
+		ttl, err := util.ParseDuration(p.DedupeTTL)
+		if err != nil || ttl <= 0 || ttl > maxDupeTTL {
+			return msg, fmt.Errorf("dedupe_ttl must be a duration up to %s, e.g. 30m", maxDupeTTL)
+		}
+		msg.DupeTTL = ttl
+	}
+
+	return msg, msg.Validate()
+}
+
+// handlePublish publishes the message in the request body to its topic
+// POST /api/v1/publish
+func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		s.writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+
+	token, ok := s.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		s.writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+		return
+	}
+
+	if allowed, wait := s.limiter.allow(token.name); !allowed {
+		s.writeRateLimited(w, wait)
+		return
+	}
+
+	req := publishRequest{}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPublishBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid request body: %s", err)
+		return
+	}
+
+	msg, err := req.message()
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid request: %s", err)
+		return
+	}
+
+	if !token.canPublish(msg.Topic) {
+		s.writeError(w, http.StatusForbidden, "token %s may not publish to %s", token.name, msg.Topic)
+		return
+	}
+
+	if err := s.publisher.Publish(r.Context(), msg); err != nil {
+		s.writePublishError(w, err, msg.Topic, "token "+token.name)
+		return
+	}
+
+	s.logger.Debug().Msgf("Token %s published to %s", token.name, msg.Topic)
+	s.writeJSON(w, http.StatusAccepted, publishResponse{Status: "queued"})
+}
@@ -0,0 +1,127 @@
+package httpapi
+
+import (
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testToken = "0123456789abcdef"
+
+func newTestServer(t *testing.T, publisher *notifications.MockPublisher) *Server {
+	t.Helper()
+
+	publisher.EXPECT().RegisterTopicPrefix("ci.").Return(nil).Once()
+	server, err := NewServer(publisher, config.HTTPAPI{
+		Tokens: []config.APIToken{{Name: "ci", Token: testToken, TopicPrefixes: []string{"ci."}}},
+		Burst:  2,
+	})
+	require.NoError(t, err)
+
+	return server
+}
+
+func doPublish(server *Server, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/publish", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_handlePublish(t *testing.T) {
+	publisher := notifications.NewMockPublisher(t)
+	publisher.EXPECT().Publish(mock.Anything, notifications.Message{
+		Topic:    "ci.builds.failed",
+		Msg:      "main is red",
+		DupeKey:  "build-42",
+		DupeTTL:  30 * time.Minute,
+		Priority: notifications.PriorityWarning,
+	}).Return(nil).Once()
+
+	server := newTestServer(t, publisher)
+	rec := doPublish(server, testToken,
+		`{"topic":"ci.builds.failed","text":"main is red","priority":"warning","dedupe_key":"build-42","dedupe_ttl":"30m"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.JSONEq(t, `{"status":"queued"}`, rec.Body.String())
+}
+
+func TestServer_handlePublish_Rejected(t *testing.T) {
+	server := newTestServer(t, notifications.NewMockPublisher(t))
+	server.limiter = newRateLimiter(60, 100)
+
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{"no token", "", `{"topic":"ci.builds","text":"hi"}`, http.StatusUnauthorized},
+		{"wrong token", "fedcba9876543210", `{"topic":"ci.builds","text":"hi"}`, http.StatusUnauthorized},
+		{"out of scope", testToken, `{"topic":"home.door","text":"hi"}`, http.StatusForbidden},
+		{"wildcard topic", testToken, `{"topic":"ci.*","text":"hi"}`, http.StatusBadRequest},
+		{"no text", testToken, `{"topic":"ci.builds"}`, http.StatusBadRequest},
+		{"bad priority", testToken, `{"topic":"ci.builds","text":"hi","priority":"loud"}`, http.StatusBadRequest},
+		{"bad ttl", testToken, `{"topic":"ci.builds","text":"hi","dedupe_ttl":"forever"}`, http.StatusBadRequest},
+		{"unknown field", testToken, `{"topic":"ci.builds","text":"hi","chat_id":1}`, http.StatusBadRequest},
+		{"not json", testToken, `topic=ci.builds`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doPublish(server, tt.token, tt.body)
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestServer_handlePublish_RateLimited(t *testing.T) {
+	publisher := notifications.NewMockPublisher(t)
+	publisher.EXPECT().Publish(mock.Anything, mock.Anything).Return(nil).Twice()
+
+	server := newTestServer(t, publisher)
+	now := time.Now()
+	server.limiter.now = func() time.Time { return now }
+
+	body := `{"topic":"ci.builds","text":"hi"}`
+	require.Equal(t, http.StatusAccepted, doPublish(server, testToken, body).Code)
+	require.Equal(t, http.StatusAccepted, doPublish(server, testToken, body).Code)
+
+	rec := doPublish(server, testToken, body)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+}
+
+func TestServer_handlePublish_BusFull(t *testing.T) {
+	publisher := notifications.NewMockPublisher(t)
+	publisher.EXPECT().Publish(mock.Anything, mock.Anything).Return(notifications.ErrBusFull).Once()
+
+	rec := doPublish(newTestServer(t, publisher), testToken, `{"topic":"ci.builds","text":"hi"}`)
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(60, 2, now)
+
+	allowed, _ := bucket.take(now)
+	require.True(t, allowed)
+	allowed, _ = bucket.take(now)
+	require.True(t, allowed)
+
+	allowed, wait := bucket.take(now)
+	require.False(t, allowed)
+	require.Equal(t, time.Second, wait)
+
+	allowed, _ = bucket.take(now.Add(time.Second))
+	require.True(t, allowed)
+}
@@ -0,0 +1,74 @@
+package httpapi
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucket allows bursts of up to burst requests, refilling at rate requests per second
+type tokenBucket struct {
+	lck     sync.Mutex
+	rate    float64
+	burst   float64
+	tokens  float64
+	updated time.Time
+}
+
+func newTokenBucket(perMinute int, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		updated: now,
+	}
+}
+
+// take removes a token if one is available. Otherwise returns false and how long until one is.
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.lck.Lock()
+	defer b.lck.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.updated).Seconds()*b.rate)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// rateLimiter keeps a token bucket per api token
+type rateLimiter struct {
+	lck       sync.Mutex
+	perMinute int
+	burst     int
+	buckets   map[string]*tokenBucket
+	now       func() time.Time
+}
+
+func newRateLimiter(perMinute int, burst int) *rateLimiter {
+	return &rateLimiter{
+		perMinute: perMinute,
+		burst:     burst,
+		buckets:   make(map[string]*tokenBucket),
+		now:       time.Now,
+	}
+}
+
+func (r *rateLimiter) allow(key string) (bool, time.Duration) {
+	now := r.now()
+
+	r.lck.Lock()
+	bucket, ok := r.buckets[key]
+	if !ok {
+		bucket = newTokenBucket(r.perMinute, r.burst, now)
+		r.buckets[key] = bucket
+	}
+	r.lck.Unlock()
+
+	return bucket.take(now)
+}
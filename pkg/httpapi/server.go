@@ -0,0 +1,156 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultListen     = ":8080"
+	defaultRateLimit  = 60
+	defaultBurst      = 10
+	shutdownTimeout   = 10 * time.Second
+	readHeaderTimeout = 10 * time.Second
+)
+
+// Server is the inbound http api external systems use to publish to topics
+type Server struct {
+	publisher notifications.Publisher
+	tokens    []apiToken
+	limiter   *rateLimiter
+	listen    string
+	logger    zerolog.Logger
+
+	mux *http.ServeMux
+}
+
+type ServerOption func(s *Server)
+
+func WithLogger(logger zerolog.Logger) ServerOption {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+func NewServer(publisher notifications.Publisher, cfg config.HTTPAPI, options ...ServerOption) (*Server, error) {
+	server := &Server{
+		publisher: publisher,
+		tokens:    newAPITokens(cfg.Tokens),
+		limiter: newRateLimiter(
+			positiveOr(cfg.RateLimit, defaultRateLimit),
+			positiveOr(cfg.Burst, defaultBurst)),
+		listen: cfg.Listen,
+		logger: zerolog.Nop(),
+		mux:    http.NewServeMux(),
+	}
+	if server.listen == "" {
+		server.listen = defaultListen
+	}
+
+	for _, option := range options {
+		option(server)
+	}
+
+	// the tokens' topics aren't known until something is published, so chats can subscribe to them up front
+	for _, token := range server.tokens {
+		for _, prefix := range token.topicPrefixes {
+			if err := publisher.RegisterTopicPrefix(prefix); err != nil {
+				return nil, fmt.Errorf("failed to register topic prefix of token %s: %w", token.name, err)
+			}
+		}
+	}
+
+	server.mux.HandleFunc("/api/v1/publish", server.handlePublish)
+	if err := server.registerWebhooks(cfg.Webhooks); err != nil {
+		return nil, fmt.Errorf("failed to register webhooks: %w", err)
+	}
+
+	return server, nil
+}
+
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Run serves the api until the context is done, then shuts down gracefully
+func (s *Server) Run(ctx context.Context) error {
+	httpServer := &http.Server{
+		Addr:              s.listen,
+		Handler:           s.mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Info().Msgf("HTTP API listening on %s", s.listen)
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("http api stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cf := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cf()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down http api: %w", err)
+	}
+
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("http api stopped: %w", err)
+	}
+
+	return nil
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.logger.Error().Err(err).Msg("failed to write response")
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, format string, args ...any) {
+	s.writeJSON(w, status, errorResponse{Error: fmt.Sprintf(format, args...)})
+}
+
+func (s *Server) writeRateLimited(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	s.writeError(w, http.StatusTooManyRequests, "rate limit exceeded, retry in %s", wait.Round(time.Second))
+}
+
+// writePublishError responds to a failed Publish, asking the caller to retry if the publisher is overloaded
+func (s *Server) writePublishError(w http.ResponseWriter, err error, topic string, caller string) {
+	if errors.Is(err, notifications.ErrBusFull) || errors.Is(err, notifications.ErrPublisherClosed) {
+		w.Header().Set("Retry-After", "5")
+		s.writeError(w, http.StatusServiceUnavailable, "%s", err)
+		return
+	}
+
+	s.logger.Error().Err(err).Msgf("failed to publish to %s for %s", topic, caller)
+	s.writeError(w, http.StatusInternalServerError, "failed to publish")
+}
+
+func positiveOr(value int, fallback int) int {
+	if value > 0 {
+		return value
+	}
+
+	return fallback
+}
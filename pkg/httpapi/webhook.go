@@ -0,0 +1,196 @@
+package httpapi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxWebhookBodySize = 1024 * 1024
+	// webhookTruncatedSuffix ends messages cut to fit in a telegram message
+	webhookTruncatedSuffix = "\n…"
+)
+
+var topicSegmentRegex = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+var webhookFuncMap = template.FuncMap{
+	"escape": html.EscapeString,
+	"upper":  strings.ToUpper,
+	"firstLine": func(s string) string {
+		line, _, _ := strings.Cut(s, "\n")
+		return line
+	},
+	"branch": func(ref string) string {
+		return strings.TrimPrefix(ref, "refs/heads/")
+	},
+	"shortSha": func(sha string) string {
+		if len(sha) > 7 {
+			return sha[:7]
+		}
+		return sha
+	},
+	"formatTime": func(t time.Time) string {
+		return t.Format(time.RFC1123)
+	},
+}
+
+// loadWebhookTemplate parses the template file configured for the webhook, or the built in one if there is none
+func loadWebhookTemplate(name string, builtIn string, path string) (*template.Template, error) {
+	tmplStr := builtIn
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s template: %w", name, err)
+		}
+		tmplStr = string(data)
+	}
+
+	tmpl, err := template.New(name).Funcs(webhookFuncMap).Parse(tmplStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+
+	return tmpl, nil
+}
+
+// renderWebhookTemplate renders the template, cutting the message to fit in a telegram message. The cut is made at
+// a line break where there is one, so the HTML tags the templates open and close on a line stay balanced.
+func renderWebhookTemplate(tmpl *template.Template, data any) (string, error) {
+	outBuf := bytes.Buffer{}
+	if err := tmpl.Execute(&outBuf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", tmpl.Name(), err)
+	}
+
+	text := strings.TrimSpace(outBuf.String())
+	if utf8.RuneCountInString(text) > util.TelegramMaxMessageLength {
+		limit := util.TelegramMaxMessageLength - utf8.RuneCountInString(webhookTruncatedSuffix)
+		text = strings.TrimSpace(util.SplitMessage(text, limit)[0]) + webhookTruncatedSuffix
+	}
+
+	return text, nil
+}
+
+// topicSegment turns a name from a payload into something usable between the dots of a topic
+func topicSegment(name string) string {
+	segment := strings.Trim(topicSegmentRegex.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if segment == "" {
+		return "unknown"
+	}
+
+	return segment
+}
+
+// hmacSHA256Hex is the hex encoded HMAC-SHA256 of the data
+func hmacSHA256Hex(secret string, data ...[]byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, d := range data {
+		mac.Write(d)
+	}
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// readWebhook checks the method, size, signature and rate limit of a webhook request and returns its body. Requests
+// that fail verification are limited separately, so they can't use up the webhook's own budget. It writes the error
+// response itself and returns false if the request should not be handled further.
+func (s *Server) readWebhook(w http.ResponseWriter, r *http.Request, name string, verify func(r *http.Request, body []byte) bool) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		s.writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "failed to read body: %s", err)
+		return nil, false
+	}
+
+	if !verify(r, body) {
+		if allowed, wait := s.limiter.allow("webhook-unverified:" + name); !allowed {
+			s.writeRateLimited(w, wait)
+			return nil, false
+		}
+		s.writeError(w, http.StatusUnauthorized, "invalid signature")
+		return nil, false
+	}
+
+	if allowed, wait := s.limiter.allow("webhook:" + name); !allowed {
+		s.writeRateLimited(w, wait)
+		return nil, false
+	}
+
+	return body, true
+}
+
+// publishWebhookMessages publishes the messages a webhook translated its payload into
+func (s *Server) publishWebhookMessages(w http.ResponseWriter, r *http.Request, name string, msgs []notifications.Message) {
+	for _, msg := range msgs {
+		if err := s.publisher.Publish(r.Context(), msg); err != nil {
+			s.writePublishError(w, err, msg.Topic, name)
+			return
+		}
+	}
+
+	s.logger.Debug().Msgf("Webhook %s published %d message(s)", name, len(msgs))
+	s.writeJSON(w, http.StatusAccepted, publishResponse{Status: "queued"})
+}
+
+// registerWebhooks adds the handlers and catalog topics of the configured webhooks
+func (s *Server) registerWebhooks(cfg config.Webhooks) error {
+	if cfg.GitHub != nil {
+		handler, err := newGitHubWebhook(*cfg.GitHub)
+		if err != nil {
+			return err
+		}
+		if err := s.publisher.RegisterTopic(githubTopicTemplate); err != nil {
+			return fmt.Errorf("failed to register github topic: %w", err)
+		}
+		s.mux.HandleFunc("/api/v1/webhooks/github", func(w http.ResponseWriter, r *http.Request) {
+			s.handleGitHubWebhook(w, r, handler)
+		})
+	}
+
+	if cfg.Alertmanager != nil {
+		handler, err := newAlertmanagerWebhook(*cfg.Alertmanager)
+		if err != nil {
+			return err
+		}
+		if err := s.publisher.RegisterTopic(alertsTopicTemplate); err != nil {
+			return fmt.Errorf("failed to register alerts topic: %w", err)
+		}
+		s.mux.HandleFunc("/api/v1/webhooks/alertmanager", func(w http.ResponseWriter, r *http.Request) {
+			s.handleAlertWebhook(w, r, handler)
+		})
+	}
+
+	if cfg.Grafana != nil {
+		handler, err := newGrafanaWebhook(*cfg.Grafana)
+		if err != nil {
+			return err
+		}
+		if err := s.publisher.RegisterTopic(alertsTopicTemplate); err != nil {
+			return fmt.Errorf("failed to register alerts topic: %w", err)
+		}
+		s.mux.HandleFunc("/api/v1/webhooks/grafana", func(w http.ResponseWriter, r *http.Request) {
+			s.handleAlertWebhook(w, r, handler)
+		})
+	}
+
+	return nil
+}
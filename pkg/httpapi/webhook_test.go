@@ -0,0 +1,250 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWebhookSecret = "webhook-secret-0123"
+
+func newWebhookServer(t *testing.T, publisher *notifications.MockPublisher, webhooks config.Webhooks) *Server {
+	t.Helper()
+
+	publisher.EXPECT().RegisterTopic(mock.Anything).Return(nil).Maybe()
+	server, err := NewServer(publisher, config.HTTPAPI{Webhooks: webhooks})
+	require.NoError(t, err)
+
+	return server
+}
+
+func doWebhook(server *Server, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+const githubPushBody = `{
+  "ref": "refs/heads/main",
+  "repository": {"name": "TomatoBot", "full_name": "tomato3017/TomatoBot"},
+  "sender": {"login": "octocat"},
+  "commits": [{"id": "0123456789abcdef", "url": "https://github.com/c/1", "message": "Fix <b> tags\n\nlong body"}]
+}`
+
+func TestServer_handleGitHubWebhook(t *testing.T) {
+	publisher := notifications.NewMockPublisher(t)
+	publisher.EXPECT().Publish(mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return msg.Topic == "github.tomatobot.push" &&
+			msg.DupeKey == "delivery-1" &&
+			strings.Contains(msg.Msg, "octocat pushed 1 commit(s) to main") &&
+			strings.Contains(msg.Msg, "0123456</a> Fix &lt;b&gt; tags")
+	})).Return(nil).Once()
+
+	server := newWebhookServer(t, publisher, config.Webhooks{GitHub: &config.WebhookSource{Secret: testWebhookSecret}})
+	headers := map[string]string{
+		"X-GitHub-Event":      "push",
+		"X-GitHub-Delivery":   "delivery-1",
+		"X-Hub-Signature-256": "sha256=" + hmacSHA256Hex(testWebhookSecret, []byte(githubPushBody)),
+	}
+	rec := doWebhook(server, "/api/v1/webhooks/github", githubPushBody, headers)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	headers["X-Hub-Signature-256"] = "sha256=" + hmacSHA256Hex("wrong-secret", []byte(githubPushBody))
+	rec = doWebhook(server, "/api/v1/webhooks/github", githubPushBody, headers)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	pingBody := `{"zen": "Keep it logically awesome."}`
+	rec = doWebhook(server, "/api/v1/webhooks/github", pingBody, map[string]string{
+		"X-GitHub-Event":      "ping",
+		"X-Hub-Signature-256": "sha256=" + hmacSHA256Hex(testWebhookSecret, []byte(pingBody)),
+	})
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestServer_handleGitHubWebhook_CustomTemplate(t *testing.T) {
+	tmplPath := filepath.Join(t.TempDir(), "github.tmpl")
+	require.NoError(t, os.WriteFile(tmplPath, []byte(`{{.Repo}} got a {{.Event}}`), 0o600))
+
+	publisher := notifications.NewMockPublisher(t)
+	publisher.EXPECT().Publish(mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return msg.Msg == "tomato3017/TomatoBot got a push"
+	})).Return(nil).Once()
+
+	server := newWebhookServer(t, publisher, config.Webhooks{
+		GitHub: &config.WebhookSource{Secret: testWebhookSecret, Template: tmplPath},
+	})
+	rec := doWebhook(server, "/api/v1/webhooks/github", githubPushBody, map[string]string{
+		"X-GitHub-Event":      "push",
+		"X-Hub-Signature-256": "sha256=" + hmacSHA256Hex(testWebhookSecret, []byte(githubPushBody)),
+	})
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+}
+
+func TestServer_handleGitHubWebhook_Truncated(t *testing.T) {
+	tmplPath := filepath.Join(t.TempDir(), "github.tmpl")
+	require.NoError(t, os.WriteFile(tmplPath, []byte(`{{range $i := .Payload.lines}}<b>line {{$i}}</b>
+{{end}}`), 0o600))
+	body := fmt.Sprintf(`{"repository": {"name": "TomatoBot"}, "lines": [%s1]}`, strings.Repeat("1,", 1000))
+
+	publisher := notifications.NewMockPublisher(t)
+	publisher.EXPECT().Publish(mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return len(msg.Msg) <= util.TelegramMaxMessageLength &&
+			strings.HasSuffix(msg.Msg, "</b>\n…") &&
+			strings.Count(msg.Msg, "<b>") == strings.Count(msg.Msg, "</b>")
+	})).Return(nil).Once()
+
+	server := newWebhookServer(t, publisher, config.Webhooks{
+		GitHub: &config.WebhookSource{Secret: testWebhookSecret, Template: tmplPath},
+	})
+	rec := doWebhook(server, "/api/v1/webhooks/github", body, map[string]string{
+		"X-GitHub-Event":      "push",
+		"X-Hub-Signature-256": "sha256=" + hmacSHA256Hex(testWebhookSecret, []byte(body)),
+	})
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+}
+
+func TestServer_handleGitHubWebhook_UnverifiedRateLimit(t *testing.T) {
+	publisher := notifications.NewMockPublisher(t)
+	publisher.EXPECT().Publish(mock.Anything, mock.Anything).Return(nil).Once()
+	publisher.EXPECT().RegisterTopic(mock.Anything).Return(nil).Once()
+
+	server, err := NewServer(publisher, config.HTTPAPI{
+		Webhooks: config.Webhooks{GitHub: &config.WebhookSource{Secret: testWebhookSecret}},
+		Burst:    1,
+	})
+	require.NoError(t, err)
+	forged := map[string]string{
+		"X-GitHub-Event":      "push",
+		"X-Hub-Signature-256": "sha256=" + hmacSHA256Hex("wrong-secret", []byte(githubPushBody)),
+	}
+
+	// forged requests run out their own budget, not the one GitHub's requests are held to
+	require.Equal(t, http.StatusUnauthorized, doWebhook(server, "/api/v1/webhooks/github", githubPushBody, forged).Code)
+	require.Equal(t, http.StatusTooManyRequests, doWebhook(server, "/api/v1/webhooks/github", githubPushBody, forged).Code)
+
+	rec := doWebhook(server, "/api/v1/webhooks/github", githubPushBody, map[string]string{
+		"X-GitHub-Event":      "push",
+		"X-Hub-Signature-256": "sha256=" + hmacSHA256Hex(testWebhookSecret, []byte(githubPushBody)),
+	})
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+}
+
+const alertmanagerBody = `{
+  "receiver": "On-Call",
+  "status": "%s",
+  "alerts": [{
+    "status": "%s",
+    "labels": {"alertname": "DiskFull", "severity": "critical"},
+    "annotations": {"summary": "Disk is 99%% full"},
+    "startsAt": "2024-05-01T10:00:00Z",
+    "endsAt": "2024-05-01T11:00:00Z",
+    "fingerprint": "abc123"
+  }]
+}`
+
+func TestServer_handleAlertWebhook_Alertmanager(t *testing.T) {
+	published := make([]notifications.Message, 0)
+	publisher := notifications.NewMockPublisher(t)
+	publisher.EXPECT().Publish(mock.Anything, mock.Anything).RunAndReturn(
+		func(_ context.Context, msg notifications.Message) error {
+			published = append(published, msg)
+			return nil
+		}).Twice()
+
+	server := newWebhookServer(t, publisher, config.Webhooks{Alertmanager: &config.WebhookSource{Secret: testWebhookSecret}})
+	auth := map[string]string{"Authorization": "Bearer " + testWebhookSecret}
+
+	rec := doWebhook(server, "/api/v1/webhooks/alertmanager", fmt.Sprintf(alertmanagerBody, "firing", "firing"), auth)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	rec = doWebhook(server, "/api/v1/webhooks/alertmanager", fmt.Sprintf(alertmanagerBody, "resolved", "resolved"), auth)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	rec = doWebhook(server, "/api/v1/webhooks/alertmanager", fmt.Sprintf(alertmanagerBody, "firing", "firing"), nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	require.Len(t, published, 2)
+	firing, resolved := published[0], published[1]
+	require.Equal(t, "alerts.on-call.critical", firing.Topic)
+	require.Equal(t, notifications.PriorityCritical, firing.Priority)
+	require.Contains(t, firing.Msg, "<b>CRITICAL</b>: DiskFull")
+	require.Contains(t, resolved.Msg, "Resolved")
+
+	// the resolution edits the firing message, but isn't deduplicated against it
+	require.Equal(t, firing.EventID, resolved.EventID)
+	require.NotEqual(t, firing.DupeKey, resolved.DupeKey)
+	require.Equal(t, firing.Priority, resolved.Priority)
+}
+
+func TestServer_handleAlertWebhook_Grafana(t *testing.T) {
+	publisher := notifications.NewMockPublisher(t)
+	publisher.EXPECT().Publish(mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return msg.Topic == "alerts.on-call.critical" && strings.HasPrefix(msg.EventID, "grafana-")
+	})).Return(nil).Once()
+
+	server := newWebhookServer(t, publisher, config.Webhooks{Grafana: &config.WebhookSource{Secret: testWebhookSecret}})
+	body := fmt.Sprintf(alertmanagerBody, "firing", "firing")
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	rec := doWebhook(server, "/api/v1/webhooks/grafana", body, map[string]string{
+		"X-Grafana-Alerting-Timestamp": timestamp,
+		"X-Grafana-Alerting-Signature": hmacSHA256Hex(testWebhookSecret, []byte(timestamp+":"), []byte(body)),
+	})
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	rec = doWebhook(server, "/api/v1/webhooks/grafana", body, map[string]string{
+		"X-Grafana-Alerting-Signature": hmacSHA256Hex(testWebhookSecret, []byte(timestamp+":"), []byte(body)),
+	})
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestServer_handleAlertWebhook_Grafana_StaleTimestamp(t *testing.T) {
+	server := newWebhookServer(t, notifications.NewMockPublisher(t), config.Webhooks{Grafana: &config.WebhookSource{Secret: testWebhookSecret}})
+	body := fmt.Sprintf(alertmanagerBody, "firing", "firing")
+
+	// a correctly signed request captured an hour ago is a replay
+	timestamp := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	rec := doWebhook(server, "/api/v1/webhooks/grafana", body, map[string]string{
+		"X-Grafana-Alerting-Timestamp": timestamp,
+		"X-Grafana-Alerting-Signature": hmacSHA256Hex(testWebhookSecret, []byte(timestamp+":"), []byte(body)),
+	})
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestGrafanaTimestampFresh(t *testing.T) {
+	now := time.Unix(1714557600, 0)
+	require.True(t, grafanaTimestampFresh("1714557600", now))
+	require.True(t, grafanaTimestampFresh("1714557400", now))
+	require.False(t, grafanaTimestampFresh("1714557000", now))
+	require.False(t, grafanaTimestampFresh("1714558200", now))
+	require.False(t, grafanaTimestampFresh("not a timestamp", now))
+}
+
+func TestServer_Webhooks_NotConfigured(t *testing.T) {
+	server := newWebhookServer(t, notifications.NewMockPublisher(t), config.Webhooks{})
+
+	rec := doWebhook(server, "/api/v1/webhooks/github", githubPushBody, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestTopicSegment(t *testing.T) {
+	require.Equal(t, "on-call", topicSegment("On-Call"))
+	require.Equal(t, "team_a", topicSegment("team a/"))
+	require.Equal(t, "unknown", topicSegment(""))
+}
@@ -22,6 +22,19 @@ func (b *BirthdayModule) Initialize(ctx context.Context, params modules.Initiali
 	b.dbConn = params.DbConn
 	b.logger = params.Logger
 	b.publisher = params.Notifications
+
+	err := b.publisher.RegisterTopic(notifications.TopicTemplate{
+		Template:    BirthdayPollerTopic + ".{chat_id}",
+		Description: "Birthday announcements for a chat",
+		Params: []notifications.TopicParam{
+			{Name: "chat_id", Description: "Chat the birthdays were added in, see /myid", Example: "-1001234567890"},
+		},
+		ACL: notifications.TopicACL{Access: notifications.TopicAccessChatScoped, ChatParam: "chat_id"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register birthday topic: %w", err)
+	}
+
 	poller, err := newPoller(b.publisher, b.dbConn, b.logger)
 	if err != nil {
 		return fmt.Errorf("failed to create birthday poller: %w", err)
@@ -149,11 +149,15 @@ func (p *poller) announceBirthdays(ctx context.Context, birthdays []dbmodels.Bir
 
 		birthdayTopic := fmt.Sprintf("%s.%d", BirthdayPollerTopic, birthday.ChatId)
 
-		p.publisher.Publish(notifications.Message{
-			Topic:   birthdayTopic,
-			Msg:     msg,
-			DupeTTL: time.Hour * 24,
+		err = p.publisher.Publish(ctx, notifications.Message{
+			Topic:    birthdayTopic,
+			Msg:      msg,
+			DupeTTL:  time.Hour * 24,
+			Priority: notifications.PriorityInfo,
 		})
+		if err != nil {
+			return fmt.Errorf("failed to publish birthday message: %w", err)
+		}
 
 		if err := p.setLastAnnouncedBirthday(ctx, birthday); err != nil {
 			return fmt.Errorf("failed to set last announced birthday: %w", err)
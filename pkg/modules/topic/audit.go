@@ -0,0 +1,88 @@
+package topic
+
+import (
+	"context"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/middleware"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"strings"
+)
+
+type TopicAuditCmd struct {
+	command.BaseCommand
+
+	botProxy  proxy.TGBotImplementation
+	publisher notifications.Publisher
+	logger    zerolog.Logger
+}
+
+var _ command.TomatobotCommand = &TopicAuditCmd{}
+
+// Execute lists the subscriptions the topic ACLs don't allow, optionally removing them
+// /topic audit [remove]
+func (t *TopicAuditCmd) Execute(ctx context.Context, params models.CommandParams) error {
+	remove := false
+	if len(params.Args) > 0 {
+		if params.Args[0] != "remove" {
+			return fmt.Errorf("unknown argument %s", params.Args[0])
+		}
+		remove = true
+	}
+
+	violations := t.publisher.AuditSubscriptions()
+	if len(violations) == 0 {
+		_, err := t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", "No subscriptions violate the topic ACLs"))
+		if err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+		return nil
+	}
+
+	outMsg := strings.Builder{}
+	outMsg.WriteString("Subscriptions violating the topic ACLs:\n")
+	for _, violation := range violations {
+		sub := violation.Subscriber
+		outMsg.WriteString(fmt.Sprintf("%s chat %d topic %s: %s\n", sub.ID, sub.ChatId, sub.TopicPattern, violation.Reason))
+
+		if remove {
+			if err := t.publisher.Unsubscribe(sub.ID, sub.ChatId); err != nil {
+				outMsg.WriteString(fmt.Sprintf("  failed to remove: %s\n", err))
+				continue
+			}
+			t.logger.Info().Msgf("Removed subscription %s to %s for chat %d: %s",
+				sub.ID, sub.TopicPattern, sub.ChatId, violation.Reason)
+			outMsg.WriteString("  removed\n")
+		}
+	}
+
+	for _, chunk := range util.SplitMessage(outMsg.String(), util.TelegramMaxMessageLength) {
+		_, err := t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", chunk))
+		if err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (t *TopicAuditCmd) Description() string {
+	return "Find subscriptions the topic ACLs don't allow (bot admins only)"
+}
+
+func (t *TopicAuditCmd) Help() string {
+	return "/topic audit [remove] - Find, and optionally remove, subscriptions the topic ACLs don't allow"
+}
+
+func newTopicAuditCmd(publisher notifications.Publisher, botProxy proxy.TGBotImplementation, logger zerolog.Logger) *TopicAuditCmd {
+	return &TopicAuditCmd{
+		BaseCommand: command.NewBaseCommand(middleware.WithBotAdminPermission(), middleware.WithMaxArgs(1)),
+		publisher:   publisher,
+		botProxy:    botProxy,
+		logger:      logger,
+	}
+}
@@ -0,0 +1,99 @@
+package topic
+
+import (
+	"context"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/middleware"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"strings"
+	"time"
+)
+
+const browseRecentTopicsLimit = 20
+
+type TopicBrowseCmd struct {
+	command.BaseCommand
+
+	botProxy  proxy.TGBotImplementation
+	publisher notifications.Publisher
+	logger    zerolog.Logger
+}
+
+var _ command.TomatobotCommand = &TopicBrowseCmd{}
+
+// Execute lists the topic templates and recently published topics
+// /topic browse [prefix]
+func (t *TopicBrowseCmd) Execute(ctx context.Context, params models.CommandParams) error {
+	prefix := ""
+	if len(params.Args) > 0 {
+		prefix = params.Args[0]
+	}
+
+	templates := t.publisher.GetTopicTemplates(prefix)
+	recent, err := t.publisher.GetRecentTopics(prefix, browseRecentTopicsLimit)
+	if err != nil {
+		return fmt.Errorf("failed to get recent topics: %w", err)
+	}
+
+	if len(templates) == 0 && len(recent) == 0 {
+		_, err = t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", "No topics found"))
+		if err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+		return nil
+	}
+
+	outMsg := strings.Builder{}
+	if len(templates) > 0 {
+		outMsg.WriteString("Available topics:\n")
+		for _, tmpl := range templates {
+			outMsg.WriteString(fmt.Sprintf("\n%s - %s\n", tmpl.Template, tmpl.Description))
+			if tmpl.ACL.Access != notifications.TopicAccessPublic {
+				outMsg.WriteString(fmt.Sprintf("  access: %s\n", tmpl.ACL.Access))
+			}
+			for _, param := range tmpl.Params {
+				outMsg.WriteString(fmt.Sprintf("  {%s}: %s\n", param.Name, param.Description))
+			}
+			outMsg.WriteString(fmt.Sprintf("  e.g. /topic sub %s\n", tmpl.Example()))
+		}
+	}
+
+	if len(recent) > 0 {
+		outMsg.WriteString("\nRecently published:\n")
+		for _, stat := range recent {
+			outMsg.WriteString(fmt.Sprintf("%s - %d messages, last %s\n", stat.Topic, stat.Count,
+				stat.LastSeen.Format(time.RFC1123)))
+		}
+	}
+
+	for _, chunk := range util.SplitMessage(outMsg.String(), util.TelegramMaxMessageLength) {
+		_, err = t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", chunk))
+		if err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (t *TopicBrowseCmd) Description() string {
+	return "Browse the topics you can subscribe to"
+}
+
+func (t *TopicBrowseCmd) Help() string {
+	return "/topic browse [prefix] - Browse the topics you can subscribe to"
+}
+
+func newTopicBrowseCmd(publisher notifications.Publisher, botProxy proxy.TGBotImplementation, logger zerolog.Logger) *TopicBrowseCmd {
+	return &TopicBrowseCmd{
+		BaseCommand: command.NewBaseCommand(middleware.WithMaxArgs(1)),
+		publisher:   publisher,
+		botProxy:    botProxy,
+		logger:      logger,
+	}
+}
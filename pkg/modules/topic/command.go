@@ -51,5 +51,45 @@ func newTopicCmd(publisher notifications.Publisher, botProxy proxy.TGBotImplemen
 		return nil, fmt.Errorf("unable to register subcommand %s. Err: %w", "list", err)
 	}
 
+	err = topicCmd.RegisterSubcommand("quiet", newTopicQuietCmd(publisher, botProxy, logger))
+	if err != nil {
+		return nil, fmt.Errorf("unable to register subcommand %s. Err: %w", "quiet", err)
+	}
+
+	err = topicCmd.RegisterSubcommand("deliveries", newTopicDeliveriesCmd(publisher, botProxy, logger))
+	if err != nil {
+		return nil, fmt.Errorf("unable to register subcommand %s. Err: %w", "deliveries", err)
+	}
+
+	err = topicCmd.RegisterSubcommand("mute", newTopicMuteCmd(publisher, botProxy, logger))
+	if err != nil {
+		return nil, fmt.Errorf("unable to register subcommand %s. Err: %w", "mute", err)
+	}
+
+	err = topicCmd.RegisterSubcommand("unmute", newTopicUnmuteCmd(publisher, botProxy, logger))
+	if err != nil {
+		return nil, fmt.Errorf("unable to register subcommand %s. Err: %w", "unmute", err)
+	}
+
+	err = topicCmd.RegisterSubcommand("browse", newTopicBrowseCmd(publisher, botProxy, logger))
+	if err != nil {
+		return nil, fmt.Errorf("unable to register subcommand %s. Err: %w", "browse", err)
+	}
+
+	err = topicCmd.RegisterSubcommand("audit", newTopicAuditCmd(publisher, botProxy, logger))
+	if err != nil {
+		return nil, fmt.Errorf("unable to register subcommand %s. Err: %w", "audit", err)
+	}
+
+	err = topicCmd.RegisterSubcommand("reload", newTopicReloadCmd(publisher, botProxy, logger))
+	if err != nil {
+		return nil, fmt.Errorf("unable to register subcommand %s. Err: %w", "reload", err)
+	}
+
+	err = topicCmd.RegisterSubcommand("history", newTopicHistoryCmd(publisher, botProxy, logger))
+	if err != nil {
+		return nil, fmt.Errorf("unable to register subcommand %s. Err: %w", "history", err)
+	}
+
 	return &topicCmd, nil
 }
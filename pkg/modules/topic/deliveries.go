@@ -0,0 +1,131 @@
+package topic
+
+import (
+	"context"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/middleware"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultDeliveriesLimit = 10
+
+type TopicDeliveriesCmd struct {
+	command.BaseCommand
+
+	botProxy  proxy.TGBotImplementation
+	publisher notifications.Publisher
+	logger    zerolog.Logger
+}
+
+var _ command.TomatobotCommand = &TopicDeliveriesCmd{}
+
+// Execute lists outbox deliveries or retries a failed one
+// /topic deliveries [pending|failed|delivered|all] [n]
+// /topic deliveries retry <id>
+func (t *TopicDeliveriesCmd) Execute(ctx context.Context, params models.CommandParams) error {
+	if len(params.Args) > 0 && params.Args[0] == "retry" {
+		return t.retryDelivery(params)
+	}
+
+	status := notifications.OutboxStatusFailed
+	limit := defaultDeliveriesLimit
+	for _, arg := range params.Args {
+		if n, err := strconv.Atoi(arg); err == nil {
+			limit = n
+			continue
+		}
+
+		switch arg {
+		case notifications.OutboxStatusPending, notifications.OutboxStatusFailed, notifications.OutboxStatusDelivered:
+			status = arg
+		case "all":
+			status = ""
+		default:
+			return fmt.Errorf("unknown argument %s", arg)
+		}
+	}
+
+	deliveries, err := t.publisher.GetDeliveries(status, limit)
+	if err != nil {
+		return fmt.Errorf("failed to get deliveries: %w", err)
+	}
+
+	if len(deliveries) == 0 {
+		_, err = t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", "No deliveries found"))
+		if err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+		return nil
+	}
+
+	outMsg := strings.Builder{}
+	outMsg.WriteString("Deliveries:\n")
+	for _, delivery := range deliveries {
+		target := fmt.Sprintf("chat %d", delivery.ChatID)
+		if delivery.Sink != "" {
+			target = fmt.Sprintf("%s %s (chat %d)", delivery.Sink, delivery.Destination, delivery.ChatID)
+		}
+		outMsg.WriteString(fmt.Sprintf("#%d %s %s topic %s attempts %d updated %s\n",
+			delivery.ID, delivery.Status, target, delivery.Topic, delivery.Attempts,
+			delivery.UpdatedAt.Format(time.RFC3339)))
+		if delivery.LastError != "" {
+			outMsg.WriteString(fmt.Sprintf("  error: %s\n", util.TruncateString(delivery.LastError, 200, "...")))
+		}
+	}
+
+	for _, chunk := range util.SplitMessage(outMsg.String(), util.TelegramMaxMessageLength) {
+		_, err = t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", chunk))
+		if err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (t *TopicDeliveriesCmd) retryDelivery(params models.CommandParams) error {
+	if len(params.Args) != 2 {
+		return fmt.Errorf("usage: /topic deliveries retry <id>")
+	}
+
+	id, err := strconv.Atoi(params.Args[1])
+	if err != nil {
+		return fmt.Errorf("invalid delivery id: %w", err)
+	}
+
+	if err := t.publisher.RetryDelivery(id); err != nil {
+		return fmt.Errorf("failed to retry delivery: %w", err)
+	}
+
+	_, err = t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", fmt.Sprintf("Delivery %d queued for retry", id)))
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+func (t *TopicDeliveriesCmd) Description() string {
+	return "Inspect notification deliveries (bot admins only)"
+}
+
+func (t *TopicDeliveriesCmd) Help() string {
+	return "/topic deliveries [pending|failed|delivered|all] [n] | retry <id> - Inspect notification deliveries"
+}
+
+func newTopicDeliveriesCmd(publisher notifications.Publisher, botProxy proxy.TGBotImplementation, logger zerolog.Logger) *TopicDeliveriesCmd {
+	return &TopicDeliveriesCmd{
+		BaseCommand: command.NewBaseCommand(middleware.WithBotAdminPermission(), middleware.WithMaxArgs(2)),
+		publisher:   publisher,
+		botProxy:    botProxy,
+		logger:      logger,
+	}
+}
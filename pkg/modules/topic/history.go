@@ -0,0 +1,122 @@
+package topic
+
+import (
+	"context"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/middleware"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultHistoryLimit = 20
+
+type TopicHistoryCmd struct {
+	command.BaseCommand
+
+	botProxy  proxy.TGBotImplementation
+	publisher notifications.Publisher
+	logger    zerolog.Logger
+}
+
+var _ command.TomatobotCommand = &TopicHistoryCmd{}
+
+// Execute lists what happened to recent notifications for the chat, or for every chat with all
+// /topic history [all] [pattern] [n]
+func (t *TopicHistoryCmd) Execute(ctx context.Context, params models.CommandParams) error {
+	msg := params.Message
+	filter := notifications.HistoryFilter{
+		ChatId: msg.AssumedChatID(),
+		Limit:  defaultHistoryLimit,
+	}
+	for _, arg := range params.Args {
+		if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+			filter.Limit = n
+			continue
+		}
+
+		switch {
+		case arg == "all":
+			if !t.botProxy.IsBotAdmin(msg.AssumedUserID()) {
+				return fmt.Errorf("only bot admins can see the history of every chat")
+			}
+			filter.AllChats = true
+		case filter.TopicPattern == "":
+			filter.TopicPattern = arg
+		default:
+			return fmt.Errorf("unknown argument %s", arg)
+		}
+	}
+
+	history, err := t.publisher.GetHistory(filter)
+	if err != nil {
+		return fmt.Errorf("failed to get history: %w", err)
+	}
+
+	if len(history) == 0 {
+		_, err = t.botProxy.Send(util.NewMessageReply(msg.InnerMsg(), "", "No notification history found"))
+		if err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+		return nil
+	}
+
+	outMsg := strings.Builder{}
+	outMsg.WriteString("Notification history, newest first:\n")
+	for _, record := range history {
+		outMsg.WriteString(fmt.Sprintf("%s %s [%s] %s", record.CreatedAt.Format(time.DateTime), record.Topic,
+			notifications.Priority(record.Priority), record.Status))
+		switch {
+		case record.Sink != "":
+			outMsg.WriteString(fmt.Sprintf(" to %s %s", record.Sink, record.Destination))
+		case filter.AllChats:
+			outMsg.WriteString(fmt.Sprintf(" to chat %d", record.ChatID))
+		}
+		if record.TelegramMessageID != 0 {
+			outMsg.WriteString(fmt.Sprintf(" (message %d)", record.TelegramMessageID))
+		}
+		if record.Repeats > 0 {
+			outMsg.WriteString(fmt.Sprintf(" (%d more, last %s)", record.Repeats, record.UpdatedAt.Format(time.DateTime)))
+		}
+		outMsg.WriteString("\n")
+
+		if record.Detail != "" {
+			outMsg.WriteString(fmt.Sprintf("  %s\n", util.TruncateString(record.Detail, 200, "...")))
+		}
+		if record.Preview != "" {
+			outMsg.WriteString(fmt.Sprintf("  %q\n", record.Preview))
+		}
+	}
+
+	for _, chunk := range util.SplitMessage(outMsg.String(), util.TelegramMaxMessageLength) {
+		_, err = t.botProxy.Send(util.NewMessageReply(msg.InnerMsg(), "", chunk))
+		if err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (t *TopicHistoryCmd) Description() string {
+	return "Show what happened to recent notifications"
+}
+
+func (t *TopicHistoryCmd) Help() string {
+	return "/topic history [all] [pattern] [n] - Show what happened to recent notifications for this chat, all chats is for bot admins"
+}
+
+func newTopicHistoryCmd(publisher notifications.Publisher, botProxy proxy.TGBotImplementation, logger zerolog.Logger) *TopicHistoryCmd {
+	return &TopicHistoryCmd{
+		BaseCommand: command.NewBaseCommand(middleware.WithMaxArgs(3)),
+		publisher:   publisher,
+		botProxy:    botProxy,
+		logger:      logger,
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/tomato3017/tomatobot/pkg/notifications"
 	"github.com/tomato3017/tomatobot/pkg/util"
 	"strings"
+	"time"
 )
 
 type TopicListCmd struct {
@@ -37,9 +38,24 @@ func (s *TopicListCmd) Execute(ctx context.Context, params models.CommandParams)
 
 	outMsg := strings.Builder{}
 	outMsg.WriteString("Current subscriptions:\n")
-	outMsg.WriteString("ID \\- Topic\n")
+	outMsg.WriteString("ID \\- Topic \\- Min Priority \\- Delivery\n")
+	now := time.Now()
 	for _, sub := range currentSubs {
-		outMsg.WriteString(fmt.Sprintf("\t`%s - %s`\n", sub.ID, sub.TopicPattern))
+		delivery := "immediate"
+		if sub.Digest != "" {
+			delivery = "digest " + sub.Digest
+		}
+		if now.Before(sub.MutedUntil) {
+			delivery += ", muted until " + sub.MutedUntil.Format(time.RFC1123)
+		}
+		if !sub.ExpiresAt.IsZero() {
+			delivery += ", expires " + sub.ExpiresAt.Format(time.RFC1123)
+		}
+		if sub.Sink != "" {
+			delivery += fmt.Sprintf(", to %s %s", sub.Sink, sub.Destination)
+		}
+		outMsg.WriteString(fmt.Sprintf("\t`%s - %s - %s - %s`\n", sub.ID, sub.TopicPattern,
+			notifications.Priority(sub.MinPriority), delivery))
 	}
 
 	_, err = s.botProxy.Send(util.NewMessageReply(message.InnerMsg(), tgbotapi.ModeMarkdownV2, outMsg.String()))
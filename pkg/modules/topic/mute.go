@@ -0,0 +1,119 @@
+package topic
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/middleware"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	mfmt "github.com/tomato3017/tomatobot/pkg/util/markdownfmt"
+	"time"
+)
+
+type TopicMuteCmd struct {
+	command.BaseCommand
+
+	botProxy  proxy.TGBotImplementation
+	publisher notifications.Publisher
+	logger    zerolog.Logger
+}
+
+var _ command.TomatobotCommand = &TopicMuteCmd{}
+
+// Execute mutes a subscription for a while
+// /topic mute <id> <duration>
+func (t *TopicMuteCmd) Execute(ctx context.Context, params models.CommandParams) error {
+	subId, err := uuid.Parse(params.Args[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse subscription id: %w", err)
+	}
+
+	duration, err := util.ParseDuration(params.Args[1])
+	if err != nil || duration <= 0 {
+		return fmt.Errorf("invalid duration %s, use something like 30m, 8h or 3d", params.Args[1])
+	}
+
+	until := time.Now().Add(duration)
+	if err := t.publisher.MuteSubscription(subId, params.Message.AssumedChatID(), until); err != nil {
+		return fmt.Errorf("failed to mute subscription: %w", err)
+	}
+
+	_, err = t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), tgbotapi.ModeMarkdownV2,
+		mfmt.Sprintf("Muted subscription %m until %m", subId.String(), until.Format(time.RFC1123))))
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+func (t *TopicMuteCmd) Description() string {
+	return "Mute a subscription for a while"
+}
+
+func (t *TopicMuteCmd) Help() string {
+	return "/topic mute <id> <duration> - Mute a subscription, e.g. /topic mute <id> 8h"
+}
+
+func newTopicMuteCmd(publisher notifications.Publisher, botProxy proxy.TGBotImplementation, logger zerolog.Logger) *TopicMuteCmd {
+	return &TopicMuteCmd{
+		BaseCommand: command.NewBaseCommand(middleware.WithNArgs(2)),
+		publisher:   publisher,
+		botProxy:    botProxy,
+		logger:      logger,
+	}
+}
+
+type TopicUnmuteCmd struct {
+	command.BaseCommand
+
+	botProxy  proxy.TGBotImplementation
+	publisher notifications.Publisher
+	logger    zerolog.Logger
+}
+
+var _ command.TomatobotCommand = &TopicUnmuteCmd{}
+
+// Execute unmutes a subscription
+// /topic unmute <id>
+func (t *TopicUnmuteCmd) Execute(ctx context.Context, params models.CommandParams) error {
+	subId, err := uuid.Parse(params.Args[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse subscription id: %w", err)
+	}
+
+	if err := t.publisher.UnmuteSubscription(subId, params.Message.AssumedChatID()); err != nil {
+		return fmt.Errorf("failed to unmute subscription: %w", err)
+	}
+
+	_, err = t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), tgbotapi.ModeMarkdownV2,
+		mfmt.Sprintf("Unmuted subscription %m", subId.String())))
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+func (t *TopicUnmuteCmd) Description() string {
+	return "Unmute a subscription"
+}
+
+func (t *TopicUnmuteCmd) Help() string {
+	return "/topic unmute <id> - Unmute a subscription"
+}
+
+func newTopicUnmuteCmd(publisher notifications.Publisher, botProxy proxy.TGBotImplementation, logger zerolog.Logger) *TopicUnmuteCmd {
+	return &TopicUnmuteCmd{
+		BaseCommand: command.NewBaseCommand(middleware.WithNArgs(1)),
+		publisher:   publisher,
+		botProxy:    botProxy,
+		logger:      logger,
+	}
+}
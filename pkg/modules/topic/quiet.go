@@ -0,0 +1,110 @@
+package topic
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/middleware"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	mfmt "github.com/tomato3017/tomatobot/pkg/util/markdownfmt"
+	"strings"
+)
+
+type TopicQuietCmd struct {
+	command.BaseCommand
+
+	botProxy  proxy.TGBotImplementation
+	publisher notifications.Publisher
+	logger    zerolog.Logger
+}
+
+var _ command.TomatobotCommand = &TopicQuietCmd{}
+
+// Execute shows, sets or clears the quiet hours for the chat
+// /topic quiet [off | <HH:MM> <HH:MM> [timezone]]
+func (t *TopicQuietCmd) Execute(ctx context.Context, params models.CommandParams) error {
+	switch {
+	case len(params.Args) == 0:
+		return t.showQuietHours(params)
+	case len(params.Args) == 1 && strings.EqualFold(params.Args[0], "off"):
+		return t.clearQuietHours(params)
+	case len(params.Args) == 2 || len(params.Args) == 3:
+		return t.setQuietHours(params)
+	default:
+		return fmt.Errorf("usage: %s", t.Help())
+	}
+}
+
+func (t *TopicQuietCmd) showQuietHours(params models.CommandParams) error {
+	replyMsg := "No quiet hours set"
+	quietHours, ok := t.publisher.GetQuietHours(params.Message.AssumedChatID())
+	if ok {
+		replyMsg = mfmt.Sprintf("Quiet hours are %m", quietHours.String())
+	}
+
+	_, err := t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), tgbotapi.ModeMarkdownV2, replyMsg))
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+func (t *TopicQuietCmd) clearQuietHours(params models.CommandParams) error {
+	if err := t.publisher.ClearQuietHours(params.Message.AssumedChatID()); err != nil {
+		return fmt.Errorf("failed to clear quiet hours: %w", err)
+	}
+
+	_, err := t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", "Quiet hours disabled"))
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+func (t *TopicQuietCmd) setQuietHours(params models.CommandParams) error {
+	tz := ""
+	if len(params.Args) == 3 {
+		tz = params.Args[2]
+	}
+
+	quietHours, err := notifications.NewQuietHours(params.Message.AssumedChatID(), params.Args[0], params.Args[1], tz)
+	if err != nil {
+		return fmt.Errorf("invalid quiet hours: %w", err)
+	}
+
+	if err := t.publisher.SetQuietHours(quietHours); err != nil {
+		return fmt.Errorf("failed to set quiet hours: %w", err)
+	}
+
+	_, err = t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), tgbotapi.ModeMarkdownV2,
+		mfmt.Sprintf("Quiet hours set to %m. Critical messages will still be delivered immediately", quietHours.String())))
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+func (t *TopicQuietCmd) Description() string {
+	return "Show or set quiet hours for notifications"
+}
+
+func (t *TopicQuietCmd) Help() string {
+	return "/topic quiet [off | <HH:MM> <HH:MM> [timezone]] - Show or set quiet hours for notifications"
+}
+
+func newTopicQuietCmd(publisher notifications.Publisher, botProxy proxy.TGBotImplementation, logger zerolog.Logger) *TopicQuietCmd {
+	return &TopicQuietCmd{
+		BaseCommand: command.NewBaseCommand(middleware.WithMaxArgs(3)),
+		publisher:   publisher,
+		botProxy:    botProxy,
+		logger:      logger,
+	}
+}
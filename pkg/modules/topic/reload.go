@@ -0,0 +1,55 @@
+package topic
+
+import (
+	"context"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/middleware"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+)
+
+type TopicReloadCmd struct {
+	command.BaseCommand
+
+	botProxy  proxy.TGBotImplementation
+	publisher notifications.Publisher
+	logger    zerolog.Logger
+}
+
+var _ command.TomatobotCommand = &TopicReloadCmd{}
+
+// Execute reloads the subscriptions from the database
+// /topic reload
+func (t *TopicReloadCmd) Execute(ctx context.Context, params models.CommandParams) error {
+	if err := t.publisher.ReloadSubscriptions(ctx); err != nil {
+		return fmt.Errorf("failed to reload subscriptions: %w", err)
+	}
+
+	_, err := t.botProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", "Subscriptions reloaded"))
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+func (t *TopicReloadCmd) Description() string {
+	return "Reload subscriptions from the database (bot admins only)"
+}
+
+func (t *TopicReloadCmd) Help() string {
+	return "/topic reload - Reload subscriptions from the database"
+}
+
+func newTopicReloadCmd(publisher notifications.Publisher, botProxy proxy.TGBotImplementation, logger zerolog.Logger) *TopicReloadCmd {
+	return &TopicReloadCmd{
+		BaseCommand: command.NewBaseCommand(middleware.WithBotAdminPermission(), middleware.WithMaxArgs(0)),
+		publisher:   publisher,
+		botProxy:    botProxy,
+		logger:      logger,
+	}
+}
@@ -2,6 +2,7 @@ package topic
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/rs/zerolog"
@@ -12,6 +13,15 @@ import (
 	"github.com/tomato3017/tomatobot/pkg/notifications"
 	"github.com/tomato3017/tomatobot/pkg/util"
 	mfmt "github.com/tomato3017/tomatobot/pkg/util/markdownfmt"
+	"strings"
+	"time"
+)
+
+const (
+	minPriorityFlag = "--min="
+	digestFlag      = "--digest="
+	expiresFlag     = "--expires="
+	toFlag          = "--to="
 )
 
 type TopicSubCmd struct {
@@ -34,18 +44,83 @@ func (t *TopicSubCmd) Execute(ctx context.Context, params models.CommandParams)
 		return fmt.Errorf("invalid topic format")
 	}
 
+	if err := t.publisher.ValidateTopicPattern(topic); err != nil {
+		return err
+	}
+
+	minPriority := notifications.PriorityDebug
+	digest := notifications.DigestSchedule{}
+	expiresAt := time.Time{}
+	sink, destination := "", ""
+	for _, arg := range params.Args[1:] {
+		switch {
+		case strings.HasPrefix(arg, minPriorityFlag):
+			priority, err := notifications.ParsePriority(strings.TrimPrefix(arg, minPriorityFlag))
+			if err != nil {
+				return fmt.Errorf("invalid minimum priority: %w", err)
+			}
+			minPriority = priority
+		case strings.HasPrefix(arg, digestFlag):
+			schedule, err := notifications.ParseDigestSchedule(strings.TrimPrefix(arg, digestFlag))
+			if err != nil {
+				return fmt.Errorf("invalid digest schedule: %w", err)
+			}
+			digest = schedule
+		case strings.HasPrefix(arg, expiresFlag):
+			duration, err := util.ParseDuration(strings.TrimPrefix(arg, expiresFlag))
+			if err != nil || duration <= 0 {
+				return fmt.Errorf("invalid expiry, use a duration like 8h or 3d")
+			}
+			expiresAt = time.Now().Add(duration)
+		case strings.HasPrefix(arg, toFlag):
+			// sinks send messages outside of telegram, so only bot admins may point a subscription at one
+			if !t.botProxy.IsBotAdmin(msg.AssumedUserID()) {
+				return fmt.Errorf("only bot admins can deliver subscriptions to a sink")
+			}
+
+			var ok bool
+			sink, destination, ok = strings.Cut(strings.TrimPrefix(arg, toFlag), ":")
+			if !ok || destination == "" {
+				return fmt.Errorf("invalid sink, use --to=<sink>:<destination> with one of: %s",
+					strings.Join(t.publisher.GetSinks(), ", "))
+			}
+		default:
+			return fmt.Errorf("unknown argument %s", arg)
+		}
+	}
+
 	sub := notifications.Subscriber{
 		TopicPattern: topic,
 		ChatId:       msg.AssumedChatID(),
+		MinPriority:  minPriority,
+		Digest:       digest,
+		ExpiresAt:    expiresAt,
+		CreatedBy:    msg.AssumedUserID(),
+		Sink:         sink,
+		Destination:  destination,
 	}
 
 	subId, err := t.publisher.Subscribe(sub)
-	if err != nil {
+	if errors.Is(err, notifications.ErrTopicAccessDenied) || errors.Is(err, notifications.ErrUnknownSink) {
+		return err
+	} else if err != nil {
 		return fmt.Errorf("failed to topic: %w", err)
 	}
 
+	delivery := "immediately"
+	if !digest.IsZero() {
+		delivery = fmt.Sprintf("as a digest (%s)", digest)
+	}
+	if !expiresAt.IsZero() {
+		delivery += fmt.Sprintf(" until %s", expiresAt.Format(time.RFC1123))
+	}
+	if sink != "" {
+		delivery += fmt.Sprintf(" to %s %s", sink, destination)
+	}
+
 	_, err = t.botProxy.Send(util.NewMessageReply(msg.InnerMsg(), tgbotapi.ModeMarkdownV2,
-		mfmt.Sprintf("Subscribed to topic %m with id %m!", topic, subId)))
+		mfmt.Sprintf("Subscribed to topic %m with id %m and minimum priority %m, delivered %m!",
+			topic, subId, minPriority.String(), delivery)))
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
@@ -58,11 +133,11 @@ func (t *TopicSubCmd) Description() string {
 }
 
 func (t *TopicSubCmd) Help() string {
-	return "/topic subscribe <topic> - Subscribe to a topic"
+	return "/topic sub <topic> [--min=debug|info|warning|critical] [--digest=30m|08:00,18:00[@Europe/London]] [--expires=3d] [--to=webhook:https://example.com/hook] - Subscribe to a topic, optionally delivering to a sink instead of the chat"
 }
 
 func newTopicSubCmd(publisher notifications.Publisher, botProxy proxy.TGBotImplementation, logger zerolog.Logger) *TopicSubCmd {
-	bCmd := command.NewBaseCommand(middleware.WithNArgs(1))
+	bCmd := command.NewBaseCommand(middleware.WithMinArgs(1))
 	return &TopicSubCmd{
 		BaseCommand: bCmd,
 		publisher:   publisher,
@@ -0,0 +1,134 @@
+package weather
+
+import (
+	"github.com/rs/zerolog"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"strings"
+)
+
+// unknownAlertPolicy is what happens to an alert the classifier can't place
+type unknownAlertPolicy string
+
+const (
+	unknownAlertsAdvisory unknownAlertPolicy = "advisory"
+	unknownAlertsPublish  unknownAlertPolicy = "unknown"
+	unknownAlertsDrop     unknownAlertPolicy = "drop"
+)
+
+// defaultAlertCategories are the events classified differently than their CAP metadata or name would suggest, keyed
+// by the upper cased event name. Fire weather, heat and cold alerts are published as advisories, they're issued
+// often enough during the season that a warning on every one would be noise.
+var defaultAlertCategories = map[string]eventType{
+	"RED FLAG WARNING":          eventTypeAdvisory,
+	"FIRE WEATHER WATCH":        eventTypeAdvisory,
+	"EXCESSIVE HEAT WARNING":    eventTypeAdvisory,
+	"EXCESSIVE HEAT WATCH":      eventTypeAdvisory,
+	"WIND CHILL WARNING":        eventTypeAdvisory,
+	"WIND CHILL WATCH":          eventTypeAdvisory,
+	"WIND CHILL ADVISORY":       eventTypeAdvisory,
+	"FREEZE WARNING":            eventTypeAdvisory,
+	"FREEZE WATCH":              eventTypeAdvisory,
+	"SEVERE WEATHER STATEMENT":  eventTypeWarning,
+	"SPECIAL WEATHER STATEMENT": eventTypeAdvisory,
+	"HAZARDOUS WEATHER OUTLOOK": eventTypeAdvisory,
+	"AIR QUALITY ALERT":         eventTypeAdvisory,
+}
+
+// alertEventSuffixes classify events named "<hazard> <category>", the convention of the NWS and most issuers
+// relayed by OpenWeatherMap, when nothing else does
+var alertEventSuffixes = map[string]eventType{
+	"WARNING":   eventTypeWarning,
+	"WATCH":     eventTypeWatch,
+	"ADVISORY":  eventTypeAdvisory,
+	"STATEMENT": eventTypeAdvisory,
+}
+
+// alertClassifier decides which topic an alert is published to. It tries, in order, the event mapping table, a tag
+// naming a category, the alert's CAP severity, urgency and certainty and the last word of the event name, then falls
+// back to the unknown policy.
+type alertClassifier struct {
+	events        map[string]eventType
+	unknownPolicy unknownAlertPolicy
+	logger        zerolog.Logger
+}
+
+// newAlertClassifier creates a classifier with the default event mapping overridden by the config's
+func newAlertClassifier(cfg config.WeatherConfig, logger zerolog.Logger) *alertClassifier {
+	events := make(map[string]eventType, len(defaultAlertCategories)+len(cfg.AlertCategories))
+	for event, category := range defaultAlertCategories {
+		events[event] = category
+	}
+	for event, category := range cfg.AlertCategories {
+		events[strings.ToUpper(strings.TrimSpace(event))] = eventType(strings.ToLower(category))
+	}
+
+	unknownPolicy := unknownAlertPolicy(cfg.UnknownAlerts)
+	if unknownPolicy == "" {
+		unknownPolicy = unknownAlertsAdvisory
+	}
+
+	return &alertClassifier{
+		events:        events,
+		unknownPolicy: unknownPolicy,
+		logger:        logger,
+	}
+}
+
+// classify returns the event type the alert is published as, false if it shouldn't be published
+func (a *alertClassifier) classify(alert provider.Alert) (eventType, bool) {
+	eventNameUpper := strings.ToUpper(strings.TrimSpace(alert.Event))
+	if category, ok := a.events[eventNameUpper]; ok {
+		return category, true
+	}
+
+	for _, tag := range alert.Tags {
+		switch category := eventType(strings.ToLower(tag)); category {
+		case eventTypeWarning, eventTypeWatch, eventTypeAdvisory:
+			return category, true
+		}
+	}
+
+	if category, ok := capEventType(alert); ok {
+		return category, true
+	}
+
+	words := strings.Fields(eventNameUpper)
+	if len(words) > 0 {
+		if category, ok := alertEventSuffixes[words[len(words)-1]]; ok {
+			return category, true
+		}
+	}
+
+	a.logger.Warn().Str("severity", string(alert.Severity)).Str("urgency", string(alert.Urgency)).
+		Str("certainty", string(alert.Certainty)).Strs("tags", alert.Tags).
+		Msgf("Unknown alert type %s, handling it as %s", alert.Event, a.unknownPolicy)
+
+	switch a.unknownPolicy {
+	case unknownAlertsDrop:
+		return "", false
+	case unknownAlertsPublish:
+		return eventTypeUnknown, true
+	default:
+		return eventTypeAdvisory, true
+	}
+}
+
+// capEventType classifies an alert by its CAP metadata. Severe alerts that are observed or likely are warnings and
+// those that are only possible or in the future are watches, lesser ones are advisories.
+func capEventType(alert provider.Alert) (eventType, bool) {
+	switch alert.Severity {
+	case provider.SeverityExtreme, provider.SeveritySevere:
+		switch {
+		case alert.Certainty == provider.CertaintyPossible, alert.Certainty == provider.CertaintyUnlikely,
+			alert.Urgency == provider.UrgencyFuture:
+			return eventTypeWatch, true
+		case alert.Certainty == provider.CertaintyObserved, alert.Certainty == provider.CertaintyLikely:
+			return eventTypeWarning, true
+		}
+	case provider.SeverityModerate, provider.SeverityMinor:
+		return eventTypeAdvisory, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,70 @@
+package weather
+
+import (
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"os"
+	"testing"
+)
+
+func TestAlertClassifier_classify(t *testing.T) {
+	data, err := os.ReadFile("testdata/alerts.json")
+	require.NoError(t, err)
+
+	var fixtures []struct {
+		Name  string
+		Alert provider.Alert
+		Want  eventType
+	}
+	require.NoError(t, json.Unmarshal(data, &fixtures))
+	require.NotEmpty(t, fixtures)
+
+	classifier := newAlertClassifier(config.WeatherConfig{UnknownAlerts: "unknown"}, zerolog.Nop())
+	for _, tt := range fixtures {
+		t.Run(tt.Name, func(t *testing.T) {
+			got, ok := classifier.classify(tt.Alert)
+			require.True(t, ok)
+			require.Equal(t, tt.Want, got)
+		})
+	}
+}
+
+func TestAlertClassifier_unknownPolicy(t *testing.T) {
+	alert := provider.Alert{Event: "Child Abduction Emergency", Severity: provider.SeverityUnknown}
+
+	tests := []struct {
+		name     string
+		policy   string
+		want     eventType
+		wantSend bool
+	}{
+		{name: "default", policy: "", want: eventTypeAdvisory, wantSend: true},
+		{name: "unknown topic", policy: "unknown", want: eventTypeUnknown, wantSend: true},
+		{name: "drop", policy: "drop", wantSend: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := newAlertClassifier(config.WeatherConfig{UnknownAlerts: tt.policy}, zerolog.Nop()).classify(alert)
+			require.Equal(t, tt.wantSend, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAlertClassifier_configCategories(t *testing.T) {
+	classifier := newAlertClassifier(config.WeatherConfig{AlertCategories: map[string]string{
+		"red flag warning":          "Warning",
+		"Child Abduction Emergency": "warning",
+	}}, zerolog.Nop())
+
+	got, _ := classifier.classify(provider.Alert{Event: "Red Flag Warning"})
+	require.Equal(t, eventTypeWarning, got)
+	got, _ = classifier.classify(provider.Alert{Event: "Child Abduction Emergency"})
+	require.Equal(t, eventTypeWarning, got)
+	// the defaults not overridden still apply
+	got, _ = classifier.classify(provider.Alert{Event: "Freeze Warning", Severity: provider.SeveritySevere, Certainty: provider.CertaintyLikely})
+	require.Equal(t, eventTypeAdvisory, got)
+}
@@ -0,0 +1,254 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"github.com/uptrace/bun"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	// defaultAlertDescriptionLength is how much of an alert's description chats see unless they pick a length
+	defaultAlertDescriptionLength = 512
+	// maxAlertDescriptionLength leaves room in the telegram message for the rest of the alert
+	maxAlertDescriptionLength = 3000
+	maxAlertTemplateLength    = 2048
+)
+
+// alertCategoryRanks orders the event types for a chat's minimum category, unknown alerts rank below them all
+var alertCategoryRanks = map[eventType]int{
+	eventTypeUnknown:  0,
+	eventTypeAdvisory: 1,
+	eventTypeWatch:    2,
+	eventTypeWarning:  3,
+}
+
+// sampleAlert is rendered to check a chat's template and to preview it
+var sampleAlert = tgWeatherAlert{
+	Alert: provider.Alert{
+		Sender:      "NWS Los Angeles CA",
+		Event:       "Heat Advisory",
+		Headline:    "Heat Advisory issued July 5 at 3:00AM PDT until July 5 at 8:00PM PDT",
+		Start:       time.Date(2024, 7, 5, 10, 0, 0, 0, time.UTC),
+		End:         time.Date(2024, 7, 6, 3, 0, 0, 0, time.UTC),
+		Description: "* WHAT...Temperatures up to 105 expected.\n\n* WHERE...Los Angeles County valleys.\n\n* IMPACTS...Hot temperatures may cause heat illnesses.",
+		Instruction: "Drink plenty of fluids and stay out of the sun.",
+		Tags:        []string{"Extreme temperature value"},
+		Urgency:     provider.UrgencyExpected,
+		Certainty:   provider.CertaintyLikely,
+	},
+	WeatherPollingLocations: dbmodels.WeatherPollingLocations{Key: "us-90210", Name: "Beverly Hills, US"},
+	Category:                eventTypeAdvisory,
+}
+
+// parseMinCategory parses the lowest event type a chat wants, all for every alert
+func parseMinCategory(raw string) (eventType, error) {
+	switch category := eventType(strings.ToLower(raw)); category {
+	case "all":
+		return "", nil
+	case eventTypeWarning, eventTypeWatch, eventTypeAdvisory:
+		return category, nil
+	default:
+		return "", fmt.Errorf("unknown category %s, use %s, %s, %s or all", raw, eventTypeWarning, eventTypeWatch, eventTypeAdvisory)
+	}
+}
+
+// getAlertSettings returns the chat's alert settings, the defaults if it hasn't changed any
+func getAlertSettings(ctx context.Context, dbConn bun.IDB, chatId int64) (dbmodels.WeatherAlertSettings, error) {
+	settings := dbmodels.WeatherAlertSettings{}
+	if err := dbConn.NewSelect().Model(&settings).Where("chat_id = ?", chatId).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dbmodels.WeatherAlertSettings{ChatID: chatId}, nil
+		}
+		return dbmodels.WeatherAlertSettings{}, fmt.Errorf("failed to get chat alert settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+func saveAlertSettings(ctx context.Context, dbConn bun.IDB, settings dbmodels.WeatherAlertSettings) error {
+	_, err := dbConn.NewInsert().Model(&settings).
+		On("CONFLICT(chat_id) DO UPDATE").
+		Set("include_events = EXCLUDED.include_events").
+		Set("exclude_events = EXCLUDED.exclude_events").
+		Set("min_category = EXCLUDED.min_category").
+		Set("description_length = EXCLUDED.description_length").
+		Set("template = EXCLUDED.template").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save chat alert settings: %w", err)
+	}
+
+	return nil
+}
+
+// alertAllowed checks the alert's event against the chat's include and exclude lists, and its category against the
+// chat's minimum
+func alertAllowed(settings dbmodels.WeatherAlertSettings, event string, category eventType) bool {
+	if len(settings.IncludeEvents) > 0 && !containsEvent(settings.IncludeEvents, event) {
+		return false
+	}
+	if containsEvent(settings.ExcludeEvents, event) {
+		return false
+	}
+	if settings.MinCategory != "" && alertCategoryRanks[category] < alertCategoryRanks[eventType(settings.MinCategory)] {
+		return false
+	}
+
+	return true
+}
+
+// containsEvent matches event names regardless of case, as providers aren't consistent about it
+func containsEvent(events []string, event string) bool {
+	return slices.ContainsFunc(events, func(e string) bool {
+		return strings.EqualFold(e, event)
+	})
+}
+
+// parseAlertTemplate parses a chat's alert template, and renders the sample alert with it so templates using fields
+// that don't exist are caught when they're set rather than when an alert comes in
+func parseAlertTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("alert template is empty")
+	}
+	if len(text) > maxAlertTemplateLength {
+		return nil, fmt.Errorf("alert template is %d characters, it can be at most %d", len(text), maxAlertTemplateLength)
+	}
+
+	tmpl, err := parseTemplate("chatalert", text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert template: %w", err)
+	}
+
+	rendered, err := renderAlert(tmpl, sampleAlert, maxAlertDescriptionLength)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert template: %w", err)
+	}
+	if strings.TrimSpace(rendered) == "" {
+		return nil, fmt.Errorf("alert template renders an empty message")
+	}
+	if err := notifications.ValidateTelegramHTML(rendered); err != nil {
+		return nil, fmt.Errorf("alert template renders HTML telegram can't send: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// renderAlert renders the alert with its description cut to the length
+func renderAlert(tmpl *template.Template, alert tgWeatherAlert, descriptionLength int) (string, error) {
+	alert.Description = util.TruncateString(alert.Description, descriptionLength, "...")
+
+	msgBuffer := bytes.Buffer{}
+	if err := tmpl.Execute(&msgBuffer, alert); err != nil {
+		return "", fmt.Errorf("failed to render weather alert: %w", err)
+	}
+
+	return msgBuffer.String(), nil
+}
+
+// alertFilter applies each chat's alert settings to the alerts delivered to it
+type alertFilter struct {
+	dbConn          bun.IDB
+	defaultTemplate *template.Template
+	logger          zerolog.Logger
+
+	// templates are the chats' parsed templates, by chat id, along with the text they were parsed from
+	templates   map[int64]chatAlertTemplate
+	templatelck sync.Mutex
+}
+
+type chatAlertTemplate struct {
+	text string
+	tmpl *template.Template
+}
+
+func newAlertFilter(dbConn bun.IDB, logger zerolog.Logger) (*alertFilter, error) {
+	defaultTemplate, err := parseTemplate("weatheralert", msgTemplateStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse weather alert template: %w", err)
+	}
+
+	return &alertFilter{
+		dbConn:          dbConn,
+		defaultTemplate: defaultTemplate,
+		logger:          logger,
+		templates:       make(map[int64]chatAlertTemplate),
+	}, nil
+}
+
+// chatTemplate returns the chat's parsed template, parsing it again only when the chat changes it. A template that
+// no longer works is replaced by the default until it's changed.
+func (a *alertFilter) chatTemplate(chatId int64, text string) *template.Template {
+	a.templatelck.Lock()
+	defer a.templatelck.Unlock()
+
+	if cached, ok := a.templates[chatId]; ok && cached.text == text {
+		return cached.tmpl
+	}
+
+	tmpl, err := parseAlertTemplate(text)
+	if err != nil {
+		a.logger.Warn().Err(err).Int64("chat_id", chatId).Msg("Chat alert template no longer works, using the default")
+		tmpl = a.defaultTemplate
+	}
+	a.templates[chatId] = chatAlertTemplate{text: text, tmpl: tmpl}
+
+	return tmpl
+}
+
+// filter leaves the chat out of alerts its settings don't allow, and renders the rest with the chat's template and
+// description length. If the settings can't be applied the alert is delivered as published, a chat getting an alert
+// it didn't want is better than missing one it did.
+func (a *alertFilter) filter(ctx context.Context, chatId int64, msg notifications.Message) (notifications.Message, bool) {
+	alert, ok := notifications.MessageData[tgWeatherAlert](msg)
+	if !ok {
+		return msg, true
+	}
+
+	// the end of an alert strikes through the message already in the chat, which there's only one of if the chat
+	// got the alert
+	if msg.Action == notifications.EventActionCancel {
+		return msg, true
+	}
+
+	settings, err := getAlertSettings(ctx, a.dbConn, chatId)
+	if err != nil {
+		a.logger.Error().Err(err).Int64("chat_id", chatId).Msg("Failed to get alert settings, delivering the alert as is")
+		return msg, true
+	}
+
+	if !alertAllowed(settings, alert.Event, alert.Category) {
+		a.logger.Trace().Int64("chat_id", chatId).Msgf("%s left out by the chat's alert settings", alert.Event)
+		return msg, false
+	}
+
+	if settings.Template == "" && settings.DescriptionLength == 0 {
+		return msg, true
+	}
+
+	tmpl := a.defaultTemplate
+	if settings.Template != "" {
+		tmpl = a.chatTemplate(chatId, settings.Template)
+	}
+
+	rendered, err := renderAlert(tmpl, alert, util.FirstNonZero(settings.DescriptionLength, defaultAlertDescriptionLength))
+	if err != nil {
+		a.logger.Error().Err(err).Int64("chat_id", chatId).Msg("Failed to render alert for chat, delivering the alert as is")
+		return msg, true
+	}
+	msg.Msg = rendered
+
+	return msg, true
+}
@@ -0,0 +1,129 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"testing"
+)
+
+func Test_alertAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings dbmodels.WeatherAlertSettings
+		event    string
+		category eventType
+		want     bool
+	}{
+		{name: "defaults", event: "Heat Advisory", category: eventTypeAdvisory, want: true},
+		{name: "excluded", settings: dbmodels.WeatherAlertSettings{ExcludeEvents: []string{"heat advisory"}},
+			event: "Heat Advisory", category: eventTypeAdvisory, want: false},
+		{name: "not included", settings: dbmodels.WeatherAlertSettings{IncludeEvents: []string{"Tornado Warning"}},
+			event: "Heat Advisory", category: eventTypeAdvisory, want: false},
+		{name: "included", settings: dbmodels.WeatherAlertSettings{IncludeEvents: []string{"Tornado Warning"}},
+			event: "Tornado Warning", category: eventTypeWarning, want: true},
+		{name: "below min", settings: dbmodels.WeatherAlertSettings{MinCategory: "watch"},
+			event: "Heat Advisory", category: eventTypeAdvisory, want: false},
+		{name: "at min", settings: dbmodels.WeatherAlertSettings{MinCategory: "watch"},
+			event: "Tornado Watch", category: eventTypeWatch, want: true},
+		{name: "unknown below advisory", settings: dbmodels.WeatherAlertSettings{MinCategory: "advisory"},
+			event: "Space Weather", category: eventTypeUnknown, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, alertAllowed(tt.settings, tt.event, tt.category))
+		})
+	}
+}
+
+func Test_parseAlertTemplate(t *testing.T) {
+	tmpl, err := parseAlertTemplate("<b>{{.Event | escape}}</b> ({{.Category}}) in {{.Name}}")
+	require.NoError(t, err)
+
+	rendered, err := renderAlert(tmpl, sampleAlert, defaultAlertDescriptionLength)
+	require.NoError(t, err)
+	require.Equal(t, "<b>Heat Advisory</b> (advisory) in Beverly Hills, US", rendered)
+
+	_, err = parseAlertTemplate("{{.Event")
+	require.ErrorContains(t, err, "invalid alert template")
+
+	// fields that don't exist only fail when the template is executed
+	_, err = parseAlertTemplate("{{.Nope}}")
+	require.ErrorContains(t, err, "invalid alert template")
+
+	_, err = parseAlertTemplate("{{if false}}x{{end}}")
+	require.ErrorContains(t, err, "empty message")
+
+	// telegram would refuse every alert rendered with these
+	_, err = parseAlertTemplate("<b>{{.Event}}")
+	require.ErrorContains(t, err, "never closed")
+	_, err = parseAlertTemplate("{{.Event}} < {{.Name}}")
+	require.ErrorContains(t, err, "unescaped")
+
+	// the built in template passes the same checks
+	_, err = parseAlertTemplate(msgTemplateStr)
+	require.NoError(t, err)
+}
+
+func Test_argsAfter(t *testing.T) {
+	require.Equal(t, "<b>{{.Event}}</b>\n{{.Description}}",
+		argsAfter("alerts template  set <b>{{.Event}}</b>\n{{.Description}}\n", 3))
+	require.Empty(t, argsAfter("alerts template preview", 3))
+}
+
+func TestAlertFilter(t *testing.T) {
+	ctx := context.Background()
+	dbConn := newPollerTestDb(t)
+	filter, err := newAlertFilter(dbConn, zerolog.Nop())
+	require.NoError(t, err)
+
+	msg := notifications.Message{Topic: "weather.us-90210.advisory", Msg: "published", Data: sampleAlert}
+
+	// chats that haven't changed anything get the alert as published
+	filtered, ok := filter.filter(ctx, 12345, msg)
+	require.True(t, ok)
+	require.Equal(t, "published", filtered.Msg)
+
+	require.NoError(t, saveAlertSettings(ctx, dbConn, dbmodels.WeatherAlertSettings{
+		ChatID:            12345,
+		DescriptionLength: 10,
+		Template:          "{{.Event}}: {{.Description}}",
+	}))
+	filtered, ok = filter.filter(ctx, 12345, msg)
+	require.True(t, ok)
+	require.Equal(t, "Heat Advisory: * WHAT...T...", filtered.Msg)
+
+	// an alert read back from the inbox after a restart is rendered the same
+	stored, err := json.Marshal(sampleAlert)
+	require.NoError(t, err)
+	replayed := msg
+	replayed.Data = notifications.StoredData(stored)
+	filtered, ok = filter.filter(ctx, 12345, replayed)
+	require.True(t, ok)
+	require.Equal(t, "Heat Advisory: * WHAT...T...", filtered.Msg)
+
+	// the template is parsed once, until the chat changes it
+	cached := filter.templates[12345].tmpl
+	_, _ = filter.filter(ctx, 12345, msg)
+	require.Same(t, cached, filter.templates[12345].tmpl)
+
+	_, err = updateAlertSettings(ctx, dbConn, 12345, func(settings *dbmodels.WeatherAlertSettings) error {
+		settings.ExcludeEvents = []string{"Heat Advisory"}
+		return nil
+	})
+	require.NoError(t, err)
+	_, ok = filter.filter(ctx, 12345, msg)
+	require.False(t, ok)
+
+	// the end of an alert still strikes through the message if the chat got it before changing its settings
+	_, ok = filter.filter(ctx, 12345, notifications.Message{Topic: "weather.us-90210.advisory.ended",
+		Action: notifications.EventActionCancel, Data: sampleAlert})
+	require.True(t, ok)
+
+	// messages that aren't alerts are left alone
+	_, ok = filter.filter(ctx, 12345, notifications.Message{Topic: "weather.us-90210.briefing", Msg: "briefing"})
+	require.True(t, ok)
+}
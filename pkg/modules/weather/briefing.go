@@ -0,0 +1,257 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/uptrace/bun"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// briefingGracePeriod is how late a briefing is still sent, so a restart around its time doesn't skip it but a
+// bot that was down all morning doesn't send it in the afternoon
+const briefingGracePeriod = time.Hour
+
+//go:embed weatherbriefing.tmpl
+var briefingTemplateStr string
+
+// briefingRenderer renders the briefing of a location, the day's forecast and its active alerts
+type briefingRenderer struct {
+	provider    provider.Provider
+	msgTemplate *template.Template
+}
+
+func newBriefingRenderer(weatherProvider provider.Provider) (*briefingRenderer, error) {
+	msgTemplate, err := parseTemplate("weatherbriefing", briefingTemplateStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse briefing template: %w", err)
+	}
+
+	return &briefingRenderer{provider: weatherProvider, msgTemplate: msgTemplate}, nil
+}
+
+// briefingForecast is what a location's briefing is rendered from, fetched once for every chat briefed at the same time
+type briefingForecast struct {
+	Periods []provider.ForecastPeriod
+	Alerts  []provider.Alert
+}
+
+func (b *briefingRenderer) fetch(ctx context.Context, location dbmodels.WeatherPollingLocations) (briefingForecast, error) {
+	providerLocation := provider.Location{Latitude: location.Lat, Longitude: location.Lon}
+	periods, err := b.provider.Forecast(ctx, providerLocation)
+	if err != nil {
+		return briefingForecast{}, fmt.Errorf("failed to get forecast: %w", err)
+	}
+
+	alerts, err := b.provider.Alerts(ctx, providerLocation)
+	if err != nil {
+		return briefingForecast{}, fmt.Errorf("failed to get alerts: %w", err)
+	}
+
+	return briefingForecast{Periods: periods, Alerts: alerts}, nil
+}
+
+func (b *briefingRenderer) render(ctx context.Context, location dbmodels.WeatherPollingLocations, tz *time.Location, chatUnits units, now time.Time) (string, error) {
+	forecast, err := b.fetch(ctx, location)
+	if err != nil {
+		return "", err
+	}
+
+	return b.renderForecast(location, forecast, tz, chatUnits, now)
+}
+
+func (b *briefingRenderer) renderForecast(location dbmodels.WeatherPollingLocations, forecast briefingForecast, tz *time.Location, chatUnits units, now time.Time) (string, error) {
+	msgBuffer := bytes.Buffer{}
+	err := b.msgTemplate.Execute(&msgBuffer, tgWeatherBriefing{
+		WeatherPollingLocations: location,
+		Units:                   chatUnits,
+		Date:                    startOfDay(now.In(tz)),
+		Rows:                    selectForecastRows(forecast.Periods, forecastRequest{View: forecastDaily, Days: 1}, now),
+		Alerts:                  forecast.Alerts,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render briefing: %w", err)
+	}
+
+	return msgBuffer.String(), nil
+}
+
+// briefingScheduler publishes each chat's briefing once a day at the chat's time, in the chat's units. Each briefing
+// goes to the chat's own topic under the location's briefing topic, see briefingTopic.
+type briefingScheduler struct {
+	publisher notifications.Publisher
+	renderer  *briefingRenderer
+	dbConn    bun.IDB
+	logger    zerolog.Logger
+
+	ctxCf func()
+	wg    sync.WaitGroup
+}
+
+func newBriefingScheduler(publisher notifications.Publisher, renderer *briefingRenderer, dbConn bun.IDB, logger zerolog.Logger) *briefingScheduler {
+	return &briefingScheduler{
+		publisher: publisher,
+		renderer:  renderer,
+		dbConn:    dbConn,
+		logger:    logger,
+	}
+}
+
+func (b *briefingScheduler) Start(ctx context.Context) {
+	ctx, cf := context.WithCancel(ctx)
+	b.ctxCf = cf
+
+	b.wg.Add(1)
+	go func(ctx context.Context) {
+		defer b.wg.Done()
+		b.run(ctx)
+	}(ctx)
+}
+
+func (b *briefingScheduler) Stop() {
+	b.ctxCf()
+	b.wg.Wait()
+}
+
+func (b *briefingScheduler) run(ctx context.Context) {
+	tick := time.NewTicker(time.Minute)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.logger.Debug().Msg("Context done, stopping briefing scheduler")
+			return
+		case now := <-tick.C:
+			if err := b.sendDueBriefings(ctx, now); err != nil {
+				b.logger.Error().Err(err).Msg("Failed to send briefings")
+			}
+		}
+	}
+}
+
+// sendDueBriefings publishes the briefings whose time has come and marks them sent
+func (b *briefingScheduler) sendDueBriefings(ctx context.Context, now time.Time) error {
+	var briefings []dbmodels.WeatherBriefings
+	if err := b.dbConn.NewSelect().Model(&briefings).Relation("Location").Scan(ctx); err != nil {
+		return fmt.Errorf("failed to get briefings: %w", err)
+	}
+
+	// chats briefed about the same location at the same time share the provider calls
+	forecasts := make(map[int]briefingForecast)
+	for _, briefing := range briefings {
+		scheduled, due, err := briefingDue(briefing, now)
+		if err != nil {
+			b.logger.Error().Err(err).Msgf("Invalid briefing %d", briefing.ID)
+			continue
+		} else if !due {
+			continue
+		}
+
+		forecast, ok := forecasts[briefing.LocationID]
+		if !ok {
+			if forecast, err = b.renderer.fetch(ctx, *briefing.Location); err != nil {
+				b.logger.Error().Err(err).Msgf("Failed to get the briefing forecast for %s", briefing.Location.Key)
+				continue
+			}
+			forecasts[briefing.LocationID] = forecast
+		}
+
+		if err := b.publishBriefing(ctx, briefing, forecast, scheduled, now); err != nil {
+			b.logger.Error().Err(err).Msgf("Failed to publish briefing for %s to chat %d", briefing.Location.Key, briefing.ChatID)
+			continue
+		}
+
+		_, err = b.dbConn.NewUpdate().Model(&briefing).Set("last_sent_at = ?", now).WherePK().Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to mark briefing %d sent: %w", briefing.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (b *briefingScheduler) publishBriefing(ctx context.Context, briefing dbmodels.WeatherBriefings, forecast briefingForecast, scheduled time.Time, now time.Time) error {
+	chatUnits, err := getChatUnits(ctx, b.dbConn, briefing.ChatID)
+	if err != nil {
+		return err
+	}
+
+	msg, err := b.renderer.renderForecast(*briefing.Location, forecast, scheduled.Location(), chatUnits, now)
+	if err != nil {
+		return err
+	}
+
+	b.logger.Debug().Msgf("Publishing briefing for %s to chat %d", briefing.Location.Key, briefing.ChatID)
+	return b.publisher.Publish(ctx, notifications.Message{
+		Topic:    briefingTopic(briefing.Location.Key, briefing.ChatID),
+		Msg:      msg,
+		DupeKey:  fmt.Sprintf("briefing_%s_%d_%s", briefing.Location.Key, briefing.ChatID, scheduled.Format(time.DateOnly)),
+		DupeTTL:  24 * time.Hour,
+		Priority: eventTypeBriefing.priority(),
+
+		ParseMode:          tgbotapi.ModeHTML,
+		Buttons:            [][]notifications.Button{{{Text: "Details", URL: forecastDetailsURL(*briefing.Location)}}},
+		DisableLinkPreview: true,
+	})
+}
+
+// briefingTopic is the topic a chat's briefing of the location is published to. It's under the location's briefing
+// topic, which the chat subscribes to, and names the chat so briefingFilter can keep it to that chat.
+func briefingTopic(locationKey string, chatId int64) string {
+	return fmt.Sprintf("%s.%d", eventTypeBriefing.fullTopicPath(locationKey), chatId)
+}
+
+// briefingFilter leaves every chat but the one it was rendered for out of a briefing, so chats sharing a location
+// or subscribed to all of weather.* don't get briefings at other chats' times and units
+func briefingFilter(_ context.Context, chatId int64, msg notifications.Message) (notifications.Message, bool) {
+	parts := strings.Split(msg.Topic, ".")
+	if len(parts) != 4 || parts[2] != eventTypeBriefing.String() {
+		return msg, true
+	}
+
+	return msg, parts[3] == strconv.FormatInt(chatId, 10)
+}
+
+// briefingDue returns when the briefing is scheduled today in its timezone, and whether it should be sent now.
+// time.Date moves a time skipped by a DST change forward by the length of the change.
+func briefingDue(briefing dbmodels.WeatherBriefings, now time.Time) (time.Time, bool, error) {
+	tz, err := time.LoadLocation(briefing.TZ)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to load timezone %s: %w", briefing.TZ, err)
+	}
+
+	timeOfDay, err := parseBriefingTime(briefing.Time)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	localNow := now.In(tz)
+	scheduled := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), timeOfDay.Hour(), timeOfDay.Minute(), 0, 0, tz)
+	due := !now.Before(scheduled) && now.Sub(scheduled) < briefingGracePeriod && briefing.LastSentAt.Before(scheduled)
+
+	return scheduled, due, nil
+}
+
+func parseBriefingTime(raw string) (time.Time, error) {
+	timeOfDay, err := time.Parse("15:04", raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s, use HH:MM", raw)
+	}
+
+	return timeOfDay, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,215 @@
+package weather
+
+import (
+	"context"
+	"database/sql"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/bot/models/tgapi"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/modules"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/sqlmigrate"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_briefingDue(t *testing.T) {
+	briefing := dbmodels.WeatherBriefings{Time: "07:00", TZ: "America/New_York"}
+
+	tests := []struct {
+		name          string
+		now           time.Time
+		lastSentAt    time.Time
+		wantScheduled time.Time
+		wantDue       bool
+	}{
+		{name: "standard time", now: time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC),
+			wantScheduled: time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC), wantDue: true},
+		{name: "daylight time", now: time.Date(2024, 3, 10, 11, 0, 30, 0, time.UTC),
+			wantScheduled: time.Date(2024, 3, 10, 11, 0, 0, 0, time.UTC), wantDue: true},
+		{name: "too early", now: time.Date(2024, 3, 10, 10, 59, 0, 0, time.UTC),
+			wantScheduled: time.Date(2024, 3, 10, 11, 0, 0, 0, time.UTC)},
+		{name: "too late", now: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+			wantScheduled: time.Date(2024, 3, 10, 11, 0, 0, 0, time.UTC)},
+		{name: "already sent", now: time.Date(2024, 3, 10, 11, 5, 0, 0, time.UTC), lastSentAt: time.Date(2024, 3, 10, 11, 1, 0, 0, time.UTC),
+			wantScheduled: time.Date(2024, 3, 10, 11, 0, 0, 0, time.UTC)},
+		{name: "sent yesterday", now: time.Date(2024, 3, 10, 11, 5, 0, 0, time.UTC), lastSentAt: time.Date(2024, 3, 9, 12, 1, 0, 0, time.UTC),
+			wantScheduled: time.Date(2024, 3, 10, 11, 0, 0, 0, time.UTC), wantDue: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			briefing.LastSentAt = tt.lastSentAt
+			scheduled, due, err := briefingDue(briefing, tt.now)
+			require.NoError(t, err)
+			require.True(t, tt.wantScheduled.Equal(scheduled), scheduled)
+			require.Equal(t, tt.wantDue, due)
+		})
+	}
+
+	_, _, err := briefingDue(dbmodels.WeatherBriefings{Time: "25:00", TZ: "America/New_York"}, time.Now())
+	require.Error(t, err)
+}
+
+type TestBriefingSuite struct {
+	suite.Suite
+
+	dbConn   *bun.DB
+	location dbmodels.WeatherPollingLocations
+}
+
+func (t *TestBriefingSuite) SetupTest() {
+	sqlDb, err := sql.Open(sqliteshim.ShimName, "file::memory:?cache=shared")
+	require.NoError(t.T(), err)
+
+	t.dbConn = bun.NewDB(sqlDb, sqlitedialect.New())
+	_, err = sqlmigrate.MigrateDbSchema(context.Background(), t.dbConn)
+	require.NoError(t.T(), err)
+
+	t.location = dbmodels.WeatherPollingLocations{Name: "Houston", Country: "US", Key: "us-77093", Lat: 29.8131, Lon: -95.3098, Polling: true}
+	_, err = t.dbConn.NewInsert().Model(&t.location).Exec(context.Background())
+	require.NoError(t.T(), err)
+}
+
+func (t *TestBriefingSuite) TearDownTest() {
+	require.NoError(t.T(), t.dbConn.Close())
+}
+
+func (t *TestBriefingSuite) mockForecast(mockProvider *provider.MockProvider, day time.Time) {
+	mockProvider.EXPECT().Forecast(mock.Anything, mock.Anything).Return([]provider.ForecastPeriod{
+		{Name: "Today", Start: day, End: day.Add(18 * time.Hour), TempHigh: 35, TempLow: 35, PrecipitationChance: 20, Summary: "Sunny"},
+		{Name: "Tomorrow", Start: day.AddDate(0, 0, 1), End: day.AddDate(0, 0, 2), TempHigh: 36, TempLow: 36},
+	}, nil).Once()
+	mockProvider.EXPECT().Alerts(mock.Anything, mock.Anything).Return([]provider.Alert{
+		{Event: "Heat Advisory", End: day.Add(20 * time.Hour)},
+	}, nil).Once()
+}
+
+func (t *TestBriefingSuite) Test_SendDueBriefings() {
+	ctx := context.Background()
+	for chatId, timeOfDay := range map[int64]string{1: "07:00", 2: "08:00"} {
+		_, err := t.dbConn.NewInsert().Model(&dbmodels.WeatherBriefings{
+			ChatID: chatId, LocationID: t.location.ID, Time: timeOfDay, TZ: "America/Chicago",
+		}).Exec(ctx)
+		require.NoError(t.T(), err)
+	}
+	require.NoError(t.T(), setChatUnits(ctx, t.dbConn, 2, unitsImperial))
+
+	tz, err := time.LoadLocation("America/Chicago")
+	require.NoError(t.T(), err)
+	day := time.Date(2024, 7, 5, 0, 0, 0, 0, tz)
+
+	mockProvider := provider.NewMockProvider(t.T())
+	t.mockForecast(mockProvider, day)
+	t.mockForecast(mockProvider, day)
+
+	mockPublisher := notifications.NewMockPublisher(t.T())
+	mockPublisher.EXPECT().Publish(mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return msg.Topic == "weather.us-77093.briefing.1" &&
+			msg.DupeKey == "briefing_us-77093_1_2024-07-05" &&
+			strings.Contains(msg.Msg, "<b>Houston</b> briefing for Friday, Jul 5") &&
+			strings.Contains(msg.Msg, "<b>Today:</b> 35°C, 20% chance of precipitation") &&
+			!strings.Contains(msg.Msg, "Tomorrow") &&
+			strings.Contains(msg.Msg, "• Heat Advisory until Fri 20:00 CDT")
+	})).Return(nil).Once()
+	// the second chat shares the location but gets its own briefing, at its time in its units
+	mockPublisher.EXPECT().Publish(mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return msg.Topic == "weather.us-77093.briefing.2" &&
+			msg.DupeKey == "briefing_us-77093_2_2024-07-05" &&
+			strings.Contains(msg.Msg, "<b>Today:</b> 95°F")
+	})).Return(nil).Once()
+
+	renderer, err := newBriefingRenderer(mockProvider)
+	require.NoError(t.T(), err)
+	scheduler := newBriefingScheduler(mockPublisher, renderer, t.dbConn, zerolog.Nop())
+
+	// too early for either chat
+	require.NoError(t.T(), scheduler.sendDueBriefings(ctx, day.Add(6*time.Hour)))
+	// the first chat's briefing is published once
+	require.NoError(t.T(), scheduler.sendDueBriefings(ctx, day.Add(7*time.Hour+time.Minute)))
+	require.NoError(t.T(), scheduler.sendDueBriefings(ctx, day.Add(7*time.Hour+2*time.Minute)))
+	require.NoError(t.T(), scheduler.sendDueBriefings(ctx, day.Add(8*time.Hour)))
+
+	var briefings []dbmodels.WeatherBriefings
+	require.NoError(t.T(), t.dbConn.NewSelect().Model(&briefings).Order("chat_id").Scan(ctx))
+	require.True(t.T(), briefings[0].LastSentAt.Equal(day.Add(7*time.Hour+time.Minute)))
+	require.True(t.T(), briefings[1].LastSentAt.Equal(day.Add(8*time.Hour)))
+}
+
+func Test_briefingFilter(t *testing.T) {
+	msg := notifications.Message{Topic: briefingTopic("us-77093", 12345)}
+
+	_, ok := briefingFilter(context.Background(), 12345, msg)
+	require.True(t, ok)
+	// chats subscribed to the location's briefings or all of weather.* only get their own
+	_, ok = briefingFilter(context.Background(), 54321, msg)
+	require.False(t, ok)
+
+	_, ok = briefingFilter(context.Background(), 54321, notifications.Message{Topic: "weather.us-77093.warning"})
+	require.True(t, ok)
+}
+
+func (t *TestBriefingSuite) Test_BriefingSetAndOff() {
+	ctx := context.Background()
+	_, err := t.dbConn.NewInsert().Model(&dbmodels.WeatherPollerChats{ChatID: 12345, PollerLocationID: t.location.ID}).Exec(ctx)
+	require.NoError(t.T(), err)
+
+	tz, err := time.LoadLocation("America/Chicago")
+	require.NoError(t.T(), err)
+
+	mockProvider := provider.NewMockProvider(t.T())
+	mockProvider.EXPECT().Timezone(mock.Anything, provider.Location{Latitude: 29.8131, Longitude: -95.3098}).Return(tz, nil).Once()
+
+	mockPublisher := notifications.NewMockPublisher(t.T())
+	mockPublisher.EXPECT().Subscribe(notifications.Subscriber{ChatId: 12345, TopicPattern: "weather.us-77093.briefing"}).
+		Return("sub", nil).Once()
+
+	mockBot := proxy.NewMockTGBotImplementation(t.T())
+	mockBot.EXPECT().Send(mock.MatchedBy(func(msg tgbotapi.MessageConfig) bool {
+		return msg.Text == "The briefing for Houston will be sent daily at 07:30 America/Chicago to weather.us-77093.briefing"
+	})).Return(tgbotapi.Message{}, nil).Once()
+
+	briefingCmd, err := newWeatherCmdBriefing(modules.InitializeParameters{
+		DbConn: t.dbConn, Notifications: mockPublisher, Logger: zerolog.Nop(),
+	}, mockProvider)
+	require.NoError(t.T(), err)
+
+	msg := tgapi.NewTGBotMsg(&tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 12345}}, tgapi.TGBotAssumedIds{ChatID: 12345}, nil)
+	err = briefingCmd.Execute(ctx, models.CommandParams{Args: []string{"set", "7:30"}, Message: msg, BotProxy: mockBot})
+	require.NoError(t.T(), err)
+
+	var briefing dbmodels.WeatherBriefings
+	require.NoError(t.T(), t.dbConn.NewSelect().Model(&briefing).Where("chat_id = ?", 12345).Scan(ctx))
+	require.Equal(t.T(), "07:30", briefing.Time)
+	require.Equal(t.T(), "America/Chicago", briefing.TZ)
+
+	mockPublisher.EXPECT().GetSubscriptions(int64(12345)).Return([]dbmodels.Subscriptions{
+		{ChatID: 12345, TopicPattern: "weather.us-77093.warning"},
+		{ChatID: 12345, TopicPattern: "weather.us-77093.briefing"},
+	}, nil).Once()
+	mockPublisher.EXPECT().Unsubscribe(mock.Anything, int64(12345)).Return(nil).Once()
+	mockBot.EXPECT().Send(mock.MatchedBy(func(msg tgbotapi.MessageConfig) bool {
+		return msg.Text == "Turned off 1 briefing(s)"
+	})).Return(tgbotapi.Message{}, nil).Once()
+
+	err = briefingCmd.Execute(ctx, models.CommandParams{Args: []string{"off", "us-77093"}, Message: msg, BotProxy: mockBot})
+	require.NoError(t.T(), err)
+
+	count, err := t.dbConn.NewSelect().Model((*dbmodels.WeatherBriefings)(nil)).Count(ctx)
+	require.NoError(t.T(), err)
+	require.Zero(t.T(), count)
+}
+
+func Test_RunBriefingSuite(t *testing.T) {
+	suite.Run(t, new(TestBriefingSuite))
+}
@@ -2,7 +2,6 @@ package weather
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
@@ -10,7 +9,7 @@ import (
 	"github.com/tomato3017/tomatobot/pkg/command/middleware"
 	"github.com/tomato3017/tomatobot/pkg/command/models"
 	"github.com/tomato3017/tomatobot/pkg/modules"
-	"github.com/tomato3017/tomatobot/pkg/modules/weather/owm"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
 	"github.com/tomato3017/tomatobot/pkg/notifications"
 	"github.com/tomato3017/tomatobot/pkg/util"
 	"github.com/uptrace/bun"
@@ -19,38 +18,41 @@ import (
 
 type weatherCmdAdd struct {
 	command.BaseCommand
-	owmClient owm.OpenWeatherMapIClient
+	provider provider.Provider
 
 	dbConn    bun.IDB
 	publisher notifications.Publisher
 }
 
-func newWeatherCmdAdd(params modules.InitializeParameters) *weatherCmdAdd {
-	client, err := owm.NewOpenWeatherMapClient(params.Cfg.Modules.Weather.APIKey)
-	if err != nil {
-		panic(fmt.Errorf("failed to create OWM client: %w", err))
-	}
-
+func newWeatherCmdAdd(params modules.InitializeParameters, weatherProvider provider.Provider) *weatherCmdAdd {
 	return &weatherCmdAdd{
 		publisher:   params.Notifications,
 		dbConn:      params.DbConn,
-		BaseCommand: command.NewBaseCommand(middleware.WithNArgs(1)),
-		owmClient:   client,
+		BaseCommand: command.NewBaseCommand(middleware.WithMinArgs(1)),
+		provider:    weatherProvider,
 	}
 }
 
 func (w *weatherCmdAdd) Execute(ctx context.Context, params models.CommandParams) error {
-	rawZipCode := params.Args[0]
-	if !usZipCodeRegex.MatchString(rawZipCode) {
-		return fmt.Errorf("invalid zip code format, must be 5 digits")
+	query, err := parseLocationQuery(strings.Join(params.Args, " "))
+	if err != nil {
+		return err
 	}
 
-	_, err := w.addWeatherLocation(ctx, rawZipCode, params.Message.AssumedChatID())
-	if err != nil {
+	location, _, err := w.addWeatherLocation(ctx, query, params.Message.AssumedChatID())
+	var ambiguousErr *ambiguousLocationError
+	if errors.As(err, &ambiguousErr) {
+		_, err = params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", ambiguousErr.Error()))
+		if err != nil {
+			return fmt.Errorf("failed to send reply: %w", err)
+		}
+		return nil
+	} else if err != nil {
 		return fmt.Errorf("failed to add weather location: %w", err)
 	}
 
-	_, err = params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", "Location added successfully"))
+	reply := fmt.Sprintf("Added %s as %s", util.FirstNonZero(location.Name, location.Key), location.Key)
+	_, err = params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", reply))
 	if err != nil {
 		return fmt.Errorf("failed to send reply: %w", err)
 	}
@@ -58,41 +60,62 @@ func (w *weatherCmdAdd) Execute(ctx context.Context, params models.CommandParams
 	return nil
 }
 
-func (w *weatherCmdAdd) addWeatherLocation(ctx context.Context, zipCode string, chatId int64) ([]string, error) {
-	//get the geo location of the zip code
+func (w *weatherCmdAdd) addWeatherLocation(ctx context.Context, query locationQuery, chatId int64) (dbmodels.WeatherPollingLocations, []string, error) {
+	//a place name has to be searched for before we know its key
+	var place *provider.Place
+	if query.Name != "" {
+		found, err := searchPlace(ctx, w.provider, query.Name, "/weather add")
+		if err != nil {
+			return dbmodels.WeatherPollingLocations{}, nil, err
+		}
+		place = &found
+		query = locationQuery{Coordinates: &found.Location}
+	}
 
-	//check if the zip code is in the db
+	//check if the location is in the db
+	var location dbmodels.WeatherPollingLocations
 	err := w.dbConn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
-		return w.insertWeatherPollerChat(ctx, tx, zipCode, chatId)
+		var err error
+		location, err = w.insertWeatherPollerChat(ctx, tx, query, place, chatId)
+		return err
 	})
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint") {
-			return nil, fmt.Errorf("location already exists")
+			return dbmodels.WeatherPollingLocations{}, nil, fmt.Errorf("location already exists")
 		}
-		return nil, fmt.Errorf("failed to insert weather poller chat: %w", err)
+		return dbmodels.WeatherPollingLocations{}, nil, fmt.Errorf("failed to insert weather poller chat: %w", err)
 	}
 
-	topics, err := w.addLocationToSubscriptions(chatId, zipCode)
+	topics, err := w.addLocationToSubscriptions(chatId, location.Key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to add location to subscriptions: %w", err)
+		return dbmodels.WeatherPollingLocations{}, nil, fmt.Errorf("failed to add location to subscriptions: %w", err)
 	}
-	return topics, nil
+	return location, topics, nil
 }
 
-func (w *weatherCmdAdd) populateZipGeoLoc(ctx context.Context, tx bun.IDB, zipCode string) (dbmodels.WeatherPollingLocations, error) {
-	//we need to pull from the OWM API the zipcode and insert it into the database
-	geoLoc, err := w.owmClient.GetLocationDataForZipCode(ctx, zipCode)
-	if err != nil {
-		return dbmodels.WeatherPollingLocations{}, fmt.Errorf("failed to get location data for zip code: %w", err)
+// populateGeoLoc inserts the location of the query, geocoding postal codes with the provider. Coordinates are named
+// after the place they were found by, if any.
+func (w *weatherCmdAdd) populateGeoLoc(ctx context.Context, tx bun.IDB, query locationQuery, place *provider.Place) (dbmodels.WeatherPollingLocations, error) {
+	switch {
+	case place != nil:
+	case query.PostalCode != "":
+		geocoded, err := w.provider.Geocode(ctx, query.PostalCode, query.Country)
+		if err != nil {
+			return dbmodels.WeatherPollingLocations{}, fmt.Errorf("failed to get location data for postal code: %w", err)
+		}
+		place = &geocoded
+	default:
+		place = &provider.Place{Name: formatCoordinates(*query.Coordinates), Location: *query.Coordinates}
 	}
 
 	weatherPollingModel := dbmodels.WeatherPollingLocations{
-		Name:    geoLoc.Name,
-		Country: geoLoc.Country,
-		ZipCode: zipCode,
-		Lon:     geoLoc.Lon,
-		Lat:     geoLoc.Lat,
-		Polling: true,
+		Name:       place.Name,
+		Country:    util.FirstNonZero(place.Country, query.Country),
+		Key:        query.key(),
+		PostalCode: util.FirstNonZero(query.PostalCode, place.PostalCode),
+		Lon:        place.Longitude,
+		Lat:        place.Latitude,
+		Polling:    true,
 	}
 	if _, err := tx.NewInsert().Model(&weatherPollingModel).Exec(ctx); err != nil {
 		return dbmodels.WeatherPollingLocations{}, fmt.Errorf("failed to insert location into db: %w", err)
@@ -101,34 +124,19 @@ func (w *weatherCmdAdd) populateZipGeoLoc(ctx context.Context, tx bun.IDB, zipCo
 	return weatherPollingModel, nil
 }
 
-func (w *weatherCmdAdd) checkZipInDb(ctx context.Context, tx bun.IDB, zipCode string) (dbmodels.WeatherPollingLocations, error) {
-	geoLoc := dbmodels.WeatherPollingLocations{
-		ZipCode: zipCode,
-	}
-
-	if err := tx.NewSelect().Model(&geoLoc).Where("zip_code = ?", zipCode).Scan(ctx); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return dbmodels.WeatherPollingLocations{}, nil
-		}
-		return dbmodels.WeatherPollingLocations{}, fmt.Errorf("failed to check if zip code is in db: %w", err)
-	}
-
-	return geoLoc, nil
-}
-
 func (w *weatherCmdAdd) Description() string {
 	return "Add a location to the weather alerting"
 }
 
 func (w *weatherCmdAdd) Help() string {
-	return "/weather add <zipcode> - Add a location to the weather alerting"
+	return "/weather add <zip|postal,country|lat,lon|place name> - Add a location to the weather alerting"
 }
 
-func (w *weatherCmdAdd) addLocationToSubscriptions(chatId int64, zipCode string) ([]string, error) {
+func (w *weatherCmdAdd) addLocationToSubscriptions(chatId int64, locationKey string) ([]string, error) {
 	topics := make([]string, 0)
 
 	for _, topic := range weatherPublisherEventTypes {
-		topicName := topic.fullTopicPath(zipCode)
+		topicName := topic.fullTopicPath(locationKey)
 
 		_, err := w.publisher.Subscribe(notifications.Subscriber{
 			ChatId:       chatId,
@@ -148,24 +156,24 @@ func (w *weatherCmdAdd) addLocationToSubscriptions(chatId int64, zipCode string)
 	return topics, nil
 }
 
-func (w *weatherCmdAdd) insertWeatherPollerChat(ctx context.Context, tx bun.Tx, zipCode string, chatId int64) error {
-	weatherPollingModel, err := w.checkZipInDb(ctx, tx, zipCode)
+func (w *weatherCmdAdd) insertWeatherPollerChat(ctx context.Context, tx bun.Tx, query locationQuery, place *provider.Place, chatId int64) (dbmodels.WeatherPollingLocations, error) {
+	weatherPollingModel, err := getLocationByKey(ctx, tx, query.key())
 	if err != nil {
-		return fmt.Errorf("failed to check zip in db: %w", err)
+		return dbmodels.WeatherPollingLocations{}, fmt.Errorf("failed to check location in db: %w", err)
 	}
 
 	if weatherPollingModel.IsEmpty() {
 		//Insert a row in the db AND get the geo location
-		newWeatherPollMdl, err := w.populateZipGeoLoc(ctx, tx, zipCode)
+		newWeatherPollMdl, err := w.populateGeoLoc(ctx, tx, query, place)
 		if err != nil {
-			return fmt.Errorf("failed to populate zip geo location: %w", err)
+			return dbmodels.WeatherPollingLocations{}, fmt.Errorf("failed to populate geo location: %w", err)
 		}
 
 		weatherPollingModel = newWeatherPollMdl
 	} else if !weatherPollingModel.Polling {
 		//update the polling to true
 		if _, err := tx.NewUpdate().Model(&weatherPollingModel).Set("polling = ?", true).WherePK().Exec(ctx); err != nil {
-			return fmt.Errorf("failed to update polling: %w", err)
+			return dbmodels.WeatherPollingLocations{}, fmt.Errorf("failed to update polling: %w", err)
 		}
 	}
 
@@ -176,8 +184,8 @@ func (w *weatherCmdAdd) insertWeatherPollerChat(ctx context.Context, tx bun.Tx,
 	}
 
 	if _, err := tx.NewInsert().Model(&weatherPollerChat).Exec(ctx); err != nil {
-		return fmt.Errorf("failed to insert weather poller chat: %w", err)
+		return dbmodels.WeatherPollingLocations{}, fmt.Errorf("failed to insert weather poller chat: %w", err)
 	}
 
-	return nil
+	return weatherPollingModel, nil
 }
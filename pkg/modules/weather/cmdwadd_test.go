@@ -3,10 +3,11 @@ package weather
 import (
 	"context"
 	"database/sql"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
-	"github.com/tomato3017/tomatobot/pkg/modules/weather/owm"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
 	"github.com/tomato3017/tomatobot/pkg/notifications"
 	"github.com/tomato3017/tomatobot/pkg/sqlmigrate"
 	"github.com/uptrace/bun"
@@ -41,11 +42,11 @@ func (t *TestWCmdAddSuite) TearDownTest() {
 	require.NoError(t.T(), t.dbConn.Close())
 }
 
-func (t *TestWCmdAddSuite) Test_WCmdAdd_checkZipInDb() {
+func (t *TestWCmdAddSuite) Test_getLocationByKey() {
 	checkLocation := dbmodels.WeatherPollingLocations{
 		Name:    "SomethingVille",
 		Country: "US",
-		ZipCode: "90210",
+		Key:     "us-90210",
 		Lon:     -50.55,
 		Lat:     49.87,
 		Polling: true,
@@ -53,13 +54,13 @@ func (t *TestWCmdAddSuite) Test_WCmdAdd_checkZipInDb() {
 	_, err := t.dbConn.NewInsert().Model(&checkLocation).Exec(context.Background())
 	require.NoError(t.T(), err)
 
-	t.Run("no zip in db", func() {
+	t.Run("no location in db", func() {
 		w := &weatherCmdAdd{
 			dbConn: t.dbConn,
 		}
 
 		err = w.dbConn.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
-			geoLoc, err := w.checkZipInDb(ctx, tx, "90211")
+			geoLoc, err := getLocationByKey(ctx, tx, "us-90211")
 			require.NoError(t.T(), err)
 			require.Equal(t.T(), dbmodels.WeatherPollingLocations{}, geoLoc)
 			return nil
@@ -67,13 +68,13 @@ func (t *TestWCmdAddSuite) Test_WCmdAdd_checkZipInDb() {
 		require.NoError(t.T(), err)
 	})
 
-	t.Run("zip in db", func() {
+	t.Run("location in db", func() {
 		w := &weatherCmdAdd{
 			dbConn: t.dbConn,
 		}
 
 		err = w.dbConn.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
-			geoLoc, err := w.checkZipInDb(ctx, tx, "90210")
+			geoLoc, err := getLocationByKey(ctx, tx, "us-90210")
 			require.NoError(t.T(), err)
 			require.Equal(t.T(), checkLocation, geoLoc)
 			return nil
@@ -85,17 +86,17 @@ func (t *TestWCmdAddSuite) Test_WCmdAdd_addLocationToSubscriptions() {
 	t.Run("add location to subscriptions", func() {
 		mockPub := notifications.NewMockPublisher(t.T())
 		mockPub.EXPECT().Subscribe(notifications.Subscriber{
-			TopicPattern: "weather.90210.warning",
+			TopicPattern: "weather.us-90210.warning",
 			ChatId:       12345,
 		}).Return("", nil)
 
 		mockPub.EXPECT().Subscribe(notifications.Subscriber{
-			TopicPattern: "weather.90210.watch",
+			TopicPattern: "weather.us-90210.watch",
 			ChatId:       12345,
 		}).Return("", nil)
 
 		mockPub.EXPECT().Subscribe(notifications.Subscriber{
-			TopicPattern: "weather.90210.advisory",
+			TopicPattern: "weather.us-90210.advisory",
 			ChatId:       12345,
 		}).Return("", nil)
 
@@ -104,53 +105,52 @@ func (t *TestWCmdAddSuite) Test_WCmdAdd_addLocationToSubscriptions() {
 			publisher: mockPub,
 		}
 
-		_, err := w.addLocationToSubscriptions(12345, "90210")
+		_, err := w.addLocationToSubscriptions(12345, "us-90210")
 		require.NoError(t.T(), err)
 	})
 
 }
 
 func (t *TestWCmdAddSuite) Test_WCmdAdd_addWeatherLocation() {
-	mockOwm := owm.NewMockOpenWeatherMapIClient(t.T())
-	mockOwm.EXPECT().GetLocationDataForZipCode(context.Background(), "90210").Return(owm.GeolocationResponse{
-		Zip:     "90210",
-		Name:    "Beverly Hills",
-		Lat:     1,
-		Lon:     2,
-		Country: "US",
+	mockProvider := provider.NewMockProvider(t.T())
+	mockProvider.EXPECT().Geocode(context.Background(), "90210", "US").Return(provider.Place{
+		PostalCode: "90210",
+		Name:       "Beverly Hills",
+		Location:   provider.Location{Latitude: 1, Longitude: 2},
+		Country:    "US",
 	}, nil)
 
 	mockPub := notifications.NewMockPublisher(t.T())
 	mockPub.EXPECT().Subscribe(notifications.Subscriber{
-		TopicPattern: "weather.90210.warning",
+		TopicPattern: "weather.us-90210.warning",
 		ChatId:       12345,
 	}).Return("", nil)
 
 	mockPub.EXPECT().Subscribe(notifications.Subscriber{
-		TopicPattern: "weather.90210.watch",
+		TopicPattern: "weather.us-90210.watch",
 		ChatId:       12345,
 	}).Return("", nil)
 
 	mockPub.EXPECT().Subscribe(notifications.Subscriber{
-		TopicPattern: "weather.90210.advisory",
+		TopicPattern: "weather.us-90210.advisory",
 		ChatId:       12345,
 	}).Return("", nil)
 
 	weatherAdd := weatherCmdAdd{
-		owmClient: mockOwm,
+		provider:  mockProvider,
 		dbConn:    t.dbConn,
 		publisher: mockPub,
 	}
 
-	_, err := weatherAdd.addWeatherLocation(context.Background(), "90210", 12345)
+	_, _, err := weatherAdd.addWeatherLocation(context.Background(), locationQuery{PostalCode: "90210", Country: "US"}, 12345)
 	require.NoError(t.T(), err)
 
 	//Ensure that the db has the location data
 	checkGeoLoc := dbmodels.WeatherPollingLocations{
-		ZipCode: "90210",
+		Key: "us-90210",
 	}
 
-	err = t.dbConn.NewSelect().Model(&checkGeoLoc).Where("zip_code = ?", "90210").
+	err = t.dbConn.NewSelect().Model(&checkGeoLoc).Where("location_key = ?", "us-90210").
 		Relation("Chats").
 		Scan(context.Background())
 	require.NoError(t.T(), err)
@@ -159,13 +159,13 @@ func (t *TestWCmdAddSuite) Test_WCmdAdd_addWeatherLocation() {
 }
 
 func (t *TestWCmdAddSuite) Test_WCmdAdd_addWeatherLocation_alreadyadded() {
-	mockOwm := owm.NewMockOpenWeatherMapIClient(t.T())
+	mockProvider := provider.NewMockProvider(t.T())
 
 	mockPub := notifications.NewMockPublisher(t.T())
 	dbLoc := dbmodels.WeatherPollingLocations{
 		Name:    "Beverly Hills",
 		Country: "US",
-		ZipCode: "90210",
+		Key:     "us-90210",
 		Lon:     1,
 		Lat:     2,
 		Polling: true,
@@ -182,21 +182,21 @@ func (t *TestWCmdAddSuite) Test_WCmdAdd_addWeatherLocation_alreadyadded() {
 	require.NoError(t.T(), err)
 
 	weatherAdd := weatherCmdAdd{
-		owmClient: mockOwm,
+		provider:  mockProvider,
 		dbConn:    t.dbConn,
 		publisher: mockPub,
 	}
 
-	_, err = weatherAdd.addWeatherLocation(context.Background(), "90210", 12345)
+	_, _, err = weatherAdd.addWeatherLocation(context.Background(), locationQuery{PostalCode: "90210", Country: "US"}, 12345)
 	require.Error(t.T(), err)
 	require.ErrorContains(t.T(), err, "already exists")
 
 	//Ensure that the db has the location data
 	checkGeoLoc := dbmodels.WeatherPollingLocations{
-		ZipCode: "90210",
+		Key: "us-90210",
 	}
 
-	err = t.dbConn.NewSelect().Model(&checkGeoLoc).Where("zip_code = ?", "90210").
+	err = t.dbConn.NewSelect().Model(&checkGeoLoc).Where("location_key = ?", "us-90210").
 		Relation("Chats").
 		Scan(context.Background())
 	require.NoError(t.T(), err)
@@ -205,28 +205,28 @@ func (t *TestWCmdAddSuite) Test_WCmdAdd_addWeatherLocation_alreadyadded() {
 }
 
 func (t *TestWCmdAddSuite) Test_WCmdAdd_addWeatherLocation_exists() {
-	mockOwm := owm.NewMockOpenWeatherMapIClient(t.T())
+	mockProvider := provider.NewMockProvider(t.T())
 
 	mockPub := notifications.NewMockPublisher(t.T())
 	mockPub.EXPECT().Subscribe(notifications.Subscriber{
-		TopicPattern: "weather.90210.warning",
+		TopicPattern: "weather.us-90210.warning",
 		ChatId:       12345,
 	}).Return("", nil)
 
 	mockPub.EXPECT().Subscribe(notifications.Subscriber{
-		TopicPattern: "weather.90210.watch",
+		TopicPattern: "weather.us-90210.watch",
 		ChatId:       12345,
 	}).Return("", nil)
 
 	mockPub.EXPECT().Subscribe(notifications.Subscriber{
-		TopicPattern: "weather.90210.advisory",
+		TopicPattern: "weather.us-90210.advisory",
 		ChatId:       12345,
 	}).Return("", nil)
 
 	dbLoc := dbmodels.WeatherPollingLocations{
 		Name:    "Beverly Hills",
 		Country: "US",
-		ZipCode: "90210",
+		Key:     "us-90210",
 		Lon:     1,
 		Lat:     2,
 		Polling: true,
@@ -236,20 +236,20 @@ func (t *TestWCmdAddSuite) Test_WCmdAdd_addWeatherLocation_exists() {
 	require.NoError(t.T(), err)
 
 	weatherAdd := weatherCmdAdd{
-		owmClient: mockOwm,
+		provider:  mockProvider,
 		dbConn:    t.dbConn,
 		publisher: mockPub,
 	}
 
-	_, err = weatherAdd.addWeatherLocation(context.Background(), "90210", 12345)
+	_, _, err = weatherAdd.addWeatherLocation(context.Background(), locationQuery{PostalCode: "90210", Country: "US"}, 12345)
 	require.NoError(t.T(), err)
 
 	//Ensure that the db has the location data
 	checkGeoLoc := dbmodels.WeatherPollingLocations{
-		ZipCode: "90210",
+		Key: "us-90210",
 	}
 
-	err = t.dbConn.NewSelect().Model(&checkGeoLoc).Where("zip_code = ?", "90210").
+	err = t.dbConn.NewSelect().Model(&checkGeoLoc).Where("location_key = ?", "us-90210").
 		Relation("Chats").
 		Scan(context.Background())
 	require.NoError(t.T(), err)
@@ -260,3 +260,68 @@ func (t *TestWCmdAddSuite) Test_WCmdAdd_addWeatherLocation_exists() {
 func TestRunWCmdAddSuite(t *testing.T) {
 	suite.Run(t, new(TestWCmdAddSuite))
 }
+
+func (t *TestWCmdAddSuite) Test_WCmdAdd_addWeatherLocation_byName() {
+	mockProvider := provider.NewMockProvider(t.T())
+	mockProvider.EXPECT().Search(context.Background(), "London").Return([]provider.Place{
+		{Name: "London", State: "England", Country: "GB", Location: provider.Location{Latitude: 51.5073, Longitude: -0.1276}},
+		{Name: "City of London", State: "England", Country: "GB", Location: provider.Location{Latitude: 51.5072, Longitude: -0.1275}},
+	}, nil).Once()
+
+	mockPub := notifications.NewMockPublisher(t.T())
+	mockPub.EXPECT().Subscribe(mock.Anything).Return("", nil).Times(3)
+
+	weatherAdd := weatherCmdAdd{
+		provider:  mockProvider,
+		dbConn:    t.dbConn,
+		publisher: mockPub,
+	}
+
+	// both results round to the same location, so the name isn't ambiguous
+	location, topics, err := weatherAdd.addWeatherLocation(context.Background(), locationQuery{Name: "London"}, 12345)
+	require.NoError(t.T(), err)
+	require.Equal(t.T(), "geo-5151n_13w", location.Key)
+	require.Equal(t.T(), "London", location.Name)
+	require.Equal(t.T(), "GB", location.Country)
+	require.Contains(t.T(), topics, "weather.geo-5151n_13w.warning")
+}
+
+func (t *TestWCmdAddSuite) Test_WCmdAdd_addWeatherLocation_ambiguous() {
+	mockProvider := provider.NewMockProvider(t.T())
+	mockProvider.EXPECT().Search(context.Background(), "London").Return([]provider.Place{
+		{Name: "London", State: "England", Country: "GB", Location: provider.Location{Latitude: 51.5073, Longitude: -0.1276}},
+		{Name: "London", State: "Ontario", Country: "CA", Location: provider.Location{Latitude: 42.9832, Longitude: -81.2434}},
+	}, nil).Once()
+
+	weatherAdd := weatherCmdAdd{
+		provider: mockProvider,
+		dbConn:   t.dbConn,
+	}
+
+	_, _, err := weatherAdd.addWeatherLocation(context.Background(), locationQuery{Name: "London"}, 12345)
+	var ambiguousErr *ambiguousLocationError
+	require.ErrorAs(t.T(), err, &ambiguousErr)
+	require.Len(t.T(), ambiguousErr.Places, 2)
+	require.Contains(t.T(), err.Error(), "London, Ontario, CA: /weather add 42.9832,-81.2434")
+
+	count, err := t.dbConn.NewSelect().Model((*dbmodels.WeatherPollingLocations)(nil)).Count(context.Background())
+	require.NoError(t.T(), err)
+	require.Zero(t.T(), count)
+}
+
+func (t *TestWCmdAddSuite) Test_WCmdAdd_addWeatherLocation_coordinates() {
+	mockPub := notifications.NewMockPublisher(t.T())
+	mockPub.EXPECT().Subscribe(mock.Anything).Return("", nil).Times(3)
+
+	weatherAdd := weatherCmdAdd{
+		provider:  provider.NewMockProvider(t.T()),
+		dbConn:    t.dbConn,
+		publisher: mockPub,
+	}
+
+	query := locationQuery{Coordinates: &provider.Location{Latitude: -33.8688, Longitude: 151.2093}}
+	location, _, err := weatherAdd.addWeatherLocation(context.Background(), query, 12345)
+	require.NoError(t.T(), err)
+	require.Equal(t.T(), "geo-3387s_15121e", location.Key)
+	require.Equal(t.T(), "-33.8688,151.2093", location.Name)
+}
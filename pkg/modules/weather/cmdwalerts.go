@@ -0,0 +1,464 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/middleware"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/modules"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"github.com/uptrace/bun"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// /weather alerts show
+// /weather alerts include <event>
+// /weather alerts exclude <event>
+// /weather alerts min <warning|watch|advisory|all>
+// /weather alerts length <characters>
+// /weather alerts template set <template>
+// /weather alerts template preview [template]
+// /weather alerts reset [include|exclude|min|length|template]
+
+type weatherCmdAlerts struct {
+	command.BaseCommand
+}
+
+func newWeatherCmdAlerts(params modules.InitializeParameters) (*weatherCmdAlerts, error) {
+	defaultTemplate, err := parseTemplate("weatheralert", msgTemplateStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse weather alert template: %w", err)
+	}
+
+	alertsCmd := &weatherCmdAlerts{
+		BaseCommand: command.NewBaseCommand(),
+	}
+
+	err = alertsCmd.RegisterSubcommand("show", &weatherCmdAlertsShow{
+		BaseCommand: command.NewBaseCommand(middleware.WithMaxArgs(0)),
+		dbConn:      params.DbConn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = alertsCmd.RegisterSubcommand("include", &weatherCmdAlertsEvents{
+		BaseCommand: command.NewBaseCommand(middleware.WithMinArgs(1)),
+		dbConn:      params.DbConn,
+		include:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = alertsCmd.RegisterSubcommand("exclude", &weatherCmdAlertsEvents{
+		BaseCommand: command.NewBaseCommand(middleware.WithMinArgs(1)),
+		dbConn:      params.DbConn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = alertsCmd.RegisterSubcommand("min", &weatherCmdAlertsMin{
+		BaseCommand: command.NewBaseCommand(middleware.WithMinArgs(1), middleware.WithMaxArgs(1)),
+		dbConn:      params.DbConn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = alertsCmd.RegisterSubcommand("length", &weatherCmdAlertsLength{
+		BaseCommand: command.NewBaseCommand(middleware.WithMinArgs(1), middleware.WithMaxArgs(1)),
+		dbConn:      params.DbConn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = alertsCmd.RegisterSubcommand("reset", &weatherCmdAlertsReset{
+		BaseCommand: command.NewBaseCommand(middleware.WithMaxArgs(1)),
+		dbConn:      params.DbConn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	templateCmd := &weatherCmdAlertsTemplate{
+		BaseCommand: command.NewBaseCommand(),
+	}
+	err = templateCmd.RegisterSubcommand("set", &weatherCmdAlertsTemplateSet{
+		BaseCommand: command.NewBaseCommand(middleware.WithMinArgs(1)),
+		dbConn:      params.DbConn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = templateCmd.RegisterSubcommand("preview", &weatherCmdAlertsTemplatePreview{
+		BaseCommand:     command.NewBaseCommand(),
+		dbConn:          params.DbConn,
+		defaultTemplate: defaultTemplate,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = alertsCmd.RegisterSubcommand("template", templateCmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return alertsCmd, nil
+}
+
+func (w *weatherCmdAlerts) Description() string {
+	return "Filter the weather alerts sent to this chat and change how they look"
+}
+
+func (w *weatherCmdAlerts) Help() string {
+	return "/weather alerts <show|include|exclude|min|length|template|reset> - Filter the weather alerts sent to this chat and change how they look"
+}
+
+type weatherCmdAlertsShow struct {
+	command.BaseCommand
+	dbConn bun.IDB
+}
+
+// Execute replies with the chat's alert settings
+func (w *weatherCmdAlertsShow) Execute(ctx context.Context, params models.CommandParams) error {
+	settings, err := getAlertSettings(ctx, w.dbConn, params.Message.AssumedChatID())
+	if err != nil {
+		return err
+	}
+
+	return sendAlertsReply(params, formatAlertSettings(settings))
+}
+
+func (w *weatherCmdAlertsShow) Description() string {
+	return "Show this chat's alert settings"
+}
+
+func (w *weatherCmdAlertsShow) Help() string {
+	return "/weather alerts show - Show which weather alerts this chat gets and how they look"
+}
+
+type weatherCmdAlertsEvents struct {
+	command.BaseCommand
+	dbConn bun.IDB
+	// include adds the event to the include list, otherwise the exclude list
+	include bool
+}
+
+// Execute adds the event to the chat's include or exclude list
+func (w *weatherCmdAlertsEvents) Execute(ctx context.Context, params models.CommandParams) error {
+	event := strings.Join(params.Args, " ")
+	settings, err := updateAlertSettings(ctx, w.dbConn, params.Message.AssumedChatID(), func(settings *dbmodels.WeatherAlertSettings) error {
+		events, other := &settings.ExcludeEvents, &settings.IncludeEvents
+		if w.include {
+			events, other = other, events
+		}
+
+		if !containsEvent(*events, event) {
+			*events = append(*events, event)
+		}
+		// an event can't be both included and excluded, the latest command wins
+		*other = slices.DeleteFunc(*other, func(e string) bool { return strings.EqualFold(e, event) })
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	reply := fmt.Sprintf("%s alerts won't be sent to this chat", event)
+	if w.include {
+		reply = fmt.Sprintf("Only %s alerts will be sent to this chat", strings.Join(settings.IncludeEvents, ", "))
+	}
+
+	return sendAlertsReply(params, reply)
+}
+
+func (w *weatherCmdAlertsEvents) Description() string {
+	if w.include {
+		return "Only send alerts for the given events"
+	}
+	return "Don't send alerts for an event"
+}
+
+func (w *weatherCmdAlertsEvents) Help() string {
+	if w.include {
+		return "/weather alerts include <event> - Only send this chat alerts for the included events, e.g. Tornado Warning"
+	}
+	return "/weather alerts exclude <event> - Don't send this chat alerts for an event, e.g. Heat Advisory"
+}
+
+type weatherCmdAlertsMin struct {
+	command.BaseCommand
+	dbConn bun.IDB
+}
+
+// Execute sets the lowest event type the chat gets alerts for
+func (w *weatherCmdAlertsMin) Execute(ctx context.Context, params models.CommandParams) error {
+	category, err := parseMinCategory(params.Args[0])
+	if err != nil {
+		return err
+	}
+
+	_, err = updateAlertSettings(ctx, w.dbConn, params.Message.AssumedChatID(), func(settings *dbmodels.WeatherAlertSettings) error {
+		settings.MinCategory = category.String()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	reply := "All alerts will be sent to this chat"
+	if category != "" {
+		reply = fmt.Sprintf("Only %s alerts and above will be sent to this chat", category)
+	}
+
+	return sendAlertsReply(params, reply)
+}
+
+func (w *weatherCmdAlertsMin) Description() string {
+	return "Set the lowest category of alerts sent"
+}
+
+func (w *weatherCmdAlertsMin) Help() string {
+	return "/weather alerts min <warning|watch|advisory|all> - Set the lowest category of alerts sent to this chat"
+}
+
+type weatherCmdAlertsLength struct {
+	command.BaseCommand
+	dbConn bun.IDB
+}
+
+// Execute sets how much of the alert descriptions the chat is shown
+func (w *weatherCmdAlertsLength) Execute(ctx context.Context, params models.CommandParams) error {
+	length, err := strconv.Atoi(params.Args[0])
+	if err != nil || length < 1 || length > maxAlertDescriptionLength {
+		return fmt.Errorf("description length must be a number from 1 to %d", maxAlertDescriptionLength)
+	}
+
+	_, err = updateAlertSettings(ctx, w.dbConn, params.Message.AssumedChatID(), func(settings *dbmodels.WeatherAlertSettings) error {
+		settings.DescriptionLength = length
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendAlertsReply(params, fmt.Sprintf("Alert descriptions will be cut to %d characters", length))
+}
+
+func (w *weatherCmdAlertsLength) Description() string {
+	return "Set how much of alert descriptions is shown"
+}
+
+func (w *weatherCmdAlertsLength) Help() string {
+	return fmt.Sprintf("/weather alerts length <characters> - Cut alert descriptions to this many characters, %d by default", defaultAlertDescriptionLength)
+}
+
+type weatherCmdAlertsReset struct {
+	command.BaseCommand
+	dbConn bun.IDB
+}
+
+// Execute puts one of the chat's alert settings back to the default, or all of them
+func (w *weatherCmdAlertsReset) Execute(ctx context.Context, params models.CommandParams) error {
+	chatId := params.Message.AssumedChatID()
+	if len(params.Args) == 0 {
+		_, err := w.dbConn.NewDelete().Model((*dbmodels.WeatherAlertSettings)(nil)).Where("chat_id = ?", chatId).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete chat alert settings: %w", err)
+		}
+
+		return sendAlertsReply(params, "All alerts will be sent to this chat in the default template")
+	}
+
+	setting := strings.ToLower(params.Args[0])
+	_, err := updateAlertSettings(ctx, w.dbConn, chatId, func(settings *dbmodels.WeatherAlertSettings) error {
+		switch setting {
+		case "include":
+			settings.IncludeEvents = nil
+		case "exclude":
+			settings.ExcludeEvents = nil
+		case "min":
+			settings.MinCategory = ""
+		case "length":
+			settings.DescriptionLength = 0
+		case "template":
+			settings.Template = ""
+		default:
+			return fmt.Errorf("unknown setting %s, use include, exclude, min, length or template", setting)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendAlertsReply(params, fmt.Sprintf("Alert %s setting reset", setting))
+}
+
+func (w *weatherCmdAlertsReset) Description() string {
+	return "Reset alert settings to the defaults"
+}
+
+func (w *weatherCmdAlertsReset) Help() string {
+	return "/weather alerts reset [include|exclude|min|length|template] - Reset one of this chat's alert settings, or all of them"
+}
+
+type weatherCmdAlertsTemplate struct {
+	command.BaseCommand
+}
+
+func (w *weatherCmdAlertsTemplate) Description() string {
+	return "Change how alerts look"
+}
+
+func (w *weatherCmdAlertsTemplate) Help() string {
+	return "/weather alerts template <set|preview> - Change how alerts sent to this chat look"
+}
+
+type weatherCmdAlertsTemplateSet struct {
+	command.BaseCommand
+	dbConn bun.IDB
+}
+
+// Execute checks the template and saves it as the chat's alert template. The template is taken from the message as
+// written, since the args lose its line breaks.
+func (w *weatherCmdAlertsTemplateSet) Execute(ctx context.Context, params models.CommandParams) error {
+	text := argsAfter(params.Message.InnerMsg().CommandArguments(), 3)
+	if _, err := parseAlertTemplate(text); err != nil {
+		return err
+	}
+
+	_, err := updateAlertSettings(ctx, w.dbConn, params.Message.AssumedChatID(), func(settings *dbmodels.WeatherAlertSettings) error {
+		settings.Template = text
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendAlertsReply(params, "Alerts will be sent to this chat in the new template, see it with /weather alerts template preview")
+}
+
+func (w *weatherCmdAlertsTemplateSet) Description() string {
+	return "Set the template alerts are sent in"
+}
+
+func (w *weatherCmdAlertsTemplateSet) Help() string {
+	return "/weather alerts template set <template> - Send alerts to this chat in a Go template rendering HTML, " +
+		"e.g. <b>{{.Event | escape}}</b> until {{.End | localTime}}"
+}
+
+type weatherCmdAlertsTemplatePreview struct {
+	command.BaseCommand
+	dbConn          bun.IDB
+	defaultTemplate *template.Template
+}
+
+// Execute replies with a sample alert in the given template, or the chat's
+func (w *weatherCmdAlertsTemplatePreview) Execute(ctx context.Context, params models.CommandParams) error {
+	settings, err := getAlertSettings(ctx, w.dbConn, params.Message.AssumedChatID())
+	if err != nil {
+		return err
+	}
+
+	tmpl := w.defaultTemplate
+	text := util.FirstNonZero(argsAfter(params.Message.InnerMsg().CommandArguments(), 3), settings.Template)
+	if text != "" {
+		tmpl, err = parseAlertTemplate(text)
+		if err != nil {
+			return err
+		}
+	}
+
+	rendered, err := renderAlert(tmpl, sampleAlert, util.FirstNonZero(settings.DescriptionLength, defaultAlertDescriptionLength))
+	if err != nil {
+		return err
+	}
+
+	_, err = params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), tgbotapi.ModeHTML, rendered))
+	if err != nil {
+		return fmt.Errorf("failed to send reply: %w", err)
+	}
+
+	return nil
+}
+
+func (w *weatherCmdAlertsTemplatePreview) Description() string {
+	return "Show a sample alert in a template"
+}
+
+func (w *weatherCmdAlertsTemplatePreview) Help() string {
+	return "/weather alerts template preview [template] - Show a sample alert in the given template, or this chat's"
+}
+
+// updateAlertSettings applies the change to the chat's alert settings and saves them
+func updateAlertSettings(ctx context.Context, dbConn bun.IDB, chatId int64, change func(settings *dbmodels.WeatherAlertSettings) error) (dbmodels.WeatherAlertSettings, error) {
+	settings, err := getAlertSettings(ctx, dbConn, chatId)
+	if err != nil {
+		return dbmodels.WeatherAlertSettings{}, err
+	}
+
+	if err := change(&settings); err != nil {
+		return dbmodels.WeatherAlertSettings{}, err
+	}
+
+	if err := saveAlertSettings(ctx, dbConn, settings); err != nil {
+		return dbmodels.WeatherAlertSettings{}, err
+	}
+
+	return settings, nil
+}
+
+func formatAlertSettings(settings dbmodels.WeatherAlertSettings) string {
+	lines := []string{"Weather alert settings"}
+	if len(settings.IncludeEvents) > 0 {
+		lines = append(lines, fmt.Sprintf("Only: %s", strings.Join(settings.IncludeEvents, ", ")))
+	}
+	if len(settings.ExcludeEvents) > 0 {
+		lines = append(lines, fmt.Sprintf("Except: %s", strings.Join(settings.ExcludeEvents, ", ")))
+	}
+	lines = append(lines, fmt.Sprintf("Lowest category: %s", util.FirstNonZero(settings.MinCategory, "all")))
+	lines = append(lines, fmt.Sprintf("Description length: %d", util.FirstNonZero(settings.DescriptionLength, defaultAlertDescriptionLength)))
+	if settings.Template != "" {
+		lines = append(lines, fmt.Sprintf("Template:\n%s", settings.Template))
+	} else {
+		lines = append(lines, "Template: default")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// argsAfter returns the command's arguments after the first words, as written
+func argsAfter(arguments string, words int) string {
+	rest := strings.TrimLeftFunc(arguments, unicode.IsSpace)
+	for i := 0; i < words && rest != ""; i++ {
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			return ""
+		}
+		rest = strings.TrimLeftFunc(rest[end:], unicode.IsSpace)
+	}
+
+	return strings.TrimSpace(rest)
+}
+
+func sendAlertsReply(params models.CommandParams, reply string) error {
+	_, err := params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", reply))
+	if err != nil {
+		return fmt.Errorf("failed to send reply: %w", err)
+	}
+
+	return nil
+}
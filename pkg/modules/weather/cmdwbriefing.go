@@ -0,0 +1,311 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/middleware"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/modules"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"github.com/uptrace/bun"
+	"strings"
+	"time"
+)
+
+// /weather briefing set <HH:MM> [location]
+// /weather briefing off [location]
+// /weather briefing preview [location]
+
+type weatherCmdBriefing struct {
+	command.BaseCommand
+}
+
+func newWeatherCmdBriefing(params modules.InitializeParameters, weatherProvider provider.Provider) (*weatherCmdBriefing, error) {
+	renderer, err := newBriefingRenderer(weatherProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	briefingCmd := &weatherCmdBriefing{
+		BaseCommand: command.NewBaseCommand(),
+	}
+
+	err = briefingCmd.RegisterSubcommand("set", &weatherCmdBriefingSet{
+		BaseCommand: command.NewBaseCommand(middleware.WithMinArgs(1)),
+		dbConn:      params.DbConn,
+		provider:    weatherProvider,
+		publisher:   params.Notifications,
+		logger:      params.Logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = briefingCmd.RegisterSubcommand("off", &weatherCmdBriefingOff{
+		BaseCommand: command.NewBaseCommand(),
+		dbConn:      params.DbConn,
+		publisher:   params.Notifications,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = briefingCmd.RegisterSubcommand("preview", &weatherCmdBriefingPreview{
+		BaseCommand: command.NewBaseCommand(),
+		dbConn:      params.DbConn,
+		lookup:      locationLookup{dbConn: params.DbConn, provider: weatherProvider, command: "/weather briefing preview"},
+		provider:    weatherProvider,
+		renderer:    renderer,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return briefingCmd, nil
+}
+
+func (w *weatherCmdBriefing) Description() string {
+	return "Schedule a daily weather briefing"
+}
+
+func (w *weatherCmdBriefing) Help() string {
+	return "/weather briefing <set|off|preview> - Schedule a daily weather briefing for this chat"
+}
+
+type weatherCmdBriefingSet struct {
+	command.BaseCommand
+	dbConn    bun.IDB
+	provider  provider.Provider
+	publisher notifications.Publisher
+	logger    zerolog.Logger
+}
+
+// Execute schedules the briefing of one of the chat's locations and subscribes the chat to it
+func (w *weatherCmdBriefingSet) Execute(ctx context.Context, params models.CommandParams) error {
+	timeOfDay, err := parseBriefingTime(params.Args[0])
+	if err != nil {
+		return err
+	}
+
+	chatId := params.Message.AssumedChatID()
+	location, err := getChatLocation(ctx, w.dbConn, chatId, params.Args[1:])
+	if err != nil {
+		return err
+	}
+
+	tz, err := w.provider.Timezone(ctx, provider.Location{Latitude: location.Lat, Longitude: location.Lon})
+	if err != nil {
+		return fmt.Errorf("failed to get the timezone of %s: %w", location.Key, err)
+	}
+
+	briefing := dbmodels.WeatherBriefings{
+		ChatID:     chatId,
+		LocationID: location.ID,
+		Time:       timeOfDay.Format("15:04"),
+		TZ:         tz.String(),
+	}
+	_, err = w.dbConn.NewInsert().Model(&briefing).
+		On("CONFLICT(chat_id, location_id) DO UPDATE").
+		Set("briefing_time = EXCLUDED.briefing_time").
+		Set("tz = EXCLUDED.tz").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save briefing: %w", err)
+	}
+
+	topic := eventTypeBriefing.fullTopicPath(location.Key)
+	_, err = w.publisher.Subscribe(notifications.Subscriber{ChatId: chatId, TopicPattern: topic})
+	if err != nil && !errors.Is(err, notifications.ErrSubExists) {
+		return fmt.Errorf("failed to subscribe to topic: %w", err)
+	}
+
+	w.logger.Debug().Str("location_key", location.Key).Int64("chat_id", chatId).Msgf("Briefing set for %s", briefing.Time)
+	reply := fmt.Sprintf("The briefing for %s will be sent daily at %s %s to %s", location.Name, briefing.Time, briefing.TZ, topic)
+	_, err = params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", reply))
+	if err != nil {
+		return fmt.Errorf("failed to send reply: %w", err)
+	}
+
+	return nil
+}
+
+func (w *weatherCmdBriefingSet) Description() string {
+	return "Send a daily briefing at a time"
+}
+
+func (w *weatherCmdBriefingSet) Help() string {
+	return "/weather briefing set <HH:MM> [location] - Send a daily briefing of one of this chat's locations at its local time"
+}
+
+type weatherCmdBriefingOff struct {
+	command.BaseCommand
+	dbConn    bun.IDB
+	publisher notifications.Publisher
+}
+
+// Execute stops the briefing of the location, or all of the chat's briefings
+func (w *weatherCmdBriefingOff) Execute(ctx context.Context, params models.CommandParams) error {
+	chatId := params.Message.AssumedChatID()
+	query := w.dbConn.NewSelect().Model((*dbmodels.WeatherBriefings)(nil)).
+		Relation("Location").
+		Where("weather_briefings.chat_id = ?", chatId)
+	if len(params.Args) > 0 {
+		locationKey, err := resolveLocationKey(strings.Join(params.Args, " "))
+		if err != nil {
+			return err
+		}
+		query = query.Where("location.location_key = ?", locationKey)
+	}
+
+	var briefings []dbmodels.WeatherBriefings
+	if err := query.Scan(ctx, &briefings); err != nil {
+		return fmt.Errorf("failed to get briefings: %w", err)
+	}
+
+	if len(briefings) == 0 {
+		return fmt.Errorf("no briefing to turn off")
+	}
+
+	for _, briefing := range briefings {
+		if err := removeBriefing(ctx, w.dbConn, w.publisher, briefing); err != nil {
+			return err
+		}
+	}
+
+	_, err := params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "",
+		fmt.Sprintf("Turned off %d briefing(s)", len(briefings))))
+	if err != nil {
+		return fmt.Errorf("failed to send reply: %w", err)
+	}
+
+	return nil
+}
+
+func (w *weatherCmdBriefingOff) Description() string {
+	return "Stop sending daily briefings"
+}
+
+func (w *weatherCmdBriefingOff) Help() string {
+	return "/weather briefing off [location] - Stop the briefing of a location, or all of this chat's briefings"
+}
+
+type weatherCmdBriefingPreview struct {
+	command.BaseCommand
+	dbConn   bun.IDB
+	lookup   locationLookup
+	provider provider.Provider
+	renderer *briefingRenderer
+}
+
+// Execute replies with the briefing of the location, or of each of the chat's locations, as it would be sent now
+func (w *weatherCmdBriefingPreview) Execute(ctx context.Context, params models.CommandParams) error {
+	locations, err := w.lookup.lookup(ctx, params.Message.AssumedChatID(), params.Args)
+	var ambiguousErr *ambiguousLocationError
+	if errors.As(err, &ambiguousErr) {
+		_, err = params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", ambiguousErr.Error()))
+		if err != nil {
+			return fmt.Errorf("failed to send reply: %w", err)
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	chatUnits, err := getChatUnits(ctx, w.dbConn, params.Message.AssumedChatID())
+	if err != nil {
+		return err
+	}
+
+	rendered := make([]string, 0, len(locations))
+	for _, location := range locations {
+		tz, err := w.provider.Timezone(ctx, provider.Location{Latitude: location.Lat, Longitude: location.Lon})
+		if err != nil {
+			return fmt.Errorf("failed to get the timezone of %s: %w", util.FirstNonZero(location.Name, location.Key), err)
+		}
+
+		msg, err := w.renderer.render(ctx, location, tz, chatUnits, time.Now())
+		if err != nil {
+			return err
+		}
+		rendered = append(rendered, msg)
+	}
+
+	for _, chunk := range util.SplitMessage(strings.Join(rendered, "\n"), util.TelegramMaxMessageLength) {
+		_, err = params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), tgbotapi.ModeHTML, chunk))
+		if err != nil {
+			return fmt.Errorf("failed to send reply: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (w *weatherCmdBriefingPreview) Description() string {
+	return "Show the briefing as it would be sent now"
+}
+
+func (w *weatherCmdBriefingPreview) Help() string {
+	return "/weather briefing preview [location] - Show the briefing of a location or this chat's locations as it would be sent now"
+}
+
+// getChatLocation returns the chat's location given in args, or its only location if there are no args
+func getChatLocation(ctx context.Context, dbConn bun.IDB, chatId int64, args []string) (dbmodels.WeatherPollingLocations, error) {
+	locations, err := getChatLocations(ctx, dbConn, chatId)
+	if err != nil {
+		return dbmodels.WeatherPollingLocations{}, fmt.Errorf("failed to get chat locations: %w", err)
+	}
+
+	if len(args) == 0 {
+		switch len(locations) {
+		case 0:
+			return dbmodels.WeatherPollingLocations{}, fmt.Errorf("no locations added to this chat, add one with /weather add")
+		case 1:
+			return locations[0], nil
+		default:
+			return dbmodels.WeatherPollingLocations{}, fmt.Errorf("this chat has %d locations, give the key of one from /weather list", len(locations))
+		}
+	}
+
+	locationKey, err := resolveLocationKey(strings.Join(args, " "))
+	if err != nil {
+		return dbmodels.WeatherPollingLocations{}, err
+	}
+
+	for _, location := range locations {
+		if location.Key == locationKey {
+			return location, nil
+		}
+	}
+
+	return dbmodels.WeatherPollingLocations{}, fmt.Errorf("%s isn't added to this chat, add it with /weather add", locationKey)
+}
+
+// removeBriefing deletes the briefing and unsubscribes its chat from the briefing topic
+func removeBriefing(ctx context.Context, dbConn bun.IDB, publisher notifications.Publisher, briefing dbmodels.WeatherBriefings) error {
+	if _, err := dbConn.NewDelete().Model(&briefing).WherePK().Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete briefing: %w", err)
+	}
+
+	subs, err := publisher.GetSubscriptions(briefing.ChatID)
+	if err != nil {
+		return fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+
+	topic := eventTypeBriefing.fullTopicPath(briefing.Location.Key)
+	for _, sub := range subs {
+		if sub.TopicPattern == topic {
+			if err := publisher.Unsubscribe(sub.ID, briefing.ChatID); err != nil {
+				return fmt.Errorf("failed to unsubscribe: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
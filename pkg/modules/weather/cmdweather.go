@@ -4,22 +4,29 @@ import (
 	"github.com/tomato3017/tomatobot/pkg/command"
 	"github.com/tomato3017/tomatobot/pkg/command/middleware"
 	"github.com/tomato3017/tomatobot/pkg/modules"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
 )
 
-// /weather add <zip>
-// /weather remove <zip>
+// /weather add <zip|postal,country|lat,lon|place name>
+// /weather remove <location key|zip|postal,country|lat,lon>
 // /weather list
+// /weather now [location]
+// /weather forecast [location] [--hourly|--daily] [--days=N]
+// /weather units [metric|imperial]
+// /weather briefing <set|off|preview>
+// /weather alerts <show|include|exclude|min|length|template|reset>
+// /weather quota
 
 type weatherCommand struct {
 	command.BaseCommand
 }
 
-func newWeatherCommand(params modules.InitializeParameters) (*weatherCommand, error) {
+func newWeatherCommand(params modules.InitializeParameters, weatherProvider provider.Provider) (*weatherCommand, error) {
 	weatherCmd := &weatherCommand{
 		BaseCommand: command.NewBaseCommand(middleware.WithAdminPermission()),
 	}
 
-	err := weatherCmd.RegisterSubcommand("add", newWeatherCmdAdd(params))
+	err := weatherCmd.RegisterSubcommand("add", newWeatherCmdAdd(params, weatherProvider))
 	if err != nil {
 		return nil, err
 	}
@@ -34,6 +41,61 @@ func newWeatherCommand(params modules.InitializeParameters) (*weatherCommand, er
 		return nil, err
 	}
 
+	nowCmd, err := newWeatherCmdNow(params, weatherProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	err = weatherCmd.RegisterSubcommand("now", nowCmd)
+	if err != nil {
+		return nil, err
+	}
+
+	forecastCmd, err := newWeatherCmdForecast(params, weatherProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	err = weatherCmd.RegisterSubcommand("forecast", forecastCmd)
+	if err != nil {
+		return nil, err
+	}
+
+	err = params.Tomatobot.RegisterCallbackQueryHandler(forecastCallbackPrefix, forecastCmd.handleCallback)
+	if err != nil {
+		return nil, err
+	}
+
+	err = weatherCmd.RegisterSubcommand("units", newWeatherCmdUnits(params))
+	if err != nil {
+		return nil, err
+	}
+
+	briefingCmd, err := newWeatherCmdBriefing(params, weatherProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	err = weatherCmd.RegisterSubcommand("briefing", briefingCmd)
+	if err != nil {
+		return nil, err
+	}
+
+	alertsCmd, err := newWeatherCmdAlerts(params)
+	if err != nil {
+		return nil, err
+	}
+
+	err = weatherCmd.RegisterSubcommand("alerts", alertsCmd)
+	if err != nil {
+		return nil, err
+	}
+
+	err = weatherCmd.RegisterSubcommand("quota", newWeatherCmdQuota(params, weatherProvider))
+	if err != nil {
+		return nil, err
+	}
+
 	return weatherCmd, nil
 }
 
@@ -0,0 +1,380 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/rs/zerolog"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/modules"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"github.com/uptrace/bun"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// forecastCacheTTL is how long a forecast is reused for a location, forecasts are updated every hour at most
+	forecastCacheTTL = 15 * time.Minute
+	// forecastLocationTTL is how long the buttons keep working on the forecast of a location no chat has added
+	forecastLocationTTL = 24 * time.Hour
+
+	defaultDailyDays  = 5
+	defaultHourlyDays = 1
+	maxForecastDays   = 7
+
+	// forecastCallbackPrefix starts the callback data of the forecast buttons, <prefix>:<view>:<days>:<location key>
+	forecastCallbackPrefix = "wforecast"
+)
+
+//go:embed weatherforecast.tmpl
+var forecastTemplateStr string
+
+type forecastView string
+
+const (
+	forecastDaily  forecastView = "daily"
+	forecastHourly forecastView = "hourly"
+)
+
+// forecastRequest is the forecast asked for by the flags of /weather forecast or a button
+type forecastRequest struct {
+	View forecastView
+	Days int
+}
+
+type weatherCmdForecast struct {
+	command.BaseCommand
+
+	dbConn      bun.IDB
+	botProxy    proxy.TGBotImplementation
+	lookup      locationLookup
+	provider    provider.Provider
+	cache       *ttlcache.Cache[string, []provider.ForecastPeriod]
+	locations   *ttlcache.Cache[string, dbmodels.WeatherPollingLocations]
+	msgTemplate *template.Template
+	logger      zerolog.Logger
+}
+
+func newWeatherCmdForecast(params modules.InitializeParameters, weatherProvider provider.Provider) (*weatherCmdForecast, error) {
+	msgTemplate, err := parseTemplate("weatherforecast", forecastTemplateStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse forecast template: %w", err)
+	}
+
+	return &weatherCmdForecast{
+		BaseCommand: command.NewBaseCommand(),
+		dbConn:      params.DbConn,
+		botProxy:    params.BotProxy,
+		lookup:      locationLookup{dbConn: params.DbConn, provider: weatherProvider, command: "/weather forecast"},
+		provider:    weatherProvider,
+		cache: ttlcache.New[string, []provider.ForecastPeriod](
+			ttlcache.WithTTL[string, []provider.ForecastPeriod](forecastCacheTTL),
+			ttlcache.WithDisableTouchOnHit[string, []provider.ForecastPeriod]()),
+		locations: ttlcache.New[string, dbmodels.WeatherPollingLocations](
+			ttlcache.WithTTL[string, dbmodels.WeatherPollingLocations](forecastLocationTTL)),
+		msgTemplate: msgTemplate,
+		logger:      params.Logger,
+	}, nil
+}
+
+// Execute shows the forecast at the given location, or at each of the chat's locations
+// /weather forecast [location] [--hourly|--daily] [--days=N]
+func (w *weatherCmdForecast) Execute(ctx context.Context, params models.CommandParams) error {
+	req, args, err := parseForecastArgs(params.Args)
+	if err != nil {
+		return err
+	}
+
+	locations, err := w.lookup.lookup(ctx, params.Message.AssumedChatID(), args)
+	var ambiguousErr *ambiguousLocationError
+	if errors.As(err, &ambiguousErr) {
+		_, err = params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", ambiguousErr.Error()))
+		if err != nil {
+			return fmt.Errorf("failed to send reply: %w", err)
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	chatUnits, err := getChatUnits(ctx, w.dbConn, params.Message.AssumedChatID())
+	if err != nil {
+		return err
+	}
+
+	//each location gets its own message so its buttons only switch that forecast
+	for _, location := range locations {
+		w.locations.Set(location.Key, location, ttlcache.DefaultTTL)
+
+		text, err := w.render(ctx, location, req, chatUnits)
+		if err != nil {
+			return err
+		}
+
+		msg := util.NewMessageReply(params.Message.InnerMsg(), tgbotapi.ModeHTML, text)
+		msg.ReplyMarkup = forecastKeyboard(req, location.Key)
+		_, err = params.BotProxy.Send(msg)
+		if err != nil {
+			return fmt.Errorf("failed to send reply: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// handleCallback switches a forecast message to the view of the button pressed
+func (w *weatherCmdForecast) handleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	if query.Message == nil {
+		return fmt.Errorf("the forecast message is too old to change")
+	}
+
+	req, locationKey, err := parseForecastCallback(query.Data)
+	if err != nil {
+		return err
+	}
+
+	location, err := w.getLocation(ctx, locationKey)
+	if err != nil {
+		return err
+	}
+
+	chatUnits, err := getChatUnits(ctx, w.dbConn, query.Message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	text, err := w.render(ctx, location, req, chatUnits)
+	if err != nil {
+		return err
+	}
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, text,
+		forecastKeyboard(req, locationKey))
+	edit.ParseMode = tgbotapi.ModeHTML
+	_, err = w.botProxy.Send(edit)
+	//pressing the button of the view already shown changes nothing, which telegram treats as an error
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		return fmt.Errorf("failed to edit forecast: %w", err)
+	}
+
+	return nil
+}
+
+// getLocation returns the location a forecast button is for, looked up in the db if it wasn't shown recently
+func (w *weatherCmdForecast) getLocation(ctx context.Context, locationKey string) (dbmodels.WeatherPollingLocations, error) {
+	if item := w.locations.Get(locationKey); item != nil {
+		return item.Value(), nil
+	}
+
+	location, err := getLocationByKey(ctx, w.dbConn, locationKey)
+	if err != nil {
+		return dbmodels.WeatherPollingLocations{}, err
+	} else if location.IsEmpty() {
+		return dbmodels.WeatherPollingLocations{}, fmt.Errorf("this forecast has expired, run /weather forecast again")
+	}
+
+	return location, nil
+}
+
+func (w *weatherCmdForecast) render(ctx context.Context, location dbmodels.WeatherPollingLocations, req forecastRequest, chatUnits units) (string, error) {
+	periods, err := w.getForecast(ctx, location, req.View)
+	if err != nil {
+		return "", fmt.Errorf("failed to get forecast for %s: %w", util.FirstNonZero(location.Name, location.Key), err)
+	}
+
+	rows := selectForecastRows(periods, req, time.Now())
+	if len(rows) == 0 {
+		return "", fmt.Errorf("no forecast available for %s", util.FirstNonZero(location.Name, location.Key))
+	}
+
+	labelWidth := 0
+	for _, row := range rows {
+		labelWidth = max(labelWidth, len([]rune(row.Label)))
+	}
+
+	msgBuffer := bytes.Buffer{}
+	err = w.msgTemplate.Execute(&msgBuffer, tgWeatherForecast{
+		WeatherPollingLocations: location,
+		Units:                   chatUnits,
+		View:                    req.View,
+		Rows:                    rows,
+		LabelWidth:              labelWidth,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render forecast: %w", err)
+	}
+
+	return msgBuffer.String(), nil
+}
+
+// getForecast returns the cached forecast of the view for the location, fetching it if it's missing or stale
+func (w *weatherCmdForecast) getForecast(ctx context.Context, location dbmodels.WeatherPollingLocations, view forecastView) ([]provider.ForecastPeriod, error) {
+	cacheKey := fmt.Sprintf("%s:%s", view, location.Key)
+	if item := w.cache.Get(cacheKey); item != nil {
+		w.logger.Trace().Msgf("Using cached %s forecast for %s", view, location.Key)
+		return item.Value(), nil
+	}
+
+	var periods []provider.ForecastPeriod
+	var err error
+	providerLocation := provider.Location{Latitude: location.Lat, Longitude: location.Lon}
+	if view == forecastHourly {
+		periods, err = w.provider.HourlyForecast(ctx, providerLocation)
+	} else {
+		periods, err = w.provider.Forecast(ctx, providerLocation)
+	}
+	if err != nil {
+		return nil, err
+	}
+	w.cache.Set(cacheKey, periods, ttlcache.DefaultTTL)
+
+	return periods, nil
+}
+
+// parseForecastArgs takes the flags out of the args, leaving the location
+func parseForecastArgs(args []string) (forecastRequest, []string, error) {
+	req := forecastRequest{View: forecastDaily}
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch {
+		case arg == "--hourly":
+			req.View = forecastHourly
+		case arg == "--daily":
+			req.View = forecastDaily
+		case strings.HasPrefix(arg, "--days="):
+			days, err := strconv.Atoi(strings.TrimPrefix(arg, "--days="))
+			if err != nil || days < 1 || days > maxForecastDays {
+				return forecastRequest{}, nil, fmt.Errorf("--days must be a number from 1 to %d", maxForecastDays)
+			}
+			req.Days = days
+		case strings.HasPrefix(arg, "--"):
+			return forecastRequest{}, nil, fmt.Errorf("unknown flag %s, use --hourly, --daily or --days=N", arg)
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	if req.Days == 0 {
+		req.Days = defaultDailyDays
+		if req.View == forecastHourly {
+			req.Days = defaultHourlyDays
+		}
+	}
+
+	return req, rest, nil
+}
+
+// forecastRow is a forecast period with the label it's shown under
+type forecastRow struct {
+	Label string
+	provider.ForecastPeriod
+}
+
+// selectForecastRows picks the periods of the request that haven't ended yet. Daily forecasts cover the days
+// asked for, hourly forecasts the hours, every third hour past the first day to keep the message readable.
+func selectForecastRows(periods []provider.ForecastPeriod, req forecastRequest, now time.Time) []forecastRow {
+	if len(periods) == 0 {
+		return nil
+	}
+
+	//days start at midnight where the location is, which is the timezone its periods are given in
+	loc := periods[0].Start.Location()
+	localNow := now.In(loc)
+	cutoff := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, req.Days)
+	limit, step := len(periods), 1
+	if req.View == forecastHourly {
+		cutoff = now.Add(time.Duration(req.Days) * 24 * time.Hour)
+		limit = req.Days * 24
+		if req.Days > 1 {
+			step = 3
+		}
+	}
+
+	rows := make([]forecastRow, 0, len(periods))
+	count := 0
+	for _, period := range periods {
+		if !period.End.After(now) || !period.Start.Before(cutoff) {
+			continue
+		}
+
+		count++
+		if count > limit {
+			break
+		} else if (count-1)%step != 0 {
+			continue
+		}
+
+		label := period.Name
+		if req.View == forecastHourly {
+			label = period.Start.Format("15:04")
+			if req.Days > 1 {
+				label = period.Start.Format("Mon 15:04")
+			}
+		}
+		rows = append(rows, forecastRow{Label: label, ForecastPeriod: period})
+	}
+
+	return rows
+}
+
+// forecastKeyboard has a button for each view of the forecast, the one shown is marked
+func forecastKeyboard(req forecastRequest, locationKey string) tgbotapi.InlineKeyboardMarkup {
+	buttons := make([]tgbotapi.InlineKeyboardButton, 0, 2)
+	for _, view := range []forecastView{forecastHourly, forecastDaily} {
+		text := strings.ToUpper(string(view[:1])) + string(view[1:])
+		if view == req.View {
+			text = "• " + text
+		}
+
+		days := req.Days
+		if view != req.View {
+			days = defaultDailyDays
+			if view == forecastHourly {
+				days = defaultHourlyDays
+			}
+		}
+
+		data := fmt.Sprintf("%s:%s:%d:%s", forecastCallbackPrefix, view, days, locationKey)
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(text, data))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(buttons)
+}
+
+func parseForecastCallback(data string) (forecastRequest, string, error) {
+	parts := strings.SplitN(data, ":", 4)
+	if len(parts) != 4 || parts[0] != forecastCallbackPrefix {
+		return forecastRequest{}, "", fmt.Errorf("invalid forecast callback %s", data)
+	}
+
+	view := forecastView(parts[1])
+	if view != forecastDaily && view != forecastHourly {
+		return forecastRequest{}, "", fmt.Errorf("invalid forecast view %s", parts[1])
+	}
+
+	days, err := strconv.Atoi(parts[2])
+	if err != nil || days < 1 || days > maxForecastDays {
+		return forecastRequest{}, "", fmt.Errorf("invalid forecast days %s", parts[2])
+	}
+
+	return forecastRequest{View: view, Days: days}, parts[3], nil
+}
+
+func (w *weatherCmdForecast) Description() string {
+	return "Show the forecast"
+}
+
+func (w *weatherCmdForecast) Help() string {
+	return "/weather forecast [location] [--hourly|--daily] [--days=N] - Show the forecast at a location or this chat's locations"
+}
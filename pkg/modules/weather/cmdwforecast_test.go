@@ -0,0 +1,183 @@
+package weather
+
+import (
+	"context"
+	"database/sql"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/tomato3017/tomatobot/pkg/bot/models/tgapi"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/modules"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/sqlmigrate"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseForecastArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		want     forecastRequest
+		wantRest []string
+		wantErr  bool
+	}{
+		{name: "defaults", args: nil, want: forecastRequest{View: forecastDaily, Days: defaultDailyDays}, wantRest: []string{}},
+		{name: "hourly", args: []string{"--hourly"}, want: forecastRequest{View: forecastHourly, Days: defaultHourlyDays}, wantRest: []string{}},
+		{name: "location and days", args: []string{"Springfield,", "--days=3", "IL"}, want: forecastRequest{View: forecastDaily, Days: 3}, wantRest: []string{"Springfield,", "IL"}},
+		{name: "too many days", args: []string{"--days=8"}, wantErr: true},
+		{name: "not a number", args: []string{"--days=x"}, wantErr: true},
+		{name: "unknown flag", args: []string{"--weekly"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, rest, err := parseForecastArgs(tt.args)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, req)
+			require.Equal(t, tt.wantRest, rest)
+		})
+	}
+}
+
+func Test_selectForecastRows(t *testing.T) {
+	loc := time.FixedZone("CDT", -5*60*60)
+	now := time.Date(2024, 7, 5, 14, 30, 0, 0, loc)
+
+	hours := make([]provider.ForecastPeriod, 0, 72)
+	for i := 0; i < 72; i++ {
+		start := time.Date(2024, 7, 5, 14+i, 0, 0, 0, loc)
+		hours = append(hours, provider.ForecastPeriod{Start: start, End: start.Add(time.Hour)})
+	}
+
+	rows := selectForecastRows(hours, forecastRequest{View: forecastHourly, Days: 1}, now)
+	require.Len(t, rows, 24)
+	require.Equal(t, "14:00", rows[0].Label)
+
+	rows = selectForecastRows(hours, forecastRequest{View: forecastHourly, Days: 2}, now)
+	require.Len(t, rows, 16)
+	require.Equal(t, "Fri 17:00", rows[1].Label)
+
+	days := make([]provider.ForecastPeriod, 0, 8)
+	for i := 0; i < 8; i++ {
+		start := time.Date(2024, 7, 5+i, 0, 0, 0, 0, loc)
+		days = append(days, provider.ForecastPeriod{Name: start.Weekday().String(), Start: start, End: start.AddDate(0, 0, 1)})
+	}
+
+	rows = selectForecastRows(days, forecastRequest{View: forecastDaily, Days: 3}, now)
+	require.Len(t, rows, 3)
+	require.Equal(t, "Friday", rows[0].Label)
+	require.Equal(t, "Sunday", rows[2].Label)
+}
+
+func Test_parseForecastCallback(t *testing.T) {
+	markup := forecastKeyboard(forecastRequest{View: forecastDaily, Days: 3}, "geo-2981n_9531w")
+	require.Len(t, markup.InlineKeyboard[0], 2)
+
+	hourly := markup.InlineKeyboard[0][0]
+	require.Equal(t, "Hourly", hourly.Text)
+	req, key, err := parseForecastCallback(*hourly.CallbackData)
+	require.NoError(t, err)
+	require.Equal(t, forecastRequest{View: forecastHourly, Days: defaultHourlyDays}, req)
+	require.Equal(t, "geo-2981n_9531w", key)
+
+	daily := markup.InlineKeyboard[0][1]
+	require.Equal(t, "• Daily", daily.Text)
+	req, _, err = parseForecastCallback(*daily.CallbackData)
+	require.NoError(t, err)
+	require.Equal(t, forecastRequest{View: forecastDaily, Days: 3}, req)
+
+	_, _, err = parseForecastCallback("wforecast:weekly:1:us-90210")
+	require.Error(t, err)
+}
+
+type TestWCmdForecastSuite struct {
+	suite.Suite
+
+	dbConn *bun.DB
+}
+
+func (t *TestWCmdForecastSuite) SetupTest() {
+	sqlDb, err := sql.Open(sqliteshim.ShimName, "file::memory:?cache=shared")
+	require.NoError(t.T(), err)
+
+	t.dbConn = bun.NewDB(sqlDb, sqlitedialect.New())
+	_, err = sqlmigrate.MigrateDbSchema(context.Background(), t.dbConn)
+	require.NoError(t.T(), err)
+}
+
+func (t *TestWCmdForecastSuite) TearDownTest() {
+	require.NoError(t.T(), t.dbConn.Close())
+}
+
+func (t *TestWCmdForecastSuite) Test_WCmdForecast_SwitchView() {
+	ctx := context.Background()
+	require.NoError(t.T(), setChatUnits(ctx, t.dbConn, 12345, unitsImperial))
+
+	location := provider.Location{Latitude: 29.8131, Longitude: -95.3098}
+	start := time.Now().Truncate(time.Hour)
+	mockProvider := provider.NewMockProvider(t.T())
+	mockProvider.EXPECT().HourlyForecast(mock.Anything, location).Return([]provider.ForecastPeriod{
+		{Start: start, End: start.Add(time.Hour), TempHigh: 35, TempLow: 35, PrecipitationChance: 20, WindSpeed: 4.47, WindDirection: 180},
+	}, nil).Once()
+	mockProvider.EXPECT().Forecast(mock.Anything, location).Return([]provider.ForecastPeriod{
+		{Name: "Today", Start: start, End: start.Add(time.Hour), TempHigh: 35, TempLow: 25, Summary: "Mostly <b>Sunny</b>"},
+	}, nil).Once()
+
+	mockBot := proxy.NewMockTGBotImplementation(t.T())
+	mockBot.EXPECT().Send(mock.MatchedBy(func(msg tgbotapi.MessageConfig) bool {
+		markup := msg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+		return msg.ParseMode == tgbotapi.ModeHTML &&
+			strings.Contains(msg.Text, "<b>29.8131,-95.3098</b> hourly forecast") &&
+			strings.Contains(msg.Text, "Temp°F Rain Wind mph") &&
+			strings.Contains(msg.Text, "     95  20%   10 S") &&
+			markup.InlineKeyboard[0][0].Text == "• Hourly"
+	})).Return(tgbotapi.Message{}, nil).Once()
+
+	cmd, err := newWeatherCmdForecast(modules.InitializeParameters{DbConn: t.dbConn, BotProxy: mockBot, Logger: zerolog.Nop()}, mockProvider)
+	require.NoError(t.T(), err)
+
+	msg := &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 12345}}
+	err = cmd.Execute(ctx, models.CommandParams{
+		Args:     []string{"29.8131,-95.3098", "--hourly"},
+		Message:  tgapi.NewTGBotMsg(msg, tgapi.TGBotAssumedIds{ChatID: 12345}, nil),
+		BotProxy: mockBot,
+	})
+	require.NoError(t.T(), err)
+
+	mockBot.EXPECT().Send(mock.MatchedBy(func(edit tgbotapi.EditMessageTextConfig) bool {
+		return edit.ChatID == 12345 && edit.MessageID == 2 &&
+			strings.Contains(edit.Text, "daily forecast") &&
+			strings.Contains(edit.Text, "Today  95/77    0%    0 N") &&
+			strings.Contains(edit.Text, "Mostly &lt;b&gt;Sunny&lt;/b&gt;") &&
+			edit.ReplyMarkup.InlineKeyboard[0][1].Text == "• Daily"
+	})).Return(tgbotapi.Message{}, nil).Once()
+
+	err = cmd.handleCallback(ctx, &tgbotapi.CallbackQuery{
+		Data:    "wforecast:daily:5:geo-2981n_9531w",
+		Message: &tgbotapi.Message{MessageID: 2, Chat: &tgbotapi.Chat{ID: 12345}},
+	})
+	require.NoError(t.T(), err)
+
+	// a location that was never shown or added can't be switched
+	err = cmd.handleCallback(ctx, &tgbotapi.CallbackQuery{
+		Data:    "wforecast:daily:5:us-90210",
+		Message: &tgbotapi.Message{MessageID: 3, Chat: &tgbotapi.Chat{ID: 12345}},
+	})
+	require.ErrorContains(t.T(), err, "expired")
+}
+
+func Test_RunWCmdForecastSuite(t *testing.T) {
+	suite.Run(t, new(TestWCmdForecastSuite))
+}
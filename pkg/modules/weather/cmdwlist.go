@@ -4,11 +4,11 @@ import (
 	"context"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
 	"github.com/tomato3017/tomatobot/pkg/command"
 	"github.com/tomato3017/tomatobot/pkg/command/middleware"
 	"github.com/tomato3017/tomatobot/pkg/command/models"
 	"github.com/tomato3017/tomatobot/pkg/modules"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
 	"github.com/tomato3017/tomatobot/pkg/util"
 	"github.com/uptrace/bun"
 	"strings"
@@ -28,7 +28,7 @@ func newWeatherCmdList(params modules.InitializeParameters) *weatherCmdList {
 
 func (w *weatherCmdList) Execute(ctx context.Context, params models.CommandParams) error {
 	//get the list of locations
-	dbLocations, err := w.getLocations(ctx, params)
+	dbLocations, err := getChatLocations(ctx, w.dbConn, params.Message.AssumedChatID())
 	if err != nil {
 		return fmt.Errorf("failed to get locations: %w", err)
 	}
@@ -44,7 +44,8 @@ func (w *weatherCmdList) Execute(ctx context.Context, params models.CommandParam
 	outStr := strings.Builder{}
 	outStr.WriteString("Weather locations:\n")
 	for _, loc := range dbLocations {
-		outStr.WriteString(fmt.Sprintf("%s \\- %s, %s\n", loc.ZipCode, loc.Name, loc.Country))
+		outStr.WriteString(notifications.EscapeText(tgbotapi.ModeMarkdownV2, fmt.Sprintf("%s - %s, %s", loc.Key, loc.Name, loc.Country)))
+		outStr.WriteString("\n")
 	}
 
 	_, err = params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), tgbotapi.ModeMarkdownV2, outStr.String()))
@@ -55,20 +56,6 @@ func (w *weatherCmdList) Execute(ctx context.Context, params models.CommandParam
 	return nil
 }
 
-func (w *weatherCmdList) getLocations(ctx context.Context, params models.CommandParams) ([]dbmodels.WeatherPollingLocations, error) {
-	//get the list of locations
-	var dbLocations []dbmodels.WeatherPollingLocations
-	err := w.dbConn.NewSelect().Model(&dbLocations).
-		Join("JOIN weather_poller_chats wp").
-		JoinOn("wp.poller_location_id = weather_polling_locations.id").
-		Where("wp.chat_id = ?", params.Message.AssumedChatID()).
-		Where("weather_polling_locations.polling=?", true).Scan(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return dbLocations, nil
-}
-
 func (w *weatherCmdList) Description() string {
 	return "List all weather locations"
 }
@@ -0,0 +1,140 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/rs/zerolog"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/modules"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"github.com/uptrace/bun"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// nowCacheTTL is how long current conditions are reused for a location, most stations only report every hour or so
+const nowCacheTTL = 5 * time.Minute
+
+//go:embed weathernow.tmpl
+var nowTemplateStr string
+
+type weatherCmdNow struct {
+	command.BaseCommand
+
+	dbConn      bun.IDB
+	lookup      locationLookup
+	provider    provider.Provider
+	cache       *ttlcache.Cache[string, provider.Conditions]
+	msgTemplate *template.Template
+	logger      zerolog.Logger
+}
+
+func newWeatherCmdNow(params modules.InitializeParameters, weatherProvider provider.Provider) (*weatherCmdNow, error) {
+	msgTemplate, err := parseTemplate("weathernow", nowTemplateStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse current conditions template: %w", err)
+	}
+
+	return &weatherCmdNow{
+		BaseCommand: command.NewBaseCommand(),
+		dbConn:      params.DbConn,
+		lookup:      locationLookup{dbConn: params.DbConn, provider: weatherProvider, command: "/weather now"},
+		provider:    weatherProvider,
+		cache: ttlcache.New[string, provider.Conditions](
+			ttlcache.WithTTL[string, provider.Conditions](nowCacheTTL),
+			ttlcache.WithDisableTouchOnHit[string, provider.Conditions]()),
+		msgTemplate: msgTemplate,
+		logger:      params.Logger,
+	}, nil
+}
+
+// Execute shows the current conditions at the given location, or at each of the chat's locations
+// /weather now [location]
+func (w *weatherCmdNow) Execute(ctx context.Context, params models.CommandParams) error {
+	locations, err := w.lookup.lookup(ctx, params.Message.AssumedChatID(), params.Args)
+	var ambiguousErr *ambiguousLocationError
+	if errors.As(err, &ambiguousErr) {
+		_, err = params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", ambiguousErr.Error()))
+		if err != nil {
+			return fmt.Errorf("failed to send reply: %w", err)
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	chatUnits, err := getChatUnits(ctx, w.dbConn, params.Message.AssumedChatID())
+	if err != nil {
+		return err
+	}
+
+	rendered := make([]string, 0, len(locations))
+	for _, location := range locations {
+		conditions, err := w.getConditions(ctx, location)
+		if err != nil {
+			return fmt.Errorf("failed to get current conditions for %s: %w", util.FirstNonZero(location.Name, location.Key), err)
+		}
+
+		msg, err := w.render(conditions, location, chatUnits)
+		if err != nil {
+			return err
+		}
+		rendered = append(rendered, msg)
+	}
+
+	for _, chunk := range util.SplitMessage(strings.Join(rendered, "\n"), util.TelegramMaxMessageLength) {
+		_, err = params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), tgbotapi.ModeHTML, chunk))
+		if err != nil {
+			return fmt.Errorf("failed to send reply: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// getConditions returns the cached conditions for the location, fetching them if they're missing or stale
+func (w *weatherCmdNow) getConditions(ctx context.Context, location dbmodels.WeatherPollingLocations) (provider.Conditions, error) {
+	if item := w.cache.Get(location.Key); item != nil {
+		w.logger.Trace().Msgf("Using cached conditions for %s", location.Key)
+		return item.Value(), nil
+	}
+
+	conditions, err := w.provider.CurrentConditions(ctx, provider.Location{Latitude: location.Lat, Longitude: location.Lon})
+	if err != nil {
+		return provider.Conditions{}, err
+	}
+	w.cache.Set(location.Key, conditions, ttlcache.DefaultTTL)
+
+	return conditions, nil
+}
+
+func (w *weatherCmdNow) render(conditions provider.Conditions, location dbmodels.WeatherPollingLocations, chatUnits units) (string, error) {
+	msgBuffer := bytes.Buffer{}
+	err := w.msgTemplate.Execute(&msgBuffer, tgWeatherNow{
+		Conditions:              conditions,
+		WeatherPollingLocations: location,
+		Units:                   chatUnits,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render current conditions: %w", err)
+	}
+
+	return msgBuffer.String(), nil
+}
+
+func (w *weatherCmdNow) Description() string {
+	return "Show the current conditions"
+}
+
+func (w *weatherCmdNow) Help() string {
+	return "/weather now [location] - Show the current conditions at a location or this chat's locations"
+}
@@ -0,0 +1,128 @@
+package weather
+
+import (
+	"context"
+	"database/sql"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/bot/models/tgapi"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/modules"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/sqlmigrate"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+	"strings"
+	"testing"
+	"time"
+)
+
+type TestWCmdNowSuite struct {
+	suite.Suite
+
+	dbConn *bun.DB
+}
+
+func (t *TestWCmdNowSuite) SetupTest() {
+	sqlDb, err := sql.Open(sqliteshim.ShimName, "file::memory:?cache=shared")
+	require.NoError(t.T(), err)
+
+	t.dbConn = bun.NewDB(sqlDb, sqlitedialect.New())
+	_, err = sqlmigrate.MigrateDbSchema(context.Background(), t.dbConn)
+	require.NoError(t.T(), err)
+}
+
+func (t *TestWCmdNowSuite) TearDownTest() {
+	require.NoError(t.T(), t.dbConn.Close())
+}
+
+func (t *TestWCmdNowSuite) newCmd(mockProvider *provider.MockProvider) *weatherCmdNow {
+	cmd, err := newWeatherCmdNow(modules.InitializeParameters{DbConn: t.dbConn, Logger: zerolog.Nop()}, mockProvider)
+	require.NoError(t.T(), err)
+	return cmd
+}
+
+func (t *TestWCmdNowSuite) params(mockBot *proxy.MockTGBotImplementation, args ...string) models.CommandParams {
+	msg := &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 12345}}
+	return models.CommandParams{
+		Args:     args,
+		Message:  tgapi.NewTGBotMsg(msg, tgapi.TGBotAssumedIds{ChatID: 12345}, nil),
+		BotProxy: mockBot,
+	}
+}
+
+func (t *TestWCmdNowSuite) Test_WCmdNow_ChatLocations() {
+	location := dbmodels.WeatherPollingLocations{Name: "Beverly Hills", Country: "US", Key: "us-90210", Lat: 34.09, Lon: -118.41, Polling: true}
+	_, err := t.dbConn.NewInsert().Model(&location).Exec(context.Background())
+	require.NoError(t.T(), err)
+	_, err = t.dbConn.NewInsert().Model(&dbmodels.WeatherPollerChats{ChatID: 12345, PollerLocationID: location.ID}).Exec(context.Background())
+	require.NoError(t.T(), err)
+
+	mockProvider := provider.NewMockProvider(t.T())
+	mockProvider.EXPECT().CurrentConditions(mock.Anything, provider.Location{Latitude: 34.09, Longitude: -118.41}).Return(provider.Conditions{
+		ObservedAt:    time.Now(),
+		Description:   "clear sky",
+		Temperature:   24.44,
+		FeelsLike:     25,
+		Humidity:      40,
+		WindSpeed:     3.2,
+		WindDirection: 270,
+	}, nil).Once()
+
+	var sent []string
+	mockBot := proxy.NewMockTGBotImplementation(t.T())
+	mockBot.EXPECT().Send(mock.Anything).RunAndReturn(func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+		msg := c.(tgbotapi.MessageConfig)
+		require.Equal(t.T(), tgbotapi.ModeHTML, msg.ParseMode)
+		sent = append(sent, msg.Text)
+		return tgbotapi.Message{}, nil
+	}).Twice()
+
+	cmd := t.newCmd(mockProvider)
+	require.NoError(t.T(), cmd.Execute(context.Background(), t.params(mockBot)))
+	// the second request is served from the cache
+	require.NoError(t.T(), cmd.Execute(context.Background(), t.params(mockBot, "us-90210")))
+
+	require.Len(t.T(), sent, 2)
+	require.Equal(t.T(), sent[0], sent[1])
+	require.Contains(t.T(), sent[0], "<b>Beverly Hills</b>: clear sky")
+	require.Contains(t.T(), sent[0], "24.4°C, feels like 25.0°C")
+	require.Contains(t.T(), sent[0], "3.2 m/s W")
+}
+
+func (t *TestWCmdNowSuite) Test_WCmdNow_GivenLocation() {
+	mockProvider := provider.NewMockProvider(t.T())
+	mockProvider.EXPECT().Geocode(mock.Anything, "10115", "DE").Return(provider.Place{
+		Name: "Berlin", Country: "DE", PostalCode: "10115", Location: provider.Location{Latitude: 52.53, Longitude: 13.38},
+	}, nil).Once()
+	mockProvider.EXPECT().CurrentConditions(mock.Anything, provider.Location{Latitude: 52.53, Longitude: 13.38}).
+		Return(provider.Conditions{Description: "light rain"}, nil).Once()
+
+	mockBot := proxy.NewMockTGBotImplementation(t.T())
+	mockBot.EXPECT().Send(mock.MatchedBy(func(c tgbotapi.MessageConfig) bool {
+		return strings.Contains(c.Text, "<b>Berlin</b>: light rain")
+	})).Return(tgbotapi.Message{}, nil).Once()
+
+	require.NoError(t.T(), t.newCmd(mockProvider).Execute(context.Background(), t.params(mockBot, "10115,", "DE")))
+}
+
+func (t *TestWCmdNowSuite) Test_WCmdNow_NoLocations() {
+	err := t.newCmd(provider.NewMockProvider(t.T())).Execute(context.Background(), t.params(proxy.NewMockTGBotImplementation(t.T())))
+	require.ErrorContains(t.T(), err, "/weather add")
+}
+
+func Test_RunWCmdNowSuite(t *testing.T) {
+	suite.Run(t, new(TestWCmdNowSuite))
+}
+
+func TestCompass(t *testing.T) {
+	for degrees, want := range map[float64]string{0: "N", 22: "N", 23: "NE", 180: "S", 270: "W", 350: "N", 359.9: "N", 720: "N"} {
+		require.Equal(t, want, compass(degrees), degrees)
+	}
+}
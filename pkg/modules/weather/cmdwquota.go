@@ -0,0 +1,89 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/middleware"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/modules"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"github.com/uptrace/bun"
+	"strings"
+	"time"
+)
+
+type weatherCmdQuota struct {
+	command.BaseCommand
+	dbConn   bun.IDB
+	provider provider.Provider
+	cfg      config.WeatherConfig
+}
+
+func newWeatherCmdQuota(params modules.InitializeParameters, weatherProvider provider.Provider) *weatherCmdQuota {
+	return &weatherCmdQuota{
+		BaseCommand: command.NewBaseCommand(middleware.WithBotAdminPermission(), middleware.WithMaxArgs(0)),
+		dbConn:      params.DbConn,
+		provider:    weatherProvider,
+		cfg:         params.Cfg.Modules.Weather,
+	}
+}
+
+// Execute shows the provider's api calls against its limits and how often locations are polled because of them
+// /weather quota
+func (w *weatherCmdQuota) Execute(ctx context.Context, params models.CommandParams) error {
+	reply := fmt.Sprintf("%s api calls aren't limited", w.provider.Name())
+	quota, ok := w.provider.(provider.Quota)
+	if ok {
+		if usage, limited := quota.CallUsage(); limited {
+			locations, err := w.dbConn.NewSelect().Model((*dbmodels.WeatherPollingLocations)(nil)).
+				Where("polling = ?", true).
+				Count(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to count polled locations: %w", err)
+			}
+
+			reply = formatQuota(w.provider.Name(), usage, quota.MinPollingInterval(locations), w.cfg.PollingInterval, locations)
+		}
+	}
+
+	_, err := params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", reply))
+	if err != nil {
+		return fmt.Errorf("failed to send reply: %w", err)
+	}
+
+	return nil
+}
+
+func (w *weatherCmdQuota) Description() string {
+	return "Show the weather api calls made against the quota (bot admins only)"
+}
+
+func (w *weatherCmdQuota) Help() string {
+	return "/weather quota - Show the weather api calls made today against the daily and per minute limits"
+}
+
+func formatQuota(providerName string, usage provider.CallUsage, minInterval time.Duration, pollingInterval time.Duration, locations int) string {
+	lines := []string{fmt.Sprintf("%s api calls", providerName)}
+	if usage.DailyLimit > 0 {
+		lines = append(lines, fmt.Sprintf("Today: %d of %d (%.0f%%), starts over at %s", usage.DailyCalls, usage.DailyLimit,
+			float64(usage.DailyCalls)/float64(usage.DailyLimit)*100, usage.ResetsAt.Local().Format("15:04 MST")))
+	} else {
+		lines = append(lines, fmt.Sprintf("Today: %d, no daily limit", usage.DailyCalls))
+	}
+	if usage.MinuteLimit > 0 {
+		lines = append(lines, fmt.Sprintf("This minute: %d of %d", usage.MinuteCalls, usage.MinuteLimit))
+	}
+
+	if minInterval > pollingInterval {
+		lines = append(lines, fmt.Sprintf("Polling %d location(s) every %s instead of %s to stay within the quota",
+			locations, minInterval.Round(time.Second), pollingInterval))
+	} else {
+		lines = append(lines, fmt.Sprintf("Polling %d location(s) every %s", locations, pollingInterval))
+	}
+
+	return strings.Join(lines, "\n")
+}
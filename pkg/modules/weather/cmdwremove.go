@@ -12,6 +12,7 @@ import (
 	"github.com/tomato3017/tomatobot/pkg/notifications"
 	"github.com/tomato3017/tomatobot/pkg/util"
 	"github.com/uptrace/bun"
+	"strings"
 )
 
 type weatherCmdRemove struct {
@@ -24,29 +25,34 @@ type weatherCmdRemove struct {
 func newWeatherCmdRemove(params modules.InitializeParameters) *weatherCmdRemove {
 	return &weatherCmdRemove{
 		dbConn:      params.DbConn,
-		BaseCommand: command.NewBaseCommand(middleware.WithNArgs(1)),
+		BaseCommand: command.NewBaseCommand(middleware.WithMinArgs(1)),
 		logger:      params.Logger,
 		publisher:   params.Notifications,
 	}
 }
 
 func (w *weatherCmdRemove) Execute(ctx context.Context, params models.CommandParams) error {
-	zipCode := params.Args[0]
-	if !usZipCodeRegex.MatchString(zipCode) {
-		return fmt.Errorf("invalid zip code format, must be 5 digits")
+	locationKey, err := resolveLocationKey(strings.Join(params.Args, " "))
+	if err != nil {
+		return err
 	}
 
-	w.logger.Debug().Str("zip_code", zipCode).Int64("chat_id", params.Message.AssumedChatID()).Msg("Removing location")
-	err := w.removeLocationInDb(ctx, zipCode, params.Message.AssumedChatID())
+	w.logger.Debug().Str("location_key", locationKey).Int64("chat_id", params.Message.AssumedChatID()).Msg("Removing location")
+	err = w.removeLocationInDb(ctx, locationKey, params.Message.AssumedChatID())
 	if err != nil {
 		return fmt.Errorf("failed to remove location: %w", err)
 	}
 
-	err = w.removeLocationFromSubscriptions(params.Message.AssumedChatID(), zipCode)
+	err = w.removeLocationFromSubscriptions(params.Message.AssumedChatID(), locationKey)
 	if err != nil {
 		return fmt.Errorf("failed to remove subscriptions: %w", err)
 	}
 
+	err = w.removeLocationBriefing(ctx, params.Message.AssumedChatID(), locationKey)
+	if err != nil {
+		return fmt.Errorf("failed to remove briefing: %w", err)
+	}
+
 	_, err = params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", "Location removed successfully"))
 	if err != nil {
 		return fmt.Errorf("failed to send reply: %w", err)
@@ -55,14 +61,14 @@ func (w *weatherCmdRemove) Execute(ctx context.Context, params models.CommandPar
 	return nil
 }
 
-func (w *weatherCmdRemove) removeLocationInDb(ctx context.Context, zipCode string, chatID int64) error {
+func (w *weatherCmdRemove) removeLocationInDb(ctx context.Context, locationKey string, chatID int64) error {
 	var chats []dbmodels.WeatherPollerChats
 	err := w.dbConn.NewSelect().
 		Model(&chats).
 		Where("chat_id = ?", chatID).
 		Join("JOIN weather_polling_locations wpl").
 		JoinOn("wpl.id = weather_poller_chats.poller_location_id").
-		Where("wpl.zip_code = ?", zipCode).Scan(ctx)
+		Where("wpl.location_key = ?", locationKey).Scan(ctx)
 
 	if err != nil {
 		return fmt.Errorf("failed to get chat: %w", err)
@@ -80,7 +86,7 @@ func (w *weatherCmdRemove) removeLocationInDb(ctx context.Context, zipCode strin
 		count, err := tx.NewSelect().Model(&dbmodels.WeatherPollerChats{}).
 			Join("JOIN weather_polling_locations wpl").
 			JoinOn("wpl.id = weather_poller_chats.poller_location_id").
-			Where("wpl.zip_code = ?", zipCode).Count(ctx)
+			Where("wpl.location_key = ?", locationKey).Count(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to count locations: %w", err)
 		}
@@ -88,7 +94,7 @@ func (w *weatherCmdRemove) removeLocationInDb(ctx context.Context, zipCode strin
 			_, err = tx.NewUpdate().
 				Model(&dbmodels.WeatherPollingLocations{}).
 				Set("polling = ?", false).
-				Where("zip_code = ?", zipCode).
+				Where("location_key = ?", locationKey).
 				Exec(ctx)
 			if err != nil {
 				return fmt.Errorf("failed to update location: %w", err)
@@ -104,7 +110,7 @@ func (w *weatherCmdRemove) removeLocationInDb(ctx context.Context, zipCode strin
 	return nil
 }
 
-func (w *weatherCmdRemove) removeLocationFromSubscriptions(chatID int64, zipCode string) error {
+func (w *weatherCmdRemove) removeLocationFromSubscriptions(chatID int64, locationKey string) error {
 	//get all the subscriptions for the chat
 	subs, err := w.publisher.GetSubscriptions(chatID)
 	if err != nil {
@@ -112,8 +118,9 @@ func (w *weatherCmdRemove) removeLocationFromSubscriptions(chatID int64, zipCode
 	}
 
 	for _, sub := range subs {
-		for _, eventType := range weatherPublisherEventTypes {
-			topic := eventType.fullTopicPath(zipCode)
+		// the unknown topic isn't subscribed by /weather add, but chats may have subscribed it themselves
+		for _, eventType := range append(weatherPublisherEventTypes, eventTypeUnknown) {
+			topic := eventType.fullTopicPath(locationKey)
 			if sub.TopicPattern == topic {
 				err := w.publisher.Unsubscribe(sub.ID, chatID)
 				if err != nil {
@@ -126,10 +133,31 @@ func (w *weatherCmdRemove) removeLocationFromSubscriptions(chatID int64, zipCode
 	return nil
 }
 
+// removeLocationBriefing turns off the chat's briefing of the location, if it has one
+func (w *weatherCmdRemove) removeLocationBriefing(ctx context.Context, chatID int64, locationKey string) error {
+	var briefings []dbmodels.WeatherBriefings
+	err := w.dbConn.NewSelect().Model(&briefings).
+		Relation("Location").
+		Where("weather_briefings.chat_id = ?", chatID).
+		Where("location.location_key = ?", locationKey).
+		Scan(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get briefings: %w", err)
+	}
+
+	for _, briefing := range briefings {
+		if err := removeBriefing(ctx, w.dbConn, w.publisher, briefing); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (w *weatherCmdRemove) Description() string {
 	return "Remove a weather location"
 }
 
 func (w *weatherCmdRemove) Help() string {
-	return "/weather remove <zip>"
+	return "/weather remove <location key|zip|postal,country|lat,lon>"
 }
@@ -43,7 +43,7 @@ func (t *TestWCmdRemoveSuite) Test_WCmdRemove_removeLocationInDb_NoMoreChats() {
 	checkLocation := dbmodels.WeatherPollingLocations{
 		Name:    "SomethingVille",
 		Country: "US",
-		ZipCode: "90210",
+		Key:     "us-90210",
 		Lon:     -50.55,
 		Lat:     49.87,
 		Polling: true,
@@ -65,7 +65,7 @@ func (t *TestWCmdRemoveSuite) Test_WCmdRemove_removeLocationInDb_NoMoreChats() {
 		dbConn: t.dbConn,
 	}
 
-	err = w.removeLocationInDb(context.Background(), checkLocation.ZipCode, 12345)
+	err = w.removeLocationInDb(context.Background(), checkLocation.Key, 12345)
 	require.NoError(t.T(), err)
 
 	//Check if the chat was removed
@@ -104,7 +104,7 @@ func (t *TestWCmdRemoveSuite) Test_WCmdRemove_removeLocationInDb() {
 	checkLocation := dbmodels.WeatherPollingLocations{
 		Name:    "SomethingVille",
 		Country: "US",
-		ZipCode: "90210",
+		Key:     "us-90210",
 		Lon:     -50.55,
 		Lat:     49.87,
 		Polling: true,
@@ -130,7 +130,7 @@ func (t *TestWCmdRemoveSuite) Test_WCmdRemove_removeLocationInDb() {
 		dbConn: t.dbConn,
 	}
 
-	err = w.removeLocationInDb(context.Background(), checkLocation.ZipCode, 12345)
+	err = w.removeLocationInDb(context.Background(), checkLocation.Key, 12345)
 	require.NoError(t.T(), err)
 
 	//Check if the chat was removed
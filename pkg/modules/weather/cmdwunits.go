@@ -0,0 +1,63 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"github.com/tomato3017/tomatobot/pkg/command"
+	"github.com/tomato3017/tomatobot/pkg/command/middleware"
+	"github.com/tomato3017/tomatobot/pkg/command/models"
+	"github.com/tomato3017/tomatobot/pkg/modules"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"github.com/uptrace/bun"
+)
+
+type weatherCmdUnits struct {
+	command.BaseCommand
+	dbConn bun.IDB
+}
+
+func newWeatherCmdUnits(params modules.InitializeParameters) *weatherCmdUnits {
+	return &weatherCmdUnits{
+		BaseCommand: command.NewBaseCommand(middleware.WithMaxArgs(1)),
+		dbConn:      params.DbConn,
+	}
+}
+
+// Execute shows the chat's units, or sets them if given
+// /weather units [metric|imperial]
+func (w *weatherCmdUnits) Execute(ctx context.Context, params models.CommandParams) error {
+	chatId := params.Message.AssumedChatID()
+
+	var reply string
+	if len(params.Args) == 0 {
+		chatUnits, err := getChatUnits(ctx, w.dbConn, chatId)
+		if err != nil {
+			return err
+		}
+		reply = fmt.Sprintf("Weather is shown in %s units", chatUnits)
+	} else {
+		chatUnits, err := parseUnits(params.Args[0])
+		if err != nil {
+			return err
+		}
+		if err := setChatUnits(ctx, w.dbConn, chatId, chatUnits); err != nil {
+			return err
+		}
+		reply = fmt.Sprintf("Weather will be shown in %s units", chatUnits)
+	}
+
+	_, err := params.BotProxy.Send(util.NewMessageReply(params.Message.InnerMsg(), "", reply))
+	if err != nil {
+		return fmt.Errorf("failed to send reply: %w", err)
+	}
+
+	return nil
+}
+
+func (w *weatherCmdUnits) Description() string {
+	return "Show or set the units weather is shown in"
+}
+
+func (w *weatherCmdUnits) Help() string {
+	return "/weather units [metric|imperial] - Show or set the units weather is shown in for this chat"
+}
@@ -0,0 +1,129 @@
+package weather
+
+import (
+	"fmt"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	usZipCodeRegex   = regexp.MustCompile(`^\d{5}$`)
+	postalCodeRegex  = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9 -]{1,9}),\s*([A-Za-z]{2})$`)
+	coordinatesRegex = regexp.MustCompile(`^(-?\d{1,2}(?:\.\d+)?)\s*,\s*(-?\d{1,3}(?:\.\d+)?)$`)
+	locationKeyRegex = regexp.MustCompile(`^(?:[a-z]{2}-[a-z0-9]+|geo-\d+[ns]_\d+[ew])$`)
+
+	nonAlphanumericRegex = regexp.MustCompile(`[^a-z0-9]`)
+)
+
+// locationQuery is a location as given to /weather add, exactly one of the postal code, coordinates or name is set
+type locationQuery struct {
+	// PostalCode and Country are set for a postal code, Country is an ISO 3166 alpha-2 code
+	PostalCode  string
+	Country     string
+	Coordinates *provider.Location
+	// Name is a free-form place name to search for
+	Name string
+}
+
+// parseLocationQuery accepts a US zip code, <postal>,<country>, <lat>,<lon> or anything else as a place name
+func parseLocationQuery(raw string) (locationQuery, error) {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case raw == "":
+		return locationQuery{}, fmt.Errorf("no location given")
+	case usZipCodeRegex.MatchString(raw):
+		return locationQuery{PostalCode: raw, Country: "US"}, nil
+	case coordinatesRegex.MatchString(raw):
+		matches := coordinatesRegex.FindStringSubmatch(raw)
+		lat, _ := strconv.ParseFloat(matches[1], 64)
+		lon, _ := strconv.ParseFloat(matches[2], 64)
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return locationQuery{}, fmt.Errorf("coordinates %s are out of range", raw)
+		}
+		return locationQuery{Coordinates: &provider.Location{Latitude: lat, Longitude: lon}}, nil
+	case postalCodeRegex.MatchString(raw) && strings.ContainsAny(raw, "0123456789"):
+		matches := postalCodeRegex.FindStringSubmatch(raw)
+		return locationQuery{PostalCode: strings.ToUpper(strings.TrimSpace(matches[1])), Country: strings.ToUpper(matches[2])}, nil
+	default:
+		return locationQuery{Name: raw}, nil
+	}
+}
+
+// key is the location key of the query, empty for a name that has to be searched for first
+func (q locationQuery) key() string {
+	switch {
+	case q.PostalCode != "":
+		return postalCodeKey(q.PostalCode, q.Country)
+	case q.Coordinates != nil:
+		return coordinatesKey(*q.Coordinates)
+	default:
+		return ""
+	}
+}
+
+// resolveLocationKey turns a location key, postal code or coordinates into the location key
+func resolveLocationKey(raw string) (string, error) {
+	if key := strings.ToLower(strings.TrimSpace(raw)); locationKeyRegex.MatchString(key) {
+		return key, nil
+	}
+
+	query, err := parseLocationQuery(raw)
+	if err != nil {
+		return "", err
+	}
+
+	key := query.key()
+	if key == "" {
+		return "", fmt.Errorf("unknown location %s, use the key shown by /weather list", raw)
+	}
+
+	return key, nil
+}
+
+// postalCodeKey is the country followed by the postal code without spaces, e.g. gb-sw1a1aa
+func postalCodeKey(postalCode string, country string) string {
+	return fmt.Sprintf("%s-%s", strings.ToLower(country),
+		nonAlphanumericRegex.ReplaceAllString(strings.ToLower(postalCode), ""))
+}
+
+// coordinatesKey rounds the location to hundredths of a degree, around a kilometer, so nearby places share a
+// location. Topics can't have dots, so 29.8131,-95.3098 becomes geo-2981n_9531w.
+func coordinatesKey(location provider.Location) string {
+	lat := int64(math.Round(location.Latitude * 100))
+	lon := int64(math.Round(location.Longitude * 100))
+
+	latHemisphere, lonHemisphere := "n", "e"
+	if lat < 0 {
+		latHemisphere, lat = "s", -lat
+	}
+	if lon < 0 {
+		lonHemisphere, lon = "w", -lon
+	}
+
+	return fmt.Sprintf("geo-%d%s_%d%s", lat, latHemisphere, lon, lonHemisphere)
+}
+
+func formatCoordinates(location provider.Location) string {
+	return fmt.Sprintf("%.4f,%.4f", location.Latitude, location.Longitude)
+}
+
+// ambiguousLocationError is returned when a place name matches more than one place
+type ambiguousLocationError struct {
+	Name   string
+	Places []provider.Place
+	// Command is run with the coordinates of the place meant
+	Command string
+}
+
+func (e *ambiguousLocationError) Error() string {
+	out := strings.Builder{}
+	out.WriteString(fmt.Sprintf("Several places match %s, pick the one you meant by its coordinates:\n", e.Name))
+	for i, place := range e.Places {
+		out.WriteString(fmt.Sprintf("%d. %s: %s %s\n", i+1, place, e.Command, formatCoordinates(place.Location)))
+	}
+
+	return out.String()
+}
@@ -0,0 +1,53 @@
+package weather
+
+import (
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"testing"
+)
+
+func TestParseLocationQuery(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    locationQuery
+		wantKey string
+	}{
+		{"90210", locationQuery{PostalCode: "90210", Country: "US"}, "us-90210"},
+		{"SW1A 1AA,gb", locationQuery{PostalCode: "SW1A 1AA", Country: "GB"}, "gb-sw1a1aa"},
+		{"10115, DE", locationQuery{PostalCode: "10115", Country: "DE"}, "de-10115"},
+		{"29.8131,-95.3098", locationQuery{Coordinates: &provider.Location{Latitude: 29.8131, Longitude: -95.3098}}, "geo-2981n_9531w"},
+		{"-33.87, 151.21", locationQuery{Coordinates: &provider.Location{Latitude: -33.87, Longitude: 151.21}}, "geo-3387s_15121e"},
+		{"Paris,FR", locationQuery{Name: "Paris,FR"}, ""},
+		{"New York", locationQuery{Name: "New York"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			query, err := parseLocationQuery(tt.raw)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, query)
+			require.Equal(t, tt.wantKey, query.key())
+		})
+	}
+
+	_, err := parseLocationQuery("95.1,10")
+	require.Error(t, err)
+	_, err = parseLocationQuery(" ")
+	require.Error(t, err)
+}
+
+func TestResolveLocationKey(t *testing.T) {
+	for raw, want := range map[string]string{
+		"us-90210":         "us-90210",
+		"GEO-2981N_9531W":  "geo-2981n_9531w",
+		"90210":            "us-90210",
+		"29.8131,-95.3098": "geo-2981n_9531w",
+	} {
+		key, err := resolveLocationKey(raw)
+		require.NoError(t, err)
+		require.Equal(t, want, key, raw)
+	}
+
+	_, err := resolveLocationKey("Springfield")
+	require.ErrorContains(t, err, "/weather list")
+}
@@ -0,0 +1,143 @@
+package weather
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/uptrace/bun"
+	"strings"
+)
+
+// locationLookup finds the locations a command is about, the one given as its arguments or else the chat's own
+type locationLookup struct {
+	dbConn   bun.IDB
+	provider provider.Provider
+	// command is suggested with the coordinates of each place when a name is ambiguous
+	command string
+}
+
+// lookup returns the location given in args, which doesn't have to be added to the chat, or the chat's polled
+// locations if there are no args
+func (l locationLookup) lookup(ctx context.Context, chatId int64, args []string) ([]dbmodels.WeatherPollingLocations, error) {
+	if len(args) == 0 {
+		locations, err := getChatLocations(ctx, l.dbConn, chatId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chat locations: %w", err)
+		}
+		if len(locations) == 0 {
+			return nil, fmt.Errorf("no locations added to this chat, give one or add one with /weather add")
+		}
+		return locations, nil
+	}
+
+	raw := strings.Join(args, " ")
+	if key := strings.ToLower(raw); locationKeyRegex.MatchString(key) {
+		location, err := getLocationByKey(ctx, l.dbConn, key)
+		if err != nil {
+			return nil, err
+		} else if location.IsEmpty() {
+			return nil, fmt.Errorf("unknown location %s", raw)
+		}
+		return []dbmodels.WeatherPollingLocations{location}, nil
+	}
+
+	query, err := parseLocationQuery(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	//a location someone already added needs no geocoding
+	if key := query.key(); key != "" {
+		location, err := getLocationByKey(ctx, l.dbConn, key)
+		if err != nil {
+			return nil, err
+		} else if !location.IsEmpty() {
+			return []dbmodels.WeatherPollingLocations{location}, nil
+		}
+	}
+
+	var place provider.Place
+	switch {
+	case query.Name != "":
+		place, err = searchPlace(ctx, l.provider, query.Name, l.command)
+		if err != nil {
+			return nil, err
+		}
+	case query.PostalCode != "":
+		place, err = l.provider.Geocode(ctx, query.PostalCode, query.Country)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get location data for postal code: %w", err)
+		}
+	default:
+		place = provider.Place{Name: formatCoordinates(*query.Coordinates), Location: *query.Coordinates}
+	}
+
+	return []dbmodels.WeatherPollingLocations{{
+		Name:       place.Name,
+		Country:    place.Country,
+		Key:        locationQuery{PostalCode: query.PostalCode, Country: query.Country, Coordinates: &place.Location}.key(),
+		PostalCode: place.PostalCode,
+		Lat:        place.Latitude,
+		Lon:        place.Longitude,
+	}}, nil
+}
+
+// searchPlace finds the single place matching the name, returning an ambiguousLocationError suggesting the command
+// if there are several
+func searchPlace(ctx context.Context, weatherProvider provider.Provider, name string, command string) (provider.Place, error) {
+	places, err := weatherProvider.Search(ctx, name)
+	if err != nil {
+		return provider.Place{}, fmt.Errorf("failed to search for %s: %w", name, err)
+	}
+
+	//places sharing a key are the same location as far as polling goes
+	seen := make(map[string]struct{})
+	unique := make([]provider.Place, 0, len(places))
+	for _, place := range places {
+		key := coordinatesKey(place.Location)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, place)
+	}
+
+	switch len(unique) {
+	case 0:
+		return provider.Place{}, fmt.Errorf("%w: %s", provider.ErrLocationNotFound, name)
+	case 1:
+		return unique[0], nil
+	default:
+		return provider.Place{}, &ambiguousLocationError{Name: name, Places: unique, Command: command}
+	}
+}
+
+// getChatLocations returns the locations the chat has added and are still polled
+func getChatLocations(ctx context.Context, dbConn bun.IDB, chatId int64) ([]dbmodels.WeatherPollingLocations, error) {
+	var dbLocations []dbmodels.WeatherPollingLocations
+	err := dbConn.NewSelect().Model(&dbLocations).
+		Join("JOIN weather_poller_chats wp").
+		JoinOn("wp.poller_location_id = weather_polling_locations.id").
+		Where("wp.chat_id = ?", chatId).
+		Where("weather_polling_locations.polling=?", true).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dbLocations, nil
+}
+
+// getLocationByKey returns the location with the key, or an empty location if there is none
+func getLocationByKey(ctx context.Context, dbConn bun.IDB, key string) (dbmodels.WeatherPollingLocations, error) {
+	location := dbmodels.WeatherPollingLocations{}
+	if err := dbConn.NewSelect().Model(&location).Where("location_key = ?", key).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dbmodels.WeatherPollingLocations{}, nil
+		}
+		return dbmodels.WeatherPollingLocations{}, fmt.Errorf("failed to check if location is in db: %w", err)
+	}
+
+	return location, nil
+}
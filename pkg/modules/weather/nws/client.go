@@ -0,0 +1,433 @@
+package nws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	NWSAPIURL     = "https://api.weather.gov"
+	ZIPLOOKUPURL  = "https://api.zippopotam.us"
+	SEARCHURL     = "https://nominatim.openstreetmap.org"
+	nwsAcceptType = "application/geo+json"
+)
+
+// searchLimit is the most places a search returns
+const searchLimit = 5
+
+// defaultTimeout bounds each request, the api can be slow but shouldn't hang a poll or a command
+const defaultTimeout = 30 * time.Second
+
+var errNotFound = errors.New("not found")
+
+// Client serves weather data from the National Weather Service api at api.weather.gov. It only covers the US and
+// needs no api key, but the NWS asks every caller to identify itself with a User-Agent holding contact details.
+// The api has no geocoding, postal codes are looked up with zippopotam.us and names with OpenStreetMap's Nominatim.
+type Client struct {
+	client *http.Client
+
+	userAgent  string
+	url        string
+	geocodeURL string
+	searchURL  string
+}
+
+var _ provider.Provider = &Client{}
+
+func NewClient(userAgent string, options ...Option) (*Client, error) {
+	if userAgent == "" {
+		return nil, fmt.Errorf("a user agent is required by the NWS api")
+	}
+
+	c := &Client{
+		client:     &http.Client{Timeout: defaultTimeout},
+		userAgent:  userAgent,
+		url:        NWSAPIURL,
+		geocodeURL: ZIPLOOKUPURL,
+		searchURL:  SEARCHURL,
+	}
+
+	for _, option := range options {
+		if err := option(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *Client) Name() string {
+	return "National Weather Service"
+}
+
+func (c *Client) Geocode(ctx context.Context, postalCode string, country string) (provider.Place, error) {
+	if !strings.EqualFold(country, "US") {
+		return provider.Place{}, fmt.Errorf("%w: the National Weather Service only covers the US", provider.ErrOutsideCoverage)
+	}
+
+	var res ZipCodeResponse
+	err := c.getJSON(ctx, fmt.Sprintf("%s/us/%s", c.geocodeURL, url.PathEscape(postalCode)), &res)
+	if errors.Is(err, errNotFound) || (err == nil && len(res.Places) == 0) {
+		return provider.Place{}, fmt.Errorf("%w: %s,%s", provider.ErrLocationNotFound, postalCode, country)
+	} else if err != nil {
+		return provider.Place{}, fmt.Errorf("failed to look up postal code %s: %w", postalCode, err)
+	}
+
+	place := res.Places[0]
+	location, err := parseLocation(place.Latitude, place.Longitude)
+	if err != nil {
+		return provider.Place{}, err
+	}
+
+	return provider.Place{
+		Name:       place.PlaceName,
+		State:      place.State,
+		Country:    "US",
+		PostalCode: postalCode,
+		Location:   location,
+	}, nil
+}
+
+// Search looks the name up in OpenStreetMap's Nominatim, limited to the US
+func (c *Client) Search(ctx context.Context, query string) ([]provider.Place, error) {
+	qParams := url.Values{}
+	qParams.Add("q", query)
+	qParams.Add("format", "jsonv2")
+	qParams.Add("addressdetails", "1")
+	qParams.Add("countrycodes", "us")
+	qParams.Add("limit", strconv.Itoa(searchLimit))
+
+	res := make([]SearchResult, 0)
+	if err := c.getJSON(ctx, fmt.Sprintf("%s/search?%s", c.searchURL, qParams.Encode()), &res); err != nil {
+		return nil, fmt.Errorf("failed to search for %s: %w", query, err)
+	}
+
+	places := make([]provider.Place, 0, len(res))
+	for _, result := range res {
+		location, err := parseLocation(result.Lat, result.Lon)
+		if err != nil {
+			return nil, err
+		}
+
+		places = append(places, provider.Place{
+			Name:       result.Name,
+			State:      result.Address.State,
+			Country:    strings.ToUpper(result.Address.CountryCode),
+			PostalCode: result.Address.Postcode,
+			Location:   location,
+		})
+	}
+
+	return places, nil
+}
+
+func (c *Client) Alerts(ctx context.Context, location provider.Location) ([]provider.Alert, error) {
+	var res AlertsResponse
+	err := c.getJSON(ctx, fmt.Sprintf("%s/alerts/active?point=%s", c.url, formatPoint(location)), &res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active alerts: %w", err)
+	}
+
+	alerts := make([]provider.Alert, 0, len(res.Features))
+	for _, feature := range res.Features {
+		props := feature.Properties
+		alert := provider.Alert{
+			ID:          util.FirstNonZero(vtecEventID(props.Parameters.VTEC), props.ID),
+			Sender:      props.SenderName,
+			Event:       props.Event,
+			Headline:    props.Headline,
+			Description: props.Description,
+			Instruction: props.Instruction,
+			Severity:    severity(props.Severity),
+			Urgency:     urgency(props.Urgency),
+			Certainty:   certainty(props.Certainty),
+			Start:       props.Effective,
+			End:         props.Expires,
+		}
+		if props.Onset != nil {
+			alert.Start = *props.Onset
+		}
+		if props.Ends != nil {
+			alert.End = *props.Ends
+		}
+
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, nil
+}
+
+// vtecEventID returns the office, phenomenon, significance and event tracking number of the alert's VTEC, which
+// stay the same when the alert is extended or reworded while its CAP id and effective time change. Empty if the
+// alert has no VTEC, like special weather statements.
+func vtecEventID(vtec []string) string {
+	if len(vtec) == 0 {
+		return ""
+	}
+
+	// /O.EXT.KHGX.HT.Y.0012.240705T2100Z-240706T0200Z/
+	fields := strings.Split(strings.Trim(vtec[0], "/"), ".")
+	if len(fields) < 6 {
+		return ""
+	}
+
+	return strings.Join(fields[2:6], ".")
+}
+
+func (c *Client) CurrentConditions(ctx context.Context, location provider.Location) (provider.Conditions, error) {
+	pointRes, err := c.point(ctx, location)
+	if err != nil {
+		return provider.Conditions{}, err
+	}
+
+	var stations StationsResponse
+	if err := c.getJSON(ctx, pointRes.Properties.ObservationStations, &stations); err != nil {
+		return provider.Conditions{}, fmt.Errorf("failed to get observation stations: %w", err)
+	} else if len(stations.Features) == 0 {
+		return provider.Conditions{}, fmt.Errorf("no observation stations near %s", formatPoint(location))
+	}
+
+	stationId := stations.Features[0].Properties.StationIdentifier
+	var res ObservationResponse
+	err = c.getJSON(ctx, fmt.Sprintf("%s/stations/%s/observations/latest", c.url, url.PathEscape(stationId)), &res)
+	if err != nil {
+		return provider.Conditions{}, fmt.Errorf("failed to get latest observation from %s: %w", stationId, err)
+	}
+
+	props := res.Properties
+	conditions := provider.Conditions{
+		ObservedAt:    props.Timestamp,
+		Description:   props.TextDescription,
+		Temperature:   celsius(props.Temperature),
+		Humidity:      value(props.RelativeHumidity),
+		WindSpeed:     metersPerSecond(props.WindSpeed),
+		WindDirection: value(props.WindDirection),
+	}
+
+	switch {
+	case props.HeatIndex.Value != nil:
+		conditions.FeelsLike = celsius(props.HeatIndex)
+	case props.WindChill.Value != nil:
+		conditions.FeelsLike = celsius(props.WindChill)
+	default:
+		conditions.FeelsLike = conditions.Temperature
+	}
+
+	return conditions, nil
+}
+
+func (c *Client) Forecast(ctx context.Context, location provider.Location) ([]provider.ForecastPeriod, error) {
+	pointRes, err := c.point(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.forecast(ctx, pointRes.Properties.Forecast)
+}
+
+func (c *Client) HourlyForecast(ctx context.Context, location provider.Location) ([]provider.ForecastPeriod, error) {
+	pointRes, err := c.point(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.forecast(ctx, pointRes.Properties.ForecastHourly)
+}
+
+func (c *Client) Timezone(ctx context.Context, location provider.Location) (*time.Location, error) {
+	pointRes, err := c.point(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	tz, err := time.LoadLocation(pointRes.Properties.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone %s: %w", pointRes.Properties.TimeZone, err)
+	}
+
+	return tz, nil
+}
+
+// forecast gets one of the forecasts linked from a point, the twelve hour periods and the hourly ones share a format
+func (c *Client) forecast(ctx context.Context, forecastUrl string) ([]provider.ForecastPeriod, error) {
+	var res ForecastResponse
+	if err := c.getJSON(ctx, forecastUrl, &res); err != nil {
+		return nil, fmt.Errorf("failed to get forecast: %w", err)
+	}
+
+	periods := make([]provider.ForecastPeriod, 0, len(res.Properties.Periods))
+	for _, period := range res.Properties.Periods {
+		temp := period.Temperature
+		if period.TemperatureUnit == "F" {
+			temp = fahrenheitToCelsius(temp)
+		}
+
+		periods = append(periods, provider.ForecastPeriod{
+			Name:                util.FirstNonZero(period.Name, period.StartTime.Format("15:04")),
+			Start:               period.StartTime,
+			End:                 period.EndTime,
+			TempHigh:            temp,
+			TempLow:             temp,
+			PrecipitationChance: value(period.ProbabilityOfPrecipitation),
+			WindSpeed:           windSpeed(period.WindSpeed),
+			WindDirection:       windDirection(period.WindDirection),
+			Summary:             period.ShortForecast,
+		})
+	}
+
+	return periods, nil
+}
+
+// point resolves the location to its forecast office grid, which links the forecast and the nearby stations
+func (c *Client) point(ctx context.Context, location provider.Location) (PointResponse, error) {
+	var res PointResponse
+	if err := c.getJSON(ctx, fmt.Sprintf("%s/points/%s", c.url, formatPoint(location)), &res); err != nil {
+		return PointResponse{}, fmt.Errorf("failed to get point %s: %w", formatPoint(location), err)
+	}
+
+	return res, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, rawUrl string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept", nwsAcceptType)
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer util.CloseSafely(res.Body)
+
+	if res.StatusCode == http.StatusNotFound {
+		return errNotFound
+	} else if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", res.Status)
+	}
+
+	rawBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if err := json.Unmarshal(rawBody, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return nil
+}
+
+// formatPoint formats the location the way the api wants it, which redirects for more than 4 decimal places
+func formatPoint(location provider.Location) string {
+	return fmt.Sprintf("%.4f,%.4f", location.Latitude, location.Longitude)
+}
+
+func parseLocation(rawLat string, rawLon string) (provider.Location, error) {
+	lat, err := strconv.ParseFloat(rawLat, 64)
+	if err != nil {
+		return provider.Location{}, fmt.Errorf("invalid latitude %q: %w", rawLat, err)
+	}
+	lon, err := strconv.ParseFloat(rawLon, 64)
+	if err != nil {
+		return provider.Location{}, fmt.Errorf("invalid longitude %q: %w", rawLon, err)
+	}
+
+	return provider.Location{Latitude: lat, Longitude: lon}, nil
+}
+
+func severity(raw string) provider.Severity {
+	switch sev := provider.Severity(strings.ToLower(raw)); sev {
+	case provider.SeverityExtreme, provider.SeveritySevere, provider.SeverityModerate, provider.SeverityMinor:
+		return sev
+	default:
+		return provider.SeverityUnknown
+	}
+}
+
+func urgency(raw string) provider.Urgency {
+	switch urg := provider.Urgency(strings.ToLower(raw)); urg {
+	case provider.UrgencyImmediate, provider.UrgencyExpected, provider.UrgencyFuture, provider.UrgencyPast:
+		return urg
+	default:
+		return provider.UrgencyUnknown
+	}
+}
+
+func certainty(raw string) provider.Certainty {
+	switch cert := provider.Certainty(strings.ToLower(raw)); cert {
+	case provider.CertaintyObserved, provider.CertaintyLikely, provider.CertaintyPossible, provider.CertaintyUnlikely:
+		return cert
+	default:
+		return provider.CertaintyUnknown
+	}
+}
+
+func value(v QuantitativeValue) float64 {
+	if v.Value == nil {
+		return 0
+	}
+	return *v.Value
+}
+
+func celsius(v QuantitativeValue) float64 {
+	if strings.HasSuffix(v.UnitCode, ":degF") {
+		return fahrenheitToCelsius(value(v))
+	}
+	return value(v)
+}
+
+func metersPerSecond(v QuantitativeValue) float64 {
+	if strings.HasSuffix(v.UnitCode, ":km_h-1") {
+		return value(v) / 3.6
+	}
+	return value(v)
+}
+
+// windSpeed parses a forecast wind speed such as "10 mph" or "5 to 10 mph", taking the higher end of a range
+func windSpeed(raw string) float64 {
+	fields := strings.Fields(raw)
+	if len(fields) < 2 {
+		return 0
+	}
+
+	speed, err := strconv.ParseFloat(fields[len(fields)-2], 64)
+	if err != nil {
+		return 0
+	}
+
+	switch fields[len(fields)-1] {
+	case "mph":
+		return speed * 0.44704
+	case "km/h":
+		return speed / 3.6
+	default:
+		return speed
+	}
+}
+
+var compassDegrees = map[string]float64{
+	"N": 0, "NNE": 22.5, "NE": 45, "ENE": 67.5, "E": 90, "ESE": 112.5, "SE": 135, "SSE": 157.5,
+	"S": 180, "SSW": 202.5, "SW": 225, "WSW": 247.5, "W": 270, "WNW": 292.5, "NW": 315, "NNW": 337.5,
+}
+
+// windDirection turns a forecast compass point into degrees, north for variable or missing directions
+func windDirection(raw string) float64 {
+	return compassDegrees[strings.ToUpper(raw)]
+}
+
+func fahrenheitToCelsius(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
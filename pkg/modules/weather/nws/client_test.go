@@ -0,0 +1,173 @@
+package nws
+
+import (
+	"context"
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"testing"
+	"time"
+)
+
+const testUserAgent = "(tomatobot tests, test@example.com)"
+
+var testLocation = provider.Location{Latitude: 29.8131, Longitude: -95.3098}
+
+func newTestClient(t *testing.T) *Client {
+	client, err := NewClient(testUserAgent)
+	require.NoError(t, err)
+	return client
+}
+
+func TestNewClient_RequiresUserAgent(t *testing.T) {
+	_, err := NewClient("")
+	require.Error(t, err)
+}
+
+func TestClient_Geocode(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(ZIPLOOKUPURL).Get("/us/77093").Reply(200).File("testdata/zipcode.json")
+	gock.New(ZIPLOOKUPURL).Get("/us/00000").Reply(404).JSON(map[string]any{})
+
+	client := newTestClient(t)
+	place, err := client.Geocode(context.TODO(), "77093", "US")
+	require.NoError(t, err)
+	require.Equal(t, provider.Place{Name: "Houston", State: "TX", Country: "US", PostalCode: "77093", Location: testLocation}, place)
+
+	_, err = client.Geocode(context.TODO(), "00000", "US")
+	require.ErrorIs(t, err, provider.ErrLocationNotFound)
+	require.True(t, gock.IsDone())
+
+	_, err = client.Geocode(context.TODO(), "10115", "DE")
+	require.ErrorIs(t, err, provider.ErrOutsideCoverage)
+}
+
+func TestClient_Search(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(SEARCHURL).Get("/search").
+		MatchParam("q", "Springfield").
+		MatchParam("countrycodes", "us").
+		MatchHeader("User-Agent", "tomatobot tests").
+		Reply(200).File("testdata/search.json")
+
+	places, err := newTestClient(t).Search(context.TODO(), "Springfield")
+	require.NoError(t, err)
+	require.Len(t, places, 2)
+	require.Equal(t, "Springfield, Illinois, US", places[0].String())
+	require.InDelta(t, 39.799, places[0].Latitude, 0.001)
+	require.Equal(t, "65806", places[1].PostalCode)
+}
+
+func TestClient_Alerts(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(NWSAPIURL).Get("/alerts/active").
+		MatchParam("point", "29.8131,-95.3098").
+		MatchHeader("User-Agent", "tomatobot tests").
+		Reply(200).File("testdata/alerts_active.json")
+
+	alerts, err := newTestClient(t).Alerts(context.TODO(), testLocation)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+
+	heat := alerts[0]
+	// updates of the advisory share its VTEC event, not its CAP id
+	require.Equal(t, "KHGX.HT.Y.0012", heat.ID)
+	require.Equal(t, "Heat Advisory", heat.Event)
+	require.Equal(t, "NWS Houston/Galveston TX", heat.Sender)
+	require.Equal(t, provider.SeverityModerate, heat.Severity)
+	require.Equal(t, provider.UrgencyExpected, heat.Urgency)
+	require.Equal(t, provider.CertaintyLikely, heat.Certainty)
+	require.NotEmpty(t, heat.Instruction)
+	// onset and ends win over effective and expires
+	require.True(t, heat.Start.Equal(time.Date(2024, 7, 5, 21, 0, 0, 0, time.UTC)))
+	require.True(t, heat.End.Equal(time.Date(2024, 7, 6, 2, 0, 0, 0, time.UTC)))
+
+	storm := alerts[1]
+	require.Equal(t, "urn:oid:2.49.0.1.840.0.2a7d44c1.001.1", storm.ID)
+	require.Equal(t, provider.SeveritySevere, storm.Severity)
+	require.Equal(t, provider.UrgencyImmediate, storm.Urgency)
+	require.Equal(t, provider.CertaintyObserved, storm.Certainty)
+	require.Empty(t, storm.Instruction)
+	require.True(t, storm.Start.Equal(time.Date(2024, 7, 5, 20, 10, 0, 0, time.UTC)))
+	require.True(t, storm.End.Equal(time.Date(2024, 7, 5, 21, 15, 0, 0, time.UTC)))
+}
+
+func TestClient_CurrentConditions(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(NWSAPIURL).Get("/points/29.8131,-95.3098").Reply(200).File("testdata/points.json")
+	gock.New(NWSAPIURL).Get("/gridpoints/HGX/66,99/stations").Reply(200).File("testdata/stations.json")
+	gock.New(NWSAPIURL).Get("/stations/KIAH/observations/latest").Reply(200).File("testdata/observation_latest.json")
+
+	conditions, err := newTestClient(t).CurrentConditions(context.TODO(), testLocation)
+	require.NoError(t, err)
+	require.Equal(t, "Partly Cloudy", conditions.Description)
+	require.Equal(t, 35.0, conditions.Temperature)
+	require.Equal(t, 42.2, conditions.FeelsLike)
+	require.Equal(t, 53.1, conditions.Humidity)
+	require.InDelta(t, 5.0, conditions.WindSpeed, 0.001)
+	require.Equal(t, 180.0, conditions.WindDirection)
+	require.True(t, gock.IsDone())
+}
+
+func TestClient_Forecast(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(NWSAPIURL).Get("/points/29.8131,-95.3098").Reply(200).File("testdata/points.json")
+	gock.New(NWSAPIURL).Get("/gridpoints/HGX/66,99/forecast").Reply(200).File("testdata/forecast.json")
+
+	periods, err := newTestClient(t).Forecast(context.TODO(), testLocation)
+	require.NoError(t, err)
+	require.Len(t, periods, 2)
+
+	require.Equal(t, "This Afternoon", periods[0].Name)
+	require.InDelta(t, 35.0, periods[0].TempHigh, 0.001)
+	require.Equal(t, 20.0, periods[0].PrecipitationChance)
+	require.Equal(t, "Slight Chance Showers And Thunderstorms", periods[0].Summary)
+	require.InDelta(t, 4.47, periods[0].WindSpeed, 0.01)
+	require.Equal(t, 180.0, periods[0].WindDirection)
+
+	require.InDelta(t, 25.0, periods[1].TempLow, 0.001)
+	require.Zero(t, periods[1].PrecipitationChance)
+}
+
+func TestClient_HourlyForecast(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(NWSAPIURL).Get("/points/29.8131,-95.3098").Reply(200).File("testdata/points.json")
+	gock.New(NWSAPIURL).Get("/gridpoints/HGX/66,99/forecast/hourly").Reply(200).File("testdata/forecast_hourly.json")
+
+	periods, err := newTestClient(t).HourlyForecast(context.TODO(), testLocation)
+	require.NoError(t, err)
+	require.Len(t, periods, 2)
+
+	// hourly periods have no name of their own
+	require.Equal(t, "15:00", periods[0].Name)
+	require.Equal(t, 202.5, periods[0].WindDirection)
+	require.Equal(t, 24.0, periods[1].PrecipitationChance)
+	// the higher end of the range
+	require.InDelta(t, 6.71, periods[1].WindSpeed, 0.01)
+	require.True(t, gock.IsDone())
+}
+
+func TestClient_Timezone(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(NWSAPIURL).Get("/points/29.8131,-95.3098").Reply(200).File("testdata/points.json")
+
+	tz, err := newTestClient(t).Timezone(context.TODO(), testLocation)
+	require.NoError(t, err)
+	require.Equal(t, "America/Chicago", tz.String())
+}
+
+func TestClient_ErrorStatus(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(NWSAPIURL).Get("/alerts/active").Reply(503)
+
+	_, err := newTestClient(t).Alerts(context.TODO(), testLocation)
+	require.ErrorContains(t, err, "503")
+}
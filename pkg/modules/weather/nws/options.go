@@ -0,0 +1,33 @@
+package nws
+
+import "net/http"
+
+type Option func(c *Client) error
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) error {
+		c.client = client
+		return nil
+	}
+}
+
+func WithBaseURL(url string) Option {
+	return func(c *Client) error {
+		c.url = url
+		return nil
+	}
+}
+
+func WithGeocodeURL(url string) Option {
+	return func(c *Client) error {
+		c.geocodeURL = url
+		return nil
+	}
+}
+
+func WithSearchURL(url string) Option {
+	return func(c *Client) error {
+		c.searchURL = url
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+package nws
+
+import "time"
+
+// QuantitativeValue is a measurement in the unit given by its WMO unit code, nil when the station didn't report it
+type QuantitativeValue struct {
+	UnitCode string   `json:"unitCode"`
+	Value    *float64 `json:"value"`
+}
+
+type PointResponse struct {
+	Properties struct {
+		Forecast            string `json:"forecast"`
+		ForecastHourly      string `json:"forecastHourly"`
+		ObservationStations string `json:"observationStations"`
+		TimeZone            string `json:"timeZone"`
+	} `json:"properties"`
+}
+
+type ForecastResponse struct {
+	Properties struct {
+		Periods []ForecastPeriod `json:"periods"`
+	} `json:"properties"`
+}
+
+type ForecastPeriod struct {
+	Number                     int               `json:"number"`
+	Name                       string            `json:"name"`
+	StartTime                  time.Time         `json:"startTime"`
+	EndTime                    time.Time         `json:"endTime"`
+	IsDaytime                  bool              `json:"isDaytime"`
+	Temperature                float64           `json:"temperature"`
+	TemperatureUnit            string            `json:"temperatureUnit"`
+	ProbabilityOfPrecipitation QuantitativeValue `json:"probabilityOfPrecipitation"`
+	WindSpeed                  string            `json:"windSpeed"`
+	WindDirection              string            `json:"windDirection"`
+	ShortForecast              string            `json:"shortForecast"`
+	DetailedForecast           string            `json:"detailedForecast"`
+}
+
+type StationsResponse struct {
+	Features []struct {
+		Properties struct {
+			StationIdentifier string `json:"stationIdentifier"`
+			Name              string `json:"name"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+type ObservationResponse struct {
+	Properties struct {
+		Timestamp        time.Time         `json:"timestamp"`
+		TextDescription  string            `json:"textDescription"`
+		Temperature      QuantitativeValue `json:"temperature"`
+		WindDirection    QuantitativeValue `json:"windDirection"`
+		WindSpeed        QuantitativeValue `json:"windSpeed"`
+		RelativeHumidity QuantitativeValue `json:"relativeHumidity"`
+		WindChill        QuantitativeValue `json:"windChill"`
+		HeatIndex        QuantitativeValue `json:"heatIndex"`
+	} `json:"properties"`
+}
+
+// AlertsResponse is the GeoJSON feature collection of active CAP alerts
+type AlertsResponse struct {
+	Features []struct {
+		Properties AlertProperties `json:"properties"`
+	} `json:"features"`
+}
+
+type AlertProperties struct {
+	ID          string     `json:"id"`
+	SenderName  string     `json:"senderName"`
+	Event       string     `json:"event"`
+	Headline    string     `json:"headline"`
+	Description string     `json:"description"`
+	Instruction string     `json:"instruction"`
+	Severity    string     `json:"severity"`
+	Urgency     string     `json:"urgency"`
+	Certainty   string     `json:"certainty"`
+	Effective   time.Time  `json:"effective"`
+	Onset       *time.Time `json:"onset"`
+	Expires     time.Time  `json:"expires"`
+	Ends        *time.Time `json:"ends"`
+	Parameters  struct {
+		// VTEC is the event tracking code of warnings, watches and advisories, which updates of the alert share
+		VTEC []string `json:"VTEC"`
+	} `json:"parameters"`
+}
+
+// ZipCodeResponse is a zippopotam.us zip code lookup
+type ZipCodeResponse struct {
+	PostCode string `json:"post code"`
+	Country  string `json:"country"`
+	Places   []struct {
+		PlaceName string `json:"place name"`
+		State     string `json:"state abbreviation"`
+		Latitude  string `json:"latitude"`
+		Longitude string `json:"longitude"`
+	} `json:"places"`
+}
+
+// SearchResult is a Nominatim search result
+type SearchResult struct {
+	Name    string `json:"name"`
+	Lat     string `json:"lat"`
+	Lon     string `json:"lon"`
+	Address struct {
+		State       string `json:"state"`
+		Postcode    string `json:"postcode"`
+		CountryCode string `json:"country_code"`
+	} `json:"address"`
+}
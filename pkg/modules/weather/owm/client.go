@@ -3,20 +3,32 @@ package owm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/tomato3017/tomatobot/pkg/util"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	OWMAPIHOST        = "api.openweathermap.org"
 	OWMAPIONECALLPATH = "/data/3.0/onecall"
 	OWMAPIONECALLURL  = "https://" + OWMAPIHOST + OWMAPIONECALLPATH
+
+	OWMAPIGEOURL        = "http://" + OWMAPIHOST + "/geo/1.0"
+	OWMAPIGEOZIPPATH    = "/zip"
+	OWMAPIGEODIRECTPATH = "/direct"
 )
 
+// defaultTimeout bounds each request, so an api that stops responding doesn't hang a poll or a command
+const defaultTimeout = 30 * time.Second
+
+var ErrZipCodeNotFound = errors.New("postal code not found")
+
 type Location struct {
 	Latitude  float64
 	Longitude float64
@@ -27,47 +39,93 @@ type OpenWeatherMapClient struct {
 
 	apiKey string
 	url    string
+	geoURL string
+	// quota budgets the One Call requests, the geocoding api isn't billed
+	quota *QuotaManager
 }
 
-func (c *OpenWeatherMapClient) GetLocationDataForZipCode(ctx context.Context, zipCode string) (GeolocationResponse, error) {
-	rawUrl := fmt.Sprintf("http://api.openweathermap.org/geo/1.0/zip?zip=%s,us&appid=%s", zipCode, c.apiKey)
+// GetLocationDataForPostalCode looks up a postal code in the country, given as an ISO 3166 alpha-2 code
+func (c *OpenWeatherMapClient) GetLocationDataForPostalCode(ctx context.Context, postalCode string, country string) (GeolocationResponse, error) {
+	qParams := url.Values{}
+	qParams.Add("zip", fmt.Sprintf("%s,%s", postalCode, country))
+	qParams.Add("appid", c.apiKey)
 
-	req, err := http.NewRequest(http.MethodGet, rawUrl, nil)
+	var response GeolocationResponse
+	status, err := c.getGeocoding(ctx, OWMAPIGEOZIPPATH, qParams, &response)
+	if status == http.StatusNotFound {
+		return GeolocationResponse{}, fmt.Errorf("%w: %s,%s", ErrZipCodeNotFound, postalCode, country)
+	} else if err != nil {
+		return GeolocationResponse{}, fmt.Errorf("failed to get location data for postal code %s,%s: %w", postalCode, country, err)
+	}
+
+	return response, nil
+}
+
+// GetLocationDataForName finds up to limit places matching a name in the form city[,state][,country]
+func (c *OpenWeatherMapClient) GetLocationDataForName(ctx context.Context, name string, limit int) ([]DirectGeolocationResponse, error) {
+	qParams := url.Values{}
+	qParams.Add("q", name)
+	qParams.Add("limit", strconv.Itoa(limit))
+	qParams.Add("appid", c.apiKey)
+
+	response := make([]DirectGeolocationResponse, 0)
+	if _, err := c.getGeocoding(ctx, OWMAPIGEODIRECTPATH, qParams, &response); err != nil {
+		return nil, fmt.Errorf("failed to get location data for %s: %w", name, err)
+	}
+
+	return response, nil
+}
+
+// getGeocoding calls the geocoding api, returning the response status along with any error
+func (c *OpenWeatherMapClient) getGeocoding(ctx context.Context, path string, qParams url.Values, out any) (int, error) {
+	rawUrl := fmt.Sprintf("%s%s?%s", c.geoURL, path, qParams.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
 	if err != nil {
-		return GeolocationResponse{}, fmt.Errorf("failed to create request: %w", err)
+		return 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	res, err := c.client.Do(req)
 	if err != nil {
-		return GeolocationResponse{}, fmt.Errorf("failed to perform request: %w", err)
+		return 0, fmt.Errorf("failed to perform request: %w", err)
 	}
 	defer util.CloseSafely(res.Body)
 
 	if res.StatusCode != http.StatusOK {
-		return GeolocationResponse{}, fmt.Errorf("failed to get location data for zip code %s: %s", zipCode, res.Status)
+		return res.StatusCode, fmt.Errorf("unexpected status: %s", res.Status)
 	}
 
 	rawBody, err := io.ReadAll(res.Body)
 	if err != nil {
-		return GeolocationResponse{}, fmt.Errorf("failed to read response body: %w", err)
+		return res.StatusCode, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	var response GeolocationResponse
-	err = json.Unmarshal(rawBody, &response)
-	if err != nil {
-		return GeolocationResponse{}, fmt.Errorf("failed to unmarshal response: %w", err)
+	if err := json.Unmarshal(rawBody, out); err != nil {
+		return res.StatusCode, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	return response, nil
+	return res.StatusCode, nil
 }
 
+// CurrentWeatherByLocation returns only the alerts for the location
 func (c *OpenWeatherMapClient) CurrentWeatherByLocation(ctx context.Context, location Location) (OneCallCurrentResponse, error) {
-	finalURL, err := c.getRenderedURL_CurrentLoc(location)
+	return c.OneCall(ctx, location, "minutely", "hourly", "daily", "current")
+}
+
+// OneCall returns the parts of the One Call response that aren't excluded, in metric units
+func (c *OpenWeatherMapClient) OneCall(ctx context.Context, location Location, exclude ...string) (OneCallCurrentResponse, error) {
+	finalURL, err := c.getRenderedURL_CurrentLoc(location, exclude)
 	if err != nil {
 		return OneCallCurrentResponse{}, err
 	}
 
-	req, err := http.NewRequest(http.MethodGet, finalURL, nil)
+	if c.quota != nil {
+		if err := c.quota.Acquire(ctx); err != nil {
+			return OneCallCurrentResponse{}, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, finalURL, nil)
 	if err != nil {
 		return OneCallCurrentResponse{}, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -78,6 +136,10 @@ func (c *OpenWeatherMapClient) CurrentWeatherByLocation(ctx context.Context, loc
 	}
 	defer util.CloseSafely(res.Body)
 
+	if res.StatusCode != http.StatusOK {
+		return OneCallCurrentResponse{}, fmt.Errorf("failed to get weather: %s", res.Status)
+	}
+
 	rawBody, err := io.ReadAll(res.Body)
 	if err != nil {
 		return OneCallCurrentResponse{}, fmt.Errorf("failed to read response body: %w", err)
@@ -92,7 +154,7 @@ func (c *OpenWeatherMapClient) CurrentWeatherByLocation(ctx context.Context, loc
 	return response, nil
 }
 
-func (c *OpenWeatherMapClient) getRenderedURL_CurrentLoc(location Location) (string, error) {
+func (c *OpenWeatherMapClient) getRenderedURL_CurrentLoc(location Location, exclude []string) (string, error) {
 	baseURL, err := url.Parse(c.url)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse base URL: %w", err)
@@ -101,7 +163,10 @@ func (c *OpenWeatherMapClient) getRenderedURL_CurrentLoc(location Location) (str
 	qParams := url.Values{}
 	qParams.Add("lat", strconv.FormatFloat(location.Latitude, 'f', -1, 64))
 	qParams.Add("lon", strconv.FormatFloat(location.Longitude, 'f', -1, 64))
-	qParams.Add("exclude", "minutely,hourly,daily,current")
+	if len(exclude) > 0 {
+		qParams.Add("exclude", strings.Join(exclude, ","))
+	}
+	qParams.Add("units", "metric")
 	qParams.Add("appid", c.apiKey)
 
 	baseURL.RawQuery = qParams.Encode()
@@ -112,8 +177,9 @@ func (c *OpenWeatherMapClient) getRenderedURL_CurrentLoc(location Location) (str
 func NewOpenWeatherMapClient(apiKey string, options ...Option) (*OpenWeatherMapClient, error) {
 	c := &OpenWeatherMapClient{
 		apiKey: apiKey,
-		client: http.DefaultClient,
+		client: &http.Client{Timeout: defaultTimeout},
 		url:    OWMAPIONECALLURL,
+		geoURL: OWMAPIGEOURL,
 	}
 
 	err := setOptions(c, options...)
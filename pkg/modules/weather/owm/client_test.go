@@ -3,6 +3,7 @@ package owm
 import (
 	"context"
 	"github.com/h2non/gock"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -43,3 +44,22 @@ func TestOpenWeatherMapClient_CurrentWeatherByLocation(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 1, len(res.Alerts))
 }
+
+func TestOpenWeatherMapClient_OneCall_Quota(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(OWMAPIONECALLURL).Reply(200).JSON(jsonWeather)
+
+	quota := NewQuotaManager(1, 0, nil, nil, zerolog.Nop())
+	client, err := NewOpenWeatherMapClient("test", WithQuota(quota))
+	require.NoError(t, err)
+
+	_, err = client.OneCall(context.TODO(), Location{Latitude: 29.8131, Longitude: -95.3098})
+	require.NoError(t, err)
+
+	// the second call is refused before it's made
+	_, err = client.OneCall(context.TODO(), Location{Latitude: 29.8131, Longitude: -95.3098})
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+	require.True(t, gock.IsDone())
+	require.Equal(t, 1, quota.Usage().DailyCalls)
+}
@@ -4,5 +4,7 @@ import "context"
 
 type OpenWeatherMapIClient interface {
 	CurrentWeatherByLocation(ctx context.Context, location Location) (OneCallCurrentResponse, error)
-	GetLocationDataForZipCode(ctx context.Context, zipCode string) (GeolocationResponse, error)
+	OneCall(ctx context.Context, location Location, exclude ...string) (OneCallCurrentResponse, error)
+	GetLocationDataForPostalCode(ctx context.Context, postalCode string, country string) (GeolocationResponse, error)
+	GetLocationDataForName(ctx context.Context, name string, limit int) ([]DirectGeolocationResponse, error)
 }
@@ -78,27 +78,152 @@ func (_c *MockOpenWeatherMapIClient_CurrentWeatherByLocation_Call) RunAndReturn(
 	return _c
 }
 
-// GetLocationDataForZipCode provides a mock function with given fields: ctx, zipCode
-func (_m *MockOpenWeatherMapIClient) GetLocationDataForZipCode(ctx context.Context, zipCode string) (GeolocationResponse, error) {
-	ret := _m.Called(ctx, zipCode)
+// GetLocationDataForName provides a mock function with given fields: ctx, name, limit
+func (_m *MockOpenWeatherMapIClient) GetLocationDataForName(ctx context.Context, name string, limit int) ([]DirectGeolocationResponse, error) {
+	ret := _m.Called(ctx, name, limit)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetLocationDataForZipCode")
+		panic("no return value specified for GetLocationDataForName")
+	}
+
+	var r0 []DirectGeolocationResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) ([]DirectGeolocationResponse, error)); ok {
+		return rf(ctx, name, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) []DirectGeolocationResponse); ok {
+		r0 = rf(ctx, name, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]DirectGeolocationResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, name, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockOpenWeatherMapIClient_GetLocationDataForName_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetLocationDataForName'
+type MockOpenWeatherMapIClient_GetLocationDataForName_Call struct {
+	*mock.Call
+}
+
+// GetLocationDataForName is a helper method to define mock.On call
+//   - ctx context.Context
+//   - name string
+//   - limit int
+func (_e *MockOpenWeatherMapIClient_Expecter) GetLocationDataForName(ctx interface{}, name interface{}, limit interface{}) *MockOpenWeatherMapIClient_GetLocationDataForName_Call {
+	return &MockOpenWeatherMapIClient_GetLocationDataForName_Call{Call: _e.mock.On("GetLocationDataForName", ctx, name, limit)}
+}
+
+func (_c *MockOpenWeatherMapIClient_GetLocationDataForName_Call) Run(run func(ctx context.Context, name string, limit int)) *MockOpenWeatherMapIClient_GetLocationDataForName_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(int))
+	})
+	return _c
+}
+
+func (_c *MockOpenWeatherMapIClient_GetLocationDataForName_Call) Return(_a0 []DirectGeolocationResponse, _a1 error) *MockOpenWeatherMapIClient_GetLocationDataForName_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockOpenWeatherMapIClient_GetLocationDataForName_Call) RunAndReturn(run func(context.Context, string, int) ([]DirectGeolocationResponse, error)) *MockOpenWeatherMapIClient_GetLocationDataForName_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetLocationDataForPostalCode provides a mock function with given fields: ctx, postalCode, country
+func (_m *MockOpenWeatherMapIClient) GetLocationDataForPostalCode(ctx context.Context, postalCode string, country string) (GeolocationResponse, error) {
+	ret := _m.Called(ctx, postalCode, country)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLocationDataForPostalCode")
 	}
 
 	var r0 GeolocationResponse
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (GeolocationResponse, error)); ok {
-		return rf(ctx, zipCode)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (GeolocationResponse, error)); ok {
+		return rf(ctx, postalCode, country)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) GeolocationResponse); ok {
-		r0 = rf(ctx, zipCode)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) GeolocationResponse); ok {
+		r0 = rf(ctx, postalCode, country)
 	} else {
 		r0 = ret.Get(0).(GeolocationResponse)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, zipCode)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, postalCode, country)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockOpenWeatherMapIClient_GetLocationDataForPostalCode_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetLocationDataForPostalCode'
+type MockOpenWeatherMapIClient_GetLocationDataForPostalCode_Call struct {
+	*mock.Call
+}
+
+// GetLocationDataForPostalCode is a helper method to define mock.On call
+//   - ctx context.Context
+//   - postalCode string
+//   - country string
+func (_e *MockOpenWeatherMapIClient_Expecter) GetLocationDataForPostalCode(ctx interface{}, postalCode interface{}, country interface{}) *MockOpenWeatherMapIClient_GetLocationDataForPostalCode_Call {
+	return &MockOpenWeatherMapIClient_GetLocationDataForPostalCode_Call{Call: _e.mock.On("GetLocationDataForPostalCode", ctx, postalCode, country)}
+}
+
+func (_c *MockOpenWeatherMapIClient_GetLocationDataForPostalCode_Call) Run(run func(ctx context.Context, postalCode string, country string)) *MockOpenWeatherMapIClient_GetLocationDataForPostalCode_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockOpenWeatherMapIClient_GetLocationDataForPostalCode_Call) Return(_a0 GeolocationResponse, _a1 error) *MockOpenWeatherMapIClient_GetLocationDataForPostalCode_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockOpenWeatherMapIClient_GetLocationDataForPostalCode_Call) RunAndReturn(run func(context.Context, string, string) (GeolocationResponse, error)) *MockOpenWeatherMapIClient_GetLocationDataForPostalCode_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// OneCall provides a mock function with given fields: ctx, location, exclude
+func (_m *MockOpenWeatherMapIClient) OneCall(ctx context.Context, location Location, exclude ...string) (OneCallCurrentResponse, error) {
+	_va := make([]interface{}, len(exclude))
+	for _i := range exclude {
+		_va[_i] = exclude[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, location)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for OneCall")
+	}
+
+	var r0 OneCallCurrentResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, Location, ...string) (OneCallCurrentResponse, error)); ok {
+		return rf(ctx, location, exclude...)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, Location, ...string) OneCallCurrentResponse); ok {
+		r0 = rf(ctx, location, exclude...)
+	} else {
+		r0 = ret.Get(0).(OneCallCurrentResponse)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, Location, ...string) error); ok {
+		r1 = rf(ctx, location, exclude...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -106,31 +231,39 @@ func (_m *MockOpenWeatherMapIClient) GetLocationDataForZipCode(ctx context.Conte
 	return r0, r1
 }
 
-// MockOpenWeatherMapIClient_GetLocationDataForZipCode_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetLocationDataForZipCode'
-type MockOpenWeatherMapIClient_GetLocationDataForZipCode_Call struct {
+// MockOpenWeatherMapIClient_OneCall_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'OneCall'
+type MockOpenWeatherMapIClient_OneCall_Call struct {
 	*mock.Call
 }
 
-// GetLocationDataForZipCode is a helper method to define mock.On call
+// OneCall is a helper method to define mock.On call
 //   - ctx context.Context
-//   - zipCode string
-func (_e *MockOpenWeatherMapIClient_Expecter) GetLocationDataForZipCode(ctx interface{}, zipCode interface{}) *MockOpenWeatherMapIClient_GetLocationDataForZipCode_Call {
-	return &MockOpenWeatherMapIClient_GetLocationDataForZipCode_Call{Call: _e.mock.On("GetLocationDataForZipCode", ctx, zipCode)}
+//   - location Location
+//   - exclude ...string
+func (_e *MockOpenWeatherMapIClient_Expecter) OneCall(ctx interface{}, location interface{}, exclude ...interface{}) *MockOpenWeatherMapIClient_OneCall_Call {
+	return &MockOpenWeatherMapIClient_OneCall_Call{Call: _e.mock.On("OneCall",
+		append([]interface{}{ctx, location}, exclude...)...)}
 }
 
-func (_c *MockOpenWeatherMapIClient_GetLocationDataForZipCode_Call) Run(run func(ctx context.Context, zipCode string)) *MockOpenWeatherMapIClient_GetLocationDataForZipCode_Call {
+func (_c *MockOpenWeatherMapIClient_OneCall_Call) Run(run func(ctx context.Context, location Location, exclude ...string)) *MockOpenWeatherMapIClient_OneCall_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(string))
+		variadicArgs := make([]string, len(args)-2)
+		for i, a := range args[2:] {
+			if a != nil {
+				variadicArgs[i] = a.(string)
+			}
+		}
+		run(args[0].(context.Context), args[1].(Location), variadicArgs...)
 	})
 	return _c
 }
 
-func (_c *MockOpenWeatherMapIClient_GetLocationDataForZipCode_Call) Return(_a0 GeolocationResponse, _a1 error) *MockOpenWeatherMapIClient_GetLocationDataForZipCode_Call {
+func (_c *MockOpenWeatherMapIClient_OneCall_Call) Return(_a0 OneCallCurrentResponse, _a1 error) *MockOpenWeatherMapIClient_OneCall_Call {
 	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *MockOpenWeatherMapIClient_GetLocationDataForZipCode_Call) RunAndReturn(run func(context.Context, string) (GeolocationResponse, error)) *MockOpenWeatherMapIClient_GetLocationDataForZipCode_Call {
+func (_c *MockOpenWeatherMapIClient_OneCall_Call) RunAndReturn(run func(context.Context, Location, ...string) (OneCallCurrentResponse, error)) *MockOpenWeatherMapIClient_OneCall_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -17,3 +17,18 @@ func WithBaseURL(url string) Option {
 		return nil
 	}
 }
+
+func WithGeocodingURL(url string) Option {
+	return func(c *OpenWeatherMapClient) error {
+		c.geoURL = url
+		return nil
+	}
+}
+
+// WithQuota counts the One Call requests against the quota, failing them once the day's calls are used up
+func WithQuota(quota *QuotaManager) Option {
+	return func(c *OpenWeatherMapClient) error {
+		c.quota = quota
+		return nil
+	}
+}
@@ -0,0 +1,219 @@
+package owm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"strings"
+	"time"
+)
+
+// searchLimit is the most places a search returns, the geocoding api allows up to 5
+const searchLimit = 5
+
+// Provider serves weather data from the OpenWeatherMap One Call 3.0 api
+type Provider struct {
+	client OpenWeatherMapIClient
+	quota  *QuotaManager
+}
+
+var _ provider.Provider = &Provider{}
+var _ provider.Quota = &Provider{}
+
+// NewProvider creates a provider using the client, with the quota the client's calls are counted against or nil
+// if there's none
+func NewProvider(client OpenWeatherMapIClient, quota *QuotaManager) *Provider {
+	return &Provider{client: client, quota: quota}
+}
+
+func (p *Provider) Name() string {
+	return "OpenWeatherMap"
+}
+
+func (p *Provider) CallUsage() (provider.CallUsage, bool) {
+	if p.quota == nil {
+		return provider.CallUsage{}, false
+	}
+
+	return p.quota.Usage(), true
+}
+
+func (p *Provider) MinPollingInterval(locations int) time.Duration {
+	if p.quota == nil {
+		return 0
+	}
+
+	return p.quota.MinPollingInterval(locations)
+}
+
+func (p *Provider) Geocode(ctx context.Context, postalCode string, country string) (provider.Place, error) {
+	geoLoc, err := p.client.GetLocationDataForPostalCode(ctx, postalCode, country)
+	if errors.Is(err, ErrZipCodeNotFound) {
+		return provider.Place{}, fmt.Errorf("%w: %s,%s", provider.ErrLocationNotFound, postalCode, country)
+	} else if err != nil {
+		return provider.Place{}, err
+	}
+
+	return provider.Place{
+		Name:       geoLoc.Name,
+		Country:    geoLoc.Country,
+		PostalCode: postalCode,
+		Location:   provider.Location{Latitude: geoLoc.Lat, Longitude: geoLoc.Lon},
+	}, nil
+}
+
+func (p *Provider) Search(ctx context.Context, query string) ([]provider.Place, error) {
+	res, err := p.client.GetLocationDataForName(ctx, query, searchLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	places := make([]provider.Place, 0, len(res))
+	for _, geoLoc := range res {
+		places = append(places, provider.Place{
+			Name:     geoLoc.Name,
+			State:    geoLoc.State,
+			Country:  geoLoc.Country,
+			Location: provider.Location{Latitude: geoLoc.Lat, Longitude: geoLoc.Lon},
+		})
+	}
+
+	return places, nil
+}
+
+func (p *Provider) Alerts(ctx context.Context, location provider.Location) ([]provider.Alert, error) {
+	res, err := p.client.CurrentWeatherByLocation(ctx, toLocation(location))
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := make([]provider.Alert, 0, len(res.Alerts))
+	for _, alert := range res.Alerts {
+		alerts = append(alerts, provider.Alert{
+			Sender:      alert.SenderName,
+			Event:       alert.Event,
+			Headline:    alert.Event,
+			Description: alert.Description,
+			// One Call doesn't give the CAP severity, urgency or certainty of an alert, only its tags
+			Severity:  provider.SeverityUnknown,
+			Urgency:   provider.UrgencyUnknown,
+			Certainty: provider.CertaintyUnknown,
+			Start:     time.Unix(alert.Start, 0),
+			End:       time.Unix(alert.End, 0),
+			Tags:      alert.Tags,
+		})
+	}
+
+	return alerts, nil
+}
+
+func (p *Provider) CurrentConditions(ctx context.Context, location provider.Location) (provider.Conditions, error) {
+	res, err := p.client.OneCall(ctx, toLocation(location), "minutely", "hourly", "daily", "alerts")
+	if err != nil {
+		return provider.Conditions{}, err
+	}
+
+	if res.Current == nil {
+		return provider.Conditions{}, fmt.Errorf("no current conditions in response")
+	}
+
+	return provider.Conditions{
+		ObservedAt:    time.Unix(res.Current.Dt, 0),
+		Description:   describe(res.Current.Weather),
+		Temperature:   res.Current.Temp,
+		FeelsLike:     res.Current.FeelsLike,
+		Humidity:      res.Current.Humidity,
+		WindSpeed:     res.Current.WindSpeed,
+		WindDirection: res.Current.WindDeg,
+	}, nil
+}
+
+func (p *Provider) Forecast(ctx context.Context, location provider.Location) ([]provider.ForecastPeriod, error) {
+	res, err := p.client.OneCall(ctx, toLocation(location), "minutely", "hourly", "current", "alerts")
+	if err != nil {
+		return nil, err
+	}
+
+	loc := timezone(res)
+	periods := make([]provider.ForecastPeriod, 0, len(res.Daily))
+	for _, daily := range res.Daily {
+		start := time.Unix(daily.Dt, 0).In(loc)
+		day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+		periods = append(periods, provider.ForecastPeriod{
+			Name:                start.Weekday().String(),
+			Start:               day,
+			End:                 day.AddDate(0, 0, 1),
+			TempHigh:            daily.Temp.Max,
+			TempLow:             daily.Temp.Min,
+			PrecipitationChance: daily.Pop * 100,
+			WindSpeed:           daily.WindSpeed,
+			WindDirection:       daily.WindDeg,
+			Summary:             util.FirstNonZero(daily.Summary, describe(daily.Weather)),
+		})
+	}
+
+	return periods, nil
+}
+
+func (p *Provider) HourlyForecast(ctx context.Context, location provider.Location) ([]provider.ForecastPeriod, error) {
+	res, err := p.client.OneCall(ctx, toLocation(location), "minutely", "daily", "current", "alerts")
+	if err != nil {
+		return nil, err
+	}
+
+	loc := timezone(res)
+	periods := make([]provider.ForecastPeriod, 0, len(res.Hourly))
+	for _, hourly := range res.Hourly {
+		start := time.Unix(hourly.Dt, 0).In(loc)
+		periods = append(periods, provider.ForecastPeriod{
+			Name:                start.Format("15:04"),
+			Start:               start,
+			End:                 start.Add(time.Hour),
+			TempHigh:            hourly.Temp,
+			TempLow:             hourly.Temp,
+			PrecipitationChance: hourly.Pop * 100,
+			WindSpeed:           hourly.WindSpeed,
+			WindDirection:       hourly.WindDeg,
+			Summary:             describe(hourly.Weather),
+		})
+	}
+
+	return periods, nil
+}
+
+func (p *Provider) Timezone(ctx context.Context, location provider.Location) (*time.Location, error) {
+	res, err := p.client.OneCall(ctx, toLocation(location), "minutely", "hourly", "daily", "current", "alerts")
+	if err != nil {
+		return nil, err
+	}
+
+	tz, err := time.LoadLocation(res.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone %s: %w", res.Timezone, err)
+	}
+
+	return tz, nil
+}
+
+// timezone is the location's timezone, which the forecast days and hours are named in
+func timezone(res OneCallCurrentResponse) *time.Location {
+	if tz, err := time.LoadLocation(res.Timezone); err == nil {
+		return tz
+	}
+	return time.Local
+}
+
+func toLocation(location provider.Location) Location {
+	return Location{Latitude: location.Latitude, Longitude: location.Longitude}
+}
+
+func describe(conditions []WeatherCondition) string {
+	descriptions := make([]string, 0, len(conditions))
+	for _, condition := range conditions {
+		descriptions = append(descriptions, condition.Description)
+	}
+
+	return strings.Join(descriptions, ", ")
+}
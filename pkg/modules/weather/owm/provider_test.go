@@ -0,0 +1,135 @@
+package owm
+
+import (
+	"context"
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"testing"
+	"time"
+)
+
+var testLocation = provider.Location{Latitude: 29.8131, Longitude: -95.3098}
+
+func newTestProvider(t *testing.T) *Provider {
+	client, err := NewOpenWeatherMapClient("test")
+	require.NoError(t, err)
+	return NewProvider(client, nil)
+}
+
+func TestProvider_Alerts(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(OWMAPIONECALLURL).MatchParam("exclude", "minutely,hourly,daily,current").
+		Reply(200).JSON(jsonWeather)
+
+	alerts, err := newTestProvider(t).Alerts(context.TODO(), testLocation)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, "Heat Advisory", alerts[0].Event)
+	require.Equal(t, "NWS Houston/Galveston TX", alerts[0].Sender)
+	require.Equal(t, provider.SeverityUnknown, alerts[0].Severity)
+	require.Equal(t, int64(1720212720), alerts[0].Start.Unix())
+	require.Equal(t, int64(1720224000), alerts[0].End.Unix())
+}
+
+func TestProvider_CurrentConditions(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(OWMAPIONECALLURL).MatchParam("units", "metric").
+		Reply(200).File("testdata/onecall.json")
+
+	conditions, err := newTestProvider(t).CurrentConditions(context.TODO(), testLocation)
+	require.NoError(t, err)
+	require.Equal(t, provider.Conditions{
+		ObservedAt:    time.Unix(1720212720, 0),
+		Description:   "scattered clouds",
+		Temperature:   35.2,
+		FeelsLike:     42.1,
+		Humidity:      53,
+		WindSpeed:     4.6,
+		WindDirection: 180,
+	}, conditions)
+}
+
+func TestProvider_Forecast(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(OWMAPIONECALLURL).Reply(200).File("testdata/onecall.json")
+
+	periods, err := newTestProvider(t).Forecast(context.TODO(), testLocation)
+	require.NoError(t, err)
+	require.Len(t, periods, 2)
+
+	require.Equal(t, "Friday", periods[0].Name)
+	require.Equal(t, 36.4, periods[0].TempHigh)
+	require.Equal(t, 25.3, periods[0].TempLow)
+	require.InDelta(t, 20.0, periods[0].PrecipitationChance, 0.001)
+	require.Equal(t, "Expect a day of partly cloudy with rain", periods[0].Summary)
+	require.Equal(t, 24*time.Hour, periods[0].End.Sub(periods[0].Start))
+	require.Equal(t, 5.1, periods[0].WindSpeed)
+	require.Equal(t, 190.0, periods[0].WindDirection)
+	require.Equal(t, "Saturday", periods[1].Name)
+}
+
+func TestProvider_HourlyForecast(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(OWMAPIONECALLURL).MatchParam("exclude", "minutely,daily,current,alerts").
+		Reply(200).File("testdata/onecall.json")
+
+	periods, err := newTestProvider(t).HourlyForecast(context.TODO(), testLocation)
+	require.NoError(t, err)
+	require.Len(t, periods, 2)
+
+	// named in the location's timezone
+	require.Equal(t, "15:00", periods[0].Name)
+	require.Equal(t, 34.8, periods[0].TempHigh)
+	require.Equal(t, periods[0].TempHigh, periods[0].TempLow)
+	require.Equal(t, time.Hour, periods[0].End.Sub(periods[0].Start))
+	require.InDelta(t, 35.0, periods[1].PrecipitationChance, 0.001)
+	require.Equal(t, 185.0, periods[1].WindDirection)
+	require.Equal(t, "light rain", periods[1].Summary)
+}
+
+func TestProvider_Timezone(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(OWMAPIONECALLURL).MatchParam("exclude", "minutely,hourly,daily,current,alerts").
+		Reply(200).File("testdata/onecall.json")
+
+	tz, err := newTestProvider(t).Timezone(context.TODO(), testLocation)
+	require.NoError(t, err)
+	require.Equal(t, "America/Chicago", tz.String())
+}
+
+func TestProvider_Geocode(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(OWMAPIGEOURL).Get(OWMAPIGEOZIPPATH).MatchParam("zip", "SW1A,GB").
+		Reply(200).JSON(map[string]any{"zip": "SW1A", "name": "London", "lat": 51.5, "lon": -0.14, "country": "GB"})
+	gock.New(OWMAPIGEOURL).Get(OWMAPIGEOZIPPATH).MatchParam("zip", "00000,US").
+		Reply(404).JSON(map[string]any{"cod": "404", "message": "not found"})
+
+	place, err := newTestProvider(t).Geocode(context.TODO(), "SW1A", "GB")
+	require.NoError(t, err)
+	require.Equal(t, provider.Place{Name: "London", Country: "GB", PostalCode: "SW1A",
+		Location: provider.Location{Latitude: 51.5, Longitude: -0.14}}, place)
+
+	_, err = newTestProvider(t).Geocode(context.TODO(), "00000", "US")
+	require.ErrorIs(t, err, provider.ErrLocationNotFound)
+}
+
+func TestProvider_Search(t *testing.T) {
+	defer gock.Off()
+
+	gock.New(OWMAPIGEOURL).Get(OWMAPIGEODIRECTPATH).MatchParam("q", "London").MatchParam("limit", "5").
+		Reply(200).File("testdata/direct.json")
+
+	places, err := newTestProvider(t).Search(context.TODO(), "London")
+	require.NoError(t, err)
+	require.Len(t, places, 2)
+	require.Equal(t, "London, England, GB", places[0].String())
+	require.Equal(t, "London, Ontario, CA", places[1].String())
+	require.Equal(t, 42.9832406, places[1].Latitude)
+}
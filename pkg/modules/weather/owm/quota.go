@@ -0,0 +1,203 @@
+package owm
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"math"
+	"sync"
+	"time"
+)
+
+var ErrQuotaExceeded = errors.New("OpenWeatherMap daily call limit reached")
+
+// pollingShare is the part of the remaining daily calls polling may use, the rest is kept for commands
+const pollingShare = 0.8
+
+// quotaWarnThresholds are the parts of the daily limit at which the warn func is called, once a day each
+var quotaWarnThresholds = []float64{0.8, 1}
+
+// UsageStore persists the day's call count, so restarting the bot doesn't reset the budget
+type UsageStore interface {
+	// GetUsage returns the calls made on the day, given as YYYY-MM-DD in UTC
+	GetUsage(ctx context.Context, day string) (int, error)
+	SaveUsage(ctx context.Context, day string, calls int) error
+}
+
+// QuotaWarnFunc is called when the day's calls reach a threshold of the daily limit
+type QuotaWarnFunc func(usage provider.CallUsage, threshold float64)
+
+// QuotaManager budgets the One Call requests, which are billed per call. The daily count follows OpenWeatherMap's,
+// which starts over at midnight UTC.
+type QuotaManager struct {
+	dailyLimit  int
+	minuteLimit int
+	store       UsageStore
+	warn        QuotaWarnFunc
+	logger      zerolog.Logger
+	now         func() time.Time
+
+	lck         sync.Mutex
+	day         string
+	dailyCalls  int
+	minute      time.Time
+	minuteCalls int
+	// warned is how many of the thresholds have been warned about today
+	warned int
+}
+
+// NewQuotaManager creates a quota manager with the given limits, zero for no limit. The store and warn func are
+// optional.
+func NewQuotaManager(dailyLimit int, minuteLimit int, store UsageStore, warn QuotaWarnFunc, logger zerolog.Logger) *QuotaManager {
+	return &QuotaManager{
+		dailyLimit:  dailyLimit,
+		minuteLimit: minuteLimit,
+		store:       store,
+		warn:        warn,
+		logger:      logger,
+		now:         time.Now,
+	}
+}
+
+// Acquire counts a call against the limits, waiting for the next minute if this one's calls are used up. Returns
+// ErrQuotaExceeded if the day's are.
+func (q *QuotaManager) Acquire(ctx context.Context) error {
+	for {
+		q.lck.Lock()
+		now := q.now().UTC()
+		q.rollover(ctx, now)
+
+		if q.dailyLimit > 0 && q.dailyCalls >= q.dailyLimit {
+			q.lck.Unlock()
+			return ErrQuotaExceeded
+		}
+
+		if q.minuteLimit > 0 && q.minuteCallsAt(now) >= q.minuteLimit {
+			wait := q.minute.Add(time.Minute).Sub(now)
+			q.lck.Unlock()
+
+			q.logger.Debug().Msgf("OpenWeatherMap calls for this minute used up, waiting %s", wait)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		q.dailyCalls++
+		q.minuteCalls++
+		day, calls := q.day, q.dailyCalls
+		usage := q.usage(now)
+		threshold, warn := q.crossedThreshold()
+		q.lck.Unlock()
+
+		if q.store != nil {
+			if err := q.store.SaveUsage(ctx, day, calls); err != nil {
+				q.logger.Error().Err(err).Msg("Failed to save OpenWeatherMap call count")
+			}
+		}
+		if warn && q.warn != nil {
+			q.warn(usage, threshold)
+		}
+
+		return nil
+	}
+}
+
+// Usage returns the calls made today and this minute
+func (q *QuotaManager) Usage() provider.CallUsage {
+	q.lck.Lock()
+	defer q.lck.Unlock()
+
+	now := q.now().UTC()
+	q.rollover(context.Background(), now)
+	return q.usage(now)
+}
+
+// MinPollingInterval spreads the part of the remaining calls kept for polling over the rest of the day, and the
+// minute's calls over each minute
+func (q *QuotaManager) MinPollingInterval(locations int) time.Duration {
+	if locations <= 0 {
+		return 0
+	}
+
+	usage := q.Usage()
+	interval := time.Duration(0)
+	if usage.DailyLimit > 0 {
+		untilReset := usage.ResetsAt.Sub(q.now())
+		remaining := int(float64(usage.DailyLimit-usage.DailyCalls) * pollingShare)
+		if remaining <= 0 {
+			return untilReset
+		}
+		interval = untilReset * time.Duration(locations) / time.Duration(remaining)
+	}
+	if usage.MinuteLimit > 0 {
+		perMinute := max(int(float64(usage.MinuteLimit)*pollingShare), 1)
+		interval = max(interval, time.Minute*time.Duration(locations)/time.Duration(perMinute))
+	}
+
+	return interval
+}
+
+// rollover starts the count over on a new day, from the stored count in case the bot was restarted during it
+func (q *QuotaManager) rollover(ctx context.Context, now time.Time) {
+	day := now.Format(time.DateOnly)
+	if day == q.day {
+		return
+	}
+
+	calls := 0
+	if q.store != nil {
+		var err error
+		calls, err = q.store.GetUsage(ctx, day)
+		if err != nil {
+			q.logger.Error().Err(err).Msg("Failed to get OpenWeatherMap call count, starting from zero")
+		}
+	}
+
+	q.day = day
+	q.dailyCalls = calls
+	// thresholds already passed before a restart were warned about then
+	q.warned = 0
+	for q.warned < len(quotaWarnThresholds) && q.dailyLimit > 0 && q.dailyCalls >= q.thresholdCalls(q.warned) {
+		q.warned++
+	}
+}
+
+func (q *QuotaManager) minuteCallsAt(now time.Time) int {
+	minute := now.Truncate(time.Minute)
+	if !minute.Equal(q.minute) {
+		q.minute = minute
+		q.minuteCalls = 0
+	}
+
+	return q.minuteCalls
+}
+
+// crossedThreshold returns the threshold the last call reached, if it reached one not warned about yet
+func (q *QuotaManager) crossedThreshold() (float64, bool) {
+	if q.dailyLimit <= 0 || q.warned >= len(quotaWarnThresholds) || q.dailyCalls < q.thresholdCalls(q.warned) {
+		return 0, false
+	}
+
+	threshold := quotaWarnThresholds[q.warned]
+	q.warned++
+	return threshold, true
+}
+
+func (q *QuotaManager) thresholdCalls(index int) int {
+	return int(math.Ceil(quotaWarnThresholds[index] * float64(q.dailyLimit)))
+}
+
+func (q *QuotaManager) usage(now time.Time) provider.CallUsage {
+	dayStart, _ := time.Parse(time.DateOnly, q.day)
+	return provider.CallUsage{
+		DailyCalls:  q.dailyCalls,
+		DailyLimit:  q.dailyLimit,
+		MinuteCalls: q.minuteCallsAt(now),
+		MinuteLimit: q.minuteLimit,
+		ResetsAt:    dayStart.AddDate(0, 0, 1),
+	}
+}
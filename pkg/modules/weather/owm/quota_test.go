@@ -0,0 +1,109 @@
+package owm
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"testing"
+	"time"
+)
+
+type memoryUsageStore map[string]int
+
+func (m memoryUsageStore) GetUsage(_ context.Context, day string) (int, error) {
+	return m[day], nil
+}
+
+func (m memoryUsageStore) SaveUsage(_ context.Context, day string, calls int) error {
+	m[day] = calls
+	return nil
+}
+
+func newTestQuota(dailyLimit int, minuteLimit int, store UsageStore, now *time.Time) (*QuotaManager, *[]float64) {
+	warnings := make([]float64, 0)
+	quota := NewQuotaManager(dailyLimit, minuteLimit, store, func(_ provider.CallUsage, threshold float64) {
+		warnings = append(warnings, threshold)
+	}, zerolog.Nop())
+	quota.now = func() time.Time { return *now }
+
+	return quota, &warnings
+}
+
+func TestQuotaManager_Acquire(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 7, 5, 23, 0, 0, 0, time.UTC)
+	store := memoryUsageStore{"2024-07-05": 5}
+	quota, warnings := newTestQuota(10, 0, store, &now)
+
+	// the count picks up where it was left before a restart
+	require.NoError(t, quota.Acquire(ctx))
+	require.NoError(t, quota.Acquire(ctx))
+	require.Equal(t, 7, store["2024-07-05"])
+	require.Empty(t, *warnings)
+
+	require.NoError(t, quota.Acquire(ctx))
+	require.Equal(t, []float64{0.8}, *warnings)
+
+	require.NoError(t, quota.Acquire(ctx))
+	require.NoError(t, quota.Acquire(ctx))
+	require.Equal(t, []float64{0.8, 1}, *warnings)
+	require.ErrorIs(t, quota.Acquire(ctx), ErrQuotaExceeded)
+
+	usage := quota.Usage()
+	require.Equal(t, 10, usage.DailyCalls)
+	require.Equal(t, time.Date(2024, 7, 6, 0, 0, 0, 0, time.UTC), usage.ResetsAt)
+
+	// a new day starts over
+	now = now.Add(time.Hour)
+	require.NoError(t, quota.Acquire(ctx))
+	require.Equal(t, 1, store["2024-07-06"])
+	require.Len(t, *warnings, 2)
+}
+
+func TestQuotaManager_Acquire_NoRewarnAfterRestart(t *testing.T) {
+	now := time.Date(2024, 7, 5, 12, 0, 0, 0, time.UTC)
+	quota, warnings := newTestQuota(10, 0, memoryUsageStore{"2024-07-05": 8}, &now)
+
+	require.NoError(t, quota.Acquire(context.Background()))
+	require.Empty(t, *warnings)
+	require.NoError(t, quota.Acquire(context.Background()))
+	require.Equal(t, []float64{1}, *warnings)
+}
+
+func TestQuotaManager_Acquire_MinuteLimit(t *testing.T) {
+	now := time.Date(2024, 7, 5, 12, 0, 59, int(999*time.Millisecond), time.UTC)
+	quota, _ := newTestQuota(0, 2, nil, &now)
+
+	require.NoError(t, quota.Acquire(context.Background()))
+	require.NoError(t, quota.Acquire(context.Background()))
+
+	// the third call waits for the next minute, which the context doesn't allow
+	ctx, cf := context.WithTimeout(context.Background(), time.Millisecond/2)
+	defer cf()
+	require.ErrorIs(t, quota.Acquire(ctx), context.DeadlineExceeded)
+
+	// it's let through once the minute is over
+	now = now.Add(time.Millisecond)
+	require.NoError(t, quota.Acquire(context.Background()))
+	require.Equal(t, 1, quota.Usage().MinuteCalls)
+}
+
+func TestQuotaManager_MinPollingInterval(t *testing.T) {
+	now := time.Date(2024, 7, 5, 12, 0, 0, 0, time.UTC)
+	quota, _ := newTestQuota(1000, 60, memoryUsageStore{"2024-07-05": 500}, &now)
+
+	// 400 of the remaining 500 calls go to polling over the 12 hours left, for 4 locations
+	require.Equal(t, 12*time.Hour*4/400, quota.MinPollingInterval(4))
+	require.Zero(t, quota.MinPollingInterval(0))
+
+	// 48 of each minute's calls go to polling
+	quota, _ = newTestQuota(0, 60, nil, &now)
+	require.Equal(t, 2*time.Minute, quota.MinPollingInterval(96))
+
+	quota, _ = newTestQuota(1000, 0, memoryUsageStore{"2024-07-05": 1000}, &now)
+	require.Equal(t, 12*time.Hour, quota.MinPollingInterval(1))
+
+	quota, _ = newTestQuota(0, 0, nil, &now)
+	require.Zero(t, quota.MinPollingInterval(10))
+}
@@ -5,9 +5,52 @@ type OneCallCurrentResponse struct {
 	Lon            float64  `json:"lon"`
 	Timezone       string   `json:"timezone"`
 	TimezoneOffset int      `json:"timezone_offset"`
+	Current        *Current `json:"current,omitempty"`
+	Hourly         []Hourly `json:"hourly,omitempty"`
+	Daily          []Daily  `json:"daily,omitempty"`
 	Alerts         []Alerts `json:"alerts"`
 }
 
+type WeatherCondition struct {
+	Id          int    `json:"id"`
+	Main        string `json:"main"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+}
+
+type Current struct {
+	Dt        int64              `json:"dt"`
+	Temp      float64            `json:"temp"`
+	FeelsLike float64            `json:"feels_like"`
+	Humidity  float64            `json:"humidity"`
+	WindSpeed float64            `json:"wind_speed"`
+	WindDeg   float64            `json:"wind_deg"`
+	Weather   []WeatherCondition `json:"weather"`
+}
+
+type Hourly struct {
+	Dt        int64              `json:"dt"`
+	Temp      float64            `json:"temp"`
+	FeelsLike float64            `json:"feels_like"`
+	Pop       float64            `json:"pop"`
+	WindSpeed float64            `json:"wind_speed"`
+	WindDeg   float64            `json:"wind_deg"`
+	Weather   []WeatherCondition `json:"weather"`
+}
+
+type Daily struct {
+	Dt      int64  `json:"dt"`
+	Summary string `json:"summary"`
+	Temp    struct {
+		Min float64 `json:"min"`
+		Max float64 `json:"max"`
+	} `json:"temp"`
+	Pop       float64            `json:"pop"`
+	WindSpeed float64            `json:"wind_speed"`
+	WindDeg   float64            `json:"wind_deg"`
+	Weather   []WeatherCondition `json:"weather"`
+}
+
 type Alerts struct {
 	SenderName  string   `json:"sender_name"`
 	Event       string   `json:"event"`
@@ -17,6 +60,14 @@ type Alerts struct {
 	Tags        []string `json:"tags"`
 }
 
+type DirectGeolocationResponse struct {
+	Name    string  `json:"name"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Country string  `json:"country"`
+	State   string  `json:"state"`
+}
+
 type GeolocationResponse struct {
 	Zip     string  `json:"zip"`
 	Name    string  `json:"name"`
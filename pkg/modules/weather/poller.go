@@ -1,19 +1,22 @@
 package weather
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/rs/zerolog"
 	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
 	"github.com/tomato3017/tomatobot/pkg/config"
-	"github.com/tomato3017/tomatobot/pkg/modules/weather/owm"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
 	"github.com/tomato3017/tomatobot/pkg/notifications"
 	"github.com/tomato3017/tomatobot/pkg/util"
 	"github.com/uptrace/bun"
+	"math/rand/v2"
 	"regexp"
-	"strings"
 	"sync"
 	"text/template"
 	"time"
@@ -21,11 +24,26 @@ import (
 
 const WeatherPollerTopic = "weather"
 
+// activeAlertRetention is how long an alert that isn't seen anymore is remembered, long enough that only the alerts
+// of locations no longer polled are forgotten
+const activeAlertRetention = 24 * time.Hour
+
+// alertMissedPollsToEnd is how many polls in a row an alert has to be missing from before it's published as ended,
+// unless it's past its end already
+const alertMissedPollsToEnd = 2
+
 const (
-	topicFmtWatch    = "%s.%s.watch"
-	topicFmtWarning  = "%s.%s.warning"
-	topicFmtAdvisory = "%s.%s.advisory"
-	topicFmtUnknown  = "%s.%s.unknown"
+	defaultPollingInterval = 5 * time.Minute
+	defaultPollConcurrency = 4
+	defaultMaxPollBackoff  = time.Hour
+	// pollerCheckInterval is how often the poller looks for locations due to be polled
+	pollerCheckInterval = 10 * time.Second
+	// pollJitter is the largest part of a location's interval added to it, so locations added at once aren't polled
+	// at the same moment ever after
+	pollJitter = 0.1
+	// pollTimeout bounds a location's poll, so a provider that stops responding fails the poll rather than holding
+	// up every later one
+	pollTimeout = time.Minute
 )
 
 var numberedStormRegex = regexp.MustCompile(`(?m)((\w+)\s(WARNING|WATCH)\s\d+)\s`)
@@ -43,31 +61,49 @@ type poller struct {
 	cfg   config.WeatherConfig
 
 	logger      zerolog.Logger
-	client      owm.OpenWeatherMapIClient
+	provider    provider.Provider
 	dbConn      bun.IDB
 	msgTemplate *template.Template
+	classifier  *alertClassifier
+	// schedules are only touched by the polling goroutine, by location key
+	schedules map[string]*locationSchedule
+}
+
+// locationSchedule is when a location is polled next and how many of its polls in a row have failed
+type locationSchedule struct {
+	nextPoll time.Time
+	failures int
 }
 
 func (p *poller) poll(ctx context.Context) {
-	p.logger.Debug().Msgf("Polling weather every %s", p.cfg.PollingInterval.String())
-	tick := time.NewTicker(p.cfg.PollingInterval)
+	p.logger.Debug().Msgf("Polling weather every %s, every %s near active alerts",
+		p.cfg.PollingInterval.String(), p.cfg.ActivePollingInterval.String())
+	tick := time.NewTicker(p.checkInterval())
 	defer tick.Stop()
 
 	for {
-		p.logger.Debug().Msg("Polling weather")
 		select {
 		case <-ctx.Done():
 			p.logger.Debug().Msg("Context done, stopping poller")
 			return
-		case <-tick.C:
+		case now := <-tick.C:
 			if err := p.updateWeatherLocations(ctx); err != nil {
-				p.logger.Fatal().Msgf("Failed to update weather locations: %s", err.Error())
+				// the locations already loaded keep being polled until the database is back
+				p.logger.Error().Err(err).Msg("Failed to update weather locations")
+			}
+			p.publishWeatherForLocations(ctx, now)
+			if err := p.pruneActiveAlerts(ctx); err != nil {
+				p.logger.Error().Err(err).Msg("Failed to prune active alerts")
 			}
-			p.publishWeatherForLocations(ctx)
 		}
 	}
 }
 
+// checkInterval is how often the poller looks for locations due to be polled, often enough to keep to the jitter
+func (p *poller) checkInterval() time.Duration {
+	return min(pollerCheckInterval, p.cfg.PollingInterval, p.cfg.ActivePollingInterval)
+}
+
 func (p *poller) updateWeatherLocations(ctx context.Context) error {
 	locations := make([]dbmodels.WeatherPollingLocations, 0)
 
@@ -76,18 +112,128 @@ func (p *poller) updateWeatherLocations(ctx context.Context) error {
 		return fmt.Errorf("failed to get weather polling locations: %w", err)
 	}
 
+	polled := make(map[string]struct{}, len(locations))
+	for _, location := range locations {
+		polled[location.Key] = struct{}{}
+	}
+	for key := range p.schedules {
+		if _, ok := polled[key]; !ok {
+			delete(p.schedules, key)
+		}
+	}
+
 	p.locations = locations
 	return nil
 }
 
-func (p *poller) publishWeatherForLocations(ctx context.Context) {
+// publishWeatherForLocations polls the locations that are due, up to the configured number at once, and schedules
+// their next polls
+func (p *poller) publishWeatherForLocations(ctx context.Context, now time.Time) {
+	due := make([]dbmodels.WeatherPollingLocations, 0, len(p.locations))
 	for _, location := range p.locations {
-		p.logger.Debug().Msgf("Publishing weather for location %s, lat %f, long %f", location.ZipCode, location.Lat, location.Lon)
-		if err := p.publishWeatherForLocation(ctx, location); err != nil {
-			p.logger.Error().Err(err).Msgf("Failed to publish weather for location %s", location.ZipCode)
-			continue
+		if !now.Before(p.schedule(location.Key, now).nextPoll) {
+			due = append(due, location)
 		}
 	}
+
+	errs := make([]error, len(due))
+	sem := make(chan struct{}, p.cfg.PollConcurrency)
+	wg := sync.WaitGroup{}
+	for i, location := range due {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, location dbmodels.WeatherPollingLocations) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			ctx, cf := context.WithTimeout(ctx, pollTimeout)
+			defer cf()
+
+			p.logger.Debug().Msgf("Publishing weather for location %s, lat %f, long %f", location.Key, location.Lat, location.Lon)
+			errs[i] = p.publishWeatherForLocation(ctx, location)
+		}(i, location)
+	}
+	wg.Wait()
+
+	for i, location := range due {
+		p.reschedule(ctx, location, errs[i], now)
+	}
+}
+
+// schedule returns the location's schedule, polling a location seen for the first time within the jitter
+func (p *poller) schedule(locationKey string, now time.Time) *locationSchedule {
+	schedule, ok := p.schedules[locationKey]
+	if !ok {
+		schedule = &locationSchedule{nextPoll: now.Add(withJitter(0, p.cfg.PollingInterval))}
+		p.schedules[locationKey] = schedule
+	}
+
+	return schedule
+}
+
+// reschedule schedules the location's next poll, backing off while its polls fail and polling more often while it
+// has an active alert
+func (p *poller) reschedule(ctx context.Context, location dbmodels.WeatherPollingLocations, pollErr error, now time.Time) {
+	schedule := p.schedule(location.Key, now)
+	if pollErr != nil {
+		schedule.failures++
+		delay := max(backoffDelay(p.cfg.PollingInterval, schedule.failures, p.cfg.MaxPollBackoff), p.minPollingInterval())
+		schedule.nextPoll = now.Add(withJitter(delay, delay))
+		p.logger.Error().Err(pollErr).Int("failures", schedule.failures).
+			Msgf("Failed to publish weather for location %s, retrying in %s", location.Key, delay)
+		return
+	}
+
+	schedule.failures = 0
+	interval := p.cfg.PollingInterval
+	active, err := p.hasActiveAlerts(ctx, location)
+	if err != nil {
+		p.logger.Error().Err(err).Msgf("Failed to check active alerts for location %s", location.Key)
+	} else if active {
+		interval = p.cfg.ActivePollingInterval
+	}
+	if minInterval := p.minPollingInterval(); minInterval > interval {
+		p.logger.Debug().Msgf("Polling location %s every %s instead of %s to stay within the api quota",
+			location.Key, minInterval, interval)
+		interval = minInterval
+	}
+	schedule.nextPoll = now.Add(withJitter(interval, interval))
+}
+
+// minPollingInterval is the shortest interval the provider's api quota allows polling the locations at, zero if
+// its calls aren't limited
+func (p *poller) minPollingInterval() time.Duration {
+	quota, ok := p.provider.(provider.Quota)
+	if !ok {
+		return 0
+	}
+
+	return quota.MinPollingInterval(len(p.locations))
+}
+
+func (p *poller) hasActiveAlerts(ctx context.Context, location dbmodels.WeatherPollingLocations) (bool, error) {
+	return p.dbConn.NewSelect().Model((*dbmodels.WeatherActiveAlerts)(nil)).
+		Where("location_id = ?", location.ID).
+		Exists(ctx)
+}
+
+// backoffDelay is the exponential backoff after the given number of failed polls in a row, starting at the polling
+// interval and capped at the max
+func backoffDelay(interval time.Duration, failures int, maxDelay time.Duration) time.Duration {
+	delay := interval
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+
+	return min(delay, maxDelay)
+}
+
+// withJitter adds a random part of the interval, up to pollJitter of it, to the delay
+func withJitter(delay time.Duration, interval time.Duration) time.Duration {
+	return delay + time.Duration(rand.Int64N(int64(float64(interval)*pollJitter)+1))
 }
 
 func (p *poller) Start(ctx context.Context) {
@@ -106,52 +252,38 @@ func (p *poller) Stop() {
 	p.wg.Wait()
 }
 
-func (p *poller) isLowerAdvisory(alertNameUpper string) bool {
-	switch {
-	case
-		strings.Contains(alertNameUpper, "RED FLAG"),
-		strings.Contains(alertNameUpper, "FIRE WEATHER"),
-		strings.Contains(alertNameUpper, "EXCESSIVE HEAT"),
-		strings.Contains(alertNameUpper, "WIND CHILL"),
-		strings.Contains(alertNameUpper, "FREEZE"):
-		return true
+func (p *poller) getDedupeKey(location dbmodels.WeatherPollingLocations, alert provider.Alert) string {
+	matches := numberedStormRegex.FindAllStringSubmatch(alert.Description, -1)
+	if len(matches) > 0 {
+		return fmt.Sprintf("%s_%s", util.FirstNonZero(location.Name, location.Key), matches[0][1])
 	}
-	return false
-}
 
-func (p *poller) topicName(location dbmodels.WeatherPollingLocations, alert owm.Alerts) string {
-	alertNameUpper := strings.ToUpper(alert.Event)
+	return fmt.Sprintf("%s_%s_%d", util.FirstNonZero(location.Name, location.Key), alert.Event, alert.End.Unix())
+}
 
-	switch {
-	case p.isLowerAdvisory(alertNameUpper):
-		return fmt.Sprintf(topicFmtAdvisory, WeatherPollerTopic, location.ZipCode)
-	case strings.Contains(alertNameUpper, "WATCH"):
-		return fmt.Sprintf(topicFmtWatch, WeatherPollerTopic, location.ZipCode)
-	case strings.Contains(alertNameUpper, "WARNING"):
-		return fmt.Sprintf(topicFmtWarning, WeatherPollerTopic, location.ZipCode)
-	case strings.Contains(alertNameUpper, "ADVISORY"):
-		return fmt.Sprintf(topicFmtAdvisory, WeatherPollerTopic, location.ZipCode)
-	default:
-		p.logger.Error().Msgf("Unknown alert type: %s", alert.Event)
-		return fmt.Sprintf(topicFmtUnknown, WeatherPollerTopic, location.ZipCode)
+// getEventID identifies the alert across updates, so an extended or reworded alert edits the message already sent.
+// The issuer's id is used when the provider has one, the alert's start can move when it's updated.
+func (p *poller) getEventID(location dbmodels.WeatherPollingLocations, alert provider.Alert) string {
+	if alert.ID != "" {
+		return fmt.Sprintf("weather_%s_%s", util.FirstNonZero(location.Name, location.Key), alert.ID)
 	}
-}
 
-func (p *poller) getDedupeKey(location dbmodels.WeatherPollingLocations, alert owm.Alerts) string {
 	matches := numberedStormRegex.FindAllStringSubmatch(alert.Description, -1)
 	if len(matches) > 0 {
-		return fmt.Sprintf("%s_%s", util.FirstNonZero(location.Name, location.ZipCode), matches[0][1])
+		return fmt.Sprintf("weather_%s_%s", util.FirstNonZero(location.Name, location.Key), matches[0][1])
 	}
 
-	return fmt.Sprintf("%s_%s_%d", util.FirstNonZero(location.Name, location.ZipCode), alert.Event, alert.End)
+	return fmt.Sprintf("weather_%s_%s_%d", util.FirstNonZero(location.Name, location.Key), alert.Event, alert.Start.Unix())
 }
 
-func (p *poller) getDedupeTTL(alert owm.Alerts) time.Duration {
-	return time.Until(time.Unix(alert.End, 0).Add(10 * time.Minute))
+func (p *poller) getDedupeTTL(alert provider.Alert) time.Duration {
+	return time.Until(alert.End.Add(10 * time.Minute))
 }
 
+// publishWeatherForLocation publishes the location's new alerts, the ones updated since the last poll to the
+// .updated topic and the ones no longer active to the .ended topic
 func (p *poller) publishWeatherForLocation(ctx context.Context, location dbmodels.WeatherPollingLocations) error {
-	res, err := p.client.CurrentWeatherByLocation(ctx, owm.Location{
+	alerts, err := p.provider.Alerts(ctx, provider.Location{
 		Latitude:  location.Lat,
 		Longitude: location.Lon,
 	})
@@ -159,81 +291,271 @@ func (p *poller) publishWeatherForLocation(ctx context.Context, location dbmodel
 		return err
 	}
 
-	for _, alert := range res.Alerts {
-		p.logger.Trace().Msgf("Publishing weather alert for location %s, event %s, start %d, end %d",
-			location.ZipCode, alert.Event, alert.Start, alert.End)
+	active, err := p.getActiveAlerts(ctx, location)
+	if err != nil {
+		return err
+	}
 
-		topicName := p.topicName(location, alert)
-		p.logger.Trace().Msgf("Topic name: %s", topicName)
+	now := time.Now()
+	seen := make(map[string]struct{}, len(alerts))
+	// one alert failing doesn't keep the location's other alerts, or the ends of the ones gone, from being published
+	errs := make([]error, 0)
+	for _, alert := range alerts {
+		p.logger.Trace().Msgf("Publishing weather alert for location %s, event %s, start %s, end %s",
+			location.Key, alert.Event, alert.Start, alert.End)
+
+		alertType, ok := p.classifier.classify(alert)
+		if !ok {
+			p.logger.Debug().Msgf("Dropping unclassified alert %s for location %s", alert.Event, location.Key)
+			continue
+		}
 
-		renderedMsg, err := p.getRenderedWeatherAlert(alert, location)
+		eventId := p.getEventID(location, alert)
+		seen[eventId] = struct{}{}
+
+		activeAlert, found := active[eventId]
+		change := alertChange(activeAlert, alert)
+		var err error
+		switch {
+		case !found:
+			err = p.publishAlert(ctx, location, alert, alertType, "")
+		case change != "":
+			err = p.publishAlert(ctx, location, alert, alertType, change)
+		default:
+			p.logger.Trace().Msgf("Alert %s for location %s is unchanged", alert.Event, location.Key)
+		}
 		if err != nil {
-			return fmt.Errorf("failed to render weather alert: %w", err)
+			// not saved, so it's published again on the next poll
+			p.logger.Error().Err(err).Msgf("Failed to publish alert %s for location %s", alert.Event, location.Key)
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := p.saveActiveAlert(ctx, location, alert, alertType, eventId, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for eventId, activeAlert := range active {
+		if _, ok := seen[eventId]; ok {
+			continue
+		}
+
+		// an alert missing from a single poll may only have been left out of the provider's response
+		if now.Before(activeAlert.EndsAt) && activeAlert.Missed+1 < alertMissedPollsToEnd {
+			p.logger.Debug().Msgf("Alert %s for location %s missing from the poll", activeAlert.Event, location.Key)
+			if err := p.markActiveAlertMissed(ctx, activeAlert); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		if err := p.publishAlertEnded(ctx, location, activeAlert, now); err != nil {
+			p.logger.Error().Err(err).Msgf("Failed to publish the end of alert %s for location %s", activeAlert.Event, location.Key)
+			errs = append(errs, err)
 		}
-		p.publisher.Publish(notifications.Message{
-			Topic:   topicName,
-			Msg:     renderedMsg,
-			DupeTTL: p.getDedupeTTL(alert),
-			DupeKey: p.getDedupeKey(location, alert),
-		})
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p *poller) publishAlert(ctx context.Context, location dbmodels.WeatherPollingLocations, alert provider.Alert, alertType eventType, change string) error {
+	topicName := alertType.fullTopicPath(location.Key)
+	dupeKey := p.getDedupeKey(location, alert)
+	if change != "" {
+		topicName = alertType.stageTopicPath(location.Key, alertStageUpdated)
+		dupeKey = ""
+	}
+	p.logger.Trace().Msgf("Topic name: %s", topicName)
+
+	// the whole alert goes along with the message, for chats with their own template or description length
+	data := tgWeatherAlert{Alert: alert, WeatherPollingLocations: location, Category: alertType, Change: change}
+	renderedMsg, err := renderAlert(p.msgTemplate, data, defaultAlertDescriptionLength)
+	if err != nil {
+		return err
+	}
+	err = p.publisher.Publish(ctx, notifications.Message{
+		Topic:    topicName,
+		Msg:      renderedMsg,
+		DupeTTL:  p.getDedupeTTL(alert),
+		DupeKey:  dupeKey,
+		Priority: alertType.priority(),
+
+		ParseMode:          tgbotapi.ModeHTML,
+		Buttons:            [][]notifications.Button{{{Text: "Details", URL: forecastDetailsURL(location)}}},
+		DisableLinkPreview: true,
+		EventID:            p.getEventID(location, alert),
+		Data:               data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish weather alert: %w", err)
 	}
 
 	return nil
 }
 
-func (p *poller) getRenderedWeatherAlert(alert owm.Alerts, location dbmodels.WeatherPollingLocations) (string, error) {
-	msgBuffer := bytes.Buffer{}
-	err := p.msgTemplate.Execute(&msgBuffer, tgWeatherAlert{
-		Alerts: owm.Alerts{
-			Event:       alert.Event,
-			Start:       alert.Start,
-			End:         alert.End,
-			Description: util.TruncateString(alert.Description, 512, "..."),
-		},
-		WeatherPollingLocations: dbmodels.WeatherPollingLocations{
-			Name: location.Name,
-		},
+// publishAlertEnded strikes through the alert's message with a note on whether it expired or was lifted early, and
+// forgets the alert
+func (p *poller) publishAlertEnded(ctx context.Context, location dbmodels.WeatherPollingLocations, activeAlert dbmodels.WeatherActiveAlerts, now time.Time) error {
+	note := fmt.Sprintf("✅ %s has expired", activeAlert.Event)
+	if now.Before(activeAlert.EndsAt) {
+		note = fmt.Sprintf("✅ %s was lifted", activeAlert.Event)
+	}
+
+	alertType := eventType(activeAlert.Category)
+	err := p.publisher.Publish(ctx, notifications.Message{
+		Topic:    alertType.stageTopicPath(location.Key, alertStageEnded),
+		Msg:      note,
+		DupeKey:  fmt.Sprintf("ended_%s", activeAlert.EventID),
+		DupeTTL:  time.Hour,
+		Priority: alertType.priority(),
+		EventID:  activeAlert.EventID,
+		Action:   notifications.EventActionCancel,
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to render weather alert: %w", err)
+		return fmt.Errorf("failed to publish weather alert end: %w", err)
+	}
+
+	_, err = p.dbConn.NewDelete().Model(&activeAlert).WherePK().Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete active alert: %w", err)
+	}
+
+	return nil
+}
+
+// getActiveAlerts returns the location's alerts published on earlier polls, by event id
+func (p *poller) getActiveAlerts(ctx context.Context, location dbmodels.WeatherPollingLocations) (map[string]dbmodels.WeatherActiveAlerts, error) {
+	var rows []dbmodels.WeatherActiveAlerts
+	err := p.dbConn.NewSelect().Model(&rows).Where("location_id = ?", location.ID).Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active alerts: %w", err)
+	}
+
+	active := make(map[string]dbmodels.WeatherActiveAlerts, len(rows))
+	for _, row := range rows {
+		active[row.EventID] = row
 	}
 
-	return msgBuffer.String(), nil
+	return active, nil
+}
+
+func (p *poller) saveActiveAlert(ctx context.Context, location dbmodels.WeatherPollingLocations, alert provider.Alert, alertType eventType, eventId string, now time.Time) error {
+	_, err := p.dbConn.NewInsert().Model(&dbmodels.WeatherActiveAlerts{
+		LocationID: location.ID,
+		EventID:    eventId,
+		Event:      alert.Event,
+		Category:   alertType.String(),
+		EndsAt:     alert.End,
+		Checksum:   alertChecksum(alert),
+		FirstSeen:  now,
+		LastSeen:   now,
+	}).
+		On("CONFLICT(location_id, event_id) DO UPDATE").
+		Set("ends_at = EXCLUDED.ends_at").
+		Set("checksum = EXCLUDED.checksum").
+		Set("last_seen = EXCLUDED.last_seen").
+		Set("missed = 0").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save active alert: %w", err)
+	}
+
+	return nil
+}
+
+func (p *poller) markActiveAlertMissed(ctx context.Context, activeAlert dbmodels.WeatherActiveAlerts) error {
+	_, err := p.dbConn.NewUpdate().Model(&activeAlert).Set("missed = missed + 1").WherePK().Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to mark active alert missed: %w", err)
+	}
+
+	return nil
+}
+
+// pruneActiveAlerts forgets the alerts of locations that are no longer polled, which would otherwise never end
+func (p *poller) pruneActiveAlerts(ctx context.Context) error {
+	_, err := p.dbConn.NewDelete().Model((*dbmodels.WeatherActiveAlerts)(nil)).
+		Where("last_seen < ?", time.Now().Add(-activeAlertRetention)).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to prune active alerts: %w", err)
+	}
+
+	return nil
+}
+
+// alertChange describes how the alert changed since it was last seen, empty if it didn't
+func alertChange(activeAlert dbmodels.WeatherActiveAlerts, alert provider.Alert) string {
+	switch {
+	case alert.End.After(activeAlert.EndsAt):
+		return "extended"
+	case alert.End.Before(activeAlert.EndsAt):
+		return "end moved up"
+	case alertChecksum(alert) != activeAlert.Checksum:
+		return "text updated"
+	default:
+		return ""
+	}
+}
+
+func alertChecksum(alert provider.Alert) string {
+	hasher := sha256.New()
+	for _, text := range []string{alert.Headline, alert.Description, alert.Instruction} {
+		hasher.Write([]byte(text))
+		hasher.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))
 }
 
 type pollerNewArgs struct {
 	publisher notifications.Publisher
+	provider  provider.Provider
 	locations []dbmodels.WeatherPollingLocations
 	cfg       config.WeatherConfig
 	logger    zerolog.Logger
 	dbConn    bun.IDB
 }
 
-func newPoller(args pollerNewArgs) *poller {
-	client, err := owm.NewOpenWeatherMapClient(args.cfg.APIKey)
+func newPoller(args pollerNewArgs) (*poller, error) {
+	msgTemplate, err := parseTemplate("weatheralert", msgTemplateStr)
 	if err != nil {
-		args.logger.Fatal().Err(err).Msg("Failed to create OpenWeatherMap client")
+		return nil, fmt.Errorf("failed to parse weather alert template: %w", err)
 	}
 
-	tmplFuncMap := template.FuncMap{
-		"int64ToTime": int64ToTime,
+	cfg := args.cfg
+	if cfg.PollingInterval <= 0 {
+		cfg.PollingInterval = defaultPollingInterval
 	}
-
-	msgTemplate, err := template.New("weatheralert").Funcs(tmplFuncMap).Parse(msgTemplateStr)
-	if err != nil {
-		args.logger.Fatal().Err(err).Msg("Failed to parse weather alert template")
+	if cfg.ActivePollingInterval <= 0 || cfg.ActivePollingInterval > cfg.PollingInterval {
+		cfg.ActivePollingInterval = cfg.PollingInterval
+	}
+	if cfg.PollConcurrency <= 0 {
+		cfg.PollConcurrency = defaultPollConcurrency
+	}
+	if cfg.MaxPollBackoff <= 0 {
+		cfg.MaxPollBackoff = defaultMaxPollBackoff
 	}
 
 	return &poller{
 		publisher:   args.publisher,
 		locations:   args.locations,
-		cfg:         args.cfg,
+		cfg:         cfg,
 		logger:      args.logger,
-		client:      client,
+		provider:    args.provider,
 		dbConn:      args.dbConn,
 		msgTemplate: msgTemplate,
-	}
+		classifier:  newAlertClassifier(cfg, args.logger),
+		schedules:   make(map[string]*locationSchedule),
+	}, nil
 }
-func int64ToTime(ts int64) time.Time {
-	return time.Unix(ts, 0)
+
+// forecastDetailsURL links to the National Weather Service forecast for US locations and OpenWeatherMap's for the rest
+func forecastDetailsURL(location dbmodels.WeatherPollingLocations) string {
+	if location.Country != "" && location.Country != "US" {
+		return fmt.Sprintf("https://openweathermap.org/weathermap?zoom=10&lat=%.4f&lon=%.4f", location.Lat, location.Lon)
+	}
+
+	return fmt.Sprintf("https://forecast.weather.gov/MapClick.php?lat=%.4f&lon=%.4f", location.Lat, location.Lon)
 }
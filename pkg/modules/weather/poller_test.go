@@ -2,59 +2,73 @@ package weather
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
 	"github.com/tomato3017/tomatobot/pkg/config"
-	"github.com/tomato3017/tomatobot/pkg/modules/weather/owm"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
 	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/sqlmigrate"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
 	"strings"
 	"testing"
 	"time"
 )
 
+func newPollerTestDb(t *testing.T) *bun.DB {
+	sqlDb, err := sql.Open(sqliteshim.ShimName, "file::memory:?cache=shared")
+	require.NoError(t, err)
+
+	dbConn := bun.NewDB(sqlDb, sqlitedialect.New())
+	t.Cleanup(func() { require.NoError(t, dbConn.Close()) })
+	_, err = sqlmigrate.MigrateDbSchema(context.Background(), dbConn)
+	require.NoError(t, err)
+
+	return dbConn
+}
+
 func TestPoller_publishWeatherForLocation(t *testing.T) {
 	mockPublisher := notifications.NewMockPublisher(t)
-	mockClient := owm.NewMockOpenWeatherMapIClient(t)
+	mockProvider := provider.NewMockProvider(t)
 
-	testPoller := newPoller(pollerNewArgs{
+	testPoller, err := newPoller(pollerNewArgs{
 		publisher: mockPublisher,
+		provider:  mockProvider,
 		locations: make([]dbmodels.WeatherPollingLocations, 0),
 		cfg:       config.WeatherConfig{},
 		logger:    zerolog.Logger{},
-		dbConn:    nil,
+		dbConn:    newPollerTestDb(t),
 	})
-	testPoller.client = mockClient
+	require.NoError(t, err)
 
-	testLocation := owm.Location{
+	testLocation := provider.Location{
 		Latitude:  55,
 		Longitude: -55,
 	}
 	testLocationDbMdl := dbmodels.WeatherPollingLocations{
-		ZipCode: "12345",
-		Lat:     testLocation.Latitude,
-		Lon:     testLocation.Longitude,
+		Key: "12345",
+		Lat: testLocation.Latitude,
+		Lon: testLocation.Longitude,
 	}
 
 	testTime, err := time.Parse(time.RFC3339, "2021-11-06T00:00:00Z")
 	require.NoError(t, err)
 	endAlertTime := testTime.Add(time.Hour * 2)
 
-	owmResponse := owm.OneCallCurrentResponse{
-		Lat:            testLocation.Latitude,
-		Lon:            testLocation.Longitude,
-		Timezone:       "America/New_York",
-		TimezoneOffset: -17000,
-		Alerts: []owm.Alerts{
-			{
-				SenderName:  "NWS TEST",
-				Event:       "Super High heat warning",
-				Start:       testTime.Add(time.Hour * -1).Unix(),
-				End:         endAlertTime.Unix(),
-				Description: "It's hot",
-				Tags:        []string{"heat", "warning"},
-			},
+	alerts := []provider.Alert{
+		{
+			Sender:      "NWS TEST",
+			Event:       "Super High heat warning",
+			Start:       testTime.Add(time.Hour * -1),
+			End:         endAlertTime,
+			Description: "It's hot",
+			Tags:        []string{"heat", "warning"},
 		},
 	}
 
@@ -64,33 +78,241 @@ func TestPoller_publishWeatherForLocation(t *testing.T) {
 	//	DupeKey: "",
 	//	DupeTTL: time.Hour * 6,
 	//}
-	mockClient.EXPECT().CurrentWeatherByLocation(mock.Anything, testLocation).Return(owmResponse, nil)
-	mockPublisher.On("Publish", mock.MatchedBy(func(msg notifications.Message) bool {
-		return strings.Contains(msg.Msg, "Super High heat warning")
-	})).Return()
+	mockProvider.EXPECT().Alerts(mock.Anything, testLocation).Return(alerts, nil)
+	mockPublisher.On("Publish", mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return strings.Contains(msg.Msg, "Super High heat warning") && msg.Priority == notifications.PriorityCritical &&
+			msg.ParseMode == tgbotapi.ModeHTML && strings.HasPrefix(msg.Buttons[0][0].URL, "https://forecast.weather.gov/MapClick.php?lat=")
+	})).Return(nil)
 
 	err = testPoller.publishWeatherForLocation(context.Background(), testLocationDbMdl)
 
 	require.NoError(t, err)
 }
 
+func TestPoller_publishWeatherForLocation_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	dbConn := newPollerTestDb(t)
+	mockPublisher := notifications.NewMockPublisher(t)
+	mockProvider := provider.NewMockProvider(t)
+
+	testPoller, err := newPoller(pollerNewArgs{
+		publisher: mockPublisher,
+		provider:  mockProvider,
+		cfg:       config.WeatherConfig{},
+		logger:    zerolog.Nop(),
+		dbConn:    dbConn,
+	})
+	require.NoError(t, err)
+
+	location := dbmodels.WeatherPollingLocations{ID: 7, Name: "Houston", Key: "us-77093", Lat: 29.8131, Lon: -95.3098}
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	tornado := provider.Alert{Event: "Tornado Warning", Severity: provider.SeverityExtreme, Certainty: provider.CertaintyObserved,
+		Start: start, End: start.Add(2 * time.Hour), Description: "A tornado was observed"}
+	heat := provider.Alert{Event: "Heat Advisory", Severity: provider.SeverityModerate, Certainty: provider.CertaintyLikely,
+		Start: start, End: start.Add(6 * time.Hour), Description: "It's hot"}
+
+	topicIs := func(topic string) interface{} {
+		return mock.MatchedBy(func(msg notifications.Message) bool { return msg.Topic == topic })
+	}
+
+	// both alerts are new
+	mockProvider.EXPECT().Alerts(mock.Anything, mock.Anything).Return([]provider.Alert{tornado, heat}, nil).Once()
+	mockPublisher.EXPECT().Publish(mock.Anything, topicIs("weather.us-77093.warning")).Return(nil).Once()
+	mockPublisher.EXPECT().Publish(mock.Anything, topicIs("weather.us-77093.advisory")).Return(nil).Once()
+	require.NoError(t, testPoller.publishWeatherForLocation(ctx, location))
+
+	// unchanged alerts aren't published again
+	mockProvider.EXPECT().Alerts(mock.Anything, mock.Anything).Return([]provider.Alert{tornado, heat}, nil).Once()
+	require.NoError(t, testPoller.publishWeatherForLocation(ctx, location))
+
+	// the warning is extended, which updates its message
+	extended := tornado
+	extended.End = tornado.End.Add(time.Hour)
+	mockProvider.EXPECT().Alerts(mock.Anything, mock.Anything).Return([]provider.Alert{extended, heat}, nil).Once()
+	mockPublisher.EXPECT().Publish(mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return msg.Topic == "weather.us-77093.warning.updated" && msg.EventID == testPoller.getEventID(location, tornado) &&
+			strings.Contains(msg.Msg, "<b>Updated:</b> extended") && msg.Priority == notifications.PriorityCritical
+	})).Return(nil).Once()
+	require.NoError(t, testPoller.publishWeatherForLocation(ctx, location))
+
+	// the warning missing from one poll isn't enough to end it
+	mockProvider.EXPECT().Alerts(mock.Anything, mock.Anything).Return([]provider.Alert{heat}, nil).Once()
+	require.NoError(t, testPoller.publishWeatherForLocation(ctx, location))
+
+	// back again, so a later miss starts counting over
+	mockProvider.EXPECT().Alerts(mock.Anything, mock.Anything).Return([]provider.Alert{extended, heat}, nil).Once()
+	require.NoError(t, testPoller.publishWeatherForLocation(ctx, location))
+	mockProvider.EXPECT().Alerts(mock.Anything, mock.Anything).Return([]provider.Alert{heat}, nil).Once()
+	require.NoError(t, testPoller.publishWeatherForLocation(ctx, location))
+
+	// the warning is lifted before it ends, missing from a second poll in a row
+	mockProvider.EXPECT().Alerts(mock.Anything, mock.Anything).Return([]provider.Alert{heat}, nil).Once()
+	mockPublisher.EXPECT().Publish(mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return msg.Topic == "weather.us-77093.warning.ended" && msg.Action == notifications.EventActionCancel &&
+			msg.EventID == testPoller.getEventID(location, tornado) && msg.Msg == "✅ Tornado Warning was lifted"
+	})).Return(nil).Once()
+	require.NoError(t, testPoller.publishWeatherForLocation(ctx, location))
+
+	var active []dbmodels.WeatherActiveAlerts
+	require.NoError(t, dbConn.NewSelect().Model(&active).Scan(ctx))
+	require.Len(t, active, 1)
+	require.Equal(t, "Heat Advisory", active[0].Event)
+	require.Equal(t, "advisory", active[0].Category)
+
+	// an alert past its end is ended on the first poll it's missing from
+	_, err = dbConn.NewUpdate().Model(&active[0]).Set("ends_at = ?", time.Now().Add(-time.Minute)).WherePK().Exec(ctx)
+	require.NoError(t, err)
+	mockProvider.EXPECT().Alerts(mock.Anything, mock.Anything).Return([]provider.Alert{}, nil).Once()
+	mockPublisher.EXPECT().Publish(mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return msg.Topic == "weather.us-77093.advisory.ended" && msg.Msg == "✅ Heat Advisory has expired"
+	})).Return(nil).Once()
+	require.NoError(t, testPoller.publishWeatherForLocation(ctx, location))
+}
+
+func TestPoller_publishWeatherForLocation_PublishError(t *testing.T) {
+	mockPublisher := notifications.NewMockPublisher(t)
+	mockProvider := provider.NewMockProvider(t)
+
+	testPoller, err := newPoller(pollerNewArgs{
+		publisher: mockPublisher,
+		provider:  mockProvider,
+		cfg:       config.WeatherConfig{},
+		logger:    zerolog.Nop(),
+		dbConn:    newPollerTestDb(t),
+	})
+	require.NoError(t, err)
+
+	location := dbmodels.WeatherPollingLocations{ID: 7, Name: "Houston", Key: "us-77093"}
+	start := time.Now().Truncate(time.Second)
+	mockProvider.EXPECT().Alerts(mock.Anything, mock.Anything).Return([]provider.Alert{
+		{Event: "Tornado Warning", Start: start, End: start.Add(time.Hour)},
+		{Event: "Heat Advisory", Start: start, End: start.Add(time.Hour)},
+	}, nil).Once()
+
+	// the bus being full for the warning doesn't keep the advisory from being published
+	mockPublisher.EXPECT().Publish(mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return msg.Topic == "weather.us-77093.warning"
+	})).Return(notifications.ErrBusFull).Once()
+	mockPublisher.EXPECT().Publish(mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return msg.Topic == "weather.us-77093.advisory"
+	})).Return(nil).Once()
+
+	require.ErrorIs(t, testPoller.publishWeatherForLocation(context.Background(), location), notifications.ErrBusFull)
+}
+
+func TestPoller_publishWeatherForLocations_Schedule(t *testing.T) {
+	ctx := context.Background()
+	mockPublisher := notifications.NewMockPublisher(t)
+	mockProvider := provider.NewMockProvider(t)
+
+	houston := dbmodels.WeatherPollingLocations{ID: 1, Key: "us-77093", Lat: 29.8131, Lon: -95.3098}
+	austin := dbmodels.WeatherPollingLocations{ID: 2, Key: "us-78701", Lat: 30.2711, Lon: -97.7437}
+	testPoller, err := newPoller(pollerNewArgs{
+		publisher: mockPublisher,
+		provider:  mockProvider,
+		locations: []dbmodels.WeatherPollingLocations{houston, austin},
+		cfg:       config.WeatherConfig{PollingInterval: 10 * time.Minute, ActivePollingInterval: time.Minute},
+		logger:    zerolog.Nop(),
+		dbConn:    newPollerTestDb(t),
+	})
+	require.NoError(t, err)
+
+	// nothing is due before the first poll's jitter
+	now := time.Now()
+	testPoller.publishWeatherForLocations(ctx, now)
+	require.Len(t, testPoller.schedules, 2)
+	for _, schedule := range testPoller.schedules {
+		require.False(t, schedule.nextPoll.Before(now))
+		require.False(t, schedule.nextPoll.After(now.Add(time.Minute)))
+	}
+
+	// houston has an active alert so it's polled more often, austin's api call fails so it backs off
+	// each poll has its own deadline, so a provider that hangs can't stall the poller
+	hasDeadline := mock.MatchedBy(func(ctx context.Context) bool {
+		deadline, ok := ctx.Deadline()
+		return ok && time.Until(deadline) <= pollTimeout
+	})
+	mockProvider.EXPECT().Alerts(hasDeadline, provider.Location{Latitude: houston.Lat, Longitude: houston.Lon}).Return([]provider.Alert{
+		{Event: "Heat Advisory", Severity: provider.SeverityModerate, Start: now, End: now.Add(time.Hour)},
+	}, nil).Once()
+	mockProvider.EXPECT().Alerts(mock.Anything, provider.Location{Latitude: austin.Lat, Longitude: austin.Lon}).
+		Return(nil, fmt.Errorf("429 too many requests")).Twice()
+	mockPublisher.EXPECT().Publish(mock.Anything, mock.Anything).Return(nil).Once()
+
+	now = now.Add(2 * time.Minute)
+	testPoller.publishWeatherForLocations(ctx, now)
+	require.Zero(t, testPoller.schedules[houston.Key].failures)
+	require.WithinRange(t, testPoller.schedules[houston.Key].nextPoll, now.Add(time.Minute), now.Add(2*time.Minute))
+	require.Equal(t, 1, testPoller.schedules[austin.Key].failures)
+	require.WithinRange(t, testPoller.schedules[austin.Key].nextPoll, now.Add(10*time.Minute), now.Add(13*time.Minute))
+
+	// only austin is due, and fails again
+	now = testPoller.schedules[austin.Key].nextPoll
+	testPoller.schedules[houston.Key].nextPoll = now.Add(time.Minute)
+	testPoller.publishWeatherForLocations(ctx, now)
+	require.Equal(t, 2, testPoller.schedules[austin.Key].failures)
+	require.WithinRange(t, testPoller.schedules[austin.Key].nextPoll, now.Add(20*time.Minute), now.Add(22*time.Minute))
+
+	// a location no longer polled is forgotten
+	_, err = testPoller.dbConn.NewInsert().Model(&dbmodels.WeatherPollingLocations{ID: 1, Key: "us-77093", Polling: true}).Exec(ctx)
+	require.NoError(t, err)
+	require.NoError(t, testPoller.updateWeatherLocations(ctx))
+	require.Len(t, testPoller.schedules, 1)
+}
+
+func Test_backoffDelay(t *testing.T) {
+	require.Equal(t, 5*time.Minute, backoffDelay(5*time.Minute, 1, time.Hour))
+	require.Equal(t, 10*time.Minute, backoffDelay(5*time.Minute, 2, time.Hour))
+	require.Equal(t, 40*time.Minute, backoffDelay(5*time.Minute, 4, time.Hour))
+	require.Equal(t, time.Hour, backoffDelay(5*time.Minute, 5, time.Hour))
+	require.Equal(t, time.Hour, backoffDelay(5*time.Minute, 100, time.Hour))
+	require.Equal(t, time.Hour, backoffDelay(2*time.Hour, 1, time.Hour))
+}
+
+func Test_withJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		delay := withJitter(time.Minute, 10*time.Minute)
+		require.GreaterOrEqual(t, delay, time.Minute)
+		require.LessOrEqual(t, delay, 2*time.Minute)
+	}
+}
+
+func Test_alertChange(t *testing.T) {
+	end := time.Date(2024, 7, 5, 21, 0, 0, 0, time.UTC)
+	alert := provider.Alert{Event: "Heat Advisory", End: end, Description: "It's hot"}
+	activeAlert := dbmodels.WeatherActiveAlerts{EndsAt: end, Checksum: alertChecksum(alert)}
+
+	require.Empty(t, alertChange(activeAlert, alert))
+
+	alert.End = end.Add(time.Hour)
+	require.Equal(t, "extended", alertChange(activeAlert, alert))
+
+	alert.End = end.Add(-time.Hour)
+	require.Equal(t, "end moved up", alertChange(activeAlert, alert))
+
+	alert.End = end
+	alert.Description = "It's very hot"
+	require.Equal(t, "text updated", alertChange(activeAlert, alert))
+}
+
 func TestPoller_getDedupeKey(t *testing.T) {
-	testPoller := newPoller(pollerNewArgs{
+	testPoller, err := newPoller(pollerNewArgs{
 		publisher: nil,
 		locations: make([]dbmodels.WeatherPollingLocations, 0),
 		cfg:       config.WeatherConfig{},
 		logger:    zerolog.Logger{},
 		dbConn:    nil,
 	})
+	require.NoError(t, err)
 
 	location := dbmodels.WeatherPollingLocations{
-		Name:    "TestLocation",
-		ZipCode: "12345",
+		Name: "TestLocation",
+		Key:  "12345",
 	}
 
-	alert := owm.Alerts{
+	alert := provider.Alert{
 		Event: "TestEvent",
-		End:   1636156800, // Example timestamp
+		End:   time.Unix(1636156800, 0), // Example timestamp
 	}
 
 	expectedDedupeKey := "TestLocation_TestEvent_1636156800"
@@ -111,3 +333,36 @@ func TestPoller_getDedupeKey(t *testing.T) {
 
 	require.Equal(t, expectedDedupeKey, actualDedupeKey)
 }
+
+func TestPoller_getEventID(t *testing.T) {
+	testPoller, err := newPoller(pollerNewArgs{
+		locations: make([]dbmodels.WeatherPollingLocations, 0),
+		cfg:       config.WeatherConfig{},
+		logger:    zerolog.Logger{},
+	})
+	require.NoError(t, err)
+
+	location := dbmodels.WeatherPollingLocations{
+		Key: "12345",
+	}
+
+	alert := provider.Alert{
+		Event: "Heat Advisory",
+		Start: time.Unix(1636150000, 0),
+		End:   time.Unix(1636156800, 0),
+	}
+	eventId := testPoller.getEventID(location, alert)
+	require.Equal(t, "weather_12345_Heat Advisory_1636150000", eventId)
+
+	// an extended alert keeps its event id
+	alert.End = alert.End.Add(time.Hour)
+	require.Equal(t, eventId, testPoller.getEventID(location, alert))
+
+	alert.Description = "SEVERE THUNDERSTORM WATCH 656 REMAINS VALID UNTIL 8 PM EDT THIS\nEVENING FOR THE FOLLOWING AREAS\n"
+	require.Equal(t, "weather_12345_THUNDERSTORM WATCH 656", testPoller.getEventID(location, alert))
+
+	// the issuer's id wins, the start of an updated alert can move
+	alert.ID = "KHGX.HT.Y.0012"
+	alert.Start = alert.Start.Add(time.Hour)
+	require.Equal(t, "weather_12345_KHGX.HT.Y.0012", testPoller.getEventID(location, alert))
+}
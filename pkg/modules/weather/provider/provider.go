@@ -0,0 +1,157 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrLocationNotFound = errors.New("location not found")
+	ErrOutsideCoverage  = errors.New("location is outside the area the provider covers")
+)
+
+// Provider is a source of weather data. Values are metric, temperatures in celsius and speeds in meters per second,
+// whatever the upstream api uses.
+type Provider interface {
+	// Name identifies the provider in logs and messages
+	Name() string
+	// Geocode looks up a postal code in the country, given as an ISO 3166 alpha-2 code. Returns ErrLocationNotFound
+	// if the provider doesn't know it.
+	Geocode(ctx context.Context, postalCode string, country string) (Place, error)
+	// Search finds the places matching a free-form name such as "Springfield, IL, US", best match first
+	Search(ctx context.Context, query string) ([]Place, error)
+	// Alerts returns the alerts currently active at the location
+	Alerts(ctx context.Context, location Location) ([]Alert, error)
+	CurrentConditions(ctx context.Context, location Location) (Conditions, error)
+	// Forecast returns the upcoming forecast periods in order, days or day and night halves depending on the provider
+	Forecast(ctx context.Context, location Location) ([]ForecastPeriod, error)
+	// HourlyForecast returns the upcoming hours in order, as far ahead as the provider forecasts them
+	HourlyForecast(ctx context.Context, location Location) ([]ForecastPeriod, error)
+	// Timezone returns the IANA timezone the location is in
+	Timezone(ctx context.Context, location Location) (*time.Location, error)
+}
+
+// Quota is implemented by providers whose api calls are limited
+type Quota interface {
+	// CallUsage returns the calls made against the limits, false if the provider's calls aren't limited
+	CallUsage() (CallUsage, bool)
+	// MinPollingInterval is the shortest interval each of the locations can be polled at for the rest of the day
+	// without running out of calls, zero if there's no limit
+	MinPollingInterval(locations int) time.Duration
+}
+
+// CallUsage is how many api calls a provider has made against its limits, which are zero when there's no limit
+type CallUsage struct {
+	DailyCalls  int
+	DailyLimit  int
+	MinuteCalls int
+	MinuteLimit int
+	// ResetsAt is when the daily count starts over
+	ResetsAt time.Time
+}
+
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Place is a geocoded location
+type Place struct {
+	Name string
+	// State is the state or region, empty if the provider doesn't give one
+	State string
+	// Country is the ISO 3166 alpha-2 code of the country
+	Country    string
+	PostalCode string
+	Location
+}
+
+// String names the place the way a user would, e.g. "Springfield, Illinois, US"
+func (p Place) String() string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{p.Name, p.State, p.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Severity is how dangerous an alert is, as given by its issuer
+type Severity string
+
+const (
+	SeverityExtreme  Severity = "extreme"
+	SeveritySevere   Severity = "severe"
+	SeverityModerate Severity = "moderate"
+	SeverityMinor    Severity = "minor"
+	SeverityUnknown  Severity = "unknown"
+)
+
+// Urgency is how soon action should be taken on an alert, as given by its issuer
+type Urgency string
+
+const (
+	UrgencyImmediate Urgency = "immediate"
+	UrgencyExpected  Urgency = "expected"
+	UrgencyFuture    Urgency = "future"
+	UrgencyPast      Urgency = "past"
+	UrgencyUnknown   Urgency = "unknown"
+)
+
+// Certainty is how likely the alert's event is, as given by its issuer
+type Certainty string
+
+const (
+	CertaintyObserved Certainty = "observed"
+	CertaintyLikely   Certainty = "likely"
+	CertaintyPossible Certainty = "possible"
+	CertaintyUnlikely Certainty = "unlikely"
+	CertaintyUnknown  Certainty = "unknown"
+)
+
+// Alert is an active weather alert
+type Alert struct {
+	// ID is the issuer's id for the alert, which its updates share where the issuer allows, empty if the provider has none
+	ID          string
+	Sender      string
+	Event       string
+	Headline    string
+	Description string
+	Instruction string
+	Severity    Severity
+	Urgency     Urgency
+	Certainty   Certainty
+	Start       time.Time
+	End         time.Time
+	Tags        []string
+}
+
+// Conditions are the current observed conditions at a location
+type Conditions struct {
+	ObservedAt    time.Time
+	Description   string
+	Temperature   float64
+	FeelsLike     float64
+	Humidity      float64
+	WindSpeed     float64
+	WindDirection float64
+}
+
+// ForecastPeriod is one period of a forecast, a whole day, half of one or an hour
+type ForecastPeriod struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+	// TempHigh and TempLow are the same for providers forecasting a single temperature per period
+	TempHigh float64
+	TempLow  float64
+	// PrecipitationChance is the chance of precipitation as a percentage
+	PrecipitationChance float64
+	WindSpeed           float64
+	WindDirection       float64
+	Summary             string
+}
@@ -0,0 +1,78 @@
+package weather
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/owm"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/uptrace/bun"
+	"time"
+)
+
+// quotaTopic is where bot admins are warned about a provider running out of api calls
+const quotaTopic = WeatherPollerTopic + ".quota"
+
+// usageStore keeps a provider's daily call counts in the database
+type usageStore struct {
+	dbConn   bun.IDB
+	provider string
+}
+
+var _ owm.UsageStore = &usageStore{}
+
+func (u *usageStore) GetUsage(ctx context.Context, day string) (int, error) {
+	usage := dbmodels.WeatherAPIUsage{}
+	err := u.dbConn.NewSelect().Model(&usage).
+		Where("provider = ?", u.provider).
+		Where("day = ?", day).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to get api usage: %w", err)
+	}
+
+	return usage.Calls, nil
+}
+
+func (u *usageStore) SaveUsage(ctx context.Context, day string, calls int) error {
+	_, err := u.dbConn.NewInsert().Model(&dbmodels.WeatherAPIUsage{Provider: u.provider, Day: day, Calls: calls}).
+		On("CONFLICT(provider, day) DO UPDATE").
+		Set("calls = EXCLUDED.calls").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save api usage: %w", err)
+	}
+
+	return nil
+}
+
+// quotaWarner publishes to the quota topic when a provider's calls reach a threshold of its daily limit
+func quotaWarner(publisher notifications.Publisher, providerName string, logger zerolog.Logger) owm.QuotaWarnFunc {
+	return func(usage provider.CallUsage, threshold float64) {
+		msg := notifications.Message{
+			Topic: quotaTopic,
+			Msg: fmt.Sprintf("⚠️ %s has used %d of its %d daily api calls, weather polling is being slowed down to stay within them",
+				providerName, usage.DailyCalls, usage.DailyLimit),
+			DupeKey:  fmt.Sprintf("quota_%s_%.0f", usage.ResetsAt.Format(time.DateOnly), threshold*100),
+			DupeTTL:  24 * time.Hour,
+			Priority: notifications.PriorityWarning,
+		}
+		if threshold >= 1 {
+			msg.Msg = fmt.Sprintf("🛑 %s has used all %d of its daily api calls, weather is paused until %s",
+				providerName, usage.DailyLimit, usage.ResetsAt.Local().Format("15:04 MST"))
+			msg.Priority = notifications.PriorityCritical
+		}
+
+		ctx, cf := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cf()
+		if err := publisher.Publish(ctx, msg); err != nil {
+			logger.Error().Err(err).Msg("Failed to publish quota warning")
+		}
+	}
+}
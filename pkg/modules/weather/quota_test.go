@@ -0,0 +1,66 @@
+package weather
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"testing"
+	"time"
+)
+
+func TestUsageStore(t *testing.T) {
+	ctx := context.Background()
+	dbConn := newPollerTestDb(t)
+	store := &usageStore{dbConn: dbConn, provider: providerOWM}
+
+	calls, err := store.GetUsage(ctx, "2024-07-05")
+	require.NoError(t, err)
+	require.Zero(t, calls)
+
+	require.NoError(t, store.SaveUsage(ctx, "2024-07-05", 1))
+	require.NoError(t, store.SaveUsage(ctx, "2024-07-05", 2))
+	calls, err = store.GetUsage(ctx, "2024-07-05")
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+
+	// each provider counts its own calls
+	calls, err = (&usageStore{dbConn: dbConn, provider: providerNWS}).GetUsage(ctx, "2024-07-05")
+	require.NoError(t, err)
+	require.Zero(t, calls)
+}
+
+func TestQuotaWarner(t *testing.T) {
+	usage := provider.CallUsage{DailyCalls: 800, DailyLimit: 1000, ResetsAt: time.Date(2024, 7, 6, 0, 0, 0, 0, time.UTC)}
+
+	mockPublisher := notifications.NewMockPublisher(t)
+	mockPublisher.EXPECT().Publish(mock.Anything, notifications.Message{
+		Topic:    "weather.quota",
+		Msg:      "⚠️ OpenWeatherMap has used 800 of its 1000 daily api calls, weather polling is being slowed down to stay within them",
+		DupeKey:  "quota_2024-07-06_80",
+		DupeTTL:  24 * time.Hour,
+		Priority: notifications.PriorityWarning,
+	}).Return(nil).Once()
+	mockPublisher.EXPECT().Publish(mock.Anything, mock.MatchedBy(func(msg notifications.Message) bool {
+		return msg.DupeKey == "quota_2024-07-06_100" && msg.Priority == notifications.PriorityCritical
+	})).Return(nil).Once()
+
+	warn := quotaWarner(mockPublisher, "OpenWeatherMap", zerolog.Nop())
+	warn(usage, 0.8)
+	usage.DailyCalls = 1000
+	warn(usage, 1)
+}
+
+func Test_formatQuota(t *testing.T) {
+	usage := provider.CallUsage{DailyCalls: 250, DailyLimit: 1000, MinuteCalls: 3, MinuteLimit: 60, ResetsAt: time.Now()}
+
+	msg := formatQuota("OpenWeatherMap", usage, time.Minute, 5*time.Minute, 2)
+	require.Contains(t, msg, "Today: 250 of 1000 (25%)")
+	require.Contains(t, msg, "This minute: 3 of 60")
+	require.Contains(t, msg, "Polling 2 location(s) every 5m0s")
+
+	msg = formatQuota("OpenWeatherMap", usage, 7*time.Minute+12*time.Second, 5*time.Minute, 4)
+	require.Contains(t, msg, "Polling 4 location(s) every 7m12s instead of 5m0s to stay within the quota")
+}
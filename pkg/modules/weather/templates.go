@@ -0,0 +1,39 @@
+package weather
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"math"
+	"text/template"
+	"time"
+)
+
+var compassPoints = []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+// templateFuncs are the functions available to the weather message templates, which are rendered as HTML
+var templateFuncs = template.FuncMap{
+	"localTime": localTime,
+	"compass":   compass,
+	"escape": func(text string) string {
+		return notifications.EscapeText(tgbotapi.ModeHTML, text)
+	},
+}
+
+func parseTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+// localTime shows alert times in the bot's timezone, whatever timezone the provider gave them in
+func localTime(t time.Time) time.Time {
+	return t.Local()
+}
+
+// compass turns a wind direction in degrees into the nearest of the eight compass points
+func compass(degrees float64) string {
+	index := int(math.Round(math.Mod(degrees, 360)/45)) % len(compassPoints)
+	if index < 0 {
+		index += len(compassPoints)
+	}
+
+	return compassPoints[index]
+}
@@ -3,129 +3,87 @@ package weather
 import (
 	"fmt"
 	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
-	"github.com/tomato3017/tomatobot/pkg/modules/weather/owm"
-	"regexp"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"time"
 )
 
-var usZipCodeRegex = regexp.MustCompile(`^\d{5}$`)
-
 type eventType string
 
+const (
+	eventTypeWarning  eventType = "warning"
+	eventTypeWatch    eventType = "watch"
+	eventTypeAdvisory eventType = "advisory"
+	eventTypeUnknown  eventType = "unknown"
+	// eventTypeBriefing is the daily briefing, not an alert
+	eventTypeBriefing eventType = "briefing"
+)
+
 func (e eventType) String() string {
 	return string(e)
 }
 
-func (e eventType) fullTopicPath(zipCode string) string {
-	return fmt.Sprintf("weather.%s.%s", zipCode, e.String())
+func (e eventType) fullTopicPath(locationKey string) string {
+	return fmt.Sprintf("weather.%s.%s", locationKey, e.String())
 }
 
-var weatherPublisherEventTypes = []eventType{"warning", "watch", "advisory"}
+// stageTopicPath is the topic the alert's lifecycle stage is published to, under the event type's topic so
+// subscribers of the alerts get their updates too
+func (e eventType) stageTopicPath(locationKey string, stage alertStage) string {
+	return fmt.Sprintf("%s.%s", e.fullTopicPath(locationKey), stage)
+}
 
-type CurrentWeatherResponse struct {
-	Lat            float64 `json:"lat"`
-	Lon            float64 `json:"lon"`
-	Timezone       string  `json:"timezone"`
-	TimezoneOffset int     `json:"timezone_offset"`
-	Current        struct {
-		Dt         int     `json:"dt"`
-		Sunrise    int     `json:"sunrise"`
-		Sunset     int     `json:"sunset"`
-		Temp       float64 `json:"temp"`
-		FeelsLike  float64 `json:"feels_like"`
-		Pressure   int     `json:"pressure"`
-		Humidity   int     `json:"humidity"`
-		DewPoint   float64 `json:"dew_point"`
-		Uvi        float64 `json:"uvi"`
-		Clouds     int     `json:"clouds"`
-		Visibility int     `json:"visibility"`
-		WindSpeed  float64 `json:"wind_speed"`
-		WindDeg    int     `json:"wind_deg"`
-		WindGust   float64 `json:"wind_gust"`
-		Weather    []struct {
-			Id          int    `json:"id"`
-			Main        string `json:"main"`
-			Description string `json:"description"`
-			Icon        string `json:"icon"`
-		} `json:"weather"`
-	} `json:"current"`
-	Minutely []struct {
-		Dt            int `json:"dt"`
-		Precipitation int `json:"precipitation"`
-	} `json:"minutely"`
-	Hourly []struct {
-		Dt         int     `json:"dt"`
-		Temp       float64 `json:"temp"`
-		FeelsLike  float64 `json:"feels_like"`
-		Pressure   int     `json:"pressure"`
-		Humidity   int     `json:"humidity"`
-		DewPoint   float64 `json:"dew_point"`
-		Uvi        float64 `json:"uvi"`
-		Clouds     int     `json:"clouds"`
-		Visibility int     `json:"visibility"`
-		WindSpeed  float64 `json:"wind_speed"`
-		WindDeg    int     `json:"wind_deg"`
-		WindGust   float64 `json:"wind_gust"`
-		Weather    []struct {
-			Id          int    `json:"id"`
-			Main        string `json:"main"`
-			Description string `json:"description"`
-			Icon        string `json:"icon"`
-		} `json:"weather"`
-		Pop  float64 `json:"pop"`
-		Rain struct {
-			H float64 `json:"1h"`
-		} `json:"rain,omitempty"`
-	} `json:"hourly"`
-	Daily []struct {
-		Dt        int     `json:"dt"`
-		Sunrise   int     `json:"sunrise"`
-		Sunset    int     `json:"sunset"`
-		Moonrise  int     `json:"moonrise"`
-		Moonset   int     `json:"moonset"`
-		MoonPhase float64 `json:"moon_phase"`
-		Summary   string  `json:"summary"`
-		Temp      struct {
-			Day   float64 `json:"day"`
-			Min   float64 `json:"min"`
-			Max   float64 `json:"max"`
-			Night float64 `json:"night"`
-			Eve   float64 `json:"eve"`
-			Morn  float64 `json:"morn"`
-		} `json:"temp"`
-		FeelsLike struct {
-			Day   float64 `json:"day"`
-			Night float64 `json:"night"`
-			Eve   float64 `json:"eve"`
-			Morn  float64 `json:"morn"`
-		} `json:"feels_like"`
-		Pressure  int     `json:"pressure"`
-		Humidity  int     `json:"humidity"`
-		DewPoint  float64 `json:"dew_point"`
-		WindSpeed float64 `json:"wind_speed"`
-		WindDeg   int     `json:"wind_deg"`
-		WindGust  float64 `json:"wind_gust"`
-		Weather   []struct {
-			Id          int    `json:"id"`
-			Main        string `json:"main"`
-			Description string `json:"description"`
-			Icon        string `json:"icon"`
-		} `json:"weather"`
-		Clouds int     `json:"clouds"`
-		Pop    float64 `json:"pop"`
-		Uvi    float64 `json:"uvi"`
-		Rain   float64 `json:"rain,omitempty"`
-	} `json:"daily"`
-	Alerts []struct {
-		SenderName  string   `json:"sender_name"`
-		Event       string   `json:"event"`
-		Start       int      `json:"start"`
-		End         int      `json:"end"`
-		Description string   `json:"description"`
-		Tags        []string `json:"tags"`
-	} `json:"alerts"`
+// priority maps the event type onto the notification priority it is published with
+func (e eventType) priority() notifications.Priority {
+	switch e {
+	case eventTypeWarning:
+		return notifications.PriorityCritical
+	case eventTypeWatch:
+		return notifications.PriorityWarning
+	default:
+		return notifications.PriorityInfo
+	}
 }
 
+// alertStage is a change to an alert already published
+type alertStage string
+
+const (
+	alertStageUpdated alertStage = "updated"
+	alertStageEnded   alertStage = "ended"
+)
+
+var weatherPublisherEventTypes = []eventType{eventTypeWarning, eventTypeWatch, eventTypeAdvisory}
+
 type tgWeatherAlert struct {
-	owm.Alerts
+	provider.Alert
+	dbmodels.WeatherPollingLocations
+	// Category is the event type the alert is published as
+	Category eventType
+	// Change is how the alert changed since it was published, empty for a new alert
+	Change string
+}
+
+type tgWeatherNow struct {
+	provider.Conditions
+	dbmodels.WeatherPollingLocations
+	Units units
+}
+
+type tgWeatherForecast struct {
+	dbmodels.WeatherPollingLocations
+	Units units
+	View  forecastView
+	Rows  []forecastRow
+	// LabelWidth is the width of the longest label, which the table is aligned to
+	LabelWidth int
+}
+
+type tgWeatherBriefing struct {
 	dbmodels.WeatherPollingLocations
+	Units units
+	// Date is the start of the day briefed on, in the location's timezone
+	Date   time.Time
+	Rows   []forecastRow
+	Alerts []provider.Alert
 }
@@ -0,0 +1,83 @@
+package weather
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/uptrace/bun"
+	"strings"
+)
+
+// units are the units a chat wants weather shown in, providers always give metric values
+type units string
+
+const (
+	unitsMetric   units = "metric"
+	unitsImperial units = "imperial"
+)
+
+func parseUnits(raw string) (units, error) {
+	switch u := units(strings.ToLower(raw)); u {
+	case unitsMetric, unitsImperial:
+		return u, nil
+	default:
+		return "", fmt.Errorf("unknown units %s, use %s or %s", raw, unitsMetric, unitsImperial)
+	}
+}
+
+// Temp converts a temperature in celsius
+func (u units) Temp(celsius float64) float64 {
+	if u == unitsImperial {
+		return celsius*9/5 + 32
+	}
+	return celsius
+}
+
+func (u units) TempSymbol() string {
+	if u == unitsImperial {
+		return "°F"
+	}
+	return "°C"
+}
+
+// Speed converts a speed in meters per second
+func (u units) Speed(metersPerSecond float64) float64 {
+	if u == unitsImperial {
+		return metersPerSecond * 2.23694
+	}
+	return metersPerSecond
+}
+
+func (u units) SpeedSymbol() string {
+	if u == unitsImperial {
+		return "mph"
+	}
+	return "m/s"
+}
+
+// getChatUnits returns the units the chat has picked, metric if it hasn't
+func getChatUnits(ctx context.Context, dbConn bun.IDB, chatId int64) (units, error) {
+	settings := dbmodels.WeatherChatSettings{}
+	if err := dbConn.NewSelect().Model(&settings).Where("chat_id = ?", chatId).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return unitsMetric, nil
+		}
+		return "", fmt.Errorf("failed to get chat weather settings: %w", err)
+	}
+
+	return parseUnits(settings.Units)
+}
+
+func setChatUnits(ctx context.Context, dbConn bun.IDB, chatId int64, chatUnits units) error {
+	_, err := dbConn.NewInsert().Model(&dbmodels.WeatherChatSettings{ChatID: chatId, Units: string(chatUnits)}).
+		On("CONFLICT(chat_id) DO UPDATE").
+		Set("units = EXCLUDED.units").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save chat weather settings: %w", err)
+	}
+
+	return nil
+}
@@ -7,10 +7,18 @@ import (
 	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
 	"github.com/tomato3017/tomatobot/pkg/config"
 	"github.com/tomato3017/tomatobot/pkg/modules"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/nws"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/owm"
+	"github.com/tomato3017/tomatobot/pkg/modules/weather/provider"
 	"github.com/tomato3017/tomatobot/pkg/notifications"
 	"github.com/uptrace/bun"
 )
 
+const (
+	providerOWM = "owm"
+	providerNWS = "nws"
+)
+
 type WeatherModule struct {
 	cfg config.WeatherConfig
 
@@ -18,8 +26,10 @@ type WeatherModule struct {
 
 	pollingLocations []dbmodels.WeatherPollingLocations
 	publisher        notifications.Publisher
+	provider         provider.Provider
 
 	weatherPoll *poller
+	briefings   *briefingScheduler
 	logger      zerolog.Logger
 }
 
@@ -29,11 +39,74 @@ func (w *WeatherModule) Initialize(ctx context.Context, params modules.Initializ
 	w.dbConn = params.DbConn
 	w.publisher = params.Notifications
 
-	//Validate api key
-	if w.cfg.APIKey == "" {
-		return fmt.Errorf("no api key provided")
+	for _, eventType := range append(weatherPublisherEventTypes, eventTypeUnknown) {
+		err := w.publisher.RegisterTopic(notifications.TopicTemplate{
+			Template:    eventType.fullTopicPath("{location}"),
+			Description: fmt.Sprintf("Weather %s alerts for a location added with /weather add", eventType),
+			Params: []notifications.TopicParam{
+				{Name: "location", Description: "Location key shown by /weather list", Example: "us-90210"},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to register weather topic: %w", err)
+		}
+
+		for _, stage := range []alertStage{alertStageUpdated, alertStageEnded} {
+			err := w.publisher.RegisterTopic(notifications.TopicTemplate{
+				Template:    eventType.stageTopicPath("{location}", stage),
+				Description: fmt.Sprintf("Weather %s alerts for a location that were %s", eventType, stage),
+				Params: []notifications.TopicParam{
+					{Name: "location", Description: "Location key shown by /weather list", Example: "us-90210"},
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to register weather topic: %w", err)
+			}
+		}
+	}
+
+	err := w.publisher.RegisterTopic(notifications.TopicTemplate{
+		Template:    eventTypeBriefing.fullTopicPath("{location}") + ".{chat_id}",
+		Description: "Daily weather briefing of a location for the chat that scheduled it with /weather briefing set",
+		Params: []notifications.TopicParam{
+			{Name: "location", Description: "Location key shown by /weather list", Example: "us-90210"},
+			{Name: "chat_id", Description: "Chat the briefing was scheduled in, see /myid", Example: "-1001234567890"},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register weather topic: %w", err)
 	}
 
+	err = w.publisher.RegisterTopic(notifications.TopicTemplate{
+		Template:    quotaTopic,
+		Description: "Warnings about the weather provider running out of daily api calls",
+		ACL:         notifications.TopicACL{Access: notifications.TopicAccessBotAdmin},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register weather topic: %w", err)
+	}
+
+	filter, err := newAlertFilter(w.dbConn, w.logger.With().Str("thread", "weather_alert_filter").Logger())
+	if err != nil {
+		return err
+	}
+	err = w.publisher.RegisterDeliveryFilter(WeatherPollerTopic+".", func(ctx context.Context, chatId int64, msg notifications.Message) (notifications.Message, bool) {
+		if msg, ok := briefingFilter(ctx, chatId, msg); !ok {
+			return msg, false
+		}
+		return filter.filter(ctx, chatId, msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register weather delivery filter: %w", err)
+	}
+
+	weatherProvider, err := w.newProvider()
+	if err != nil {
+		return fmt.Errorf("failed to create weather provider: %w", err)
+	}
+	w.provider = weatherProvider
+	w.logger.Debug().Msgf("Using %s for weather data", w.provider.Name())
+
 	//Load weather polling locations
 	weatherPollingLocations, err := w.getWeatherPollingLocations(ctx)
 	if err != nil {
@@ -48,7 +121,14 @@ func (w *WeatherModule) Initialize(ctx context.Context, params modules.Initializ
 
 	//TODO
 
-	wCmd, err := newWeatherCommand(params)
+	renderer, err := newBriefingRenderer(w.provider)
+	if err != nil {
+		return err
+	}
+	w.briefings = newBriefingScheduler(w.publisher, renderer, w.dbConn,
+		w.logger.With().Str("thread", "weather_briefings").Logger())
+
+	wCmd, err := newWeatherCommand(params, w.provider)
 	if err != nil {
 		return fmt.Errorf("failed to create weather command: %w", err)
 	}
@@ -60,18 +140,48 @@ func (w *WeatherModule) Initialize(ctx context.Context, params modules.Initializ
 	return nil
 }
 
-func (w *WeatherModule) startPolling(ctx context.Context) {
-	wPoller := newPoller(pollerNewArgs{
+// newProvider creates the weather provider selected in the config
+func (w *WeatherModule) newProvider() (provider.Provider, error) {
+	cfg := w.cfg
+	switch cfg.Provider {
+	case "", providerOWM:
+		//Validate api key
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("no api key provided")
+		}
+
+		quota := owm.NewQuotaManager(cfg.DailyCallLimit, cfg.MinuteCallLimit,
+			&usageStore{dbConn: w.dbConn, provider: providerOWM},
+			quotaWarner(w.publisher, "OpenWeatherMap", w.logger), w.logger)
+		client, err := owm.NewOpenWeatherMapClient(cfg.APIKey, owm.WithQuota(quota))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OpenWeatherMap client: %w", err)
+		}
+		return owm.NewProvider(client, quota), nil
+	case providerNWS:
+		return nws.NewClient(cfg.UserAgent)
+	default:
+		return nil, fmt.Errorf("unknown weather provider %s", cfg.Provider)
+	}
+}
+
+func (w *WeatherModule) startPolling(ctx context.Context) error {
+	wPoller, err := newPoller(pollerNewArgs{
 		publisher: w.publisher,
+		provider:  w.provider,
 		locations: w.pollingLocations,
 		cfg:       w.cfg,
 		logger:    w.logger.With().Str("thread", "weather_poller").Logger(),
 		dbConn:    w.dbConn,
 	})
+	if err != nil {
+		return fmt.Errorf("failed to create weather poller: %w", err)
+	}
 
 	wPoller.Start(ctx)
 
 	w.weatherPoll = wPoller
+	return nil
 }
 
 func (w *WeatherModule) getWeatherPollingLocations(ctx context.Context) ([]dbmodels.WeatherPollingLocations, error) {
@@ -85,13 +195,17 @@ func (w *WeatherModule) getWeatherPollingLocations(ctx context.Context) ([]dbmod
 }
 
 func (w *WeatherModule) Start(ctx context.Context) error {
-	w.startPolling(ctx)
+	if err := w.startPolling(ctx); err != nil {
+		return err
+	}
+	w.briefings.Start(ctx)
 
 	return nil
 }
 
 func (w *WeatherModule) Shutdown(ctx context.Context) error {
 	w.weatherPoll.Stop()
+	w.briefings.Stop()
 
 	return nil
 }
@@ -0,0 +1,200 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrTopicAccessDenied = errors.New("topic access denied")
+
+// TopicAccess is who may subscribe to the topics of a template
+type TopicAccess int
+
+const (
+	// TopicAccessPublic topics can be subscribed to by any chat
+	TopicAccessPublic TopicAccess = iota
+	// TopicAccessChatScoped topics can only be subscribed to by the chat named in the template's chat param
+	TopicAccessChatScoped
+	// TopicAccessBotAdmin topics can only be subscribed to by bot admins
+	TopicAccessBotAdmin
+	// TopicAccessAllowlist topics can only be subscribed to by the allowed chats
+	TopicAccessAllowlist
+)
+
+func (a TopicAccess) String() string {
+	switch a {
+	case TopicAccessPublic:
+		return "public"
+	case TopicAccessChatScoped:
+		return "chat scoped"
+	case TopicAccessBotAdmin:
+		return "bot admins only"
+	case TopicAccessAllowlist:
+		return "allowlisted chats only"
+	default:
+		return fmt.Sprintf("TopicAccess(%d)", int(a))
+	}
+}
+
+// TopicACL restricts who may subscribe to the topics of a template
+type TopicACL struct {
+	Access TopicAccess
+	// ChatParam is the template param that must equal the subscribing chat's id, used by TopicAccessChatScoped
+	ChatParam string
+	// AllowedChats may subscribe to the topics, used by TopicAccessAllowlist
+	AllowedChats []int64
+}
+
+func (a TopicACL) validate(tmpl TopicTemplate) error {
+	switch a.Access {
+	case TopicAccessPublic, TopicAccessBotAdmin, TopicAccessAllowlist:
+	case TopicAccessChatScoped:
+		if !slices.ContainsFunc(tmpl.Params, func(param TopicParam) bool { return param.Name == a.ChatParam }) {
+			return fmt.Errorf("chat param %s is not a param of template %s", a.ChatParam, tmpl.Template)
+		}
+	default:
+		return fmt.Errorf("unsupported topic access %s", a.Access)
+	}
+
+	return nil
+}
+
+// SubscriptionViolation is an existing subscription the topic ACLs no longer allow
+type SubscriptionViolation struct {
+	Subscriber Subscriber
+	Reason     string
+}
+
+// registeredTopic is a catalog template along with the regex matching the concrete topics it describes
+type registeredTopic struct {
+	TopicTemplate
+	topicRegex *regexp.Regexp
+}
+
+func newRegisteredTopic(tmpl TopicTemplate) registeredTopic {
+	return registeredTopic{
+		TopicTemplate: tmpl,
+		topicRegex: templateRegex(tmpl.Template, func(name string) string {
+			return fmt.Sprintf(`(?P<%s>[^.]+)`, name)
+		}),
+	}
+}
+
+// templateRegex builds an anchored regex matching the template, with each placeholder replaced by paramExpr
+func templateRegex(template string, paramExpr func(name string) string) *regexp.Regexp {
+	expr := strings.Builder{}
+	expr.WriteString("^")
+	last := 0
+	for _, loc := range topicParamRegex.FindAllStringSubmatchIndex(template, -1) {
+		expr.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+		expr.WriteString(paramExpr(template[loc[2]:loc[3]]))
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(template[last:]))
+	expr.WriteString("$")
+
+	return regexp.MustCompile(expr.String())
+}
+
+// checkSubscriptionAccess returns an error wrapping ErrTopicAccessDenied when the subscription pattern could match
+// a topic the subscriber isn't allowed to receive. Patterns that start with a wildcard, like a bare *, don't name any
+// topic and are allowed, topicAllowed leaves the restricted topics out when they're delivered.
+func (n *NotificationPublisher) checkSubscriptionAccess(sub Subscriber) error {
+	if strings.HasPrefix(sub.TopicPattern, "*") {
+		return nil
+	}
+
+	n.cataloglck.RLock()
+	defer n.cataloglck.RUnlock()
+
+	for _, tmpl := range n.topicTemplates {
+		if tmpl.ACL.Access == TopicAccessPublic || !patternMatchesTemplate(sub.TopicPattern, tmpl.Template) {
+			continue
+		}
+
+		switch tmpl.ACL.Access {
+		case TopicAccessBotAdmin:
+			if !n.isBotAdmin(sub.CreatedBy) {
+				return fmt.Errorf("%w: %s is restricted to bot admins", ErrTopicAccessDenied, tmpl.Template)
+			}
+		case TopicAccessAllowlist:
+			if !slices.Contains(tmpl.ACL.AllowedChats, sub.ChatId) {
+				return fmt.Errorf("%w: this chat is not allowed to subscribe to %s", ErrTopicAccessDenied, tmpl.Template)
+			}
+		case TopicAccessChatScoped:
+			chatId := strconv.FormatInt(sub.ChatId, 10)
+			// the pattern has to name this chat in the chat param, the other params may be concrete or *
+			scoped := templateRegex(tmpl.Template, func(name string) string {
+				if name == tmpl.ACL.ChatParam {
+					return regexp.QuoteMeta(chatId)
+				}
+				return `(?:[^.*]+|\*)`
+			})
+			if !scoped.MatchString(sub.TopicPattern) {
+				return fmt.Errorf("%w: %s is scoped to each chat, subscribe to %s instead", ErrTopicAccessDenied,
+					tmpl.Template, strings.ReplaceAll(tmpl.Template, "{"+tmpl.ACL.ChatParam+"}", chatId))
+			}
+		}
+	}
+
+	return nil
+}
+
+// topicAllowed checks the subscriber may receive the concrete topic. Subscribe already rejects patterns that name a
+// restricted topic, this catches wildcard subscriptions, subscriptions made before the ACL existed and unanchored
+// patterns that match more than they name.
+func (n *NotificationPublisher) topicAllowed(topic string, sub Subscriber) bool {
+	n.cataloglck.RLock()
+	defer n.cataloglck.RUnlock()
+
+	for _, tmpl := range n.topicTemplates {
+		if tmpl.ACL.Access == TopicAccessPublic {
+			continue
+		}
+
+		match := tmpl.topicRegex.FindStringSubmatch(topic)
+		if match == nil {
+			continue
+		}
+
+		switch tmpl.ACL.Access {
+		case TopicAccessBotAdmin:
+			if !n.isBotAdmin(sub.CreatedBy) {
+				return false
+			}
+		case TopicAccessAllowlist:
+			if !slices.Contains(tmpl.ACL.AllowedChats, sub.ChatId) {
+				return false
+			}
+		case TopicAccessChatScoped:
+			if match[tmpl.topicRegex.SubexpIndex(tmpl.ACL.ChatParam)] != strconv.FormatInt(sub.ChatId, 10) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// AuditSubscriptions returns the existing subscriptions the topic ACLs don't allow
+func (n *NotificationPublisher) AuditSubscriptions() []SubscriptionViolation {
+	violations := make([]SubscriptionViolation, 0)
+	for _, sub := range n.subs.All() {
+		if err := n.checkSubscriptionAccess(sub); err != nil {
+			violations = append(violations, SubscriptionViolation{
+				Subscriber: sub,
+				Reason:     strings.TrimPrefix(err.Error(), ErrTopicAccessDenied.Error()+": "),
+			})
+		}
+	}
+
+	return violations
+}
+
+func (n *NotificationPublisher) isBotAdmin(userId int64) bool {
+	return n.botAdminCheck != nil && userId != 0 && n.botAdminCheck(userId)
+}
@@ -0,0 +1,80 @@
+package notifications
+
+import (
+	"github.com/stretchr/testify/require"
+	"slices"
+)
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_ChatScopedTopic() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	// subscribed before the topic was restricted
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "birthday.*", ChatId: 12345})
+	require.NoError(t.T(), err)
+
+	require.NoError(t.T(), publisher.RegisterTopic(TopicTemplate{
+		Template: "birthday.{chat_id}",
+		Params:   []TopicParam{{Name: "chat_id"}},
+		ACL:      TopicACL{Access: TopicAccessChatScoped, ChatParam: "chat_id"},
+	}))
+
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "birthday.54321", ChatId: 12345})
+	require.ErrorIs(t.T(), err, ErrTopicAccessDenied)
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "birthday.*", ChatId: 54321})
+	require.ErrorIs(t.T(), err, ErrTopicAccessDenied)
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "birthday.54321", ChatId: 54321})
+	require.NoError(t.T(), err)
+
+	// the old wildcard subscription only receives its own chat's topic
+	chatIds := matchedChatIds(publisher, "birthday.54321", PriorityInfo)
+	require.Equal(t.T(), []int64{54321}, chatIds)
+
+	chatIds = matchedChatIds(publisher, "birthday.12345", PriorityInfo)
+	require.Equal(t.T(), []int64{12345}, chatIds)
+
+	violations := publisher.AuditSubscriptions()
+	require.Len(t.T(), violations, 1)
+	require.Equal(t.T(), "birthday.*", violations[0].Subscriber.TopicPattern)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_RestrictedTopics() {
+	admins := []int64{1}
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithBotAdminCheck(func(userId int64) bool {
+		return slices.Contains(admins, userId)
+	}))
+
+	require.NoError(t.T(), publisher.RegisterTopic(TopicTemplate{
+		Template: "system.health",
+		ACL:      TopicACL{Access: TopicAccessBotAdmin},
+	}))
+	require.NoError(t.T(), publisher.RegisterTopic(TopicTemplate{
+		Template: "beta.{feature}",
+		Params:   []TopicParam{{Name: "feature"}},
+		ACL:      TopicACL{Access: TopicAccessAllowlist, AllowedChats: []int64{12345}},
+	}))
+
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "system.*", ChatId: 12345, CreatedBy: 2})
+	require.ErrorIs(t.T(), err, ErrTopicAccessDenied)
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "system.*", ChatId: 12345, CreatedBy: 1})
+	require.NoError(t.T(), err)
+
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "beta.digest", ChatId: 54321})
+	require.ErrorIs(t.T(), err, ErrTopicAccessDenied)
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "beta.digest", ChatId: 12345})
+	require.NoError(t.T(), err)
+
+	// a subscription to everything is allowed, the restricted topics are left out when they're delivered
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "*", ChatId: 54321, CreatedBy: 2})
+	require.NoError(t.T(), err)
+	require.Equal(t.T(), []int64{12345}, matchedChatIds(publisher, "beta.digest", PriorityInfo))
+	require.Equal(t.T(), []int64{12345}, matchedChatIds(publisher, "system.health", PriorityInfo))
+	require.Equal(t.T(), []int64{54321}, matchedChatIds(publisher, "weather.90210.warning", PriorityInfo))
+
+	require.Empty(t.T(), publisher.AuditSubscriptions())
+
+	// the admin losing their role invalidates the subscription they made
+	admins = nil
+	require.Len(t.T(), publisher.AuditSubscriptions(), 1)
+	chatIds := matchedChatIds(publisher, "system.health", PriorityInfo)
+	require.Empty(t.T(), chatIds)
+}
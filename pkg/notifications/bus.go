@@ -0,0 +1,131 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrPublisherClosed = errors.New("publisher is closed")
+	ErrBusFull         = errors.New("publisher bus is full")
+)
+
+// OverflowPolicy decides what Publish does when the bus is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for room on the bus until the block timeout or the context is done
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued message to make room for the new one
+	OverflowDropOldest
+	// OverflowDropNewest discards the message being published
+	OverflowDropNewest
+)
+
+func (o OverflowPolicy) String() string {
+	switch o {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop_oldest"
+	case OverflowDropNewest:
+		return "drop_newest"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(o))
+	}
+}
+
+const (
+	defaultBusSize          = 100
+	defaultBusBlockTimeout  = 10 * time.Second
+	defaultMaxParallelSends = 8
+)
+
+// Publish validates the message, writes it to the inbox and queues it for delivery. Once Publish returns nil the
+// message is delivered even if the bot stops before getting to it. Depending on the overflow policy a full bus either
+// blocks until there is room, drops the oldest queued message or rejects this one with ErrBusFull. Publishing after
+// the publisher is closed returns ErrPublisherClosed.
+func (n *NotificationPublisher) Publish(ctx context.Context, msg Message) error {
+	if err := msg.Validate(); err != nil {
+		return fmt.Errorf("invalid message: %w", err)
+	}
+
+	select {
+	case <-n.closed:
+		return ErrPublisherClosed
+	default:
+	}
+
+	inboxID, err := n.saveInbound(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	if err := n.queueBusMessage(ctx, busMessage{Message: msg, inboxID: inboxID}); err != nil {
+		// the caller is told the message wasn't accepted, so it mustn't turn up after a restart either
+		n.removeInbound(context.Background(), inboxID)
+		return err
+	}
+
+	return nil
+}
+
+func (n *NotificationPublisher) queueBusMessage(ctx context.Context, msg busMessage) error {
+	// fast path, the bus has room
+	select {
+	case n.bus <- msg:
+		return nil
+	default:
+	}
+
+	switch n.overflowPolicy {
+	case OverflowDropNewest:
+		n.logger.Warn().Msgf("Bus full, dropping message for topic %s", msg.Topic)
+		return ErrBusFull
+	case OverflowDropOldest:
+		return n.publishDropOldest(ctx, msg)
+	default:
+		return n.publishBlocking(ctx, msg)
+	}
+}
+
+func (n *NotificationPublisher) publishBlocking(ctx context.Context, msg busMessage) error {
+	timer := time.NewTimer(n.busBlockTimeout)
+	defer timer.Stop()
+
+	select {
+	case n.bus <- msg:
+		return nil
+	case <-n.closed:
+		return ErrPublisherClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		n.logger.Warn().Msgf("Timed out after %s waiting for bus, dropping message for topic %s",
+			n.busBlockTimeout, msg.Topic)
+		return ErrBusFull
+	}
+}
+
+func (n *NotificationPublisher) publishDropOldest(ctx context.Context, msg busMessage) error {
+	for {
+		select {
+		case <-n.closed:
+			return ErrPublisherClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		case n.bus <- msg:
+			return nil
+		default:
+		}
+
+		select {
+		case dropped := <-n.bus:
+			n.logger.Warn().Msgf("Bus full, dropping oldest message for topic %s", dropped.Topic)
+			n.removeInbound(context.Background(), dropped.inboxID)
+		default:
+		}
+	}
+}
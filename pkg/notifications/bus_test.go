@@ -0,0 +1,70 @@
+package notifications
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"time"
+)
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Publish_DropNewest() {
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithBusSize(1), WithOverflowPolicy(OverflowDropNewest, 0))
+
+	require.NoError(t.T(), publisher.Publish(context.Background(), Message{Topic: "first"}))
+	require.ErrorIs(t.T(), publisher.Publish(context.Background(), Message{Topic: "second"}), ErrBusFull)
+
+	require.Equal(t.T(), "first", (<-publisher.bus).Topic)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Publish_DropOldest() {
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithBusSize(1), WithOverflowPolicy(OverflowDropOldest, 0))
+
+	require.NoError(t.T(), publisher.Publish(context.Background(), Message{Topic: "first"}))
+	require.NoError(t.T(), publisher.Publish(context.Background(), Message{Topic: "second"}))
+
+	require.Equal(t.T(), "second", (<-publisher.bus).Topic)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Publish_DropOldest_Unbuffered() {
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithBusSize(0),
+		WithOverflowPolicy(OverflowDropOldest, 10*time.Millisecond))
+	require.Equal(t.T(), OverflowBlock, publisher.overflowPolicy)
+
+	// nothing reads the bus, so the publish gives up instead of spinning forever
+	require.ErrorIs(t.T(), publisher.Publish(context.Background(), Message{Topic: "first"}), ErrBusFull)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_publishDropOldest_ContextDone() {
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithBusSize(0))
+
+	ctx, cf := context.WithCancel(context.Background())
+	cf()
+	require.ErrorIs(t.T(), publisher.publishDropOldest(ctx, busMessage{Message: Message{Topic: "first"}}), context.Canceled)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Publish_Block() {
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithBusSize(1),
+		WithOverflowPolicy(OverflowBlock, 10*time.Millisecond))
+
+	require.NoError(t.T(), publisher.Publish(context.Background(), Message{Topic: "first"}))
+	require.ErrorIs(t.T(), publisher.Publish(context.Background(), Message{Topic: "second"}), ErrBusFull)
+
+	ctx, cf := context.WithCancel(context.Background())
+	cf()
+	publisher.busBlockTimeout = time.Minute
+	require.ErrorIs(t.T(), publisher.Publish(ctx, Message{Topic: "third"}), context.Canceled)
+
+	// room frees up while blocked
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-publisher.bus
+	}()
+	require.NoError(t.T(), publisher.Publish(context.Background(), Message{Topic: "fourth"}))
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Publish_Closed() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+	require.NoError(t.T(), publisher.Start(context.Background()))
+	require.NoError(t.T(), publisher.Stop())
+
+	require.ErrorIs(t.T(), publisher.Publish(context.Background(), Message{Topic: "late"}), ErrPublisherClosed)
+}
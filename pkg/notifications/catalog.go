@@ -0,0 +1,359 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	maxTopicSuggestions = 3
+	topicStatsRetention = 30 * 24 * time.Hour
+)
+
+var topicParamRegex = regexp.MustCompile(`\{(\w+)}`)
+
+// TopicParam is a placeholder in a topic template, e.g. the location in weather.{location}.warning
+type TopicParam struct {
+	Name        string
+	Description string
+	Example     string
+}
+
+// TopicTemplate describes a family of topics a module publishes to
+type TopicTemplate struct {
+	Template    string
+	Description string
+	Params      []TopicParam
+	ACL         TopicACL
+}
+
+// Validate checks every placeholder in the template is declared as a param and vice versa
+func (t TopicTemplate) Validate() error {
+	if t.Template == "" {
+		return fmt.Errorf("topic template is empty")
+	}
+
+	used := make([]string, 0)
+	for _, match := range topicParamRegex.FindAllStringSubmatch(t.Template, -1) {
+		used = append(used, match[1])
+	}
+
+	for _, param := range t.Params {
+		if !slices.Contains(used, param.Name) {
+			return fmt.Errorf("param %s is not used in template %s", param.Name, t.Template)
+		}
+	}
+
+	for _, name := range used {
+		if !slices.ContainsFunc(t.Params, func(param TopicParam) bool { return param.Name == name }) {
+			return fmt.Errorf("placeholder %s in template %s is not declared", name, t.Template)
+		}
+	}
+
+	return t.ACL.validate(t)
+}
+
+// Example fills in the template with the example value of each param
+func (t TopicTemplate) Example() string {
+	example := t.Template
+	for _, param := range t.Params {
+		example = strings.ReplaceAll(example, "{"+param.Name+"}", util.FirstNonZero(param.Example, param.Name))
+	}
+
+	return example
+}
+
+// UnknownTopicError is returned when a subscription pattern can't match any known topic
+type UnknownTopicError struct {
+	Pattern     string
+	Suggestions []string
+}
+
+func (e *UnknownTopicError) Error() string {
+	if len(e.Suggestions) == 0 {
+		return fmt.Sprintf("unknown topic %s, see /topic browse", e.Pattern)
+	}
+
+	return fmt.Sprintf("unknown topic %s, did you mean %s?", e.Pattern, strings.Join(e.Suggestions, " or "))
+}
+
+// RegisterTopic adds a topic template to the catalog so users can find it with /topic browse
+func (n *NotificationPublisher) RegisterTopic(tmpl TopicTemplate) error {
+	if err := tmpl.Validate(); err != nil {
+		return fmt.Errorf("invalid topic template: %w", err)
+	}
+
+	n.cataloglck.Lock()
+	defer n.cataloglck.Unlock()
+
+	for i, existing := range n.topicTemplates {
+		if existing.Template == tmpl.Template {
+			n.topicTemplates[i] = newRegisteredTopic(tmpl)
+			return nil
+		}
+	}
+	n.topicTemplates = append(n.topicTemplates, newRegisteredTopic(tmpl))
+
+	return nil
+}
+
+// RegisterTopicPrefix marks every topic starting with the prefix as known, for publishers like the HTTP API that
+// publish whatever topics their callers send. A prefix of * covers every topic.
+func (n *NotificationPublisher) RegisterTopicPrefix(prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("topic prefix is empty")
+	}
+
+	n.cataloglck.Lock()
+	defer n.cataloglck.Unlock()
+
+	if !slices.Contains(n.topicPrefixes, prefix) {
+		n.topicPrefixes = append(n.topicPrefixes, prefix)
+	}
+
+	return nil
+}
+
+// underTopicPrefix reports whether the pattern could match a topic under one of the prefixes
+func underTopicPrefix(pattern string, prefixes []string) bool {
+	return slices.ContainsFunc(prefixes, func(prefix string) bool {
+		return prefix == "*" || strings.HasPrefix(pattern, prefix) || patternMatchesTemplate(pattern, prefix)
+	})
+}
+
+// GetTopicTemplates returns the registered templates starting with the prefix
+func (n *NotificationPublisher) GetTopicTemplates(prefix string) []TopicTemplate {
+	n.cataloglck.RLock()
+	defer n.cataloglck.RUnlock()
+
+	templates := make([]TopicTemplate, 0)
+	for _, tmpl := range n.topicTemplates {
+		if strings.HasPrefix(tmpl.Template, prefix) {
+			templates = append(templates, tmpl.TopicTemplate)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Template < templates[j].Template
+	})
+
+	return templates
+}
+
+// GetRecentTopics returns the concrete topics published to most recently, starting with the prefix
+func (n *NotificationPublisher) GetRecentTopics(prefix string, limit int) ([]dbmodels.NotificationsTopicStats, error) {
+	stats := make([]dbmodels.NotificationsTopicStats, 0)
+	query := n.dbConn.NewSelect().Model(&stats).
+		Order("last_seen DESC").
+		Limit(limit)
+	if prefix != "" {
+		query = query.Where(`topic LIKE ? ESCAPE '\'`, likeEscaper.Replace(prefix)+"%")
+	}
+
+	if err := query.Scan(context.TODO()); err != nil {
+		return nil, fmt.Errorf("failed to get recent topics: %w", err)
+	}
+
+	return stats, nil
+}
+
+// ValidateTopicPattern checks the subscription pattern could match a registered template, a topic under a registered
+// prefix or a recently published topic. Returns an UnknownTopicError with suggestions if it can't. Every pattern is
+// accepted while the catalog is empty.
+func (n *NotificationPublisher) ValidateTopicPattern(pattern string) error {
+	templates := n.GetTopicTemplates("")
+	n.cataloglck.RLock()
+	prefixes := slices.Clone(n.topicPrefixes)
+	n.cataloglck.RUnlock()
+	if len(templates) == 0 && len(prefixes) == 0 {
+		return nil
+	}
+
+	if underTopicPrefix(pattern, prefixes) {
+		return nil
+	}
+
+	for _, tmpl := range templates {
+		if patternMatchesTemplate(pattern, tmpl.Template) {
+			return nil
+		}
+	}
+
+	recent, err := n.GetRecentTopics("", 500)
+	if err != nil {
+		return err
+	}
+
+	candidates := make([]string, 0, len(templates)+len(recent))
+	for _, stat := range recent {
+		if patternMatchesTemplate(pattern, stat.Topic) {
+			return nil
+		}
+		candidates = append(candidates, stat.Topic)
+	}
+	for _, tmpl := range templates {
+		candidates = append(candidates, tmpl.Template)
+	}
+	for _, prefix := range prefixes {
+		candidates = append(candidates, prefix+"*")
+	}
+
+	return &UnknownTopicError{Pattern: pattern, Suggestions: suggestTopics(pattern, candidates)}
+}
+
+// recordTopicSeen bumps the publish count and last seen time of the topic
+func (n *NotificationPublisher) recordTopicSeen(ctx context.Context, topic string) {
+	now := time.Now()
+	dbStats := &dbmodels.NotificationsTopicStats{
+		Topic:     topic,
+		FirstSeen: now,
+		LastSeen:  now,
+		Count:     1,
+	}
+
+	_, err := n.dbConn.NewInsert().
+		Model(dbStats).
+		On("CONFLICT(topic) DO UPDATE").
+		Set("last_seen = EXCLUDED.last_seen").
+		Set("count = ?TableAlias.count + 1").
+		Exec(ctx)
+	if err != nil {
+		n.logger.Error().Err(err).Msgf("failed to record topic stats for %s", topic)
+	}
+}
+
+func (n *NotificationPublisher) cleanupTopicStats(ctx context.Context) {
+	_, err := n.dbConn.NewDelete().Model((*dbmodels.NotificationsTopicStats)(nil)).
+		Where("last_seen < ?", time.Now().Add(-topicStatsRetention)).
+		Exec(ctx)
+	if err != nil {
+		n.logger.Error().Err(err).Msg("failed to clean up topic stats")
+	}
+}
+
+// patternMatchesTemplate reports whether some topic matches both the subscription pattern and the template.
+// Patterns are unanchored with * matching anything, template placeholders match a single non-empty segment.
+func patternMatchesTemplate(pattern string, template string) bool {
+	const (
+		star  = -1
+		param = -2
+	)
+
+	patternTokens := []rune{star}
+	for _, r := range pattern {
+		if r == '*' {
+			patternTokens = append(patternTokens, star)
+		} else {
+			patternTokens = append(patternTokens, r)
+		}
+	}
+	patternTokens = append(patternTokens, star)
+
+	templateTokens := make([]rune, 0, len(template))
+	for i := 0; i < len(template); {
+		if loc := topicParamRegex.FindStringIndex(template[i:]); loc != nil && loc[0] == 0 {
+			templateTokens = append(templateTokens, param)
+			i += loc[1]
+			continue
+		}
+		r := []rune(template[i:])[0]
+		templateTokens = append(templateTokens, r)
+		i += len(string(r))
+	}
+
+	type state struct {
+		p, t    int
+		inParam bool
+	}
+	memo := make(map[state]bool)
+	var match func(s state) bool
+	match = func(s state) bool {
+		if result, ok := memo[s]; ok {
+			return result
+		}
+		memo[s] = false
+
+		result := false
+		switch {
+		case s.p == len(patternTokens) && s.t == len(templateTokens):
+			result = true
+		case s.p < len(patternTokens) && patternTokens[s.p] == star:
+			// the star matches nothing, a template character, or the rest of a placeholder
+			result = match(state{s.p + 1, s.t, s.inParam}) ||
+				(s.t < len(templateTokens) && match(state{s.p, s.t + 1, false}))
+		case s.t < len(templateTokens) && templateTokens[s.t] == param:
+			// the placeholder ends, or takes the next pattern character as part of its value
+			result = (s.inParam && match(state{s.p, s.t + 1, false})) ||
+				(s.p < len(patternTokens) && patternTokens[s.p] != '.' && match(state{s.p + 1, s.t, true}))
+		case s.p < len(patternTokens) && s.t < len(templateTokens):
+			result = patternTokens[s.p] == templateTokens[s.t] && match(state{s.p + 1, s.t + 1, false})
+		}
+
+		memo[s] = result
+		return result
+	}
+
+	return match(state{})
+}
+
+// suggestTopics returns the candidates closest to the pattern by edit distance
+func suggestTopics(pattern string, candidates []string) []string {
+	type scored struct {
+		topic    string
+		distance int
+	}
+
+	seen := make(map[string]struct{})
+	scoredTopics := make([]scored, 0, len(candidates))
+	for _, candidate := range candidates {
+		if _, ok := seen[candidate]; ok {
+			continue
+		}
+		seen[candidate] = struct{}{}
+		scoredTopics = append(scoredTopics, scored{candidate, levenshtein(pattern, candidate)})
+	}
+
+	sort.SliceStable(scoredTopics, func(i, j int) bool {
+		return scoredTopics[i].distance < scoredTopics[j].distance
+	})
+
+	suggestions := make([]string, 0, maxTopicSuggestions)
+	for _, s := range scoredTopics {
+		// anything needing more edits than half its length isn't a helpful suggestion
+		if len(suggestions) == maxTopicSuggestions || s.distance > len(s.topic)/2 {
+			break
+		}
+		suggestions = append(suggestions, s.topic)
+	}
+
+	return suggestions
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
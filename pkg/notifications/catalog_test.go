@@ -0,0 +1,125 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPatternMatchesTemplate(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		template string
+		want     bool
+	}{
+		{"weather.90210.warning", "weather.{zip}.warning", true},
+		{"weather.*.warning", "weather.{zip}.warning", true},
+		{"weather.*", "weather.{zip}.warning", true},
+		{"warning", "weather.{zip}.warning", true},
+		{"weather.90210", "weather.{zip}.warning", true},
+		{"weather..warning", "weather.{zip}.warning", false},
+		{"weather.90.210.warning", "weather.{zip}.warning", false},
+		{"weather.90210.alert", "weather.{zip}.warning", false},
+		{"birthday.-1001234567890", "birthday.{chat_id}", true},
+		{"birthdays.*", "birthday.{chat_id}", false},
+		{"test.alert", "test.alert", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"_"+tt.template, func(t *testing.T) {
+			require.Equal(t, tt.want, patternMatchesTemplate(tt.pattern, tt.template))
+		})
+	}
+}
+
+func TestTopicTemplate_Validate(t *testing.T) {
+	tmpl := TopicTemplate{
+		Template: "weather.{zip}.warning",
+		Params:   []TopicParam{{Name: "zip", Example: "90210"}},
+	}
+	require.NoError(t, tmpl.Validate())
+	require.Equal(t, "weather.90210.warning", tmpl.Example())
+
+	require.Error(t, TopicTemplate{Template: "weather.{zip}.warning"}.Validate())
+	require.Error(t, TopicTemplate{Template: "weather.warning", Params: []TopicParam{{Name: "zip"}}}.Validate())
+	require.Error(t, TopicTemplate{
+		Template: "birthday.{chat_id}",
+		Params:   []TopicParam{{Name: "chat_id"}},
+		ACL:      TopicACL{Access: TopicAccessChatScoped, ChatParam: "chat"},
+	}.Validate())
+}
+
+func TestSuggestTopics(t *testing.T) {
+	candidates := []string{"weather.{zip}.warning", "weather.{zip}.watch", "birthday.{chat_id}", "weather.{zip}.warning"}
+
+	require.Equal(t, []string{"birthday.{chat_id}"}, suggestTopics("birthdya.{chat_id}", candidates))
+	require.Empty(t, suggestTopics("stocks", candidates))
+	require.Equal(t, 3, levenshtein("kitten", "sitting"))
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_ValidateTopicPattern() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	// nothing registered yet, so nothing can be rejected
+	require.NoError(t.T(), publisher.ValidateTopicPattern("anything.goes"))
+
+	require.NoError(t.T(), publisher.RegisterTopic(TopicTemplate{
+		Template: "weather.{zip}.warning",
+		Params:   []TopicParam{{Name: "zip", Example: "90210"}},
+	}))
+	require.NoError(t.T(), publisher.ValidateTopicPattern("weather.*.warning"))
+
+	err := publisher.ValidateTopicPattern("weathr.90210.warning")
+	unknownErr := &UnknownTopicError{}
+	require.True(t.T(), errors.As(err, &unknownErr))
+	require.Equal(t.T(), []string{"weather.{zip}.warning"}, unknownErr.Suggestions)
+
+	// recently published topics are accepted even without a template
+	publisher.recordTopicSeen(context.Background(), "test.alert")
+	require.NoError(t.T(), publisher.ValidateTopicPattern("test.alert"))
+
+	// topics under a registered prefix are accepted before anything is published to them
+	require.Error(t.T(), publisher.RegisterTopicPrefix(""))
+	require.NoError(t.T(), publisher.RegisterTopicPrefix("ci."))
+	require.NoError(t.T(), publisher.ValidateTopicPattern("ci.builds.failed"))
+	require.NoError(t.T(), publisher.ValidateTopicPattern("ci.*"))
+	require.Error(t.T(), publisher.ValidateTopicPattern("cd.builds.failed"))
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_GetRecentTopics() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	publisher.recordTopicSeen(context.Background(), "weather.90210.warning")
+	publisher.recordTopicSeen(context.Background(), "weather.90210.warning")
+	publisher.recordTopicSeen(context.Background(), "birthday.12345")
+
+	recent, err := publisher.GetRecentTopics("weather.", 10)
+	require.NoError(t.T(), err)
+	require.Len(t.T(), recent, 1)
+	require.Equal(t.T(), "weather.90210.warning", recent[0].Topic)
+	require.Equal(t.T(), 2, recent[0].Count)
+
+	recent, err = publisher.GetRecentTopics("", 10)
+	require.NoError(t.T(), err)
+	require.Len(t.T(), recent, 2)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_GetRecentTopics_EscapedPrefix() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	publisher.recordTopicSeen(context.Background(), "ci.foo_bar.failed")
+	publisher.recordTopicSeen(context.Background(), "ci.fooxbar.failed")
+	publisher.recordTopicSeen(context.Background(), `ci.foo\bar.failed`)
+
+	// _ and \ are taken literally rather than as like wildcards or escapes
+	recent, err := publisher.GetRecentTopics("ci.foo_bar", 10)
+	require.NoError(t.T(), err)
+	require.Len(t.T(), recent, 1)
+	require.Equal(t.T(), "ci.foo_bar.failed", recent[0].Topic)
+
+	recent, err = publisher.GetRecentTopics(`ci.foo\`, 10)
+	require.NoError(t.T(), err)
+	require.Len(t.T(), recent, 1)
+	require.Equal(t.T(), `ci.foo\bar.failed`, recent[0].Topic)
+}
@@ -0,0 +1,68 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DeliveryFilter lets the module publishing a family of topics tailor each message to the chat it's delivered to,
+// e.g. to apply the chat's own preferences. It returns the message to deliver to the chat, or false to leave the
+// chat out.
+type DeliveryFilter func(ctx context.Context, chatId int64, msg Message) (Message, bool)
+
+type registeredFilter struct {
+	prefix string
+	filter DeliveryFilter
+}
+
+// RegisterDeliveryFilter applies the filter to messages for topics starting with the prefix, on their way to each
+// telegram chat. Registering a prefix again replaces its filter.
+func (n *NotificationPublisher) RegisterDeliveryFilter(prefix string, filter DeliveryFilter) error {
+	if prefix == "" {
+		return fmt.Errorf("delivery filter prefix is empty")
+	}
+	if filter == nil {
+		return fmt.Errorf("delivery filter for %s is nil", prefix)
+	}
+
+	n.cataloglck.Lock()
+	defer n.cataloglck.Unlock()
+
+	for i, existing := range n.deliveryFilters {
+		if existing.prefix == prefix {
+			n.deliveryFilters[i].filter = filter
+			return nil
+		}
+	}
+	n.deliveryFilters = append(n.deliveryFilters, registeredFilter{prefix: prefix, filter: filter})
+	// the longest prefixes run first, so a module's filter runs before a broader one
+	sort.SliceStable(n.deliveryFilters, func(i, j int) bool {
+		return len(n.deliveryFilters[i].prefix) > len(n.deliveryFilters[j].prefix)
+	})
+
+	return nil
+}
+
+// filterForChat runs the message through the delivery filters matching its topic, returning false as soon as one
+// leaves the chat out
+func (n *NotificationPublisher) filterForChat(ctx context.Context, chatId int64, msg Message) (Message, bool) {
+	n.cataloglck.RLock()
+	filters := make([]DeliveryFilter, 0)
+	for _, registered := range n.deliveryFilters {
+		if strings.HasPrefix(msg.Topic, registered.prefix) {
+			filters = append(filters, registered.filter)
+		}
+	}
+	n.cataloglck.RUnlock()
+
+	for _, filter := range filters {
+		var ok bool
+		if msg, ok = filter(ctx, chatId, msg); !ok {
+			return msg, false
+		}
+	}
+
+	return msg, true
+}
@@ -0,0 +1,46 @@
+package notifications
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+)
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_DeliveryFilter() {
+	mockBot := proxy.NewMockTGBotSendable(t.T())
+	mockBot.EXPECT().Send(mock.MatchedBy(func(c tgbotapi.MessageConfig) bool {
+		return c.ChatID == 12345 && c.Text == "Heat advisory for 12345"
+	})).Return(tgbotapi.Message{MessageID: 42}, nil).Once()
+
+	publisher := NewNotificationPublisher(mockBot, t.dbConn)
+	require.Error(t.T(), publisher.RegisterDeliveryFilter("", func(_ context.Context, _ int64, msg Message) (Message, bool) {
+		return msg, true
+	}))
+	require.NoError(t.T(), publisher.RegisterDeliveryFilter("weather.", func(_ context.Context, chatId int64, msg Message) (Message, bool) {
+		if chatId == 54321 {
+			return msg, false
+		}
+
+		msg.Msg = msg.Data.(string) + " for 12345"
+		return msg, true
+	}))
+	require.NoError(t.T(), publisher.RegisterDeliveryFilter("other.", func(_ context.Context, _ int64, msg Message) (Message, bool) {
+		return msg, false
+	}))
+
+	for _, chatId := range []int64{12345, 54321} {
+		_, err := publisher.Subscribe(Subscriber{TopicPattern: "weather.*", ChatId: chatId})
+		require.NoError(t.T(), err)
+	}
+
+	require.NoError(t.T(), publisher.handleBusMessage(context.Background(),
+		Message{Topic: "weather.12345.advisory", Msg: "Heat advisory", Data: "Heat advisory"}))
+	publisher.dispatchOutbox(context.Background())
+
+	history, err := publisher.GetHistory(HistoryFilter{ChatId: 54321})
+	require.NoError(t.T(), err)
+	require.Len(t.T(), history, 1)
+	require.Equal(t.T(), HistoryStatusFiltered, history[0].Status)
+}
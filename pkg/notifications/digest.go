@@ -0,0 +1,261 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/google/uuid"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"github.com/uptrace/bun"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed digest.tmpl
+var digestTemplateStr string
+
+var digestTemplate = template.Must(template.New("digest").Parse(digestTemplateStr))
+
+// DigestSchedule controls when the messages collected for a digest subscription are sent. Either every Interval
+// after the first collected message, or at fixed times of day. The zero value means immediate delivery. Fixed times
+// without a Location follow the chat's quiet hours timezone.
+type DigestSchedule struct {
+	Interval time.Duration
+	Times    []time.Duration
+	Location *time.Location
+}
+
+// ParseDigestSchedule parses either a duration (30m, 2h) or a comma separated list of HH:MM times, optionally
+// followed by @ and a timezone (08:00,18:00@Europe/London). An empty string is immediate delivery.
+func ParseDigestSchedule(raw string) (DigestSchedule, error) {
+	if raw == "" {
+		return DigestSchedule{}, nil
+	}
+
+	if !strings.Contains(raw, ":") {
+		interval, err := time.ParseDuration(raw)
+		if err != nil {
+			return DigestSchedule{}, fmt.Errorf("digest must be a duration like 30m or times like 08:00,18:00")
+		}
+		if interval < time.Minute {
+			return DigestSchedule{}, fmt.Errorf("digest interval must be at least a minute")
+		}
+
+		return DigestSchedule{Interval: interval}, nil
+	}
+
+	var loc *time.Location
+	if rawTimes, tz, ok := strings.Cut(raw, "@"); ok {
+		var err error
+		if loc, err = time.LoadLocation(strings.TrimSpace(tz)); err != nil {
+			return DigestSchedule{}, fmt.Errorf("invalid digest timezone %s: %w", tz, err)
+		}
+		raw = rawTimes
+	}
+
+	times := make([]time.Duration, 0)
+	for _, rawTime := range strings.Split(raw, ",") {
+		offset, err := parseTimeOfDay(strings.TrimSpace(rawTime))
+		if err != nil {
+			return DigestSchedule{}, fmt.Errorf("invalid digest time %s: %w", rawTime, err)
+		}
+		times = append(times, offset)
+	}
+	slices.Sort(times)
+
+	return DigestSchedule{Times: slices.Compact(times), Location: loc}, nil
+}
+
+func (d DigestSchedule) IsZero() bool {
+	return d.Interval == 0 && len(d.Times) == 0
+}
+
+// InLocation returns the schedule with its fixed times in loc, unless the schedule already names a timezone
+func (d DigestSchedule) InLocation(loc *time.Location) DigestSchedule {
+	if d.Location == nil {
+		d.Location = loc
+	}
+
+	return d
+}
+
+// NextAfter returns the first time a digest is due for a message collected at t. Fixed times without a Location are
+// taken as UTC, see InLocation.
+func (d DigestSchedule) NextAfter(t time.Time) time.Time {
+	if d.Interval > 0 {
+		return t.Add(d.Interval)
+	}
+
+	loc := d.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	local := t.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	for day := 0; day < 2; day++ {
+		for _, offset := range d.Times {
+			next := midnight.AddDate(0, 0, day).Add(offset)
+			if next.After(t) {
+				return next
+			}
+		}
+	}
+
+	return midnight.AddDate(0, 0, 2)
+}
+
+func (d DigestSchedule) String() string {
+	if d.Interval > 0 {
+		return d.Interval.String()
+	}
+
+	times := make([]string, 0, len(d.Times))
+	for _, offset := range d.Times {
+		times = append(times, formatTimeOfDay(offset))
+	}
+
+	if d.Location != nil {
+		return strings.Join(times, ",") + "@" + d.Location.String()
+	}
+
+	return strings.Join(times, ",")
+}
+
+type digestTemplateData struct {
+	Pattern string
+	Items   []dbmodels.NotificationsDigestItems
+}
+
+func (n *NotificationPublisher) collectDigestItem(ctx context.Context, sub Subscriber, dupKey string, msg Message) error {
+	n.logger.Trace().Msgf("Collecting message for digest subscription %s in chat %d", sub.ID, sub.ChatId)
+
+	dbItem := &dbmodels.NotificationsDigestItems{
+		ChatID:         sub.ChatId,
+		SubscriptionID: sub.ID,
+		Topic:          msg.Topic,
+		Message:        msg.PlainText(),
+		DupeKey:        dupKey,
+		Priority:       int(msg.Priority),
+	}
+
+	_, err := n.dbConn.NewInsert().Model(dbItem).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to insert digest item: %w", err)
+	}
+
+	return nil
+}
+
+// flushDigests sends every digest that is due. Digests for chats in quiet hours wait until the window ends.
+func (n *NotificationPublisher) flushDigests(ctx context.Context) {
+	subIds := make([]uuid.UUID, 0)
+	err := n.dbConn.NewSelect().Model((*dbmodels.NotificationsDigestItems)(nil)).
+		Column("subscription_id").
+		Distinct().
+		Scan(ctx, &subIds)
+	if err != nil {
+		n.logger.Error().Err(err).Msg("failed to get pending digests")
+		return
+	}
+
+	now := time.Now()
+	for _, subId := range subIds {
+		if err := n.flushDigest(ctx, subId, now); err != nil {
+			n.logger.Error().Err(err).Msgf("failed to flush digest for subscription %s", subId)
+		}
+	}
+}
+
+func (n *NotificationPublisher) flushDigest(ctx context.Context, subId uuid.UUID, now time.Time) error {
+	items := make([]dbmodels.NotificationsDigestItems, 0)
+	err := n.dbConn.NewSelect().Model(&items).
+		Where("subscription_id = ?", subId).
+		Order("created_at ASC", "id ASC").
+		Scan(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get digest items: %w", err)
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	chatId := items[0].ChatID
+	loc := n.chatLocation(chatId)
+
+	// a subscription removed since the items were collected is flushed right away
+	pattern := items[0].Topic
+	if sub, ok := n.subs.Get(subId); ok {
+		schedule := sub.Digest.InLocation(loc)
+		if now.Before(schedule.NextAfter(items[0].CreatedAt)) {
+			return nil
+		}
+		pattern = sub.TopicPattern
+		loc = schedule.Location
+	}
+
+	if n.isQuiet(chatId, PriorityDebug, now) {
+		return nil
+	}
+
+	digest, err := renderDigest(pattern, items, loc)
+	if err != nil {
+		return err
+	}
+
+	n.logger.Debug().Msgf("Delivering digest of %d messages to chat %d", len(items), chatId)
+	for _, chunk := range util.SplitMessage(digest, util.TelegramMaxMessageLength) {
+		digestMsg := Message{
+			Topic:    items[0].Topic,
+			Msg:      chunk,
+			Priority: PriorityInfo,
+		}
+		if err := n.enqueueDelivery(ctx, telegramTarget(chatId), fmt.Sprintf("%d-digest-%s", chatId, subId), digestMsg); err != nil {
+			return fmt.Errorf("failed to queue digest: %w", err)
+		}
+	}
+
+	ids := make([]int, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+
+	_, err = n.dbConn.NewDelete().Model((*dbmodels.NotificationsDigestItems)(nil)).
+		Where("id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete digest items: %w", err)
+	}
+
+	return nil
+}
+
+// renderDigest renders the items into a single digest message, collapsing repeats of the same dupe key into the
+// most recent one, with the times stamped in loc
+func renderDigest(pattern string, items []dbmodels.NotificationsDigestItems, loc *time.Location) (string, error) {
+	latest := make(map[string]int, len(items))
+	for i, item := range items {
+		latest[item.DupeKey] = i
+	}
+
+	data := digestTemplateData{Pattern: pattern}
+	for i, item := range items {
+		if latest[item.DupeKey] != i {
+			continue
+		}
+		item.CreatedAt = item.CreatedAt.In(loc)
+		data.Items = append(data.Items, item)
+	}
+
+	msgBuffer := bytes.Buffer{}
+	if err := digestTemplate.Execute(&msgBuffer, data); err != nil {
+		return "", fmt.Errorf("failed to execute digest template: %w", err)
+	}
+
+	return msgBuffer.String(), nil
+}
@@ -0,0 +1,151 @@
+package notifications
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDigestSchedule(t *testing.T) {
+	immediate, err := ParseDigestSchedule("")
+	require.NoError(t, err)
+	require.True(t, immediate.IsZero())
+
+	interval, err := ParseDigestSchedule("30m")
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Minute, interval.Interval)
+	require.Equal(t, "30m0s", interval.String())
+
+	fixed, err := ParseDigestSchedule("18:00,08:00")
+	require.NoError(t, err)
+	require.Equal(t, []time.Duration{8 * time.Hour, 18 * time.Hour}, fixed.Times)
+	require.Equal(t, "08:00,18:00", fixed.String())
+
+	zoned, err := ParseDigestSchedule("08:00@Europe/London")
+	require.NoError(t, err)
+	require.Equal(t, "08:00@Europe/London", zoned.String())
+
+	_, err = ParseDigestSchedule("08:00@Nowhere/Special")
+	require.Error(t, err)
+
+	_, err = ParseDigestSchedule("10s")
+	require.Error(t, err)
+
+	_, err = ParseDigestSchedule("08:00,25:00")
+	require.Error(t, err)
+
+	_, err = ParseDigestSchedule("soon")
+	require.Error(t, err)
+}
+
+func TestDigestSchedule_NextAfter(t *testing.T) {
+	interval, err := ParseDigestSchedule("30m")
+	require.NoError(t, err)
+	start := time.Date(2024, 7, 1, 12, 10, 0, 0, time.UTC)
+	require.Equal(t, start.Add(30*time.Minute), interval.NextAfter(start))
+
+	loc, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	fixed, err := ParseDigestSchedule("08:00,18:00")
+	require.NoError(t, err)
+	require.Nil(t, fixed.Location)
+	fixed = fixed.InLocation(loc)
+
+	require.Equal(t, time.Date(2024, 7, 1, 18, 0, 0, 0, loc), fixed.NextAfter(time.Date(2024, 7, 1, 9, 0, 0, 0, loc)))
+	require.Equal(t, time.Date(2024, 7, 2, 8, 0, 0, 0, loc), fixed.NextAfter(time.Date(2024, 7, 1, 19, 0, 0, 0, loc)))
+	require.Equal(t, time.Date(2024, 7, 1, 8, 0, 0, 0, loc), fixed.NextAfter(time.Date(2024, 7, 1, 7, 59, 0, 0, loc)))
+
+	// a timezone in the schedule wins over the chat's
+	tokyo, err := ParseDigestSchedule("08:00@Asia/Tokyo")
+	require.NoError(t, err)
+	tokyo = tokyo.InLocation(loc)
+	require.Equal(t, "Asia/Tokyo", tokyo.Location.String())
+	require.True(t, time.Date(2024, 7, 1, 23, 0, 0, 0, time.UTC).Equal(tokyo.NextAfter(time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC))))
+}
+
+func TestRenderDigest(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	digest, err := renderDigest("weather.*", []dbmodels.NotificationsDigestItems{
+		{Topic: "weather.90210.warning", DupeKey: "a", Message: "first alert"},
+		{Topic: "weather.10001.watch", DupeKey: "b", Message: "flood watch"},
+		{Topic: "weather.90210.warning", DupeKey: "a", Message: "updated alert",
+			CreatedAt: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)},
+	}, loc)
+	require.NoError(t, err)
+
+	require.True(t, strings.HasPrefix(digest, "📰 Digest for weather.* (2 messages)"))
+	require.NotContains(t, digest, "first alert")
+	require.Contains(t, digest, "[weather.10001.watch]")
+	require.Less(t, strings.Index(digest, "flood watch"), strings.Index(digest, "updated alert"))
+	require.Contains(t, digest, "Jul 1 14:00 CEST")
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Digest() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	digest, err := ParseDigestSchedule("30m")
+	require.NoError(t.T(), err)
+	subId, err := publisher.Subscribe(Subscriber{
+		TopicPattern: "weather.*",
+		ChatId:       12345,
+		Digest:       digest,
+	})
+	require.NoError(t.T(), err)
+
+	for _, msg := range []Message{
+		{Topic: "weather.90210.warning", Msg: "heat warning", Priority: PriorityWarning},
+		{Topic: "weather.10001.watch", Msg: "flood watch", Priority: PriorityWarning},
+		{Topic: "weather.10001.warning", Msg: "tornado warning", Priority: PriorityCritical},
+	} {
+		require.NoError(t.T(), publisher.handleBusMessage(context.Background(), msg))
+	}
+
+	// critical messages skip the digest
+	outbox := t.getOutbox()
+	require.Len(t.T(), outbox, 1)
+	require.Equal(t.T(), "tornado warning", outbox[0].Message)
+
+	items := make([]dbmodels.NotificationsDigestItems, 0)
+	require.NoError(t.T(), t.dbConn.NewSelect().Model(&items).Scan(context.Background()))
+	require.Len(t.T(), items, 2)
+	require.Equal(t.T(), subId, items[0].SubscriptionID.String())
+
+	// not due yet
+	require.NoError(t.T(), publisher.flushDigest(context.Background(), items[0].SubscriptionID, time.Now()))
+	require.Len(t.T(), t.getOutbox(), 1)
+
+	require.NoError(t.T(), publisher.flushDigest(context.Background(), items[0].SubscriptionID, time.Now().Add(time.Hour)))
+	outbox = t.getOutbox()
+	require.Len(t.T(), outbox, 2)
+	require.Contains(t.T(), outbox[1].Message, "heat warning")
+	require.Contains(t.T(), outbox[1].Message, "flood watch")
+
+	count, err := t.dbConn.NewSelect().Model((*dbmodels.NotificationsDigestItems)(nil)).Count(context.Background())
+	require.NoError(t.T(), err)
+	require.Zero(t.T(), count)
+
+	// digest schedule survives a reload
+	reloaded := NewNotificationPublisher(nil, t.dbConn)
+	sub, ok := reloaded.subs.Get(items[0].SubscriptionID)
+	require.True(t.T(), ok)
+	require.Equal(t.T(), 30*time.Minute, sub.Digest.Interval)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Digest_ImmediateWins() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	digest, err := ParseDigestSchedule("30m")
+	require.NoError(t.T(), err)
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "weather.*", ChatId: 12345, Digest: digest})
+	require.NoError(t.T(), err)
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "weather.90210.*", ChatId: 12345})
+	require.NoError(t.T(), err)
+
+	subscribers := publisher.matchSubscribers("weather.90210.warning", PriorityWarning, nil)
+	require.Len(t.T(), subscribers, 1)
+	require.True(t.T(), subscribers[0].Digest.IsZero())
+}
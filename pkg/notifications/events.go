@@ -0,0 +1,204 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"strings"
+	"time"
+)
+
+// EventAction is what a message with an EventID does to the message already sent for that event
+type EventAction string
+
+const (
+	// EventActionUpdate posts the message, or updates the one already sent for the event
+	EventActionUpdate EventAction = ""
+	// EventActionCancel strikes through the message already sent for the event
+	EventActionCancel EventAction = "cancel"
+)
+
+// EventUpdateStyle is how an update to an already sent event is shown
+type EventUpdateStyle int
+
+const (
+	// EventUpdateEdit edits the sent message in place
+	EventUpdateEdit EventUpdateStyle = iota
+	// EventUpdateReply posts the update as a reply to the sent message
+	EventUpdateReply
+)
+
+const defaultCancelNote = "❌ Cancelled"
+
+// deliver sends the outbox row, editing or replying to the previously sent message when the row belongs to an event.
+// Returns the id of the telegram message sent or edited, 0 if there was nothing to send.
+func (n *NotificationPublisher) deliver(ctx context.Context, row dbmodels.NotificationsOutbox, opts messageOptions) (int, error) {
+	if opts.EventID == "" {
+		sentMsg, err := n.tgbot.Send(opts.chattable(row.ChatID, row.Message, 0))
+		return sentMsg.MessageID, err
+	}
+
+	sent, found, err := n.getSentEvent(ctx, row.ChatID, opts.EventID)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case opts.Action == EventActionCancel && !found:
+		n.logger.Debug().Msgf("Nothing sent for event %s in chat %d, skipping cancel", opts.EventID, row.ChatID)
+		return 0, nil
+	case opts.Action == EventActionCancel:
+		return sent.MessageID, n.cancelEvent(ctx, sent, row, opts)
+	case found && n.eventUpdateStyle == EventUpdateEdit && (opts.Attachment != nil) == sent.HasAttachment:
+		err := n.editEvent(sent, row.Message, opts)
+		if err == nil {
+			n.recordSentEvent(ctx, row.ChatID, opts, sent.MessageID, row.Message)
+			return sent.MessageID, nil
+		}
+		n.logger.Warn().Err(err).Msgf("Failed to edit message for event %s in chat %d, replying instead",
+			opts.EventID, row.ChatID)
+	}
+
+	replyTo := 0
+	if found {
+		replyTo = sent.MessageID
+	}
+
+	sentMsg, err := n.tgbot.Send(opts.chattable(row.ChatID, row.Message, replyTo))
+	if err != nil {
+		return 0, err
+	}
+
+	n.recordSentEvent(ctx, row.ChatID, opts, sentMsg.MessageID, row.Message)
+	return sentMsg.MessageID, nil
+}
+
+func (n *NotificationPublisher) editEvent(sent dbmodels.NotificationsSentEvents, text string, opts messageOptions) error {
+	var edit tgbotapi.Chattable
+	if sent.HasAttachment {
+		caption := tgbotapi.NewEditMessageCaption(sent.ChatID, sent.MessageID, text)
+		caption.ParseMode = opts.ParseMode
+		caption.ReplyMarkup = opts.keyboard()
+		edit = caption
+	} else {
+		editText := tgbotapi.NewEditMessageText(sent.ChatID, sent.MessageID, text)
+		editText.ParseMode = opts.ParseMode
+		editText.DisableWebPagePreview = opts.DisableLinkPreview
+		editText.ReplyMarkup = opts.keyboard()
+		edit = editText
+	}
+
+	_, err := n.tgbot.Send(edit)
+	if err != nil && strings.Contains(err.Error(), "message is not modified") {
+		return nil
+	}
+
+	return err
+}
+
+// cancelEvent strikes through the last text sent for the event and adds the cancel message, or a default note,
+// below it
+func (n *NotificationPublisher) cancelEvent(ctx context.Context, sent dbmodels.NotificationsSentEvents, row dbmodels.NotificationsOutbox, opts messageOptions) error {
+	note := Message{Msg: row.Message, ParseMode: opts.ParseMode}.PlainText()
+	if note == "" {
+		note = defaultCancelNote
+	}
+
+	parseMode := sent.ParseMode
+	var struck string
+	switch parseMode {
+	case tgbotapi.ModeHTML:
+		struck = "<s>" + sent.Text + "</s>"
+	case tgbotapi.ModeMarkdownV2:
+		struck = "~" + sent.Text + "~"
+	default:
+		parseMode = tgbotapi.ModeHTML
+		struck = "<s>" + EscapeText(parseMode, sent.Text) + "</s>"
+	}
+	text := struck + "\n\n" + EscapeText(parseMode, note)
+
+	// the buttons are dropped since the event is over
+	cancelOpts := messageOptions{ParseMode: parseMode, DisableLinkPreview: true}
+	if err := n.editEvent(sent, text, cancelOpts); err != nil {
+		return fmt.Errorf("failed to strike through event message: %w", err)
+	}
+
+	_, err := n.dbConn.NewDelete().Model((*dbmodels.NotificationsSentEvents)(nil)).
+		Where("chat_id = ?", sent.ChatID).
+		Where("event_id = ?", sent.EventID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete sent event: %w", err)
+	}
+
+	return nil
+}
+
+func (n *NotificationPublisher) getSentEvent(ctx context.Context, chatId int64, eventId string) (dbmodels.NotificationsSentEvents, bool, error) {
+	sent := dbmodels.NotificationsSentEvents{}
+	err := n.dbConn.NewSelect().Model(&sent).
+		Where("chat_id = ?", chatId).
+		Where("event_id = ?", eventId).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sent, false, nil
+	} else if err != nil {
+		return sent, false, fmt.Errorf("failed to get sent event: %w", err)
+	}
+
+	return sent, true, nil
+}
+
+func (n *NotificationPublisher) hasSentEvent(ctx context.Context, chatId int64, eventId string) bool {
+	if eventId == "" {
+		return false
+	}
+
+	_, found, err := n.getSentEvent(ctx, chatId, eventId)
+	if err != nil {
+		n.logger.Error().Err(err).Msgf("failed to check sent event %s for chat %d", eventId, chatId)
+	}
+
+	return found
+}
+
+// recordSentEvent remembers the telegram message sent for the event. Failing to record only loses the ability to
+// update it later, so the error is logged rather than failing a delivery that already went out.
+func (n *NotificationPublisher) recordSentEvent(ctx context.Context, chatId int64, opts messageOptions, messageId int, text string) {
+	now := time.Now()
+	dbSent := &dbmodels.NotificationsSentEvents{
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		ChatID:        chatId,
+		EventID:       opts.EventID,
+		MessageID:     messageId,
+		Text:          text,
+		ParseMode:     opts.ParseMode,
+		HasAttachment: opts.Attachment != nil,
+	}
+
+	_, err := n.dbConn.NewInsert().
+		Model(dbSent).
+		On("CONFLICT(chat_id, event_id) DO UPDATE").
+		Set("message_id = EXCLUDED.message_id").
+		Set("text = EXCLUDED.text").
+		Set("parse_mode = EXCLUDED.parse_mode").
+		Set("has_attachment = EXCLUDED.has_attachment").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil {
+		n.logger.Error().Err(err).Msgf("failed to record sent event %s for chat %d", opts.EventID, chatId)
+	}
+}
+
+func (n *NotificationPublisher) cleanupSentEvents(ctx context.Context) {
+	_, err := n.dbConn.NewDelete().Model((*dbmodels.NotificationsSentEvents)(nil)).
+		Where("updated_at < ?", time.Now().Add(-outboxRetention)).
+		Exec(ctx)
+	if err != nil {
+		n.logger.Error().Err(err).Msg("failed to clean up sent events")
+	}
+}
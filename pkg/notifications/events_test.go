@@ -0,0 +1,95 @@
+package notifications
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"time"
+)
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Events_EditAndCancel() {
+	mockBot := proxy.NewMockTGBotSendable(t.T())
+	mockBot.EXPECT().Send(mock.MatchedBy(func(c tgbotapi.MessageConfig) bool {
+		return c.Text == "Heat advisory until 6pm"
+	})).Return(tgbotapi.Message{MessageID: 42}, nil).Once()
+	mockBot.EXPECT().Send(mock.MatchedBy(func(c tgbotapi.EditMessageTextConfig) bool {
+		return c.MessageID == 42 && c.Text == "Heat advisory until 9pm"
+	})).Return(tgbotapi.Message{MessageID: 42}, nil).Once()
+	mockBot.EXPECT().Send(mock.MatchedBy(func(c tgbotapi.EditMessageTextConfig) bool {
+		return c.MessageID == 42 && c.ParseMode == tgbotapi.ModeHTML &&
+			c.Text == "<s>Heat advisory until 9pm</s>\n\n"+defaultCancelNote
+	})).Return(tgbotapi.Message{MessageID: 42}, nil).Once()
+
+	publisher := NewNotificationPublisher(mockBot, t.dbConn)
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "weather.*", ChatId: 12345})
+	require.NoError(t.T(), err)
+
+	for _, msg := range []Message{
+		{Topic: "weather.12345.advisory", Msg: "Heat advisory until 6pm", EventID: "heat"},
+		{Topic: "weather.12345.advisory", Msg: "Heat advisory until 9pm", EventID: "heat"},
+		{Topic: "weather.12345.advisory", EventID: "heat", Action: EventActionCancel},
+	} {
+		require.NoError(t.T(), publisher.handleBusMessage(context.Background(), msg))
+		publisher.dispatchOutbox(context.Background())
+	}
+
+	_, found, err := publisher.getSentEvent(context.Background(), 12345, "heat")
+	require.NoError(t.T(), err)
+	require.False(t.T(), found)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Events_Reply() {
+	mockBot := proxy.NewMockTGBotSendable(t.T())
+	mockBot.EXPECT().Send(mock.MatchedBy(func(c tgbotapi.MessageConfig) bool {
+		return c.ReplyToMessageID == 0
+	})).Return(tgbotapi.Message{MessageID: 42}, nil).Once()
+	mockBot.EXPECT().Send(mock.MatchedBy(func(c tgbotapi.MessageConfig) bool {
+		return c.ReplyToMessageID == 42 && c.AllowSendingWithoutReply
+	})).Return(tgbotapi.Message{MessageID: 43}, nil).Once()
+
+	publisher := NewNotificationPublisher(mockBot, t.dbConn, WithEventUpdateStyle(EventUpdateReply))
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "weather.*", ChatId: 12345})
+	require.NoError(t.T(), err)
+
+	for _, msg := range []Message{
+		{Topic: "weather.12345.advisory", Msg: "first", EventID: "heat"},
+		{Topic: "weather.12345.advisory", Msg: "second", EventID: "heat"},
+	} {
+		require.NoError(t.T(), publisher.handleBusMessage(context.Background(), msg))
+		publisher.dispatchOutbox(context.Background())
+	}
+
+	sent, found, err := publisher.getSentEvent(context.Background(), 12345, "heat")
+	require.NoError(t.T(), err)
+	require.True(t.T(), found)
+	require.Equal(t.T(), 43, sent.MessageID)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Events_SkipQuietHours() {
+	mockBot := proxy.NewMockTGBotSendable(t.T())
+	mockBot.EXPECT().Send(mock.Anything).Return(tgbotapi.Message{MessageID: 42}, nil).Twice()
+
+	publisher := NewNotificationPublisher(mockBot, t.dbConn)
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "weather.*", ChatId: 12345})
+	require.NoError(t.T(), err)
+
+	require.NoError(t.T(), publisher.handleBusMessage(context.Background(),
+		Message{Topic: "weather.12345.advisory", Msg: "first", EventID: "heat"}))
+	publisher.dispatchOutbox(context.Background())
+
+	now := time.Now().UTC()
+	quietHours, err := NewQuietHours(12345, now.Add(-time.Hour).Format("15:04"), now.Add(time.Hour).Format("15:04"), "UTC")
+	require.NoError(t.T(), err)
+	require.NoError(t.T(), publisher.SetQuietHours(quietHours))
+
+	// the update edits the sent message even during quiet hours
+	require.NoError(t.T(), publisher.handleBusMessage(context.Background(),
+		Message{Topic: "weather.12345.advisory", Msg: "second", EventID: "heat"}))
+	publisher.dispatchOutbox(context.Background())
+
+	outbox := t.getOutbox()
+	require.Len(t.T(), outbox, 2)
+	require.Equal(t.T(), OutboxStatusDelivered, outbox[1].Status)
+}
@@ -0,0 +1,270 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// maxCaptionLength is the most characters telegram accepts as the caption of a photo or document
+const maxCaptionLength = 1024
+
+var htmlTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// telegramHTMLTagRegex matches an opening or closing tag, capturing the slash and the tag name
+var telegramHTMLTagRegex = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9-]*)(?:\s[^<>]*)?>`)
+
+// telegramHTMLTags are the tags telegram's HTML parse mode supports
+var telegramHTMLTags = []string{"a", "b", "blockquote", "code", "del", "em", "i", "ins", "pre", "s", "span",
+	"strike", "strong", "tg-emoji", "tg-spoiler", "u"}
+
+type AttachmentType string
+
+const (
+	AttachmentPhoto    AttachmentType = "photo"
+	AttachmentDocument AttachmentType = "document"
+)
+
+// Attachment is a photo or document sent by url. The message text becomes its caption.
+type Attachment struct {
+	Type AttachmentType `json:"type"`
+	URL  string         `json:"url"`
+}
+
+// Button is an inline button that opens a url
+type Button struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// messageOptions is everything about a message besides its text, stored as json alongside outbox rows
+type messageOptions struct {
+	ParseMode          string      `json:"parse_mode,omitempty"`
+	Buttons            [][]Button  `json:"buttons,omitempty"`
+	Attachment         *Attachment `json:"attachment,omitempty"`
+	Silent             bool        `json:"silent,omitempty"`
+	DisableLinkPreview bool        `json:"disable_link_preview,omitempty"`
+	EventID            string      `json:"event_id,omitempty"`
+	Action             EventAction `json:"action,omitempty"`
+}
+
+// EscapeText escapes text so it renders literally in the given parse mode. Plain text is returned unchanged.
+func EscapeText(parseMode string, text string) string {
+	switch parseMode {
+	case tgbotapi.ModeMarkdownV2:
+		return tgbotapi.EscapeText(parseMode, strings.ReplaceAll(text, `\`, `\\`))
+	case tgbotapi.ModeHTML:
+		return tgbotapi.EscapeText(parseMode, text)
+	default:
+		return text
+	}
+}
+
+// ValidateTelegramHTML checks text in the HTML parse mode only uses tags telegram supports, closes them in the order
+// they were opened and escapes every < that doesn't start a tag. Telegram refuses messages that don't.
+func ValidateTelegramHTML(text string) error {
+	open := make([]string, 0)
+	last := 0
+	for _, loc := range telegramHTMLTagRegex.FindAllStringSubmatchIndex(text, -1) {
+		if strings.Contains(text[last:loc[0]], "<") {
+			return fmt.Errorf("unescaped < before %s, use &lt;", text[loc[0]:loc[1]])
+		}
+		last = loc[1]
+
+		name := strings.ToLower(text[loc[4]:loc[5]])
+		if !slices.Contains(telegramHTMLTags, name) {
+			return fmt.Errorf("unsupported tag <%s>", name)
+		}
+
+		if loc[2] == loc[3] {
+			open = append(open, name)
+			continue
+		}
+		if len(open) == 0 || open[len(open)-1] != name {
+			return fmt.Errorf("</%s> doesn't close the last opened tag", name)
+		}
+		open = open[:len(open)-1]
+	}
+
+	if strings.Contains(text[last:], "<") {
+		return fmt.Errorf("unescaped <, use &lt;")
+	}
+	if len(open) > 0 {
+		return fmt.Errorf("<%s> is never closed", open[len(open)-1])
+	}
+
+	return nil
+}
+
+// Validate checks the message can be sent by telegram
+func (m Message) Validate() error {
+	switch m.ParseMode {
+	case "", tgbotapi.ModeMarkdownV2, tgbotapi.ModeHTML:
+	default:
+		return fmt.Errorf("unsupported parse mode %s", m.ParseMode)
+	}
+
+	for _, row := range m.Buttons {
+		for _, button := range row {
+			if button.Text == "" || button.URL == "" {
+				return fmt.Errorf("buttons need both text and a url")
+			}
+		}
+	}
+
+	switch m.Action {
+	case EventActionUpdate:
+	case EventActionCancel:
+		if m.EventID == "" {
+			return fmt.Errorf("cancelling needs an event id")
+		}
+	default:
+		return fmt.Errorf("unsupported event action %s", m.Action)
+	}
+
+	if m.Attachment != nil {
+		switch m.Attachment.Type {
+		case AttachmentPhoto, AttachmentDocument:
+		default:
+			return fmt.Errorf("unsupported attachment type %s", m.Attachment.Type)
+		}
+
+		if m.Attachment.URL == "" {
+			return fmt.Errorf("attachment needs a url")
+		}
+
+		if len([]rune(m.Msg)) > maxCaptionLength {
+			return fmt.Errorf("message is too long for an attachment caption, max %d characters", maxCaptionLength)
+		}
+	}
+
+	return nil
+}
+
+// PlainText returns the message text with its formatting removed. Used when the message is combined with others
+// into a single plain text batch.
+func (m Message) PlainText() string {
+	switch m.ParseMode {
+	case tgbotapi.ModeHTML:
+		return html.UnescapeString(htmlTagRegex.ReplaceAllString(m.Msg, ""))
+	case tgbotapi.ModeMarkdownV2:
+		return stripMarkdownV2(m.Msg)
+	default:
+		return m.Msg
+	}
+}
+
+func stripMarkdownV2(text string) string {
+	outStr := strings.Builder{}
+	escaped := false
+	for _, r := range text {
+		switch {
+		case escaped:
+			outStr.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case strings.ContainsRune("*_~`|", r):
+		default:
+			outStr.WriteRune(r)
+		}
+	}
+
+	return outStr.String()
+}
+
+func (m Message) options() messageOptions {
+	return messageOptions{
+		ParseMode:          m.ParseMode,
+		Buttons:            m.Buttons,
+		Attachment:         m.Attachment,
+		Silent:             m.Silent,
+		DisableLinkPreview: m.DisableLinkPreview,
+		EventID:            m.EventID,
+		Action:             m.Action,
+	}
+}
+
+func (o messageOptions) encode() (string, error) {
+	encoded, err := json.Marshal(o)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode message options: %w", err)
+	}
+
+	return string(encoded), nil
+}
+
+func decodeMessageOptions(raw string) (messageOptions, error) {
+	opts := messageOptions{}
+	if raw == "" {
+		return opts, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+		return opts, fmt.Errorf("failed to decode message options: %w", err)
+	}
+
+	return opts, nil
+}
+
+// keyboard builds the inline keyboard for the buttons, nil if there are none
+func (o messageOptions) keyboard() *tgbotapi.InlineKeyboardMarkup {
+	if len(o.Buttons) == 0 {
+		return nil
+	}
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(o.Buttons))
+	for _, row := range o.Buttons {
+		buttons := make([]tgbotapi.InlineKeyboardButton, 0, len(row))
+		for _, button := range row {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonURL(button.Text, button.URL))
+		}
+		rows = append(rows, buttons)
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &markup
+}
+
+// chattable builds the telegram request for the text and options. A non-zero replyTo threads it under that message.
+func (o messageOptions) chattable(chatId int64, text string, replyTo int) tgbotapi.Chattable {
+	var markup interface{}
+	if keyboard := o.keyboard(); keyboard != nil {
+		markup = *keyboard
+	}
+
+	baseChat := tgbotapi.BaseChat{
+		ChatID:                   chatId,
+		ReplyToMessageID:         replyTo,
+		AllowSendingWithoutReply: replyTo != 0,
+		DisableNotification:      o.Silent,
+		ReplyMarkup:              markup,
+	}
+
+	if o.Attachment != nil {
+		file := tgbotapi.FileURL(o.Attachment.URL)
+		if o.Attachment.Type == AttachmentDocument {
+			document := tgbotapi.NewDocument(chatId, file)
+			document.BaseChat = baseChat
+			document.Caption = text
+			document.ParseMode = o.ParseMode
+			return document
+		}
+
+		photo := tgbotapi.NewPhoto(chatId, file)
+		photo.BaseChat = baseChat
+		photo.Caption = text
+		photo.ParseMode = o.ParseMode
+		return photo
+	}
+
+	msg := tgbotapi.NewMessage(chatId, text)
+	msg.BaseChat = baseChat
+	msg.ParseMode = o.ParseMode
+	msg.DisableWebPagePreview = o.DisableLinkPreview
+	return msg
+}
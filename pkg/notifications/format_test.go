@@ -0,0 +1,90 @@
+package notifications
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"strings"
+	"testing"
+)
+
+func TestEscapeText(t *testing.T) {
+	require.Equal(t, "a < b", EscapeText("", "a < b"))
+	require.Equal(t, "a &lt; b &amp;&amp; c", EscapeText(tgbotapi.ModeHTML, "a < b && c"))
+	require.Equal(t, `1\.5 \\ 2\!`, EscapeText(tgbotapi.ModeMarkdownV2, `1.5 \ 2!`))
+}
+
+func TestMessage_Validate(t *testing.T) {
+	require.NoError(t, Message{Msg: "hi", ParseMode: tgbotapi.ModeHTML}.Validate())
+	require.Error(t, Message{Msg: "hi", ParseMode: "BBCode"}.Validate())
+	require.Error(t, Message{Msg: "hi", Buttons: [][]Button{{{Text: "no url"}}}}.Validate())
+	require.Error(t, Message{Msg: "hi", Attachment: &Attachment{Type: "video", URL: "https://example.com/a.mp4"}}.Validate())
+	require.Error(t, Message{
+		Msg:        strings.Repeat("a", maxCaptionLength+1),
+		Attachment: &Attachment{Type: AttachmentPhoto, URL: "https://example.com/a.png"},
+	}.Validate())
+}
+
+func TestValidateTelegramHTML(t *testing.T) {
+	require.NoError(t, ValidateTelegramHTML(`<b>Alert:</b> <a href="https://example.com">a &lt; b</a> <i><u>x</u></i>`))
+	require.ErrorContains(t, ValidateTelegramHTML("<b>Alert:"), "never closed")
+	require.ErrorContains(t, ValidateTelegramHTML("<b><i>Alert:</b></i>"), "doesn't close")
+	require.ErrorContains(t, ValidateTelegramHTML("Alert:</b>"), "doesn't close")
+	require.ErrorContains(t, ValidateTelegramHTML("<h1>Alert</h1>"), "unsupported tag")
+	require.ErrorContains(t, ValidateTelegramHTML("a < b <b>c</b>"), "unescaped")
+	require.ErrorContains(t, ValidateTelegramHTML("<b>c</b> a < b"), "unescaped")
+}
+
+func TestMessage_PlainText(t *testing.T) {
+	require.Equal(t, "Alert: a < b", Message{Msg: "<b>Alert:</b> a &lt; b", ParseMode: tgbotapi.ModeHTML}.PlainText())
+	require.Equal(t, "Alert: 1.5 *", Message{Msg: `*Alert:* 1\.5 \*`, ParseMode: tgbotapi.ModeMarkdownV2}.PlainText())
+	require.Equal(t, "*plain*", Message{Msg: "*plain*"}.PlainText())
+}
+
+func TestMessageOptions_chattable(t *testing.T) {
+	opts := Message{
+		ParseMode:          tgbotapi.ModeHTML,
+		Buttons:            [][]Button{{{Text: "Details", URL: "https://example.com"}}},
+		Silent:             true,
+		DisableLinkPreview: true,
+	}.options()
+
+	encoded, err := opts.encode()
+	require.NoError(t, err)
+	decoded, err := decodeMessageOptions(encoded)
+	require.NoError(t, err)
+
+	msg, ok := decoded.chattable(12345, "<b>hi</b>", 0).(tgbotapi.MessageConfig)
+	require.True(t, ok)
+	require.Equal(t, tgbotapi.ModeHTML, msg.ParseMode)
+	require.True(t, msg.DisableNotification)
+	require.True(t, msg.DisableWebPagePreview)
+	markup, ok := msg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	require.True(t, ok)
+	require.Equal(t, "https://example.com", *markup.InlineKeyboard[0][0].URL)
+
+	decoded.Attachment = &Attachment{Type: AttachmentDocument, URL: "https://example.com/report.pdf"}
+	document, ok := decoded.chattable(12345, "report", 0).(tgbotapi.DocumentConfig)
+	require.True(t, ok)
+	require.Equal(t, "report", document.Caption)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Outbox_Options() {
+	mockBot := proxy.NewMockTGBotSendable(t.T())
+	mockBot.EXPECT().Send(mock.MatchedBy(func(c tgbotapi.PhotoConfig) bool {
+		return c.ChatID == 12345 && c.Caption == "<i>look</i>" && c.ParseMode == tgbotapi.ModeHTML && c.DisableNotification
+	})).Return(tgbotapi.Message{}, nil).Once()
+
+	publisher := NewNotificationPublisher(mockBot, t.dbConn)
+	require.NoError(t.T(), publisher.enqueueDelivery(context.Background(), telegramTarget(12345), "key", Message{
+		Msg:        "<i>look</i>",
+		ParseMode:  tgbotapi.ModeHTML,
+		Attachment: &Attachment{Type: AttachmentPhoto, URL: "https://example.com/radar.png"},
+		Silent:     true,
+	}))
+
+	publisher.dispatchOutbox(context.Background())
+	require.Equal(t.T(), OutboxStatusDelivered, t.getOutbox()[0].Status)
+}
@@ -0,0 +1,152 @@
+package notifications
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"strings"
+	"time"
+)
+
+// History statuses. A queued record follows its outbox row through retrying to delivered or failed, the others are
+// the reasons a subscription didn't get a message right away.
+const (
+	HistoryStatusQueued        = "queued"
+	HistoryStatusRetrying      = "retrying"
+	HistoryStatusDelivered     = "delivered"
+	HistoryStatusFailed        = "failed"
+	HistoryStatusDuplicate     = "duplicate"
+	HistoryStatusDigest        = "digest"
+	HistoryStatusDeferred      = "deferred"
+	HistoryStatusMuted         = "muted"
+	HistoryStatusExpired       = "expired"
+	HistoryStatusBelowPriority = "below_priority"
+	HistoryStatusDenied        = "denied"
+	HistoryStatusFiltered      = "filtered"
+)
+
+const (
+	defaultHistoryRetention = 30 * 24 * time.Hour
+	historyPreviewLength    = 100
+	maxHistoryLimit         = 100
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// HistoryFilter narrows down the delivery history returned by GetHistory
+type HistoryFilter struct {
+	// ChatId limits the history to a single chat unless AllChats is set
+	ChatId   int64
+	AllChats bool
+	// TopicPattern is matched like a subscription pattern, * matches anything
+	TopicPattern string
+	Limit        int
+}
+
+// messageHash identifies the message text in the history without storing all of it
+func messageHash(msg Message) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(msg.Msg))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// recordHistory notes what happened to the message for the target. Failing to record is logged, never fatal to
+// the delivery itself.
+func (n *NotificationPublisher) recordHistory(ctx context.Context, target Target, msg Message, status string, detail string, outboxId int) {
+	now := time.Now()
+	dbHistory := &dbmodels.NotificationsHistory{
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ChatID:      target.ChatId,
+		Sink:        target.Sink,
+		Destination: target.Destination,
+		Topic:       msg.Topic,
+		MessageHash: messageHash(msg),
+		Preview:     util.TruncateString(msg.PlainText(), historyPreviewLength, "..."),
+		Priority:    int(msg.Priority),
+		Status:      status,
+		Detail:      detail,
+		OutboxID:    outboxId,
+	}
+
+	if _, err := n.dbConn.NewInsert().Model(dbHistory).Exec(ctx); err != nil {
+		n.logger.Error().Err(err).Msgf("failed to record %s history for topic %s", status, msg.Topic)
+	}
+}
+
+// recordDuplicate counts a duplicate on the target's existing duplicate row for the message, so a message published
+// over and over doesn't add a row per publish
+func (n *NotificationPublisher) recordDuplicate(ctx context.Context, target Target, msg Message) {
+	res, err := n.dbConn.NewUpdate().Model((*dbmodels.NotificationsHistory)(nil)).
+		Set("repeats = repeats + 1").
+		Set("updated_at = ?", time.Now()).
+		Where("chat_id = ?", target.ChatId).
+		Where("sink = ?", target.Sink).
+		Where("destination = ?", target.Destination).
+		Where("message_hash = ?", messageHash(msg)).
+		Where("status = ?", HistoryStatusDuplicate).
+		Exec(ctx)
+	if err != nil {
+		n.logger.Error().Err(err).Msgf("failed to count duplicate history for topic %s", msg.Topic)
+		return
+	}
+
+	if rows, err := res.RowsAffected(); err == nil && rows > 0 {
+		return
+	}
+	n.recordHistory(ctx, target, msg, HistoryStatusDuplicate, "", 0)
+}
+
+// updateHistory moves the history of an outbox row on to its next status
+func (n *NotificationPublisher) updateHistory(ctx context.Context, outboxId int, status string, detail string, telegramMsgId int) {
+	query := n.dbConn.NewUpdate().Model((*dbmodels.NotificationsHistory)(nil)).
+		Set("status = ?", status).
+		Set("detail = ?", detail).
+		Set("updated_at = ?", time.Now()).
+		Where("outbox_id = ?", outboxId)
+	if telegramMsgId != 0 {
+		query = query.Set("telegram_message_id = ?", telegramMsgId)
+	}
+
+	if _, err := query.Exec(ctx); err != nil {
+		n.logger.Error().Err(err).Msgf("failed to update history of delivery %d", outboxId)
+	}
+}
+
+// GetHistory returns the most recent history records matching the filter
+func (n *NotificationPublisher) GetHistory(filter HistoryFilter) ([]dbmodels.NotificationsHistory, error) {
+	limit := filter.Limit
+	if limit <= 0 || limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	history := make([]dbmodels.NotificationsHistory, 0)
+	query := n.dbConn.NewSelect().Model(&history).
+		Order("created_at DESC", "id DESC").
+		Limit(limit)
+	if !filter.AllChats {
+		query = query.Where("chat_id = ?", filter.ChatId)
+	}
+	if filter.TopicPattern != "" {
+		like := "%" + strings.ReplaceAll(likeEscaper.Replace(filter.TopicPattern), "*", "%") + "%"
+		query = query.Where(`topic LIKE ? ESCAPE '\'`, like)
+	}
+
+	if err := query.Scan(context.TODO()); err != nil {
+		return nil, fmt.Errorf("failed to get history: %w", err)
+	}
+
+	return history, nil
+}
+
+func (n *NotificationPublisher) cleanupHistory(ctx context.Context) {
+	_, err := n.dbConn.NewDelete().Model((*dbmodels.NotificationsHistory)(nil)).
+		Where("created_at < ?", time.Now().Add(-n.historyRetention)).
+		Exec(ctx)
+	if err != nil {
+		n.logger.Error().Err(err).Msg("failed to clean up history")
+	}
+}
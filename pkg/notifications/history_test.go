@@ -0,0 +1,116 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"time"
+)
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_History() {
+	mockBot := proxy.NewMockTGBotSendable(t.T())
+	mockBot.EXPECT().Send(mock.Anything).Return(tgbotapi.Message{MessageID: 77}, nil).Once()
+
+	publisher := NewNotificationPublisher(mockBot, t.dbConn)
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "test.alert", ChatId: 1})
+	require.NoError(t.T(), err)
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "test.alert", ChatId: 2, MutedUntil: time.Now().Add(time.Hour)})
+	require.NoError(t.T(), err)
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "test.alert", ChatId: 3, MinPriority: PriorityCritical})
+	require.NoError(t.T(), err)
+
+	msg := Message{Topic: "test.alert", Msg: "tornado warning", Priority: PriorityWarning}
+	require.NoError(t.T(), publisher.handleBusMessage(context.Background(), msg))
+	publisher.dispatchOutbox(context.Background())
+	require.NoError(t.T(), publisher.handleBusMessage(context.Background(), msg))
+
+	statuses := func(history []dbmodels.NotificationsHistory) map[int64][]string {
+		byChat := make(map[int64][]string)
+		for _, record := range history {
+			byChat[record.ChatID] = append(byChat[record.ChatID], record.Status)
+		}
+		return byChat
+	}
+
+	history, err := publisher.GetHistory(HistoryFilter{AllChats: true})
+	require.NoError(t.T(), err)
+	require.Equal(t.T(), map[int64][]string{
+		1: {HistoryStatusDuplicate, HistoryStatusDelivered},
+		2: {HistoryStatusMuted, HistoryStatusMuted},
+		3: {HistoryStatusBelowPriority, HistoryStatusBelowPriority},
+	}, statuses(history))
+
+	history, err = publisher.GetHistory(HistoryFilter{ChatId: 1, TopicPattern: "test.*", Limit: 1})
+	require.NoError(t.T(), err)
+	require.Len(t.T(), history, 1)
+	require.Equal(t.T(), HistoryStatusDuplicate, history[0].Status)
+
+	history, err = publisher.GetHistory(HistoryFilter{ChatId: 1, TopicPattern: "alert"})
+	require.NoError(t.T(), err)
+	require.Len(t.T(), history, 2)
+	delivered := history[1]
+	require.Equal(t.T(), 77, delivered.TelegramMessageID)
+	require.Equal(t.T(), "tornado warning", delivered.Preview)
+	require.Equal(t.T(), messageHash(msg), delivered.MessageHash)
+	require.NotZero(t.T(), delivered.OutboxID)
+
+	// _ is literal, not a LIKE wildcard
+	history, err = publisher.GetHistory(HistoryFilter{ChatId: 1, TopicPattern: "tes_"})
+	require.NoError(t.T(), err)
+	require.Empty(t.T(), history)
+
+	// publishing the same message again counts on the duplicate row rather than adding one
+	require.NoError(t.T(), publisher.handleBusMessage(context.Background(), msg))
+	history, err = publisher.GetHistory(HistoryFilter{ChatId: 1})
+	require.NoError(t.T(), err)
+	require.Len(t.T(), history, 2)
+	require.Equal(t.T(), HistoryStatusDuplicate, history[0].Status)
+	require.Equal(t.T(), 1, history[0].Repeats)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_History_Retry() {
+	sink := newMockWebhookSink(t)
+	sink.EXPECT().Send(mock.Anything, mock.Anything).Return(errors.New("connection refused")).Once()
+
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithRetryPolicy(1, time.Minute, time.Hour), WithSink(sink))
+	target := Target{ChatId: 1, Sink: "webhook", Destination: "https://example.com/hook"}
+	require.NoError(t.T(), publisher.enqueueDelivery(context.Background(), target, "key", Message{Topic: "test.alert", Msg: "hello"}))
+
+	publisher.dispatchOutbox(context.Background())
+
+	history, err := publisher.GetHistory(HistoryFilter{ChatId: 1})
+	require.NoError(t.T(), err)
+	require.Len(t.T(), history, 1)
+	require.Equal(t.T(), HistoryStatusFailed, history[0].Status)
+	require.Equal(t.T(), "webhook", history[0].Sink)
+	require.Equal(t.T(), "connection refused", history[0].Detail)
+
+	require.NoError(t.T(), publisher.RetryDelivery(history[0].OutboxID))
+
+	history, err = publisher.GetHistory(HistoryFilter{ChatId: 1})
+	require.NoError(t.T(), err)
+	require.Equal(t.T(), HistoryStatusQueued, history[0].Status)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_cleanupHistory() {
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithHistoryRetention(time.Hour))
+	publisher.recordHistory(context.Background(), telegramTarget(1), Message{Topic: "test.old"}, HistoryStatusMuted, "", 0)
+	publisher.recordHistory(context.Background(), telegramTarget(1), Message{Topic: "test.new"}, HistoryStatusMuted, "", 0)
+
+	_, err := t.dbConn.NewUpdate().Model((*dbmodels.NotificationsHistory)(nil)).
+		Set("created_at = ?", time.Now().Add(-2*time.Hour)).
+		Where("topic = ?", "test.old").
+		Exec(context.Background())
+	require.NoError(t.T(), err)
+
+	publisher.cleanupHistory(context.Background())
+
+	history, err := publisher.GetHistory(HistoryFilter{AllChats: true})
+	require.NoError(t.T(), err)
+	require.Len(t.T(), history, 1)
+	require.Equal(t.T(), "test.new", history[0].Topic)
+}
@@ -0,0 +1,227 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"time"
+)
+
+const (
+	inboxRetryBatchSize = 50
+	inboxRetention      = 7 * 24 * time.Hour
+)
+
+// busMessage is a published message on its way through the bus, with the inbox row that keeps it until it's handled
+type busMessage struct {
+	Message
+	inboxID int
+	// attempts is how many times handling the message failed before
+	attempts int
+}
+
+// StoredData is the Data of a message read back from the inbox, still encoded as json. Use MessageData to read it.
+type StoredData json.RawMessage
+
+// MessageData returns the message's Data as a T, decoding it if the message was read back from the inbox
+func MessageData[T any](msg Message) (T, bool) {
+	var data T
+	switch stored := msg.Data.(type) {
+	case T:
+		return stored, true
+	case StoredData:
+		if err := json.Unmarshal(stored, &data); err != nil {
+			return data, false
+		}
+		return data, true
+	default:
+		return data, false
+	}
+}
+
+// saveInbound writes the message to the inbox, so it's handled after a restart if the bot stops before the bus gets
+// to it
+func (n *NotificationPublisher) saveInbound(ctx context.Context, msg Message) (int, error) {
+	opts, err := msg.options().encode()
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := encodeMessageData(msg.Data)
+	if err != nil {
+		return 0, err
+	}
+
+	row := &dbmodels.NotificationsInbox{
+		CreatedAt: time.Now(),
+		Topic:     msg.Topic,
+		Message:   msg.Msg,
+		DupeKey:   msg.DupeKey,
+		DupeTTL:   int64(msg.DupeTTL),
+		Priority:  int(msg.Priority),
+		Options:   opts,
+		Data:      data,
+	}
+	if _, err := n.dbConn.NewInsert().Model(row).Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to insert inbox row: %w", err)
+	}
+
+	return row.ID, nil
+}
+
+// removeInbound deletes the message's inbox row once it's been handled or dropped
+func (n *NotificationPublisher) removeInbound(ctx context.Context, inboxID int) {
+	_, err := n.dbConn.NewDelete().Model((*dbmodels.NotificationsInbox)(nil)).Where("id = ?", inboxID).Exec(ctx)
+	if err != nil {
+		n.logger.Error().Err(err).Msgf("failed to delete inbox row %d", inboxID)
+	}
+}
+
+// handleInbound fans the message out to its subscribers and forgets it. If any of them failed the row is kept and
+// tried again with backoff, the dupe cache keeps the rest from getting it twice. After the max attempts it's dropped.
+func (n *NotificationPublisher) handleInbound(ctx context.Context, item busMessage) error {
+	err := n.handleBusMessage(ctx, item.Message)
+	if err == nil {
+		n.removeInbound(ctx, item.inboxID)
+		return nil
+	}
+
+	attempt := item.attempts + 1
+	if attempt >= n.maxDeliveryAttempts {
+		n.logger.Error().Err(err).Msgf("giving up on inbox row %d after %d attempts", item.inboxID, attempt)
+		n.removeInbound(ctx, item.inboxID)
+		return err
+	}
+
+	_, dbErr := n.dbConn.NewUpdate().Model((*dbmodels.NotificationsInbox)(nil)).
+		Set("attempts = ?", attempt).
+		Set("next_attempt_at = ?", time.Now().Add(n.retryDelay(attempt))).
+		Where("id = ?", item.inboxID).
+		Exec(ctx)
+	if dbErr != nil {
+		n.logger.Error().Err(dbErr).Msgf("failed to schedule inbox row %d for a retry", item.inboxID)
+	}
+
+	return err
+}
+
+// lastInboxID returns the newest inbox row, the rows up to it were left by an earlier run
+func (n *NotificationPublisher) lastInboxID(ctx context.Context) (int, error) {
+	var lastID int
+	err := n.dbConn.NewSelect().Model((*dbmodels.NotificationsInbox)(nil)).
+		ColumnExpr("COALESCE(MAX(id), 0)").
+		Scan(ctx, &lastID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last inbox row: %w", err)
+	}
+
+	return lastID, nil
+}
+
+// replayInbox handles the messages an earlier run accepted but stopped before handling
+func (n *NotificationPublisher) replayInbox(ctx context.Context) error {
+	var rows []dbmodels.NotificationsInbox
+	err := n.dbConn.NewSelect().Model(&rows).
+		Where("id <= ?", n.replayInboxUpTo).
+		Order("id ASC").
+		Scan(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get inbox rows: %w", err)
+	}
+
+	if len(rows) > 0 {
+		n.logger.Info().Msgf("Handling %d message(s) published before the last stop", len(rows))
+	}
+	n.handleInboxRows(ctx, rows)
+
+	return nil
+}
+
+// retryInbox handles again the messages whose handling failed once their backoff is up
+func (n *NotificationPublisher) retryInbox(ctx context.Context) {
+	var rows []dbmodels.NotificationsInbox
+	err := n.dbConn.NewSelect().Model(&rows).
+		Where("attempts > 0").
+		Where("next_attempt_at <= ?", time.Now()).
+		Order("id ASC").
+		Limit(inboxRetryBatchSize).
+		Scan(ctx)
+	if err != nil {
+		n.logger.Error().Err(err).Msg("failed to get inbox rows to retry")
+		return
+	}
+
+	n.handleInboxRows(ctx, rows)
+}
+
+func (n *NotificationPublisher) handleInboxRows(ctx context.Context, rows []dbmodels.NotificationsInbox) {
+	for _, row := range rows {
+		item, err := inboundFromRow(row)
+		if err != nil {
+			n.logger.Error().Err(err).Msgf("dropping inbox row %d", row.ID)
+			n.removeInbound(ctx, row.ID)
+			continue
+		}
+
+		if err := n.handleInbound(ctx, item); err != nil {
+			n.logger.Error().Err(err).Msgf("failed to handle inbox row %d", row.ID)
+		}
+	}
+}
+
+// cleanupInbox drops messages stuck in the inbox for longer than the retention, e.g. left by a bug
+func (n *NotificationPublisher) cleanupInbox(ctx context.Context) {
+	res, err := n.dbConn.NewDelete().Model((*dbmodels.NotificationsInbox)(nil)).
+		Where("created_at < ?", time.Now().Add(-inboxRetention)).
+		Exec(ctx)
+	if err != nil {
+		n.logger.Error().Err(err).Msg("failed to clean up inbox")
+		return
+	}
+
+	if rows, err := res.RowsAffected(); err == nil && rows > 0 {
+		n.logger.Warn().Msgf("Dropped %d message(s) that couldn't be handled within %s", rows, inboxRetention)
+	}
+}
+
+// inboundFromRow rebuilds the published message from its inbox row
+func inboundFromRow(row dbmodels.NotificationsInbox) (busMessage, error) {
+	opts, err := decodeMessageOptions(row.Options)
+	if err != nil {
+		return busMessage{}, err
+	}
+
+	msg := Message{
+		Topic:              row.Topic,
+		Msg:                row.Message,
+		DupeKey:            row.DupeKey,
+		DupeTTL:            time.Duration(row.DupeTTL),
+		Priority:           Priority(row.Priority),
+		ParseMode:          opts.ParseMode,
+		Buttons:            opts.Buttons,
+		Attachment:         opts.Attachment,
+		Silent:             opts.Silent,
+		DisableLinkPreview: opts.DisableLinkPreview,
+		EventID:            opts.EventID,
+		Action:             opts.Action,
+	}
+	if row.Data != "" {
+		msg.Data = StoredData(row.Data)
+	}
+
+	return busMessage{Message: msg, inboxID: row.ID, attempts: row.Attempts}, nil
+}
+
+func encodeMessageData(data any) (string, error) {
+	if data == nil {
+		return "", nil
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode message data: %w", err)
+	}
+
+	return string(encoded), nil
+}
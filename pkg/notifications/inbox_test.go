@@ -0,0 +1,103 @@
+package notifications
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"time"
+)
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Inbox_ReplayAfterRestart() {
+	ctx := context.Background()
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithBusSize(1), WithOverflowPolicy(OverflowDropNewest, 0))
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "test.*", ChatId: 12345})
+	require.NoError(t.T(), err)
+
+	// the bot stops before the bus gets to the first message, the second is rejected and never accepted
+	require.NoError(t.T(), publisher.Publish(ctx, Message{Topic: "test.first", Msg: "first"}))
+	require.ErrorIs(t.T(), publisher.Publish(ctx, Message{Topic: "test.second", Msg: "second"}), ErrBusFull)
+
+	count, err := t.dbConn.NewSelect().Model((*db.NotificationsInbox)(nil)).Count(ctx)
+	require.NoError(t.T(), err)
+	require.Equal(t.T(), 1, count)
+
+	restarted := NewNotificationPublisher(nil, t.dbConn)
+	require.NoError(t.T(), restarted.replayInbox(ctx))
+
+	var rows []db.NotificationsOutbox
+	require.NoError(t.T(), t.dbConn.NewSelect().Model(&rows).Scan(ctx))
+	require.Len(t.T(), rows, 1)
+	require.Equal(t.T(), "first", rows[0].Message)
+
+	count, err = t.dbConn.NewSelect().Model((*db.NotificationsInbox)(nil)).Count(ctx)
+	require.NoError(t.T(), err)
+	require.Zero(t.T(), count)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Inbox_ReplayKeepsData() {
+	ctx := context.Background()
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithBusSize(1))
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "test.*", ChatId: 12345})
+	require.NoError(t.T(), err)
+
+	type alertData struct {
+		Event string
+	}
+	require.NoError(t.T(), publisher.Publish(ctx, Message{Topic: "test.first", Msg: "first", Data: alertData{Event: "Heat"}}))
+
+	restarted := NewNotificationPublisher(nil, t.dbConn)
+	var seen alertData
+	require.NoError(t.T(), restarted.RegisterDeliveryFilter("test.", func(ctx context.Context, chatId int64, msg Message) (Message, bool) {
+		seen, _ = MessageData[alertData](msg)
+		return msg, true
+	}))
+	require.NoError(t.T(), restarted.replayInbox(ctx))
+	require.Equal(t.T(), alertData{Event: "Heat"}, seen)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Inbox_RetriesFailedRows() {
+	ctx := context.Background()
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithBusSize(1), WithRetryPolicy(defaultMaxDeliveryAttempt, time.Millisecond, time.Millisecond))
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "test.*", ChatId: 12345})
+	require.NoError(t.T(), err)
+
+	require.NoError(t.T(), publisher.Publish(ctx, Message{Topic: "test.first", Msg: "first"}))
+	item := <-publisher.bus
+
+	// the outbox is gone, so the message can't be fanned out and stays in the inbox for a retry
+	_, err = t.dbConn.NewDropTable().Model((*db.NotificationsOutbox)(nil)).Exec(ctx)
+	require.NoError(t.T(), err)
+	require.Error(t.T(), publisher.handleInbound(ctx, item))
+
+	var row db.NotificationsInbox
+	require.NoError(t.T(), t.dbConn.NewSelect().Model(&row).Where("id = ?", item.inboxID).Scan(ctx))
+	require.Equal(t.T(), 1, row.Attempts)
+
+	_, err = t.dbConn.NewCreateTable().Model((*db.NotificationsOutbox)(nil)).Exec(ctx)
+	require.NoError(t.T(), err)
+	time.Sleep(5 * time.Millisecond)
+	publisher.retryInbox(ctx)
+
+	var rows []db.NotificationsOutbox
+	require.NoError(t.T(), t.dbConn.NewSelect().Model(&rows).Scan(ctx))
+	require.Len(t.T(), rows, 1)
+	count, err := t.dbConn.NewSelect().Model((*db.NotificationsInbox)(nil)).Count(ctx)
+	require.NoError(t.T(), err)
+	require.Zero(t.T(), count)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Inbox_Cleanup() {
+	ctx := context.Background()
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	for _, createdAt := range []time.Time{time.Now().Add(-inboxRetention - time.Hour), time.Now()} {
+		_, err := t.dbConn.NewInsert().Model(&db.NotificationsInbox{CreatedAt: createdAt, Topic: "test.first", Message: "first"}).Exec(ctx)
+		require.NoError(t.T(), err)
+	}
+
+	publisher.cleanupDb(ctx)
+
+	count, err := t.dbConn.NewSelect().Model((*db.NotificationsInbox)(nil)).Count(ctx)
+	require.NoError(t.T(), err)
+	require.Equal(t.T(), 1, count)
+}
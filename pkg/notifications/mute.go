@@ -0,0 +1,46 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// MuteSubscription stops delivering the subscription's messages until the given time
+func (n *NotificationPublisher) MuteSubscription(subId uuid.UUID, chatId int64, until time.Time) error {
+	return n.setMutedUntil(subId, chatId, until)
+}
+
+func (n *NotificationPublisher) UnmuteSubscription(subId uuid.UUID, chatId int64) error {
+	return n.setMutedUntil(subId, chatId, time.Time{})
+}
+
+func (n *NotificationPublisher) setMutedUntil(subId uuid.UUID, chatId int64, until time.Time) error {
+	return n.subs.SetMutedUntil(context.TODO(), subId, chatId, until)
+}
+
+// sweepExpiredSubscriptions removes subscriptions past their expiry and lets the chat know
+func (n *NotificationPublisher) sweepExpiredSubscriptions(ctx context.Context) {
+	for _, subscriber := range n.subs.Expired(time.Now()) {
+		n.logger.Debug().Msgf("Subscription %s to %s for chat %d expired", subscriber.ID, subscriber.TopicPattern, subscriber.ChatId)
+		removed, err := n.subs.Remove(ctx, subscriber.ID, subscriber.ChatId)
+		if err != nil {
+			n.logger.Error().Err(err).Msgf("failed to remove expired subscription %s", subscriber.ID)
+			continue
+		} else if !removed {
+			// already unsubscribed, nothing to tell the chat
+			continue
+		}
+
+		notice := Message{
+			Topic:    subscriber.TopicPattern,
+			Msg:      fmt.Sprintf("⌛ Subscription to %s (%s) has expired", subscriber.TopicPattern, subscriber.ID),
+			Priority: PriorityInfo,
+		}
+		dupKey := fmt.Sprintf("%d-expired-%s", subscriber.ChatId, subscriber.ID)
+		if err := n.enqueueDelivery(ctx, telegramTarget(subscriber.ChatId), dupKey, notice); err != nil {
+			n.logger.Error().Err(err).Msgf("failed to notify chat %d of expired subscription", subscriber.ChatId)
+		}
+	}
+}
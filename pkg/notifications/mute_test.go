@@ -0,0 +1,60 @@
+package notifications
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"time"
+)
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_MuteSubscription() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	subId, err := publisher.Subscribe(Subscriber{TopicPattern: "test.alert", ChatId: 12345})
+	require.NoError(t.T(), err)
+	subUUID := uuid.MustParse(subId)
+
+	chatIds := matchedChatIds(publisher, "test.alert", PriorityInfo)
+	require.Len(t.T(), chatIds, 1)
+
+	require.ErrorIs(t.T(), publisher.MuteSubscription(subUUID, 54321, time.Now().Add(time.Hour)), ErrSubNotFound)
+	require.NoError(t.T(), publisher.MuteSubscription(subUUID, 12345, time.Now().Add(time.Hour)))
+
+	chatIds = matchedChatIds(publisher, "test.alert", PriorityInfo)
+	require.Empty(t.T(), chatIds)
+
+	// the mute survives a reload
+	reloaded := NewNotificationPublisher(nil, t.dbConn)
+	chatIds = matchedChatIds(reloaded, "test.alert", PriorityInfo)
+	require.Empty(t.T(), chatIds)
+
+	require.NoError(t.T(), publisher.UnmuteSubscription(subUUID, 12345))
+	chatIds = matchedChatIds(publisher, "test.alert", PriorityInfo)
+	require.Len(t.T(), chatIds, 1)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_sweepExpiredSubscriptions() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "test.expired", ChatId: 12345, ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t.T(), err)
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "test.active", ChatId: 12345, ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t.T(), err)
+
+	chatIds := matchedChatIds(publisher, "test.expired", PriorityInfo)
+	require.Empty(t.T(), chatIds)
+
+	publisher.sweepExpiredSubscriptions(context.Background())
+
+	subs, err := publisher.GetSubscriptions(12345)
+	require.NoError(t.T(), err)
+	require.Len(t.T(), subs, 1)
+	require.Equal(t.T(), "test.active", subs[0].TopicPattern)
+	require.Len(t.T(), publisher.subs.All(), 1)
+
+	outbox := make([]dbmodels.NotificationsOutbox, 0)
+	require.NoError(t.T(), t.dbConn.NewSelect().Model(&outbox).Scan(context.Background()))
+	require.Len(t.T(), outbox, 1)
+	require.Contains(t.T(), outbox[0].Message, "test.expired")
+}
@@ -14,17 +14,17 @@ func WithLogger(logger zerolog.Logger) PublisherOptions {
 	}
 }
 
-// WithBusSize sets the size of the bus channel, defaults to unbuffered
+// WithBusSize sets the size of the bus channel, defaults to 100
 func WithBusSize(size int) PublisherOptions {
 	return func(p *NotificationPublisher) {
-		p.bus = make(chan Message, size)
+		p.bus = make(chan busMessage, size)
 	}
 }
 
 func WithSubCacheTTL(ttl time.Duration) PublisherOptions {
 	return func(p *NotificationPublisher) {
-		p.subCache = ttlcache.New[string, []int64](
-			ttlcache.WithTTL[string, []int64](ttl))
+		p.subCache = ttlcache.New[string, []Subscriber](
+			ttlcache.WithTTL[string, []Subscriber](ttl))
 	}
 }
 
@@ -34,3 +34,72 @@ func WithDupeCacheTTL(ttl time.Duration) PublisherOptions {
 			ttlcache.WithTTL[string, struct{}](ttl))
 	}
 }
+
+// WithDispatchInterval sets how often the outbox is polled for due deliveries
+func WithDispatchInterval(interval time.Duration) PublisherOptions {
+	return func(p *NotificationPublisher) {
+		p.dispatchInterval = interval
+	}
+}
+
+// WithRetryPolicy sets the maximum delivery attempts and the exponential backoff bounds between them
+func WithRetryPolicy(maxAttempts int, baseDelay, maxDelay time.Duration) PublisherOptions {
+	return func(p *NotificationPublisher) {
+		p.maxDeliveryAttempts = maxAttempts
+		p.retryBaseDelay = baseDelay
+		p.retryMaxDelay = maxDelay
+	}
+}
+
+// WithOverflowPolicy sets what Publish does when the bus is full. The block timeout only applies to OverflowBlock.
+// OverflowDropOldest needs a buffered bus, with WithBusSize(0) OverflowBlock is used instead.
+func WithOverflowPolicy(policy OverflowPolicy, blockTimeout time.Duration) PublisherOptions {
+	return func(p *NotificationPublisher) {
+		p.overflowPolicy = policy
+		p.busBlockTimeout = blockTimeout
+	}
+}
+
+// WithEventUpdateStyle sets whether updates to an event edit the sent message or reply to it, defaults to editing
+func WithEventUpdateStyle(style EventUpdateStyle) PublisherOptions {
+	return func(p *NotificationPublisher) {
+		p.eventUpdateStyle = style
+	}
+}
+
+// WithMaxParallelSends limits how many chats the dispatcher sends to at once
+func WithMaxParallelSends(limit int) PublisherOptions {
+	return func(p *NotificationPublisher) {
+		p.maxParallelSends = limit
+	}
+}
+
+// WithBotAdminCheck sets how the publisher tells whether a user is a bot admin, used by bot admin only topics.
+// Nobody is a bot admin without it.
+func WithBotAdminCheck(isBotAdmin func(userId int64) bool) PublisherOptions {
+	return func(p *NotificationPublisher) {
+		p.botAdminCheck = isBotAdmin
+	}
+}
+
+// WithSink adds a sink subscriptions can deliver to besides telegram
+func WithSink(sink Sink) PublisherOptions {
+	return func(p *NotificationPublisher) {
+		p.sinks[sink.Name()] = sink
+	}
+}
+
+// WithSinkRetryPolicy overrides the retry policy for deliveries through the named sink. Zero values keep the
+// publisher's retry policy.
+func WithSinkRetryPolicy(sink string, maxAttempts int, baseDelay, maxDelay time.Duration) PublisherOptions {
+	return func(p *NotificationPublisher) {
+		p.sinkRetry[sink] = retryPolicy{maxAttempts: maxAttempts, baseDelay: baseDelay, maxDelay: maxDelay}
+	}
+}
+
+// WithHistoryRetention sets how long delivery history is kept, defaults to 30 days
+func WithHistoryRetention(retention time.Duration) PublisherOptions {
+	return func(p *NotificationPublisher) {
+		p.historyRetention = retention
+	}
+}
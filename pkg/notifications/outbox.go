@@ -0,0 +1,286 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/uptrace/bun"
+	"sync"
+	"time"
+)
+
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusSending   = "sending"
+	OutboxStatusDelivered = "delivered"
+	OutboxStatusFailed    = "failed"
+)
+
+const (
+	defaultDispatchInterval   = 5 * time.Second
+	defaultDispatchBatchSize  = 50
+	defaultMaxDeliveryAttempt = 8
+	defaultRetryBaseDelay     = 10 * time.Second
+	defaultRetryMaxDelay      = time.Hour
+	outboxRetention           = 7 * 24 * time.Hour
+)
+
+// enqueueDelivery writes a pending outbox row for the target. The dispatcher picks it up and sends it.
+func (n *NotificationPublisher) enqueueDelivery(ctx context.Context, target Target, dupKey string, msg Message) error {
+	outboxId, err := insertDelivery(ctx, n.dbConn, target, dupKey, msg)
+	if err != nil {
+		return err
+	}
+	n.recordHistory(ctx, target, msg, HistoryStatusQueued, "", outboxId)
+
+	n.wakeDispatcher()
+
+	return nil
+}
+
+// insertDelivery writes the pending outbox row, returning its id. Use it over enqueueDelivery to write the row in a
+// transaction, the history and the dispatcher wake up are left to the caller.
+func insertDelivery(ctx context.Context, db bun.IDB, target Target, dupKey string, msg Message) (int, error) {
+	opts, err := msg.options().encode()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	dbOutbox := &dbmodels.NotificationsOutbox{
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		ChatID:        target.ChatId,
+		Sink:          target.Sink,
+		Destination:   target.Destination,
+		Topic:         msg.Topic,
+		Message:       msg.Msg,
+		DupeKey:       dupKey,
+		Priority:      int(msg.Priority),
+		Options:       opts,
+		Status:        OutboxStatusPending,
+		NextAttemptAt: now,
+	}
+
+	_, err = db.NewInsert().Model(dbOutbox).Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert outbox row: %w", err)
+	}
+
+	return dbOutbox.ID, nil
+}
+
+func (n *NotificationPublisher) wakeDispatcher() {
+	select {
+	case n.dispatchWake <- struct{}{}:
+	default:
+	}
+}
+
+// resumeOutbox puts rows that were claimed but never finished back into the pending state. This happens when the
+// bot stops in the middle of a send.
+func (n *NotificationPublisher) resumeOutbox(ctx context.Context) error {
+	res, err := n.dbConn.NewUpdate().Model((*dbmodels.NotificationsOutbox)(nil)).
+		Set("status = ?", OutboxStatusPending).
+		Set("updated_at = ?", time.Now()).
+		Where("status = ?", OutboxStatusSending).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to resume outbox: %w", err)
+	}
+
+	if rows, err := res.RowsAffected(); err == nil && rows > 0 {
+		n.logger.Info().Msgf("Resumed %d interrupted deliveries", rows)
+	}
+
+	return nil
+}
+
+// dispatchOutbox claims and sends every pending row that is due
+func (n *NotificationPublisher) dispatchOutbox(ctx context.Context) {
+	for {
+		due := make([]dbmodels.NotificationsOutbox, 0)
+		err := n.dbConn.NewSelect().Model(&due).
+			Where("status = ?", OutboxStatusPending).
+			Where("next_attempt_at <= ?", time.Now()).
+			Order("next_attempt_at ASC", "id ASC").
+			Limit(n.dispatchBatchSize).
+			Scan(ctx)
+		if err != nil {
+			n.logger.Error().Err(err).Msg("failed to get pending deliveries")
+			return
+		}
+
+		n.dispatchRows(ctx, due)
+
+		if len(due) < n.dispatchBatchSize {
+			return
+		}
+	}
+}
+
+// dispatchRows sends to several targets in parallel, bounded by the max parallel sends. Rows for the same chat or
+// sink destination are still sent one at a time and in order.
+func (n *NotificationPublisher) dispatchRows(ctx context.Context, rows []dbmodels.NotificationsOutbox) {
+	targetOrder := make([]string, 0)
+	rowsByTarget := make(map[string][]dbmodels.NotificationsOutbox)
+	for _, row := range rows {
+		key := outboxTarget(row).key()
+		if _, ok := rowsByTarget[key]; !ok {
+			targetOrder = append(targetOrder, key)
+		}
+		rowsByTarget[key] = append(rowsByTarget[key], row)
+	}
+
+	sem := make(chan struct{}, max(n.maxParallelSends, 1))
+	wg := sync.WaitGroup{}
+	for _, key := range targetOrder {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(chatRows []dbmodels.NotificationsOutbox) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			for _, row := range chatRows {
+				if ctx.Err() != nil {
+					return
+				}
+				n.dispatchRow(ctx, row)
+			}
+		}(rowsByTarget[key])
+	}
+
+	wg.Wait()
+}
+
+func (n *NotificationPublisher) dispatchRow(ctx context.Context, row dbmodels.NotificationsOutbox) {
+	claimed, err := n.claimRow(ctx, row)
+	if err != nil {
+		n.logger.Error().Err(err).Msgf("failed to claim delivery %d", row.ID)
+		return
+	} else if !claimed {
+		return
+	}
+
+	row.Attempts++
+	target := outboxTarget(row)
+	sentMsgId := 0
+	opts, sendErr := decodeMessageOptions(row.Options)
+	if sendErr == nil && target.IsTelegram() {
+		sentMsgId, sendErr = n.deliver(ctx, row, opts)
+	} else if sendErr == nil {
+		sendErr = n.deliverToSink(ctx, row, opts)
+	}
+
+	policy := n.retryPolicyFor(row.Sink)
+
+	now := time.Now()
+	update := n.dbConn.NewUpdate().Model((*dbmodels.NotificationsOutbox)(nil)).
+		Set("attempts = ?", row.Attempts).
+		Set("updated_at = ?", now).
+		Where("id = ?", row.ID)
+
+	switch {
+	case sendErr == nil:
+		update = update.
+			Set("status = ?", OutboxStatusDelivered).
+			Set("delivered_at = ?", now).
+			Set("last_error = ?", "")
+		n.updateHistory(ctx, row.ID, HistoryStatusDelivered, "", sentMsgId)
+	case row.Attempts >= policy.maxAttempts:
+		n.logger.Error().Err(sendErr).Msgf("Delivery %d to %s failed permanently after %d attempts",
+			row.ID, target, row.Attempts)
+		update = update.
+			Set("status = ?", OutboxStatusFailed).
+			Set("last_error = ?", sendErr.Error())
+		n.updateHistory(ctx, row.ID, HistoryStatusFailed, sendErr.Error(), 0)
+	default:
+		delay := policy.delay(row.Attempts)
+		n.logger.Warn().Err(sendErr).Msgf("Delivery %d to %s failed, retrying in %s", row.ID, target, delay)
+		update = update.
+			Set("status = ?", OutboxStatusPending).
+			Set("next_attempt_at = ?", now.Add(delay)).
+			Set("last_error = ?", sendErr.Error())
+		n.updateHistory(ctx, row.ID, HistoryStatusRetrying, sendErr.Error(), 0)
+	}
+
+	if _, err := update.Exec(ctx); err != nil {
+		n.logger.Error().Err(err).Msgf("failed to update delivery %d", row.ID)
+	}
+}
+
+// claimRow marks the row as sending. Returns false if another dispatcher got to it first.
+func (n *NotificationPublisher) claimRow(ctx context.Context, row dbmodels.NotificationsOutbox) (bool, error) {
+	res, err := n.dbConn.NewUpdate().Model((*dbmodels.NotificationsOutbox)(nil)).
+		Set("status = ?", OutboxStatusSending).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", row.ID).
+		Where("status = ?", OutboxStatusPending).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+// retryDelay is the exponential backoff of telegram deliveries for the given attempt, capped at the max retry delay
+func (n *NotificationPublisher) retryDelay(attempt int) time.Duration {
+	return n.retryPolicyFor(SinkTelegram).delay(attempt)
+}
+
+// GetDeliveries returns the most recent outbox rows, optionally filtered by status
+func (n *NotificationPublisher) GetDeliveries(status string, limit int) ([]dbmodels.NotificationsOutbox, error) {
+	deliveries := make([]dbmodels.NotificationsOutbox, 0)
+	query := n.dbConn.NewSelect().Model(&deliveries).
+		Order("updated_at DESC", "id DESC").
+		Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Scan(context.TODO()); err != nil {
+		return nil, fmt.Errorf("failed to get deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// RetryDelivery puts a failed delivery back into the outbox
+func (n *NotificationPublisher) RetryDelivery(id int) error {
+	res, err := n.dbConn.NewUpdate().Model((*dbmodels.NotificationsOutbox)(nil)).
+		Set("status = ?", OutboxStatusPending).
+		Set("attempts = ?", 0).
+		Set("next_attempt_at = ?", time.Now()).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Where("status = ?", OutboxStatusFailed).
+		Exec(context.TODO())
+	if err != nil {
+		return fmt.Errorf("failed to retry delivery: %w", err)
+	}
+
+	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+		return fmt.Errorf("no failed delivery with id %d", id)
+	}
+	n.updateHistory(context.TODO(), id, HistoryStatusQueued, "retried by hand", 0)
+
+	n.wakeDispatcher()
+
+	return nil
+}
+
+func (n *NotificationPublisher) cleanupOutbox(ctx context.Context) {
+	_, err := n.dbConn.NewDelete().Model((*dbmodels.NotificationsOutbox)(nil)).
+		Where("status IN (?)", bun.In([]string{OutboxStatusDelivered, OutboxStatusFailed})).
+		Where("updated_at < ?", time.Now().Add(-outboxRetention)).
+		Exec(ctx)
+	if err != nil {
+		n.logger.Error().Err(err).Msg("failed to clean up outbox")
+	}
+}
@@ -0,0 +1,156 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func (t *TestNotificationSuite) getOutbox() []dbmodels.NotificationsOutbox {
+	outbox := make([]dbmodels.NotificationsOutbox, 0)
+	err := t.dbConn.NewSelect().Model(&outbox).Order("id ASC").Scan(context.Background())
+	require.NoError(t.T(), err)
+
+	return outbox
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Outbox_Delivered() {
+	mockBot := proxy.NewMockTGBotSendable(t.T())
+	mockBot.EXPECT().Send(mock.MatchedBy(func(c tgbotapi.MessageConfig) bool {
+		return c.ChatID == 12345 && c.Text == "hello"
+	})).Return(tgbotapi.Message{}, nil).Once()
+
+	publisher := NewNotificationPublisher(mockBot, t.dbConn)
+	_, err := publisher.Subscribe(Subscriber{
+		TopicPattern: "test.alert",
+		ChatId:       12345,
+	})
+	require.NoError(t.T(), err)
+
+	err = publisher.handleBusMessage(context.Background(), Message{Topic: "test.alert", Msg: "hello"})
+	require.NoError(t.T(), err)
+
+	outbox := t.getOutbox()
+	require.Len(t.T(), outbox, 1)
+	require.Equal(t.T(), OutboxStatusPending, outbox[0].Status)
+
+	publisher.dispatchOutbox(context.Background())
+
+	outbox = t.getOutbox()
+	require.Equal(t.T(), OutboxStatusDelivered, outbox[0].Status)
+	require.Equal(t.T(), 1, outbox[0].Attempts)
+	require.False(t.T(), outbox[0].DeliveredAt.IsZero())
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Outbox_Retry() {
+	mockBot := proxy.NewMockTGBotSendable(t.T())
+	mockBot.EXPECT().Send(mock.Anything).Return(tgbotapi.Message{}, errors.New("telegram is down"))
+
+	publisher := NewNotificationPublisher(mockBot, t.dbConn, WithRetryPolicy(2, time.Minute, time.Hour))
+	require.NoError(t.T(), publisher.enqueueDelivery(context.Background(), telegramTarget(12345), "key", Message{Msg: "hello"}))
+
+	publisher.dispatchOutbox(context.Background())
+
+	outbox := t.getOutbox()
+	require.Equal(t.T(), OutboxStatusPending, outbox[0].Status)
+	require.Equal(t.T(), "telegram is down", outbox[0].LastError)
+	require.True(t.T(), outbox[0].NextAttemptAt.After(time.Now().Add(50*time.Second)))
+
+	// Not due yet, so nothing happens
+	publisher.dispatchOutbox(context.Background())
+	require.Equal(t.T(), 1, t.getOutbox()[0].Attempts)
+
+	_, err := t.dbConn.NewUpdate().Model((*dbmodels.NotificationsOutbox)(nil)).
+		Set("next_attempt_at = ?", time.Now().Add(-time.Second)).
+		Where("id = ?", outbox[0].ID).
+		Exec(context.Background())
+	require.NoError(t.T(), err)
+
+	publisher.dispatchOutbox(context.Background())
+
+	outbox = t.getOutbox()
+	require.Equal(t.T(), OutboxStatusFailed, outbox[0].Status)
+	require.Equal(t.T(), 2, outbox[0].Attempts)
+
+	failed, err := publisher.GetDeliveries(OutboxStatusFailed, 10)
+	require.NoError(t.T(), err)
+	require.Len(t.T(), failed, 1)
+
+	require.NoError(t.T(), publisher.RetryDelivery(outbox[0].ID))
+	require.Equal(t.T(), OutboxStatusPending, t.getOutbox()[0].Status)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Outbox_Resume() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+	require.NoError(t.T(), publisher.enqueueDelivery(context.Background(), telegramTarget(12345), "key", Message{Msg: "hello"}))
+
+	claimed, err := publisher.claimRow(context.Background(), t.getOutbox()[0])
+	require.NoError(t.T(), err)
+	require.True(t.T(), claimed)
+	require.Equal(t.T(), OutboxStatusSending, t.getOutbox()[0].Status)
+
+	// A restarted publisher picks the interrupted row back up
+	restarted := NewNotificationPublisher(nil, t.dbConn)
+	require.NoError(t.T(), restarted.resumeOutbox(context.Background()))
+	require.Equal(t.T(), OutboxStatusPending, t.getOutbox()[0].Status)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_retryDelay() {
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithRetryPolicy(10, time.Second, 10*time.Second))
+
+	require.Equal(t.T(), time.Second, publisher.retryDelay(1))
+	require.Equal(t.T(), 2*time.Second, publisher.retryDelay(2))
+	require.Equal(t.T(), 8*time.Second, publisher.retryDelay(4))
+	require.Equal(t.T(), 10*time.Second, publisher.retryDelay(5))
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Outbox_ParallelChats() {
+	var inFlight, maxInFlight atomic.Int32
+	// the first two sends wait for each other, which only happens if they're sent at the same time
+	overlapped := make(chan struct{})
+	var overlapOnce sync.Once
+	mockBot := proxy.NewMockTGBotSendable(t.T())
+	mockBot.EXPECT().Send(mock.Anything).RunAndReturn(func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		if current == 2 {
+			overlapOnce.Do(func() { close(overlapped) })
+		}
+
+		select {
+		case <-overlapped:
+		case <-time.After(time.Second):
+		}
+		return tgbotapi.Message{}, nil
+	}).Times(6)
+
+	publisher := NewNotificationPublisher(mockBot, t.dbConn, WithMaxParallelSends(2))
+	for chatId := int64(1); chatId <= 6; chatId++ {
+		require.NoError(t.T(), publisher.enqueueDelivery(context.Background(), telegramTarget(chatId), "key", Message{Msg: "hello"}))
+	}
+
+	publisher.dispatchOutbox(context.Background())
+
+	for _, row := range t.getOutbox() {
+		require.Equal(t.T(), OutboxStatusDelivered, row.Status)
+	}
+	require.LessOrEqual(t.T(), maxInFlight.Load(), int32(2))
+	select {
+	case <-overlapped:
+	default:
+		t.T().Fatal("no two sends were in flight at once")
+	}
+}
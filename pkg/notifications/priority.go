@@ -0,0 +1,33 @@
+//go:generate go run golang.org/x/tools/cmd/stringer@latest -type=Priority -trimprefix Priority
+package notifications
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Priority is the severity of a published message. Subscriptions carry a minimum priority and only receive
+// messages at or above it.
+type Priority int
+
+const (
+	PriorityDebug Priority = iota
+	PriorityInfo
+	PriorityWarning
+	PriorityCritical
+)
+
+func (i Priority) IsValid() bool {
+	return i >= PriorityDebug && i <= PriorityCritical
+}
+
+// ParsePriority parses a priority name such as "warning", case insensitive.
+func ParsePriority(s string) (Priority, error) {
+	for p := PriorityDebug; p <= PriorityCritical; p++ {
+		if strings.EqualFold(s, p.String()) {
+			return p, nil
+		}
+	}
+
+	return PriorityDebug, fmt.Errorf("invalid priority %s", s)
+}
@@ -6,40 +6,74 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/google/uuid"
 	"github.com/jellydator/ttlcache/v3"
 	"github.com/rs/zerolog"
 	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
 	"github.com/tomato3017/tomatobot/pkg/util"
 	"github.com/uptrace/bun"
-	"regexp"
-	"strings"
 	"sync"
 	"time"
 )
 
 var (
-	ErrSubExists = errors.New("subscription already exists")
+	ErrSubExists   = errors.New("subscription already exists")
+	ErrSubNotFound = errors.New("subscription not found")
 )
 
 type Publisher interface {
 	Subscribe(sub Subscriber) (string, error)
-	Publish(msg Message)
+	Publish(ctx context.Context, msg Message) error
 	Unsubscribe(topicId uuid.UUID, chatId int64) error
 	GetSubscriptions(chatId int64) ([]dbmodels.Subscriptions, error)
 	UnsubscribeAll(chatId int64) error
+	MuteSubscription(subId uuid.UUID, chatId int64, until time.Time) error
+	UnmuteSubscription(subId uuid.UUID, chatId int64) error
+	SetQuietHours(quietHours QuietHours) error
+	ClearQuietHours(chatId int64) error
+	GetQuietHours(chatId int64) (QuietHours, bool)
+	GetDeliveries(status string, limit int) ([]dbmodels.NotificationsOutbox, error)
+	RetryDelivery(id int) error
+	RegisterTopic(tmpl TopicTemplate) error
+	RegisterTopicPrefix(prefix string) error
+	RegisterDeliveryFilter(prefix string, filter DeliveryFilter) error
+	GetTopicTemplates(prefix string) []TopicTemplate
+	GetRecentTopics(prefix string, limit int) ([]dbmodels.NotificationsTopicStats, error)
+	ValidateTopicPattern(pattern string) error
+	AuditSubscriptions() []SubscriptionViolation
+	ReloadSubscriptions(ctx context.Context) error
+	GetSinks() []string
+	GetHistory(filter HistoryFilter) ([]dbmodels.NotificationsHistory, error)
 }
 
 type Message struct {
-	Topic   string
-	Msg     string
-	DupeKey string
-	DupeTTL time.Duration
+	Topic    string
+	Msg      string
+	DupeKey  string
+	DupeTTL  time.Duration
+	Priority Priority
+
+	// ParseMode is tgbotapi.ModeMarkdownV2, tgbotapi.ModeHTML or empty for plain text. Use EscapeText for user input.
+	ParseMode          string
+	Buttons            [][]Button
+	Attachment         *Attachment
+	Silent             bool
+	DisableLinkPreview bool
+
+	// EventID ties together messages about the same evolving event. Later messages with the same id update the
+	// message already sent to the chat instead of posting a new one.
+	EventID string
+	Action  EventAction
+
+	// Data is what the message was rendered from, for delivery filters to render it differently per chat. It's kept
+	// as json in the inbox, read it with MessageData since it comes back as StoredData after a restart. It's gone
+	// once the message is queued, deferred or collected into a digest.
+	Data any
 }
 
 func (m Message) String() string {
-	return fmt.Sprintf("Topic: %s, Message: %s", m.Topic, m.Msg)
+	return fmt.Sprintf("Topic: %s, Priority: %s, Message: %s", m.Topic, m.Priority, m.Msg)
 }
 
 func (m Message) DuplicationKey() string {
@@ -61,7 +95,30 @@ type Subscriber struct {
 	ID           uuid.UUID
 	TopicPattern string
 	ChatId       int64
-	//TODO priority filter?
+	MinPriority  Priority
+	Digest       DigestSchedule
+	MutedUntil   time.Time
+	ExpiresAt    time.Time
+	// CreatedBy is the user who subscribed, checked against bot admin only topics
+	CreatedBy int64
+	// Sink and Destination send the chat's subscription somewhere else, like a webhook or an email address. An
+	// empty sink delivers to the chat itself.
+	Sink        string
+	Destination string
+}
+
+// Target is where the subscription's messages are delivered
+func (s *Subscriber) Target() Target {
+	return Target{ChatId: s.ChatId, Sink: s.Sink, Destination: s.Destination}
+}
+
+// Active returns false while the subscription is muted or once it has expired
+func (s *Subscriber) Active(now time.Time) bool {
+	if now.Before(s.MutedUntil) {
+		return false
+	}
+
+	return s.ExpiresAt.IsZero() || now.Before(s.ExpiresAt)
 }
 
 func (s *Subscriber) DbModel() *dbmodels.Subscriptions {
@@ -73,51 +130,115 @@ func (s *Subscriber) DbModel() *dbmodels.Subscriptions {
 		ID:           s.ID,
 		ChatID:       s.ChatId,
 		TopicPattern: s.TopicPattern,
+		MinPriority:  int(s.MinPriority),
+		Digest:       s.Digest.String(),
+		MutedUntil:   s.MutedUntil,
+		ExpiresAt:    s.ExpiresAt,
+		CreatedBy:    s.CreatedBy,
+		Sink:         s.Sink,
+		Destination:  s.Destination,
 	}
 }
 
 type NotificationPublisher struct {
-	bus        chan Message
-	wg         *sync.WaitGroup
-	cancelFunc context.CancelFunc
-
-	subscribers []Subscriber
-	dbConn      bun.IDB
-
-	tgbot  *tgbotapi.BotAPI
-	logger zerolog.Logger
-
-	sublck sync.RWMutex
-
-	subCache  *ttlcache.Cache[string, []int64]
+	bus             chan busMessage
+	overflowPolicy  OverflowPolicy
+	busBlockTimeout time.Duration
+	closed          chan struct{}
+	closeOnce       sync.Once
+	wg              *sync.WaitGroup
+	cancelFunc      context.CancelFunc
+
+	subs   *subscriptionRepo
+	dbConn bun.IDB
+
+	tgbot     proxy.TGBotSendable
+	sinks     map[string]Sink
+	sinkRetry map[string]retryPolicy
+	logger    zerolog.Logger
+
+	subCache  *ttlcache.Cache[string, []Subscriber]
 	dupeCache *ttlcache.Cache[string, struct{}]
+
+	quietHours map[int64]QuietHours
+	quietlck   sync.RWMutex
+
+	topicTemplates  []registeredTopic
+	topicPrefixes   []string
+	deliveryFilters []registeredFilter
+	cataloglck      sync.RWMutex
+	botAdminCheck   func(userId int64) bool
+
+	dispatchWake        chan struct{}
+	dispatchInterval    time.Duration
+	dispatchBatchSize   int
+	maxDeliveryAttempts int
+	retryBaseDelay      time.Duration
+	retryMaxDelay       time.Duration
+	maxParallelSends    int
+	eventUpdateStyle    EventUpdateStyle
+	historyRetention    time.Duration
+	// replayInboxUpTo is the last inbox row left by an earlier run, the ones after it are on the bus
+	replayInboxUpTo int
 }
 
 var _ Publisher = &NotificationPublisher{}
 
-func NewNotificationPublisher(tgbot *tgbotapi.BotAPI, dbConn bun.IDB, options ...PublisherOptions) *NotificationPublisher {
+func NewNotificationPublisher(tgbot proxy.TGBotSendable, dbConn bun.IDB, options ...PublisherOptions) *NotificationPublisher {
 	publisher := NotificationPublisher{
-		bus:         make(chan Message),
-		subscribers: make([]Subscriber, 0),
-		tgbot:       tgbot,
-		logger:      zerolog.Logger{},
-		dbConn:      dbConn,
-		dupeCache:   ttlcache.New[string, struct{}](ttlcache.WithTTL[string, struct{}](5 * time.Minute)),
-		subCache:    ttlcache.New[string, []int64](ttlcache.WithTTL[string, []int64](5 * time.Minute)),
+		bus:             make(chan busMessage, defaultBusSize),
+		overflowPolicy:  OverflowBlock,
+		busBlockTimeout: defaultBusBlockTimeout,
+		closed:          make(chan struct{}),
+		tgbot:           tgbot,
+		sinks:           make(map[string]Sink),
+		sinkRetry:       make(map[string]retryPolicy),
+		logger:          zerolog.Logger{},
+		dbConn:          dbConn,
+		dupeCache:       ttlcache.New[string, struct{}](ttlcache.WithTTL[string, struct{}](5 * time.Minute)),
+		subCache:        ttlcache.New[string, []Subscriber](ttlcache.WithTTL[string, []Subscriber](5 * time.Minute)),
+		quietHours:      make(map[int64]QuietHours),
+
+		dispatchWake:        make(chan struct{}, 1),
+		dispatchInterval:    defaultDispatchInterval,
+		dispatchBatchSize:   defaultDispatchBatchSize,
+		maxDeliveryAttempts: defaultMaxDeliveryAttempt,
+		retryBaseDelay:      defaultRetryBaseDelay,
+		retryMaxDelay:       defaultRetryMaxDelay,
+		maxParallelSends:    defaultMaxParallelSends,
+		historyRetention:    defaultHistoryRetention,
+	}
+	for _, option := range options {
+		option(&publisher)
+	}
+
+	// there's no oldest message to drop from an unbuffered bus, so dropping it could never make room
+	if publisher.overflowPolicy == OverflowDropOldest && cap(publisher.bus) == 0 {
+		publisher.logger.Error().Msgf("Overflow policy %s needs a buffered bus, using %s", OverflowDropOldest, OverflowBlock)
+		publisher.overflowPolicy = OverflowBlock
 	}
+
+	publisher.subs = newSubscriptionRepo(dbConn, publisher.subCache, publisher.logger)
+
 	if err := publisher.populateDupeCache(); err != nil {
 		publisher.logger.Fatal().Err(err).Msg("failed to populate dupe cache")
 	}
 
-	if err := publisher.updateSubsFromDb(); err != nil {
+	if err := publisher.subs.Reload(context.Background()); err != nil {
 		publisher.logger.Fatal().Err(err).Msg("failed to update subscriptions from db")
 	}
 
-	publisher.dupeCache.OnInsertion(publisher.insertDupeCache)
+	if err := publisher.updateQuietHoursFromDb(); err != nil {
+		publisher.logger.Fatal().Err(err).Msg("failed to update quiet hours from db")
+	}
 
-	for _, option := range options {
-		option(&publisher)
+	lastInboxID, err := publisher.lastInboxID(context.Background())
+	if err != nil {
+		publisher.logger.Fatal().Err(err).Msg("failed to get inbox from db")
 	}
+	publisher.replayInboxUpTo = lastInboxID
+
+	publisher.dupeCache.OnInsertion(publisher.insertDupeCache)
 
 	return &publisher
 }
@@ -141,43 +262,16 @@ func (n *NotificationPublisher) insertDupeCache(ctx context.Context, item *ttlca
 }
 
 func (n *NotificationPublisher) UnsubscribeAll(chatId int64) error {
-	n.sublck.Lock()
-	defer n.sublck.Unlock()
-
-	//create a list of topics to remove
-	topics := make([]uuid.UUID, 0)
-	for _, subscriber := range n.subscribers {
-		if subscriber.ChatId == chatId {
-			topics = append(topics, subscriber.ID)
-		}
-	}
-
-	//unsubscribe from each topic
-	for _, topic := range topics {
-		if err := n.unsubUnSafe(topic, chatId); err != nil {
-			return fmt.Errorf("failed to unsubscribe from topic: %w", err)
-		}
+	if _, err := n.subs.RemoveAll(context.TODO(), chatId); err != nil {
+		return fmt.Errorf("failed to unsubscribe from all topics: %w", err)
 	}
 
 	return nil
 }
 
-func (n *NotificationPublisher) updateSubsFromDb() error {
-	subs := make([]dbmodels.Subscriptions, 0)
-
-	err := n.dbConn.NewSelect().Model(&subs).Scan(context.Background())
-	if err != nil {
-		return fmt.Errorf("failed to get subscriptions: %w", err)
-	}
-
-	for _, sub := range subs {
-		n.subscribers = append(n.subscribers, Subscriber{
-			ChatId:       sub.ChatID,
-			TopicPattern: sub.TopicPattern,
-		})
-	}
-
-	return nil
+// ReloadSubscriptions rebuilds the in-memory subscriptions from the database, picking up changes made to it directly
+func (n *NotificationPublisher) ReloadSubscriptions(ctx context.Context) error {
+	return n.subs.Reload(ctx)
 }
 
 func (n *NotificationPublisher) GetSubscriptions(chatId int64) ([]dbmodels.Subscriptions, error) {
@@ -192,88 +286,69 @@ func (n *NotificationPublisher) GetSubscriptions(chatId int64) ([]dbmodels.Subsc
 }
 
 func (n *NotificationPublisher) Subscribe(sub Subscriber) (string, error) {
-	n.sublck.Lock()
-	defer n.sublck.Unlock()
-
-	_, err := n.dbConn.NewInsert().Model(sub.DbModel()).Exec(context.TODO())
-	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			return "", ErrSubExists
-		}
-		return "", fmt.Errorf("failed to insert subscription: %w", err)
-	}
-
-	n.subscribers = append(n.subscribers, sub)
-	n.invalidateSubCache()
-
-	return sub.ID.String(), nil
-}
-
-func (n *NotificationPublisher) invalidateSubCache() {
-	n.logger.Trace().Msgf("Invalidating subscription cache")
-	n.subCache.DeleteAll()
-}
-
-func (n *NotificationPublisher) unsubUnSafe(topicId uuid.UUID, chatId int64) error {
-	if topicId == uuid.Nil {
-		return fmt.Errorf("invalid subscription id")
+	if err := n.validateTarget(sub); err != nil {
+		return "", err
 	}
 
-	changed := false
-	for i, currentSub := range n.subscribers {
-		if currentSub.ID == topicId && currentSub.ChatId == chatId {
-			_, err := n.dbConn.NewDelete().Model(currentSub.DbModel()).
-				WherePK().
-				Where("chat_id = ?", chatId).
-				Exec(context.TODO())
-
-			if err != nil {
-				return fmt.Errorf("failed to delete subscription: %w", err)
-			}
-
-			n.subscribers = append(n.subscribers[:i], n.subscribers[i+1:]...)
-			changed = true
-			break
-		}
+	if err := n.checkSubscriptionAccess(sub); err != nil {
+		return "", err
 	}
 
-	if changed {
-		n.invalidateSubCache()
+	sub, err := n.subs.Add(context.TODO(), sub)
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	return sub.ID.String(), nil
 }
 
 func (n *NotificationPublisher) Unsubscribe(topicId uuid.UUID, chatId int64) error {
-	n.sublck.Lock()
-	defer n.sublck.Unlock()
-
-	return n.unsubUnSafe(topicId, chatId)
-}
-
-func (n *NotificationPublisher) Publish(msg Message) {
-	n.bus <- msg
+	_, err := n.subs.Remove(context.TODO(), topicId, chatId)
+	return err
 }
 
+// Close rejects any further publishes and stops the caches. The bus is never closed so a late Publish can't panic.
 func (n *NotificationPublisher) Close() error {
-	close(n.bus)
+	n.markClosed()
 	n.subCache.Stop()
 	n.dupeCache.Stop()
 
 	return nil
 }
 
+func (n *NotificationPublisher) markClosed() {
+	n.closeOnce.Do(func() {
+		close(n.closed)
+	})
+}
+
 func (n *NotificationPublisher) Stop() error {
 	if n.wg == nil {
 		return fmt.Errorf("publisher not started")
 	}
 
+	n.markClosed()
 	n.cancelFunc()
 	n.wg.Wait()
+	n.drainBus()
 
 	return n.Close()
 }
 
+// drainBus writes any messages still waiting on the bus to the outbox so they are delivered after a restart
+func (n *NotificationPublisher) drainBus() {
+	for {
+		select {
+		case msg := <-n.bus:
+			if err := n.handleInbound(context.Background(), msg); err != nil {
+				n.logger.Error().Err(err).Msg("failed to handle bus message during shutdown")
+			}
+		default:
+			return
+		}
+	}
+}
+
 func (n *NotificationPublisher) startCaches() {
 	go func() {
 		n.subCache.Start()
@@ -289,6 +364,14 @@ func (n *NotificationPublisher) Start(ctx context.Context) error {
 	}
 	n.startCaches()
 
+	if err := n.resumeOutbox(ctx); err != nil {
+		return err
+	}
+
+	if err := n.replayInbox(ctx); err != nil {
+		return err
+	}
+
 	n.wg = &sync.WaitGroup{}
 	n.wg.Add(1)
 
@@ -304,7 +387,7 @@ func (n *NotificationPublisher) Start(ctx context.Context) error {
 			case <-ctx.Done():
 				return
 			case msg := <-n.bus:
-				if err := n.handleBusMessage(ctx, msg); err != nil {
+				if err := n.handleInbound(ctx, msg); err != nil {
 					n.logger.Error().Err(err).Msg("failed to handle bus message")
 					continue
 				}
@@ -327,6 +410,42 @@ func (n *NotificationPublisher) Start(ctx context.Context) error {
 		}
 	}(ctx, n.wg)
 
+	//start outbox dispatcher
+	n.wg.Add(1)
+	go func(ctx context.Context, wg *sync.WaitGroup) {
+		defer wg.Done()
+		n.logger.Trace().Msg("Starting outbox dispatcher")
+		ticker := time.NewTicker(n.dispatchInterval)
+		defer ticker.Stop()
+		for {
+			n.dispatchOutbox(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-n.dispatchWake:
+			}
+		}
+	}(ctx, n.wg)
+
+	//start deferred message, digest delivery, subscription expiry and inbox retry routine
+	n.wg.Add(1)
+	go func(ctx context.Context, wg *sync.WaitGroup) {
+		defer wg.Done()
+		n.logger.Trace().Msg("Starting deferred message, digest delivery, subscription expiry and inbox retry routine")
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Minute):
+				n.flushDeferred(ctx)
+				n.flushDigests(ctx)
+				n.sweepExpiredSubscriptions(ctx)
+				n.retryInbox(ctx)
+			}
+		}
+	}(ctx, n.wg)
+
 	return nil
 }
 
@@ -334,90 +453,153 @@ func (n *NotificationPublisher) handleBusMessage(ctx context.Context, msg Messag
 	logger := n.logger.
 		With().Str("func", "handleBusMessage").Logger()
 	logger.Trace().Msgf("Handling message for topic: %s", msg.Topic)
+	n.recordTopicSeen(ctx, msg.Topic)
+
+	// get the subscription to deliver through for each chat and sink destination
+	subscribers := n.matchSubscribers(msg.Topic, msg.Priority, func(subscriber Subscriber, status string) {
+		n.recordHistory(ctx, subscriber.Target(), msg, status, subscriber.TopicPattern, 0)
+	})
+
+	// one subscriber failing doesn't keep the message from the rest
+	errs := make([]error, 0)
+	for _, subscriber := range subscribers {
+		if err := n.handleSubscriber(ctx, subscriber, msg); err != nil {
+			logger.Error().Err(err).Msgf("Failed to handle message for %s", subscriber.Target())
+			errs = append(errs, err)
+		}
+	}
 
-	// get the chat ids for the topic
-	chatIds, err := n.getChatIdsForTopic(msg.Topic)
-	if err != nil {
-		return fmt.Errorf("failed to get chat ids for topic: %w", err)
+	return errors.Join(errs...)
+}
+
+// handleSubscriber queues, defers or collects the message for one subscription's chat or sink destination
+func (n *NotificationPublisher) handleSubscriber(ctx context.Context, subscriber Subscriber, msg Message) error {
+	logger := n.logger.
+		With().Str("func", "handleSubscriber").Logger()
+
+	target := subscriber.Target()
+	n.logger.Trace().Msgf("Sending message to %s", target)
+	// check if the message is a duplicate
+	dupKey := fmt.Sprintf("%s-%s", target.key(), msg.DuplicationKey())
+	n.logger.Trace().Msgf("Message dupe key: %s", dupKey)
+	trunMsg := util.TruncateString(msg.String(), 1024, "")
+	if ok := n.dupeCache.Has(dupKey); ok {
+		logger.Trace().Msgf("Duplicate message detected: %s", trunMsg)
+		n.recordDuplicate(ctx, target, msg)
+		return nil
 	}
+	n.logger.Trace().Msgf("Message not a duplicate: %s", trunMsg)
 
-	for _, chatId := range chatIds {
-		n.logger.Trace().Msgf("Sending message to chat: %d", chatId)
-		// check if the message is a duplicate
-		dupKey := fmt.Sprintf("%d-%s", chatId, msg.DuplicationKey())
-		n.logger.Trace().Msgf("Message dupe key: %s", dupKey)
-		trunMsg := util.TruncateString(msg.String(), 1024, "")
-		if ok := n.dupeCache.Has(dupKey); ok {
-			logger.Trace().Msgf("Duplicate message detected: %s", trunMsg)
-			continue
+	// digests, quiet hours and edits are about telegram chats, other sinks get every message right away
+	if !target.IsTelegram() {
+		if err := n.enqueueDelivery(ctx, target, dupKey, msg); err != nil {
+			return fmt.Errorf("failed to queue message: %w", err)
 		}
-		n.logger.Trace().Msgf("Message not a duplicate: %s", trunMsg)
 
-		// send the message to the chat
-		_, err := n.tgbot.Send(tgbotapi.NewMessage(chatId, msg.Msg))
-		if err != nil {
-			return fmt.Errorf("failed to send message: %w", err)
+		n.dupeCache.Set(dupKey, struct{}{}, msg.DupeTTL)
+		return nil
+	}
+
+	chatId := subscriber.ChatId
+
+	msg, ok := n.filterForChat(ctx, chatId, msg)
+	if !ok {
+		logger.Trace().Msgf("Message filtered out for chat %d: %s", chatId, trunMsg)
+		n.recordHistory(ctx, target, msg, HistoryStatusFiltered, "", 0)
+		return nil
+	}
+
+	// updates to a message already in the chat are edits that don't notify anyone, so they skip the digest and
+	// quiet hours
+	if msg.Action == EventActionCancel || n.hasSentEvent(ctx, chatId, msg.EventID) {
+		if err := n.enqueueDelivery(ctx, target, dupKey, msg); err != nil {
+			return fmt.Errorf("failed to queue message: %w", err)
 		}
 
-		// cache the message to prevent duplicates
 		n.dupeCache.Set(dupKey, struct{}{}, msg.DupeTTL)
+		return nil
 	}
 
-	return nil
-}
+	// critical messages skip the digest just like they skip quiet hours
+	if !subscriber.Digest.IsZero() && msg.Priority < PriorityCritical {
+		if err := n.collectDigestItem(ctx, subscriber, dupKey, msg); err != nil {
+			return fmt.Errorf("failed to collect digest item: %w", err)
+		}
+		n.recordHistory(ctx, target, msg, HistoryStatusDigest, subscriber.Digest.String(), 0)
 
-func (n *NotificationPublisher) tokenizeTopicString(topic string) string {
-	rtnStr := strings.ReplaceAll(topic, "*", "<star>")
-	return rtnStr
-}
+		n.dupeCache.Set(dupKey, struct{}{}, msg.DupeTTL)
+		return nil
+	}
 
-func (n *NotificationPublisher) detokenizeTopicString(topic string) string {
-	rtnStr := strings.ReplaceAll(topic, "<star>", ".*")
-	return rtnStr
-}
+	if n.isQuiet(chatId, msg.Priority, time.Now()) {
+		if err := n.deferMessage(ctx, chatId, dupKey, msg); err != nil {
+			return fmt.Errorf("failed to defer message: %w", err)
+		}
+		n.recordHistory(ctx, target, msg, HistoryStatusDeferred, "quiet hours", 0)
 
-func (n *NotificationPublisher) getChatIdsForTopic(topic string) ([]int64, error) {
-	n.sublck.RLock()
-	defer n.sublck.RUnlock()
+		n.dupeCache.Set(dupKey, struct{}{}, msg.DupeTTL)
+		return nil
+	}
 
-	if ok := n.subCache.Has(topic); ok {
-		cacheEntry := n.subCache.Get(topic)
-		if cacheEntry != nil {
-			n.logger.Trace().Msgf("Cache hit for topic: %s", topic)
-			return cacheEntry.Value(), nil
-		}
+	// queue the message for delivery to the chat
+	if err := n.enqueueDelivery(ctx, target, dupKey, msg); err != nil {
+		return fmt.Errorf("failed to queue message: %w", err)
 	}
-	n.logger.Trace().Msgf("Cache miss for topic: %s", topic)
 
-	chatIdSet := make(map[int64]struct{})
-	chatIds := make([]int64, 0)
-	for _, subscriber := range n.subscribers {
-		pattern := subscriber.TopicPattern
+	// cache the message to prevent duplicates
+	n.dupeCache.Set(dupKey, struct{}{}, msg.DupeTTL)
 
-		tokenizedStr := n.tokenizeTopicString(pattern)
-		escapedString := regexp.QuoteMeta(tokenizedStr)
-		finalPattern := n.detokenizeTopicString(escapedString)
+	return nil
+}
 
-		//compile the regex
-		re, err := regexp.Compile(finalPattern)
-		if err != nil {
-			return nil, fmt.Errorf("failed to compile regex: %w", err)
+// matchSubscribers returns one matching subscription per chat or sink destination. If a chat has several matching
+// subscriptions an immediate one wins over a digest. onSkip, if set, is called with the history status of every
+// matching subscription left out because it is muted, expired, not allowed or above the priority
+func (n *NotificationPublisher) matchSubscribers(topic string, priority Priority, onSkip func(subscriber Subscriber, status string)) []Subscriber {
+	if onSkip == nil {
+		onSkip = func(Subscriber, string) {}
+	}
+	subscribers := n.subs.MatchTopic(topic)
+
+	now := time.Now()
+	targetIndex := make(map[string]int)
+	chatSubs := make([]Subscriber, 0)
+	for _, subscriber := range subscribers {
+		if !subscriber.Active(now) {
+			n.logger.Trace().Msgf("Skipping subscription %s for topic %s, muted or expired", subscriber.ID, topic)
+			if now.Before(subscriber.MutedUntil) {
+				onSkip(subscriber, HistoryStatusMuted)
+			} else {
+				onSkip(subscriber, HistoryStatusExpired)
+			}
+			continue
 		}
 
-		//check if the topic matches the regex
-		if re.MatchString(topic) {
-			chatIdSet[subscriber.ChatId] = struct{}{}
+		if !n.topicAllowed(topic, subscriber) {
+			n.logger.Warn().Msgf("Skipping subscription %s for topic %s, not allowed by the topic ACL", subscriber.ID, topic)
+			onSkip(subscriber, HistoryStatusDenied)
+			continue
 		}
-	}
 
-	for id := range chatIdSet {
-		chatIds = append(chatIds, id)
-	}
+		if priority < subscriber.MinPriority {
+			n.logger.Trace().Msgf("Skipping chat %d for topic %s, priority %s below %s",
+				subscriber.ChatId, topic, priority, subscriber.MinPriority)
+			onSkip(subscriber, HistoryStatusBelowPriority)
+			continue
+		}
 
-	n.logger.Trace().Msgf("Setting cache for topic: %s TO: %+v", topic, chatIds)
+		key := subscriber.Target().key()
+		if i, ok := targetIndex[key]; ok {
+			if !chatSubs[i].Digest.IsZero() && subscriber.Digest.IsZero() {
+				chatSubs[i] = subscriber
+			}
+			continue
+		}
+		targetIndex[key] = len(chatSubs)
+		chatSubs = append(chatSubs, subscriber)
+	}
 
-	n.subCache.Set(topic, chatIds, ttlcache.DefaultTTL)
-	return chatIds, nil
+	return chatSubs
 }
 
 func (n *NotificationPublisher) populateDupeCache() error {
@@ -445,4 +627,10 @@ func (n *NotificationPublisher) cleanupDb(ctx context.Context) {
 	if err != nil {
 		n.logger.Error().Err(err).Msg("failed to clean up dupe cache")
 	}
+
+	n.cleanupOutbox(ctx)
+	n.cleanupSentEvents(ctx)
+	n.cleanupTopicStats(ctx)
+	n.cleanupHistory(ctx)
+	n.cleanupInbox(ctx)
 }
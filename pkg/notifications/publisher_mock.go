@@ -3,112 +3,875 @@
 package notifications
 
 import (
+	context "context"
+
 	mock "github.com/stretchr/testify/mock"
 	db "github.com/tomato3017/tomatobot/pkg/bot/models/db"
 
-	uuid "github.com/google/uuid"
-)
+	time "time"
+
+	uuid "github.com/google/uuid"
+)
+
+// MockPublisher is an autogenerated mock type for the Publisher type
+type MockPublisher struct {
+	mock.Mock
+}
+
+type MockPublisher_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockPublisher) EXPECT() *MockPublisher_Expecter {
+	return &MockPublisher_Expecter{mock: &_m.Mock}
+}
+
+// AuditSubscriptions provides a mock function with given fields:
+func (_m *MockPublisher) AuditSubscriptions() []SubscriptionViolation {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for AuditSubscriptions")
+	}
+
+	var r0 []SubscriptionViolation
+	if rf, ok := ret.Get(0).(func() []SubscriptionViolation); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]SubscriptionViolation)
+		}
+	}
+
+	return r0
+}
+
+// MockPublisher_AuditSubscriptions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AuditSubscriptions'
+type MockPublisher_AuditSubscriptions_Call struct {
+	*mock.Call
+}
+
+// AuditSubscriptions is a helper method to define mock.On call
+func (_e *MockPublisher_Expecter) AuditSubscriptions() *MockPublisher_AuditSubscriptions_Call {
+	return &MockPublisher_AuditSubscriptions_Call{Call: _e.mock.On("AuditSubscriptions")}
+}
+
+func (_c *MockPublisher_AuditSubscriptions_Call) Run(run func()) *MockPublisher_AuditSubscriptions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockPublisher_AuditSubscriptions_Call) Return(_a0 []SubscriptionViolation) *MockPublisher_AuditSubscriptions_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPublisher_AuditSubscriptions_Call) RunAndReturn(run func() []SubscriptionViolation) *MockPublisher_AuditSubscriptions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ClearQuietHours provides a mock function with given fields: chatId
+func (_m *MockPublisher) ClearQuietHours(chatId int64) error {
+	ret := _m.Called(chatId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClearQuietHours")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
+		r0 = rf(chatId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockPublisher_ClearQuietHours_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ClearQuietHours'
+type MockPublisher_ClearQuietHours_Call struct {
+	*mock.Call
+}
+
+// ClearQuietHours is a helper method to define mock.On call
+//   - chatId int64
+func (_e *MockPublisher_Expecter) ClearQuietHours(chatId interface{}) *MockPublisher_ClearQuietHours_Call {
+	return &MockPublisher_ClearQuietHours_Call{Call: _e.mock.On("ClearQuietHours", chatId)}
+}
+
+func (_c *MockPublisher_ClearQuietHours_Call) Run(run func(chatId int64)) *MockPublisher_ClearQuietHours_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(int64))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_ClearQuietHours_Call) Return(_a0 error) *MockPublisher_ClearQuietHours_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPublisher_ClearQuietHours_Call) RunAndReturn(run func(int64) error) *MockPublisher_ClearQuietHours_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetDeliveries provides a mock function with given fields: status, limit
+func (_m *MockPublisher) GetDeliveries(status string, limit int) ([]db.NotificationsOutbox, error) {
+	ret := _m.Called(status, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDeliveries")
+	}
+
+	var r0 []db.NotificationsOutbox
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, int) ([]db.NotificationsOutbox, error)); ok {
+		return rf(status, limit)
+	}
+	if rf, ok := ret.Get(0).(func(string, int) []db.NotificationsOutbox); ok {
+		r0 = rf(status, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]db.NotificationsOutbox)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(status, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockPublisher_GetDeliveries_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetDeliveries'
+type MockPublisher_GetDeliveries_Call struct {
+	*mock.Call
+}
+
+// GetDeliveries is a helper method to define mock.On call
+//   - status string
+//   - limit int
+func (_e *MockPublisher_Expecter) GetDeliveries(status interface{}, limit interface{}) *MockPublisher_GetDeliveries_Call {
+	return &MockPublisher_GetDeliveries_Call{Call: _e.mock.On("GetDeliveries", status, limit)}
+}
+
+func (_c *MockPublisher_GetDeliveries_Call) Run(run func(status string, limit int)) *MockPublisher_GetDeliveries_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(int))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_GetDeliveries_Call) Return(_a0 []db.NotificationsOutbox, _a1 error) *MockPublisher_GetDeliveries_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPublisher_GetDeliveries_Call) RunAndReturn(run func(string, int) ([]db.NotificationsOutbox, error)) *MockPublisher_GetDeliveries_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetHistory provides a mock function with given fields: filter
+func (_m *MockPublisher) GetHistory(filter HistoryFilter) ([]db.NotificationsHistory, error) {
+	ret := _m.Called(filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetHistory")
+	}
+
+	var r0 []db.NotificationsHistory
+	var r1 error
+	if rf, ok := ret.Get(0).(func(HistoryFilter) ([]db.NotificationsHistory, error)); ok {
+		return rf(filter)
+	}
+	if rf, ok := ret.Get(0).(func(HistoryFilter) []db.NotificationsHistory); ok {
+		r0 = rf(filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]db.NotificationsHistory)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(HistoryFilter) error); ok {
+		r1 = rf(filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockPublisher_GetHistory_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetHistory'
+type MockPublisher_GetHistory_Call struct {
+	*mock.Call
+}
+
+// GetHistory is a helper method to define mock.On call
+//   - filter HistoryFilter
+func (_e *MockPublisher_Expecter) GetHistory(filter interface{}) *MockPublisher_GetHistory_Call {
+	return &MockPublisher_GetHistory_Call{Call: _e.mock.On("GetHistory", filter)}
+}
+
+func (_c *MockPublisher_GetHistory_Call) Run(run func(filter HistoryFilter)) *MockPublisher_GetHistory_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(HistoryFilter))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_GetHistory_Call) Return(_a0 []db.NotificationsHistory, _a1 error) *MockPublisher_GetHistory_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPublisher_GetHistory_Call) RunAndReturn(run func(HistoryFilter) ([]db.NotificationsHistory, error)) *MockPublisher_GetHistory_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetQuietHours provides a mock function with given fields: chatId
+func (_m *MockPublisher) GetQuietHours(chatId int64) (QuietHours, bool) {
+	ret := _m.Called(chatId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetQuietHours")
+	}
+
+	var r0 QuietHours
+	var r1 bool
+	if rf, ok := ret.Get(0).(func(int64) (QuietHours, bool)); ok {
+		return rf(chatId)
+	}
+	if rf, ok := ret.Get(0).(func(int64) QuietHours); ok {
+		r0 = rf(chatId)
+	} else {
+		r0 = ret.Get(0).(QuietHours)
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) bool); ok {
+		r1 = rf(chatId)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// MockPublisher_GetQuietHours_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetQuietHours'
+type MockPublisher_GetQuietHours_Call struct {
+	*mock.Call
+}
+
+// GetQuietHours is a helper method to define mock.On call
+//   - chatId int64
+func (_e *MockPublisher_Expecter) GetQuietHours(chatId interface{}) *MockPublisher_GetQuietHours_Call {
+	return &MockPublisher_GetQuietHours_Call{Call: _e.mock.On("GetQuietHours", chatId)}
+}
+
+func (_c *MockPublisher_GetQuietHours_Call) Run(run func(chatId int64)) *MockPublisher_GetQuietHours_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(int64))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_GetQuietHours_Call) Return(_a0 QuietHours, _a1 bool) *MockPublisher_GetQuietHours_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPublisher_GetQuietHours_Call) RunAndReturn(run func(int64) (QuietHours, bool)) *MockPublisher_GetQuietHours_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRecentTopics provides a mock function with given fields: prefix, limit
+func (_m *MockPublisher) GetRecentTopics(prefix string, limit int) ([]db.NotificationsTopicStats, error) {
+	ret := _m.Called(prefix, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRecentTopics")
+	}
+
+	var r0 []db.NotificationsTopicStats
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, int) ([]db.NotificationsTopicStats, error)); ok {
+		return rf(prefix, limit)
+	}
+	if rf, ok := ret.Get(0).(func(string, int) []db.NotificationsTopicStats); ok {
+		r0 = rf(prefix, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]db.NotificationsTopicStats)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(prefix, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockPublisher_GetRecentTopics_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRecentTopics'
+type MockPublisher_GetRecentTopics_Call struct {
+	*mock.Call
+}
+
+// GetRecentTopics is a helper method to define mock.On call
+//   - prefix string
+//   - limit int
+func (_e *MockPublisher_Expecter) GetRecentTopics(prefix interface{}, limit interface{}) *MockPublisher_GetRecentTopics_Call {
+	return &MockPublisher_GetRecentTopics_Call{Call: _e.mock.On("GetRecentTopics", prefix, limit)}
+}
+
+func (_c *MockPublisher_GetRecentTopics_Call) Run(run func(prefix string, limit int)) *MockPublisher_GetRecentTopics_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(int))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_GetRecentTopics_Call) Return(_a0 []db.NotificationsTopicStats, _a1 error) *MockPublisher_GetRecentTopics_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPublisher_GetRecentTopics_Call) RunAndReturn(run func(string, int) ([]db.NotificationsTopicStats, error)) *MockPublisher_GetRecentTopics_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetSinks provides a mock function with given fields:
+func (_m *MockPublisher) GetSinks() []string {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSinks")
+	}
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func() []string); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	return r0
+}
+
+// MockPublisher_GetSinks_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetSinks'
+type MockPublisher_GetSinks_Call struct {
+	*mock.Call
+}
+
+// GetSinks is a helper method to define mock.On call
+func (_e *MockPublisher_Expecter) GetSinks() *MockPublisher_GetSinks_Call {
+	return &MockPublisher_GetSinks_Call{Call: _e.mock.On("GetSinks")}
+}
+
+func (_c *MockPublisher_GetSinks_Call) Run(run func()) *MockPublisher_GetSinks_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockPublisher_GetSinks_Call) Return(_a0 []string) *MockPublisher_GetSinks_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPublisher_GetSinks_Call) RunAndReturn(run func() []string) *MockPublisher_GetSinks_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetSubscriptions provides a mock function with given fields: chatId
+func (_m *MockPublisher) GetSubscriptions(chatId int64) ([]db.Subscriptions, error) {
+	ret := _m.Called(chatId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSubscriptions")
+	}
+
+	var r0 []db.Subscriptions
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) ([]db.Subscriptions, error)); ok {
+		return rf(chatId)
+	}
+	if rf, ok := ret.Get(0).(func(int64) []db.Subscriptions); ok {
+		r0 = rf(chatId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]db.Subscriptions)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(chatId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockPublisher_GetSubscriptions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetSubscriptions'
+type MockPublisher_GetSubscriptions_Call struct {
+	*mock.Call
+}
+
+// GetSubscriptions is a helper method to define mock.On call
+//   - chatId int64
+func (_e *MockPublisher_Expecter) GetSubscriptions(chatId interface{}) *MockPublisher_GetSubscriptions_Call {
+	return &MockPublisher_GetSubscriptions_Call{Call: _e.mock.On("GetSubscriptions", chatId)}
+}
+
+func (_c *MockPublisher_GetSubscriptions_Call) Run(run func(chatId int64)) *MockPublisher_GetSubscriptions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(int64))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_GetSubscriptions_Call) Return(_a0 []db.Subscriptions, _a1 error) *MockPublisher_GetSubscriptions_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPublisher_GetSubscriptions_Call) RunAndReturn(run func(int64) ([]db.Subscriptions, error)) *MockPublisher_GetSubscriptions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetTopicTemplates provides a mock function with given fields: prefix
+func (_m *MockPublisher) GetTopicTemplates(prefix string) []TopicTemplate {
+	ret := _m.Called(prefix)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTopicTemplates")
+	}
+
+	var r0 []TopicTemplate
+	if rf, ok := ret.Get(0).(func(string) []TopicTemplate); ok {
+		r0 = rf(prefix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]TopicTemplate)
+		}
+	}
+
+	return r0
+}
+
+// MockPublisher_GetTopicTemplates_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetTopicTemplates'
+type MockPublisher_GetTopicTemplates_Call struct {
+	*mock.Call
+}
+
+// GetTopicTemplates is a helper method to define mock.On call
+//   - prefix string
+func (_e *MockPublisher_Expecter) GetTopicTemplates(prefix interface{}) *MockPublisher_GetTopicTemplates_Call {
+	return &MockPublisher_GetTopicTemplates_Call{Call: _e.mock.On("GetTopicTemplates", prefix)}
+}
+
+func (_c *MockPublisher_GetTopicTemplates_Call) Run(run func(prefix string)) *MockPublisher_GetTopicTemplates_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_GetTopicTemplates_Call) Return(_a0 []TopicTemplate) *MockPublisher_GetTopicTemplates_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPublisher_GetTopicTemplates_Call) RunAndReturn(run func(string) []TopicTemplate) *MockPublisher_GetTopicTemplates_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MuteSubscription provides a mock function with given fields: subId, chatId, until
+func (_m *MockPublisher) MuteSubscription(subId uuid.UUID, chatId int64, until time.Time) error {
+	ret := _m.Called(subId, chatId, until)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MuteSubscription")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, int64, time.Time) error); ok {
+		r0 = rf(subId, chatId, until)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockPublisher_MuteSubscription_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MuteSubscription'
+type MockPublisher_MuteSubscription_Call struct {
+	*mock.Call
+}
+
+// MuteSubscription is a helper method to define mock.On call
+//   - subId uuid.UUID
+//   - chatId int64
+//   - until time.Time
+func (_e *MockPublisher_Expecter) MuteSubscription(subId interface{}, chatId interface{}, until interface{}) *MockPublisher_MuteSubscription_Call {
+	return &MockPublisher_MuteSubscription_Call{Call: _e.mock.On("MuteSubscription", subId, chatId, until)}
+}
+
+func (_c *MockPublisher_MuteSubscription_Call) Run(run func(subId uuid.UUID, chatId int64, until time.Time)) *MockPublisher_MuteSubscription_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uuid.UUID), args[1].(int64), args[2].(time.Time))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_MuteSubscription_Call) Return(_a0 error) *MockPublisher_MuteSubscription_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPublisher_MuteSubscription_Call) RunAndReturn(run func(uuid.UUID, int64, time.Time) error) *MockPublisher_MuteSubscription_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Publish provides a mock function with given fields: ctx, msg
+func (_m *MockPublisher) Publish(ctx context.Context, msg Message) error {
+	ret := _m.Called(ctx, msg)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Publish")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, Message) error); ok {
+		r0 = rf(ctx, msg)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
 
-// MockPublisher is an autogenerated mock type for the Publisher type
-type MockPublisher struct {
-	mock.Mock
+// MockPublisher_Publish_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Publish'
+type MockPublisher_Publish_Call struct {
+	*mock.Call
 }
 
-type MockPublisher_Expecter struct {
-	mock *mock.Mock
+// Publish is a helper method to define mock.On call
+//   - ctx context.Context
+//   - msg Message
+func (_e *MockPublisher_Expecter) Publish(ctx interface{}, msg interface{}) *MockPublisher_Publish_Call {
+	return &MockPublisher_Publish_Call{Call: _e.mock.On("Publish", ctx, msg)}
 }
 
-func (_m *MockPublisher) EXPECT() *MockPublisher_Expecter {
-	return &MockPublisher_Expecter{mock: &_m.Mock}
+func (_c *MockPublisher_Publish_Call) Run(run func(ctx context.Context, msg Message)) *MockPublisher_Publish_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(Message))
+	})
+	return _c
 }
 
-// GetSubscriptions provides a mock function with given fields: chatId
-func (_m *MockPublisher) GetSubscriptions(chatId int64) ([]db.Subscriptions, error) {
-	ret := _m.Called(chatId)
+func (_c *MockPublisher_Publish_Call) Return(_a0 error) *MockPublisher_Publish_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPublisher_Publish_Call) RunAndReturn(run func(context.Context, Message) error) *MockPublisher_Publish_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RegisterDeliveryFilter provides a mock function with given fields: prefix, filter
+func (_m *MockPublisher) RegisterDeliveryFilter(prefix string, filter DeliveryFilter) error {
+	ret := _m.Called(prefix, filter)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetSubscriptions")
+		panic("no return value specified for RegisterDeliveryFilter")
 	}
 
-	var r0 []db.Subscriptions
-	var r1 error
-	if rf, ok := ret.Get(0).(func(int64) ([]db.Subscriptions, error)); ok {
-		return rf(chatId)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, DeliveryFilter) error); ok {
+		r0 = rf(prefix, filter)
+	} else {
+		r0 = ret.Error(0)
 	}
-	if rf, ok := ret.Get(0).(func(int64) []db.Subscriptions); ok {
-		r0 = rf(chatId)
+
+	return r0
+}
+
+// MockPublisher_RegisterDeliveryFilter_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RegisterDeliveryFilter'
+type MockPublisher_RegisterDeliveryFilter_Call struct {
+	*mock.Call
+}
+
+// RegisterDeliveryFilter is a helper method to define mock.On call
+//   - prefix string
+//   - filter DeliveryFilter
+func (_e *MockPublisher_Expecter) RegisterDeliveryFilter(prefix interface{}, filter interface{}) *MockPublisher_RegisterDeliveryFilter_Call {
+	return &MockPublisher_RegisterDeliveryFilter_Call{Call: _e.mock.On("RegisterDeliveryFilter", prefix, filter)}
+}
+
+func (_c *MockPublisher_RegisterDeliveryFilter_Call) Run(run func(prefix string, filter DeliveryFilter)) *MockPublisher_RegisterDeliveryFilter_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(DeliveryFilter))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_RegisterDeliveryFilter_Call) Return(_a0 error) *MockPublisher_RegisterDeliveryFilter_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPublisher_RegisterDeliveryFilter_Call) RunAndReturn(run func(string, DeliveryFilter) error) *MockPublisher_RegisterDeliveryFilter_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RegisterTopic provides a mock function with given fields: tmpl
+func (_m *MockPublisher) RegisterTopic(tmpl TopicTemplate) error {
+	ret := _m.Called(tmpl)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RegisterTopic")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(TopicTemplate) error); ok {
+		r0 = rf(tmpl)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]db.Subscriptions)
-		}
+		r0 = ret.Error(0)
 	}
 
-	if rf, ok := ret.Get(1).(func(int64) error); ok {
-		r1 = rf(chatId)
+	return r0
+}
+
+// MockPublisher_RegisterTopic_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RegisterTopic'
+type MockPublisher_RegisterTopic_Call struct {
+	*mock.Call
+}
+
+// RegisterTopic is a helper method to define mock.On call
+//   - tmpl TopicTemplate
+func (_e *MockPublisher_Expecter) RegisterTopic(tmpl interface{}) *MockPublisher_RegisterTopic_Call {
+	return &MockPublisher_RegisterTopic_Call{Call: _e.mock.On("RegisterTopic", tmpl)}
+}
+
+func (_c *MockPublisher_RegisterTopic_Call) Run(run func(tmpl TopicTemplate)) *MockPublisher_RegisterTopic_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(TopicTemplate))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_RegisterTopic_Call) Return(_a0 error) *MockPublisher_RegisterTopic_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPublisher_RegisterTopic_Call) RunAndReturn(run func(TopicTemplate) error) *MockPublisher_RegisterTopic_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RegisterTopicPrefix provides a mock function with given fields: prefix
+func (_m *MockPublisher) RegisterTopicPrefix(prefix string) error {
+	ret := _m.Called(prefix)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RegisterTopicPrefix")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(prefix)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
-// MockPublisher_GetSubscriptions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetSubscriptions'
-type MockPublisher_GetSubscriptions_Call struct {
+// MockPublisher_RegisterTopicPrefix_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RegisterTopicPrefix'
+type MockPublisher_RegisterTopicPrefix_Call struct {
 	*mock.Call
 }
 
-// GetSubscriptions is a helper method to define mock.On call
-//   - chatId int64
-func (_e *MockPublisher_Expecter) GetSubscriptions(chatId interface{}) *MockPublisher_GetSubscriptions_Call {
-	return &MockPublisher_GetSubscriptions_Call{Call: _e.mock.On("GetSubscriptions", chatId)}
+// RegisterTopicPrefix is a helper method to define mock.On call
+//   - prefix string
+func (_e *MockPublisher_Expecter) RegisterTopicPrefix(prefix interface{}) *MockPublisher_RegisterTopicPrefix_Call {
+	return &MockPublisher_RegisterTopicPrefix_Call{Call: _e.mock.On("RegisterTopicPrefix", prefix)}
 }
 
-func (_c *MockPublisher_GetSubscriptions_Call) Run(run func(chatId int64)) *MockPublisher_GetSubscriptions_Call {
+func (_c *MockPublisher_RegisterTopicPrefix_Call) Run(run func(prefix string)) *MockPublisher_RegisterTopicPrefix_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(int64))
+		run(args[0].(string))
 	})
 	return _c
 }
 
-func (_c *MockPublisher_GetSubscriptions_Call) Return(_a0 []db.Subscriptions, _a1 error) *MockPublisher_GetSubscriptions_Call {
-	_c.Call.Return(_a0, _a1)
+func (_c *MockPublisher_RegisterTopicPrefix_Call) Return(_a0 error) *MockPublisher_RegisterTopicPrefix_Call {
+	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *MockPublisher_GetSubscriptions_Call) RunAndReturn(run func(int64) ([]db.Subscriptions, error)) *MockPublisher_GetSubscriptions_Call {
+func (_c *MockPublisher_RegisterTopicPrefix_Call) RunAndReturn(run func(string) error) *MockPublisher_RegisterTopicPrefix_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReloadSubscriptions provides a mock function with given fields: ctx
+func (_m *MockPublisher) ReloadSubscriptions(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReloadSubscriptions")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockPublisher_ReloadSubscriptions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReloadSubscriptions'
+type MockPublisher_ReloadSubscriptions_Call struct {
+	*mock.Call
+}
+
+// ReloadSubscriptions is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockPublisher_Expecter) ReloadSubscriptions(ctx interface{}) *MockPublisher_ReloadSubscriptions_Call {
+	return &MockPublisher_ReloadSubscriptions_Call{Call: _e.mock.On("ReloadSubscriptions", ctx)}
+}
+
+func (_c *MockPublisher_ReloadSubscriptions_Call) Run(run func(ctx context.Context)) *MockPublisher_ReloadSubscriptions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_ReloadSubscriptions_Call) Return(_a0 error) *MockPublisher_ReloadSubscriptions_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPublisher_ReloadSubscriptions_Call) RunAndReturn(run func(context.Context) error) *MockPublisher_ReloadSubscriptions_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// Publish provides a mock function with given fields: msg
-func (_m *MockPublisher) Publish(msg Message) {
-	_m.Called(msg)
+// RetryDelivery provides a mock function with given fields: id
+func (_m *MockPublisher) RetryDelivery(id int) error {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetryDelivery")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
-// MockPublisher_Publish_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Publish'
-type MockPublisher_Publish_Call struct {
+// MockPublisher_RetryDelivery_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RetryDelivery'
+type MockPublisher_RetryDelivery_Call struct {
 	*mock.Call
 }
 
-// Publish is a helper method to define mock.On call
-//   - msg Message
-func (_e *MockPublisher_Expecter) Publish(msg interface{}) *MockPublisher_Publish_Call {
-	return &MockPublisher_Publish_Call{Call: _e.mock.On("Publish", msg)}
+// RetryDelivery is a helper method to define mock.On call
+//   - id int
+func (_e *MockPublisher_Expecter) RetryDelivery(id interface{}) *MockPublisher_RetryDelivery_Call {
+	return &MockPublisher_RetryDelivery_Call{Call: _e.mock.On("RetryDelivery", id)}
+}
+
+func (_c *MockPublisher_RetryDelivery_Call) Run(run func(id int)) *MockPublisher_RetryDelivery_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(int))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_RetryDelivery_Call) Return(_a0 error) *MockPublisher_RetryDelivery_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPublisher_RetryDelivery_Call) RunAndReturn(run func(int) error) *MockPublisher_RetryDelivery_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SetQuietHours provides a mock function with given fields: quietHours
+func (_m *MockPublisher) SetQuietHours(quietHours QuietHours) error {
+	ret := _m.Called(quietHours)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetQuietHours")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(QuietHours) error); ok {
+		r0 = rf(quietHours)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockPublisher_SetQuietHours_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetQuietHours'
+type MockPublisher_SetQuietHours_Call struct {
+	*mock.Call
+}
+
+// SetQuietHours is a helper method to define mock.On call
+//   - quietHours QuietHours
+func (_e *MockPublisher_Expecter) SetQuietHours(quietHours interface{}) *MockPublisher_SetQuietHours_Call {
+	return &MockPublisher_SetQuietHours_Call{Call: _e.mock.On("SetQuietHours", quietHours)}
 }
 
-func (_c *MockPublisher_Publish_Call) Run(run func(msg Message)) *MockPublisher_Publish_Call {
+func (_c *MockPublisher_SetQuietHours_Call) Run(run func(quietHours QuietHours)) *MockPublisher_SetQuietHours_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(Message))
+		run(args[0].(QuietHours))
 	})
 	return _c
 }
 
-func (_c *MockPublisher_Publish_Call) Return() *MockPublisher_Publish_Call {
-	_c.Call.Return()
+func (_c *MockPublisher_SetQuietHours_Call) Return(_a0 error) *MockPublisher_SetQuietHours_Call {
+	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *MockPublisher_Publish_Call) RunAndReturn(run func(Message)) *MockPublisher_Publish_Call {
+func (_c *MockPublisher_SetQuietHours_Call) RunAndReturn(run func(QuietHours) error) *MockPublisher_SetQuietHours_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -169,6 +932,53 @@ func (_c *MockPublisher_Subscribe_Call) RunAndReturn(run func(Subscriber) (strin
 	return _c
 }
 
+// UnmuteSubscription provides a mock function with given fields: subId, chatId
+func (_m *MockPublisher) UnmuteSubscription(subId uuid.UUID, chatId int64) error {
+	ret := _m.Called(subId, chatId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UnmuteSubscription")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uuid.UUID, int64) error); ok {
+		r0 = rf(subId, chatId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockPublisher_UnmuteSubscription_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UnmuteSubscription'
+type MockPublisher_UnmuteSubscription_Call struct {
+	*mock.Call
+}
+
+// UnmuteSubscription is a helper method to define mock.On call
+//   - subId uuid.UUID
+//   - chatId int64
+func (_e *MockPublisher_Expecter) UnmuteSubscription(subId interface{}, chatId interface{}) *MockPublisher_UnmuteSubscription_Call {
+	return &MockPublisher_UnmuteSubscription_Call{Call: _e.mock.On("UnmuteSubscription", subId, chatId)}
+}
+
+func (_c *MockPublisher_UnmuteSubscription_Call) Run(run func(subId uuid.UUID, chatId int64)) *MockPublisher_UnmuteSubscription_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uuid.UUID), args[1].(int64))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_UnmuteSubscription_Call) Return(_a0 error) *MockPublisher_UnmuteSubscription_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPublisher_UnmuteSubscription_Call) RunAndReturn(run func(uuid.UUID, int64) error) *MockPublisher_UnmuteSubscription_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Unsubscribe provides a mock function with given fields: topicId, chatId
 func (_m *MockPublisher) Unsubscribe(topicId uuid.UUID, chatId int64) error {
 	ret := _m.Called(topicId, chatId)
@@ -262,6 +1072,52 @@ func (_c *MockPublisher_UnsubscribeAll_Call) RunAndReturn(run func(int64) error)
 	return _c
 }
 
+// ValidateTopicPattern provides a mock function with given fields: pattern
+func (_m *MockPublisher) ValidateTopicPattern(pattern string) error {
+	ret := _m.Called(pattern)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ValidateTopicPattern")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(pattern)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockPublisher_ValidateTopicPattern_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ValidateTopicPattern'
+type MockPublisher_ValidateTopicPattern_Call struct {
+	*mock.Call
+}
+
+// ValidateTopicPattern is a helper method to define mock.On call
+//   - pattern string
+func (_e *MockPublisher_Expecter) ValidateTopicPattern(pattern interface{}) *MockPublisher_ValidateTopicPattern_Call {
+	return &MockPublisher_ValidateTopicPattern_Call{Call: _e.mock.On("ValidateTopicPattern", pattern)}
+}
+
+func (_c *MockPublisher_ValidateTopicPattern_Call) Run(run func(pattern string)) *MockPublisher_ValidateTopicPattern_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *MockPublisher_ValidateTopicPattern_Call) Return(_a0 error) *MockPublisher_ValidateTopicPattern_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPublisher_ValidateTopicPattern_Call) RunAndReturn(run func(string) error) *MockPublisher_ValidateTopicPattern_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockPublisher creates a new instance of MockPublisher. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockPublisher(t interface {
@@ -41,7 +41,7 @@ func (t *TestNotificationSuite) Test_NotificationPublisher_Subscribe() {
 	publisher := NewNotificationPublisher(nil, t.dbConn)
 	require.NotNil(t.T(), publisher)
 
-	require.Zero(t.T(), len(publisher.subscribers))
+	require.Empty(t.T(), publisher.subs.All())
 	count, err := t.dbConn.NewSelect().Model(&db.Subscriptions{}).
 		Count(context.Background())
 	require.NoError(t.T(), err)
@@ -53,7 +53,7 @@ func (t *TestNotificationSuite) Test_NotificationPublisher_Subscribe() {
 	})
 	require.NoError(t.T(), err)
 
-	require.Equal(t.T(), 1, len(publisher.subscribers))
+	require.Len(t.T(), publisher.subs.All(), 1)
 
 	checkCount, err := t.dbConn.NewSelect().Model(&db.Subscriptions{}).
 		Count(context.Background())
@@ -85,11 +85,15 @@ func (t *TestNotificationSuite) Test_NotificationPublisher_updateSubsFromDb() {
 	require.NoError(t.T(), err)
 	require.Equal(t.T(), 2, count)
 
-	err = publisher.updateSubsFromDb()
+	err = publisher.ReloadSubscriptions(context.Background())
 	require.NoError(t.T(), err)
 
-	require.Equal(t.T(), 2, len(publisher.subscribers))
-
+	require.Len(t.T(), publisher.subs.All(), 2)
+	for _, subscription := range subscriptions {
+		sub, ok := publisher.subs.Get(subscription.ID)
+		require.True(t.T(), ok)
+		require.Equal(t.T(), subscription.ChatID, sub.ChatId)
+	}
 }
 
 func (t *TestNotificationSuite) Test_NotificationPublisher_Subscribe_Conflict() {
@@ -120,16 +124,15 @@ func (t *TestNotificationSuite) Test_NotificationPublisher_Unsubscribe() {
 	}
 
 	// Subscribe first
-	publisher.subscribers = append(publisher.subscribers, checkSub)
-
 	_, err := t.dbConn.NewInsert().Model(checkSub.DbModel()).Exec(context.Background())
 	require.NoError(t.T(), err)
+	require.NoError(t.T(), publisher.ReloadSubscriptions(context.Background()))
 
 	// Now lets unsubscribe
 	err = publisher.Unsubscribe(checkSub.ID, checkSub.ChatId)
 	require.NoError(t.T(), err)
 
-	require.Zero(t.T(), len(publisher.subscribers))
+	require.Empty(t.T(), publisher.subs.All())
 
 	checkCount, err := t.dbConn.NewSelect().Model(&db.Subscriptions{}).
 		Count(context.Background())
@@ -137,6 +140,109 @@ func (t *TestNotificationSuite) Test_NotificationPublisher_Unsubscribe() {
 	require.Zero(t.T(), checkCount)
 }
 
+func (t *TestNotificationSuite) Test_NotificationPublisher_Unsubscribe_AfterRestart() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	subId, err := publisher.Subscribe(Subscriber{TopicPattern: "test.alert", ChatId: 12345})
+	require.NoError(t.T(), err)
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "test.*", ChatId: 12345})
+	require.NoError(t.T(), err)
+
+	restarted := NewNotificationPublisher(nil, t.dbConn)
+	require.NoError(t.T(), restarted.Unsubscribe(uuid.MustParse(subId), 12345))
+
+	require.Len(t.T(), restarted.subs.All(), 1)
+	_, ok := restarted.subs.Get(uuid.MustParse(subId))
+	require.False(t.T(), ok)
+
+	subs, err := restarted.GetSubscriptions(12345)
+	require.NoError(t.T(), err)
+	require.Len(t.T(), subs, 1)
+	require.Equal(t.T(), "test.*", subs[0].TopicPattern)
+
+	require.NoError(t.T(), restarted.UnsubscribeAll(12345))
+	chatIds := matchedChatIds(NewNotificationPublisher(nil, t.dbConn), "test.alert", PriorityInfo)
+	require.Empty(t.T(), chatIds)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Unsubscribe_WrongChat() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	subId, err := publisher.Subscribe(Subscriber{TopicPattern: "test.alert", ChatId: 12345})
+	require.NoError(t.T(), err)
+
+	restarted := NewNotificationPublisher(nil, t.dbConn)
+	require.NoError(t.T(), restarted.Unsubscribe(uuid.MustParse(subId), 54321))
+
+	chatIds := matchedChatIds(restarted, "test.alert", PriorityInfo)
+	require.Equal(t.T(), []int64{12345}, chatIds)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_ReloadSubscriptions() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	chatIds := matchedChatIds(publisher, "test.alert", PriorityInfo)
+	require.Empty(t.T(), chatIds)
+
+	// written by another process, e.g. a second instance or a manual fix
+	subId := uuid.New()
+	_, err := t.dbConn.NewInsert().Model(&db.Subscriptions{ID: subId, ChatID: 12345, TopicPattern: "test.*"}).
+		Exec(context.Background())
+	require.NoError(t.T(), err)
+
+	require.NoError(t.T(), publisher.ReloadSubscriptions(context.Background()))
+	chatIds = matchedChatIds(publisher, "test.alert", PriorityInfo)
+	require.Equal(t.T(), []int64{12345}, chatIds)
+
+	// digests and unsubscribing find subscriptions by their ID, so it has to survive the reload
+	sub, ok := publisher.subs.Get(subId)
+	require.True(t.T(), ok)
+	require.Equal(t.T(), subId, sub.ID)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_matchSubscribers_Priority() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+	require.NotNil(t.T(), publisher)
+
+	_, err := publisher.Subscribe(Subscriber{
+		TopicPattern: "test.alert",
+		ChatId:       12345,
+	})
+	require.NoError(t.T(), err)
+
+	_, err = publisher.Subscribe(Subscriber{
+		TopicPattern: "test.*",
+		ChatId:       54321,
+		MinPriority:  PriorityWarning,
+	})
+	require.NoError(t.T(), err)
+
+	chatIds := matchedChatIds(publisher, "test.alert", PriorityInfo)
+	require.ElementsMatch(t.T(), []int64{12345}, chatIds)
+
+	chatIds = matchedChatIds(publisher, "test.alert", PriorityCritical)
+	require.ElementsMatch(t.T(), []int64{12345, 54321}, chatIds)
+}
+
+// matchedChatIds returns the chats a message on the topic at the priority would be delivered to
+func matchedChatIds(publisher *NotificationPublisher, topic string, priority Priority) []int64 {
+	chatIds := make([]int64, 0)
+	for _, subscriber := range publisher.matchSubscribers(topic, priority, nil) {
+		chatIds = append(chatIds, subscriber.ChatId)
+	}
+
+	return chatIds
+}
+
 func Test_RunNotificationSuite(t *testing.T) {
 	suite.Run(t, new(TestNotificationSuite))
 }
+
+func TestParsePriority(t *testing.T) {
+	priority, err := ParsePriority("WARNING")
+	require.NoError(t, err)
+	require.Equal(t, PriorityWarning, priority)
+
+	_, err = ParsePriority("loud")
+	require.Error(t, err)
+}
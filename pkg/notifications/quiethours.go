@@ -0,0 +1,320 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"github.com/uptrace/bun"
+	"strings"
+	"time"
+)
+
+const (
+	quietHoursTimeFmt   = "15:04"
+	defaultQuietHoursTZ = "America/New_York"
+)
+
+// QuietHours is a daily window in the chat's timezone where non-critical messages are held back and delivered
+// as a single batch when the window ends.
+type QuietHours struct {
+	ChatId   int64
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// NewQuietHours parses a HH:MM start and end time in the given timezone. An empty timezone defaults to America/New_York.
+func NewQuietHours(chatId int64, start, end, tz string) (QuietHours, error) {
+	if tz == "" {
+		tz = defaultQuietHoursTZ
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return QuietHours{}, fmt.Errorf("failed to load timezone: %w", err)
+	}
+
+	startOffset, err := parseTimeOfDay(start)
+	if err != nil {
+		return QuietHours{}, fmt.Errorf("invalid start time: %w", err)
+	}
+
+	endOffset, err := parseTimeOfDay(end)
+	if err != nil {
+		return QuietHours{}, fmt.Errorf("invalid end time: %w", err)
+	}
+
+	if startOffset == endOffset {
+		return QuietHours{}, fmt.Errorf("start and end time must differ")
+	}
+
+	return QuietHours{
+		ChatId:   chatId,
+		Start:    startOffset,
+		End:      endOffset,
+		Location: loc,
+	}, nil
+}
+
+func parseTimeOfDay(raw string) (time.Duration, error) {
+	t, err := time.Parse(quietHoursTimeFmt, raw)
+	if err != nil {
+		return 0, fmt.Errorf("time must be in HH:MM format")
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func formatTimeOfDay(d time.Duration) string {
+	return time.Time{}.Add(d).Format(quietHoursTimeFmt)
+}
+
+// Contains returns true if the given time falls inside the quiet window. Windows may wrap past midnight.
+func (q QuietHours) Contains(t time.Time) bool {
+	local := t.In(q.Location)
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+
+	return offset >= q.Start || offset < q.End
+}
+
+func (q QuietHours) String() string {
+	return fmt.Sprintf("%s - %s %s", formatTimeOfDay(q.Start), formatTimeOfDay(q.End), q.Location)
+}
+
+func (q QuietHours) DbModel() *dbmodels.NotificationsQuietHours {
+	return &dbmodels.NotificationsQuietHours{
+		ChatID: q.ChatId,
+		Start:  formatTimeOfDay(q.Start),
+		End:    formatTimeOfDay(q.End),
+		TZ:     q.Location.String(),
+	}
+}
+
+func (n *NotificationPublisher) SetQuietHours(quietHours QuietHours) error {
+	n.quietlck.Lock()
+	defer n.quietlck.Unlock()
+
+	_, err := n.dbConn.NewInsert().Model(quietHours.DbModel()).
+		On("CONFLICT(chat_id) DO UPDATE").
+		Set("start_time = EXCLUDED.start_time").
+		Set("end_time = EXCLUDED.end_time").
+		Set("tz = EXCLUDED.tz").
+		Exec(context.TODO())
+	if err != nil {
+		return fmt.Errorf("failed to save quiet hours: %w", err)
+	}
+
+	n.quietHours[quietHours.ChatId] = quietHours
+
+	return nil
+}
+
+func (n *NotificationPublisher) ClearQuietHours(chatId int64) error {
+	n.quietlck.Lock()
+	defer n.quietlck.Unlock()
+
+	_, err := n.dbConn.NewDelete().Model((*dbmodels.NotificationsQuietHours)(nil)).
+		Where("chat_id = ?", chatId).
+		Exec(context.TODO())
+	if err != nil {
+		return fmt.Errorf("failed to delete quiet hours: %w", err)
+	}
+
+	delete(n.quietHours, chatId)
+
+	return nil
+}
+
+func (n *NotificationPublisher) GetQuietHours(chatId int64) (QuietHours, bool) {
+	n.quietlck.RLock()
+	defer n.quietlck.RUnlock()
+
+	quietHours, ok := n.quietHours[chatId]
+	return quietHours, ok
+}
+
+func (n *NotificationPublisher) updateQuietHoursFromDb() error {
+	dbQuietHours := make([]dbmodels.NotificationsQuietHours, 0)
+	err := n.dbConn.NewSelect().Model(&dbQuietHours).Scan(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get quiet hours: %w", err)
+	}
+
+	for _, dbQuiet := range dbQuietHours {
+		quietHours, err := NewQuietHours(dbQuiet.ChatID, dbQuiet.Start, dbQuiet.End, dbQuiet.TZ)
+		if err != nil {
+			n.logger.Error().Err(err).Msgf("Ignoring invalid quiet hours for chat %d", dbQuiet.ChatID)
+			continue
+		}
+
+		n.quietHours[dbQuiet.ChatID] = quietHours
+	}
+
+	return nil
+}
+
+// chatLocation returns the chat's quiet hours timezone, the quiet hours default of America/New_York if the chat has
+// no quiet hours
+func (n *NotificationPublisher) chatLocation(chatId int64) *time.Location {
+	if quietHours, ok := n.GetQuietHours(chatId); ok {
+		return quietHours.Location
+	}
+
+	loc, err := time.LoadLocation(defaultQuietHoursTZ)
+	if err != nil {
+		n.logger.Error().Err(err).Msg("failed to load the default timezone, using UTC")
+		return time.UTC
+	}
+
+	return loc
+}
+
+// isQuiet returns true if messages of the given priority should be deferred for the chat right now
+func (n *NotificationPublisher) isQuiet(chatId int64, priority Priority, now time.Time) bool {
+	if priority >= PriorityCritical {
+		return false
+	}
+
+	quietHours, ok := n.GetQuietHours(chatId)
+	if !ok {
+		return false
+	}
+
+	return quietHours.Contains(now)
+}
+
+func (n *NotificationPublisher) deferMessage(ctx context.Context, chatId int64, dupKey string, msg Message) error {
+	n.logger.Trace().Msgf("Deferring message for chat %d during quiet hours", chatId)
+
+	dbDeferred := &dbmodels.NotificationsDeferred{
+		ChatID:     chatId,
+		Topic:      msg.Topic,
+		Message:    msg.PlainText(),
+		DupeKey:    dupKey,
+		DupeTTLEnd: time.Now().Add(msg.DupeTTL),
+		Priority:   int(msg.Priority),
+	}
+
+	_, err := n.dbConn.NewInsert().Model(dbDeferred).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to insert deferred message: %w", err)
+	}
+
+	return nil
+}
+
+// flushDeferred delivers the held messages of every chat whose quiet hours have ended
+func (n *NotificationPublisher) flushDeferred(ctx context.Context) {
+	chatIds := make([]int64, 0)
+	err := n.dbConn.NewSelect().Model((*dbmodels.NotificationsDeferred)(nil)).
+		Column("chat_id").
+		Distinct().
+		Scan(ctx, &chatIds)
+	if err != nil {
+		n.logger.Error().Err(err).Msg("failed to get chats with deferred messages")
+		return
+	}
+
+	now := time.Now()
+	for _, chatId := range chatIds {
+		if n.isQuiet(chatId, PriorityDebug, now) {
+			continue
+		}
+
+		if err := n.flushDeferredForChat(ctx, chatId); err != nil {
+			n.logger.Error().Err(err).Msgf("failed to flush deferred messages for chat %d", chatId)
+		}
+	}
+}
+
+func (n *NotificationPublisher) flushDeferredForChat(ctx context.Context, chatId int64) error {
+	deferred := make([]dbmodels.NotificationsDeferred, 0)
+	err := n.dbConn.NewSelect().Model(&deferred).
+		Where("chat_id = ?", chatId).
+		Order("created_at ASC", "id ASC").
+		Scan(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get deferred messages: %w", err)
+	}
+
+	if len(deferred) == 0 {
+		return nil
+	}
+
+	n.logger.Debug().Msgf("Delivering %d deferred messages to chat %d", len(deferred), chatId)
+	ids := make([]int, 0, len(deferred))
+	for _, msg := range deferred {
+		ids = append(ids, msg.ID)
+	}
+
+	// each flush gets its own dupe key, the last deferred message's id, so one flush can't pass for another
+	target := telegramTarget(chatId)
+	dupKey := fmt.Sprintf("%d-deferred-%d", chatId, ids[len(ids)-1])
+	batch := make([]Message, 0)
+	for _, chunk := range util.SplitMessage(batchDeferredMessages(deferred), util.TelegramMaxMessageLength) {
+		batch = append(batch, Message{
+			Topic:    deferred[0].Topic,
+			Msg:      chunk,
+			Priority: PriorityInfo,
+		})
+	}
+
+	// the batch is queued and the deferred messages deleted together, so a failed delete can't send the batch twice
+	outboxIds := make([]int, 0, len(batch))
+	err = n.dbConn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, batchMsg := range batch {
+			outboxId, err := insertDelivery(ctx, tx, target, dupKey, batchMsg)
+			if err != nil {
+				return fmt.Errorf("failed to queue deferred batch: %w", err)
+			}
+			outboxIds = append(outboxIds, outboxId)
+		}
+
+		_, err := tx.NewDelete().Model((*dbmodels.NotificationsDeferred)(nil)).
+			Where("id IN (?)", bun.In(ids)).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete deferred messages: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, batchMsg := range batch {
+		n.recordHistory(ctx, target, batchMsg, HistoryStatusQueued, "", outboxIds[i])
+	}
+	n.wakeDispatcher()
+
+	return nil
+}
+
+// batchDeferredMessages joins the deferred messages into a single message, collapsing repeats of the same dupe key
+// into the most recent one
+func batchDeferredMessages(deferred []dbmodels.NotificationsDeferred) string {
+	latest := make(map[string]int, len(deferred))
+	for i, msg := range deferred {
+		latest[msg.DupeKey] = i
+	}
+
+	outMsg := strings.Builder{}
+	outMsg.WriteString("🌙 Messages held during quiet hours:\n")
+	for i, msg := range deferred {
+		if latest[msg.DupeKey] != i {
+			continue
+		}
+
+		outMsg.WriteString("\n")
+		outMsg.WriteString(msg.Message)
+		outMsg.WriteString("\n")
+	}
+
+	return outMsg.String()
+}
@@ -0,0 +1,120 @@
+package notifications
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuietHours_Contains(t *testing.T) {
+	overnight, err := NewQuietHours(12345, "22:00", "07:00", "UTC")
+	require.NoError(t, err)
+
+	require.True(t, overnight.Contains(time.Date(2024, 7, 1, 23, 30, 0, 0, time.UTC)))
+	require.True(t, overnight.Contains(time.Date(2024, 7, 1, 3, 0, 0, 0, time.UTC)))
+	require.False(t, overnight.Contains(time.Date(2024, 7, 1, 7, 0, 0, 0, time.UTC)))
+	require.False(t, overnight.Contains(time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)))
+
+	afternoon, err := NewQuietHours(12345, "13:00", "15:00", "America/New_York")
+	require.NoError(t, err)
+
+	require.True(t, afternoon.Contains(time.Date(2024, 7, 1, 18, 0, 0, 0, time.UTC)))
+	require.False(t, afternoon.Contains(time.Date(2024, 7, 1, 14, 0, 0, 0, time.UTC)))
+
+	_, err = NewQuietHours(12345, "25:00", "07:00", "UTC")
+	require.Error(t, err)
+
+	_, err = NewQuietHours(12345, "07:00", "07:00", "UTC")
+	require.Error(t, err)
+}
+
+func TestBatchDeferredMessages(t *testing.T) {
+	batch := batchDeferredMessages([]dbmodels.NotificationsDeferred{
+		{DupeKey: "a", Message: "first alert"},
+		{DupeKey: "b", Message: "birthday"},
+		{DupeKey: "a", Message: "updated alert"},
+	})
+
+	require.NotContains(t, batch, "first alert")
+	require.Contains(t, batch, "birthday")
+	require.Contains(t, batch, "updated alert")
+	require.Less(t, strings.Index(batch, "birthday"), strings.Index(batch, "updated alert"))
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_handleBusMessage_QuietHours() {
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+	require.NotNil(t.T(), publisher)
+
+	_, err := publisher.Subscribe(Subscriber{
+		TopicPattern: "test.alert",
+		ChatId:       12345,
+	})
+	require.NoError(t.T(), err)
+
+	now := time.Now().UTC()
+	quietHours, err := NewQuietHours(12345, now.Add(-time.Hour).Format("15:04"), now.Add(time.Hour).Format("15:04"), "UTC")
+	require.NoError(t.T(), err)
+	require.NoError(t.T(), publisher.SetQuietHours(quietHours))
+
+	err = publisher.handleBusMessage(context.Background(), Message{
+		Topic:    "test.alert",
+		Msg:      "quiet please",
+		Priority: PriorityWarning,
+	})
+	require.NoError(t.T(), err)
+
+	var deferred []dbmodels.NotificationsDeferred
+	err = t.dbConn.NewSelect().Model(&deferred).Scan(context.Background())
+	require.NoError(t.T(), err)
+	require.Len(t.T(), deferred, 1)
+	require.Equal(t.T(), "quiet please", deferred[0].Message)
+
+	// Quiet hours are reloaded from the db
+	reloaded := NewNotificationPublisher(nil, t.dbConn)
+	_, ok := reloaded.GetQuietHours(12345)
+	require.True(t.T(), ok)
+
+	require.NoError(t.T(), publisher.ClearQuietHours(12345))
+	_, ok = publisher.GetQuietHours(12345)
+	require.False(t.T(), ok)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_flushDeferredForChat() {
+	ctx := context.Background()
+	publisher := NewNotificationPublisher(nil, t.dbConn)
+
+	deferMessage := func(text string) {
+		require.NoError(t.T(), publisher.deferMessage(ctx, 12345, text, Message{Topic: "test.alert", Msg: text, DupeTTL: time.Hour}))
+	}
+	countDeferred := func() int {
+		count, err := t.dbConn.NewSelect().Model((*dbmodels.NotificationsDeferred)(nil)).Count(ctx)
+		require.NoError(t.T(), err)
+		return count
+	}
+
+	// the batch can't be queued, so the deferred messages are kept for the next flush
+	deferMessage("first")
+	_, err := t.dbConn.NewDropTable().Model((*dbmodels.NotificationsOutbox)(nil)).Exec(ctx)
+	require.NoError(t.T(), err)
+	require.Error(t.T(), publisher.flushDeferredForChat(ctx, 12345))
+	require.Equal(t.T(), 1, countDeferred())
+
+	_, err = t.dbConn.NewCreateTable().Model((*dbmodels.NotificationsOutbox)(nil)).Exec(ctx)
+	require.NoError(t.T(), err)
+	require.NoError(t.T(), publisher.flushDeferredForChat(ctx, 12345))
+	require.Zero(t.T(), countDeferred())
+
+	deferMessage("second")
+	require.NoError(t.T(), publisher.flushDeferredForChat(ctx, 12345))
+
+	// every flush is queued under its own dupe key
+	var rows []dbmodels.NotificationsOutbox
+	require.NoError(t.T(), t.dbConn.NewSelect().Model(&rows).Order("id ASC").Scan(ctx))
+	require.Len(t.T(), rows, 2)
+	require.Contains(t.T(), rows[0].Message, "first")
+	require.Contains(t.T(), rows[1].Message, "second")
+	require.NotEqual(t.T(), rows[0].DupeKey, rows[1].DupeKey)
+}
@@ -0,0 +1,187 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// SinkTelegram is the built in sink delivering to the subscriber's telegram chat
+const SinkTelegram = "telegram"
+
+var ErrUnknownSink = errors.New("unknown sink")
+
+// Sink delivers notifications somewhere other than a telegram chat, such as a webhook or an email address
+type Sink interface {
+	// Name is what subscriptions use to pick the sink, e.g. webhook or smtp
+	Name() string
+	// ValidateDestination checks the destination is something the sink can deliver to
+	ValidateDestination(destination string) error
+	Send(ctx context.Context, delivery Delivery) error
+}
+
+// Delivery is a message on its way to a sink destination. Sinks don't understand telegram formatting, so the text
+// is plain and buttons are passed along as links.
+type Delivery struct {
+	ID          int
+	Destination string
+	Topic       string
+	Text        string
+	Priority    Priority
+	Links       []Button
+	Attachment  *Attachment
+	EventID     string
+	Action      EventAction
+	CreatedAt   time.Time
+}
+
+// Target is where a subscription delivers to, the subscriber's chat or a destination of one of the sinks
+type Target struct {
+	ChatId      int64
+	Sink        string
+	Destination string
+}
+
+func telegramTarget(chatId int64) Target {
+	return Target{ChatId: chatId}
+}
+
+func outboxTarget(row dbmodels.NotificationsOutbox) Target {
+	return Target{ChatId: row.ChatID, Sink: row.Sink, Destination: row.Destination}
+}
+
+func (t Target) IsTelegram() bool {
+	return t.Sink == "" || t.Sink == SinkTelegram
+}
+
+// key identifies the target in dupe keys and when grouping deliveries. Telegram targets are keyed by the bare chat
+// id so dupe keys written before sinks existed still match.
+func (t Target) key() string {
+	if t.IsTelegram() {
+		return strconv.FormatInt(t.ChatId, 10)
+	}
+
+	return t.Sink + ":" + t.Destination
+}
+
+func (t Target) String() string {
+	if t.IsTelegram() {
+		return fmt.Sprintf("chat %d", t.ChatId)
+	}
+
+	return fmt.Sprintf("%s %s", t.Sink, t.Destination)
+}
+
+// retryPolicy is how often and how far apart failed deliveries are retried
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// delay is the exponential backoff for the given attempt, capped at the max delay
+func (r retryPolicy) delay(attempt int) time.Duration {
+	delay := r.baseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= r.maxDelay {
+			return r.maxDelay
+		}
+	}
+
+	return delay
+}
+
+// retryPolicyFor returns the sink's own retry policy, with anything it leaves unset taken from the publisher's
+func (n *NotificationPublisher) retryPolicyFor(sink string) retryPolicy {
+	policy := retryPolicy{
+		maxAttempts: n.maxDeliveryAttempts,
+		baseDelay:   n.retryBaseDelay,
+		maxDelay:    n.retryMaxDelay,
+	}
+
+	override := n.sinkRetry[sink]
+	if override.maxAttempts > 0 {
+		policy.maxAttempts = override.maxAttempts
+	}
+	if override.baseDelay > 0 {
+		policy.baseDelay = override.baseDelay
+	}
+	if override.maxDelay > 0 {
+		policy.maxDelay = override.maxDelay
+	}
+
+	return policy
+}
+
+// validateTarget checks the subscription's sink is registered and can deliver to its destination
+func (n *NotificationPublisher) validateTarget(sub Subscriber) error {
+	target := sub.Target()
+	if target.IsTelegram() {
+		if target.Destination != "" {
+			return fmt.Errorf("telegram subscriptions deliver to their own chat and take no destination")
+		}
+		return nil
+	}
+
+	sink, ok := n.sinks[target.Sink]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSink, target.Sink)
+	}
+
+	if !sub.Digest.IsZero() {
+		return fmt.Errorf("digests can only be delivered to telegram")
+	}
+
+	if err := sink.ValidateDestination(target.Destination); err != nil {
+		return fmt.Errorf("invalid %s destination: %w", target.Sink, err)
+	}
+
+	return nil
+}
+
+// GetSinks returns the names of the sinks subscriptions can deliver to besides telegram
+func (n *NotificationPublisher) GetSinks() []string {
+	names := make([]string, 0, len(n.sinks))
+	for name := range n.sinks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// deliverToSink hands the outbox row to the sink named in it
+func (n *NotificationPublisher) deliverToSink(ctx context.Context, row dbmodels.NotificationsOutbox, opts messageOptions) error {
+	sink, ok := n.sinks[row.Sink]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSink, row.Sink)
+	}
+
+	text := Message{Msg: row.Message, ParseMode: opts.ParseMode}.PlainText()
+	if text == "" && opts.Action == EventActionCancel {
+		text = defaultCancelNote
+	}
+
+	links := make([]Button, 0)
+	for _, buttonRow := range opts.Buttons {
+		links = append(links, buttonRow...)
+	}
+
+	return sink.Send(ctx, Delivery{
+		ID:          row.ID,
+		Destination: row.Destination,
+		Topic:       row.Topic,
+		Text:        text,
+		Priority:    Priority(row.Priority),
+		Links:       links,
+		Attachment:  opts.Attachment,
+		EventID:     opts.EventID,
+		Action:      opts.Action,
+		CreatedAt:   row.CreatedAt,
+	})
+}
@@ -0,0 +1,130 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/bot/proxy"
+	"time"
+)
+
+func newMockWebhookSink(t *TestNotificationSuite) *MockSink {
+	sink := NewMockSink(t.T())
+	sink.EXPECT().Name().Return("webhook").Maybe()
+	sink.EXPECT().ValidateDestination(mock.Anything).RunAndReturn(func(destination string) error {
+		if destination == "not a url" {
+			return errors.New("not a url")
+		}
+		return nil
+	}).Maybe()
+
+	return sink
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Subscribe_Sink() {
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithSink(newMockWebhookSink(t)))
+
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "test.alert", ChatId: 1, Sink: "smtp", Destination: "a@example.com"})
+	require.ErrorIs(t.T(), err, ErrUnknownSink)
+
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "test.alert", ChatId: 1, Sink: "webhook", Destination: "not a url"})
+	require.ErrorContains(t.T(), err, "invalid webhook destination")
+
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "test.alert", ChatId: 1, Sink: "webhook",
+		Destination: "https://example.com/hook", Digest: DigestSchedule{Interval: time.Hour}})
+	require.ErrorContains(t.T(), err, "digests can only be delivered to telegram")
+
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "test.alert", ChatId: 1, Sink: "webhook", Destination: "https://example.com/hook"})
+	require.NoError(t.T(), err)
+	require.Equal(t.T(), []string{"webhook"}, publisher.GetSinks())
+
+	// the sink survives a restart
+	restarted := NewNotificationPublisher(nil, t.dbConn)
+	subs := restarted.subs.All()
+	require.Len(t.T(), subs, 1)
+	require.Equal(t.T(), Target{ChatId: 1, Sink: "webhook", Destination: "https://example.com/hook"}, subs[0].Target())
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Subscribe_SinkAndTelegram() {
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithSink(newMockWebhookSink(t)))
+
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "weather.*", ChatId: 1})
+	require.NoError(t.T(), err)
+
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "weather.*", ChatId: 1, Sink: "webhook", Destination: "https://example.com/hook"})
+	require.NoError(t.T(), err)
+
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "weather.*", ChatId: 1, Sink: "webhook", Destination: "https://example.com/other"})
+	require.NoError(t.T(), err)
+
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "weather.*", ChatId: 1, Sink: "webhook", Destination: "https://example.com/hook"})
+	require.ErrorIs(t.T(), err, ErrSubExists)
+
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "weather.*", ChatId: 1})
+	require.ErrorIs(t.T(), err, ErrSubExists)
+
+	require.Len(t.T(), publisher.subs.All(), 3)
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Sink_Delivered() {
+	mockBot := proxy.NewMockTGBotSendable(t.T())
+	mockBot.EXPECT().Send(mock.MatchedBy(func(c tgbotapi.MessageConfig) bool {
+		return c.ChatID == 1 && c.Text == "<b>disk</b> full"
+	})).Return(tgbotapi.Message{}, nil).Once()
+
+	sink := newMockWebhookSink(t)
+	sink.EXPECT().Send(mock.Anything, mock.MatchedBy(func(d Delivery) bool {
+		return d.Destination == "https://example.com/hook" && d.Topic == "test.alert" && d.Text == "disk full" &&
+			d.Priority == PriorityWarning && len(d.Links) == 1 && d.Links[0].URL == "https://example.com/runbook"
+	})).Return(nil).Once()
+
+	publisher := NewNotificationPublisher(mockBot, t.dbConn, WithSink(sink))
+	_, err := publisher.Subscribe(Subscriber{TopicPattern: "test.alert", ChatId: 1})
+	require.NoError(t.T(), err)
+	_, err = publisher.Subscribe(Subscriber{TopicPattern: "test.*", ChatId: 1, Sink: "webhook", Destination: "https://example.com/hook"})
+	require.NoError(t.T(), err)
+
+	msg := Message{
+		Topic:     "test.alert",
+		Msg:       "<b>disk</b> full",
+		ParseMode: tgbotapi.ModeHTML,
+		Priority:  PriorityWarning,
+		Buttons:   [][]Button{{{Text: "Runbook", URL: "https://example.com/runbook"}}},
+	}
+	require.NoError(t.T(), publisher.handleBusMessage(context.Background(), msg))
+	// the same message again is a duplicate for both the chat and the webhook
+	require.NoError(t.T(), publisher.handleBusMessage(context.Background(), msg))
+
+	outbox := t.getOutbox()
+	require.Len(t.T(), outbox, 2)
+	require.NotEqual(t.T(), outbox[0].DupeKey, outbox[1].DupeKey)
+
+	publisher.dispatchOutbox(context.Background())
+
+	for _, row := range t.getOutbox() {
+		require.Equal(t.T(), OutboxStatusDelivered, row.Status)
+	}
+}
+
+func (t *TestNotificationSuite) Test_NotificationPublisher_Sink_RetryPolicy() {
+	sink := newMockWebhookSink(t)
+	sink.EXPECT().Send(mock.Anything, mock.Anything).Return(errors.New("502 Bad Gateway")).Once()
+
+	publisher := NewNotificationPublisher(nil, t.dbConn, WithSink(sink), WithSinkRetryPolicy("webhook", 1, 0, 0))
+	target := Target{ChatId: 1, Sink: "webhook", Destination: "https://example.com/hook"}
+	require.NoError(t.T(), publisher.enqueueDelivery(context.Background(), target, "key", Message{Msg: "hello"}))
+
+	publisher.dispatchOutbox(context.Background())
+
+	outbox := t.getOutbox()
+	require.Equal(t.T(), OutboxStatusFailed, outbox[0].Status)
+	require.Equal(t.T(), "502 Bad Gateway", outbox[0].LastError)
+
+	// the unset delays fall back to the publisher's
+	policy := publisher.retryPolicyFor("webhook")
+	require.Equal(t.T(), 1, policy.maxAttempts)
+	require.Equal(t.T(), defaultRetryBaseDelay, policy.baseDelay)
+	require.Equal(t.T(), defaultMaxDeliveryAttempt, publisher.retryPolicyFor(SinkTelegram).maxAttempts)
+}
@@ -0,0 +1,80 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"net/http"
+	"strings"
+)
+
+const (
+	slackMaxTextLength   = 40000
+	discordMaxTextLength = 2000
+)
+
+// ChatWebhookSink posts notifications to a Slack or Discord compatible incoming webhook url. The two only differ in
+// the json field holding the text and how long it may be.
+type ChatWebhookSink struct {
+	name      string
+	textField string
+	maxLength int
+	client    *http.Client
+}
+
+var _ notifications.Sink = &ChatWebhookSink{}
+
+func NewSlackSink(cfg config.ChatWebhookSink) *ChatWebhookSink {
+	return &ChatWebhookSink{
+		name:      "slack",
+		textField: "text",
+		maxLength: slackMaxTextLength,
+		client:    &http.Client{Timeout: positiveOr(cfg.Timeout, defaultTimeout)},
+	}
+}
+
+func NewDiscordSink(cfg config.ChatWebhookSink) *ChatWebhookSink {
+	return &ChatWebhookSink{
+		name:      "discord",
+		textField: "content",
+		maxLength: discordMaxTextLength,
+		client:    &http.Client{Timeout: positiveOr(cfg.Timeout, defaultTimeout)},
+	}
+}
+
+func (c *ChatWebhookSink) Name() string {
+	return c.name
+}
+
+func (c *ChatWebhookSink) ValidateDestination(destination string) error {
+	return validateURL(destination, "https")
+}
+
+func (c *ChatWebhookSink) Send(ctx context.Context, delivery notifications.Delivery) error {
+	text := util.TruncateString(formatDelivery(delivery), c.maxLength-3, "...")
+	body, err := json.Marshal(map[string]string{c.textField: text})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s payload: %w", c.name, err)
+	}
+
+	return postJSON(ctx, c.client, delivery.Destination, body, nil)
+}
+
+// formatDelivery is the plain text version of a delivery used by the chat webhooks and email, with the topic on top
+// and any links below the text
+func formatDelivery(delivery notifications.Delivery) string {
+	outStr := strings.Builder{}
+	outStr.WriteString(fmt.Sprintf("[%s] %s\n", delivery.Priority, delivery.Topic))
+	outStr.WriteString(delivery.Text)
+	if delivery.Attachment != nil {
+		outStr.WriteString("\n" + delivery.Attachment.URL)
+	}
+	for _, link := range delivery.Links {
+		outStr.WriteString(fmt.Sprintf("\n%s: %s", link.Text, link.URL))
+	}
+
+	return outStr.String()
+}
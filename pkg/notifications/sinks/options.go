@@ -0,0 +1,31 @@
+package sinks
+
+import (
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+)
+
+// Options returns the publisher options adding each configured sink along with its retry policy
+func Options(cfg config.Sinks) []notifications.PublisherOptions {
+	options := make([]notifications.PublisherOptions, 0)
+	add := func(sink notifications.Sink, retry config.SinkRetry) {
+		options = append(options,
+			notifications.WithSink(sink),
+			notifications.WithSinkRetryPolicy(sink.Name(), retry.MaxAttempts, retry.BaseDelay, retry.MaxDelay))
+	}
+
+	if cfg.Webhook != nil {
+		add(NewWebhookSink(*cfg.Webhook), cfg.Webhook.Retry)
+	}
+	if cfg.Slack != nil {
+		add(NewSlackSink(*cfg.Slack), cfg.Slack.Retry)
+	}
+	if cfg.Discord != nil {
+		add(NewDiscordSink(*cfg.Discord), cfg.Discord.Retry)
+	}
+	if cfg.SMTP != nil {
+		add(NewSMTPSink(*cfg.SMTP), cfg.SMTP.Retry)
+	}
+
+	return options
+}
@@ -0,0 +1,94 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultSMTPPort = 587
+
+// SMTPSink emails each notification to the subscription's address. The server is expected to offer STARTTLS when
+// credentials are configured, net/smtp refuses to send them in the clear to anything but localhost.
+type SMTPSink struct {
+	addr     string
+	from     string
+	auth     smtp.Auth
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+var _ notifications.Sink = &SMTPSink{}
+
+func NewSMTPSink(cfg config.SMTPSink) *SMTPSink {
+	port := cfg.Port
+	if port <= 0 {
+		port = defaultSMTPPort
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return &SMTPSink{
+		addr:     net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		from:     cfg.From,
+		auth:     auth,
+		sendMail: smtp.SendMail,
+	}
+}
+
+func (s *SMTPSink) Name() string {
+	return "smtp"
+}
+
+func (s *SMTPSink) ValidateDestination(destination string) error {
+	address, err := mail.ParseAddress(destination)
+	if err != nil {
+		return err
+	}
+
+	if address.Address != destination {
+		return fmt.Errorf("use a bare address like someone@example.com")
+	}
+
+	return nil
+}
+
+// Send emails the delivery. net/smtp has no context support, a hung server is only cut off by the tcp timeouts.
+func (s *SMTPSink) Send(ctx context.Context, delivery notifications.Delivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.sendMail(s.addr, s.auth, s.from, []string{delivery.Destination}, s.message(delivery)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SMTPSink) message(delivery notifications.Delivery) []byte {
+	subject := fmt.Sprintf("[%s] %s", delivery.Priority, delivery.Topic)
+	body := strings.ReplaceAll(formatDelivery(delivery), "\n", "\r\n")
+
+	msg := strings.Builder{}
+	msg.WriteString("From: " + s.from + "\r\n")
+	msg.WriteString("To: " + delivery.Destination + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(body + "\r\n")
+
+	return []byte(msg.String())
+}
@@ -0,0 +1,117 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type receivedMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// newFakeSMTPServer accepts a single connection and speaks just enough SMTP for net/smtp to hand it a message
+func newFakeSMTPServer(t *testing.T) (string, int, chan receivedMail) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	received := make(chan receivedMail, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) {
+			_, _ = conn.Write([]byte(line + "\r\n"))
+		}
+
+		mail := receivedMail{}
+		reply("220 localhost ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+
+			switch cmd := strings.ToUpper(line); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				mail.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				mail.to = append(mail.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				data := strings.Builder{}
+				for {
+					dataLine, err := reader.ReadString('\n')
+					if err != nil || dataLine == ".\r\n" {
+						break
+					}
+					data.WriteString(dataLine)
+				}
+				mail.data = data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 bye")
+				received <- mail
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, received
+}
+
+func TestSMTPSink_Send(t *testing.T) {
+	host, port, received := newFakeSMTPServer(t)
+	sink := NewSMTPSink(config.SMTPSink{Host: host, Port: port, From: "bot@example.com"})
+
+	require.NoError(t, sink.Send(context.Background(), testDelivery("oncall@example.com")))
+
+	mail := <-received
+	require.Equal(t, "bot@example.com", mail.from)
+	require.Equal(t, []string{"oncall@example.com"}, mail.to)
+	require.Contains(t, mail.data, "To: oncall@example.com\r\n")
+	require.Contains(t, mail.data, "Subject: [Warning] ci.builds.failed\r\n")
+	require.Contains(t, mail.data, "main is red\r\nBuild: https://ci.example.com/42\r\n")
+}
+
+func TestSMTPSink_Send_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	sink := NewSMTPSink(config.SMTPSink{Host: "127.0.0.1", Port: port, From: "bot@example.com"})
+	require.ErrorContains(t, sink.Send(context.Background(), testDelivery("oncall@example.com")), "failed to send email")
+	require.Equal(t, "127.0.0.1:"+strconv.Itoa(port), sink.addr)
+}
+
+func TestSMTPSink_ValidateDestination(t *testing.T) {
+	sink := NewSMTPSink(config.SMTPSink{Host: "localhost", From: "bot@example.com"})
+
+	require.NoError(t, sink.ValidateDestination("oncall@example.com"))
+	require.Error(t, sink.ValidateDestination("Oncall <oncall@example.com>"))
+	require.Error(t, sink.ValidateDestination("not an address"))
+	require.Equal(t, "localhost:587", sink.addr)
+}
@@ -0,0 +1,145 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"github.com/tomato3017/tomatobot/pkg/util"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+
+	// SignatureHeader carries the hex HMAC-SHA256 of the body, prefixed with sha256=
+	SignatureHeader = "X-Tomatobot-Signature"
+	// DeliveryHeader carries the outbox id of the delivery, the same on every retry
+	DeliveryHeader = "X-Tomatobot-Delivery"
+)
+
+// webhookPayload is the json body the webhook sink posts
+type webhookPayload struct {
+	ID         int                       `json:"id"`
+	Topic      string                    `json:"topic"`
+	Text       string                    `json:"text"`
+	Priority   string                    `json:"priority"`
+	Links      []notifications.Button    `json:"links,omitempty"`
+	Attachment *notifications.Attachment `json:"attachment,omitempty"`
+	EventID    string                    `json:"event_id,omitempty"`
+	Action     string                    `json:"action,omitempty"`
+	CreatedAt  time.Time                 `json:"created_at"`
+}
+
+// WebhookSink posts each notification as json to the subscription's url, signed with the configured secret so the
+// receiver can verify it came from the bot
+type WebhookSink struct {
+	secret string
+	client *http.Client
+}
+
+var _ notifications.Sink = &WebhookSink{}
+
+func NewWebhookSink(cfg config.WebhookSink) *WebhookSink {
+	return &WebhookSink{
+		secret: cfg.Secret,
+		client: &http.Client{Timeout: positiveOr(cfg.Timeout, defaultTimeout)},
+	}
+}
+
+func (w *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookSink) ValidateDestination(destination string) error {
+	return validateURL(destination, "http", "https")
+}
+
+func (w *WebhookSink) Send(ctx context.Context, delivery notifications.Delivery) error {
+	body, err := json.Marshal(webhookPayload{
+		ID:         delivery.ID,
+		Topic:      delivery.Topic,
+		Text:       delivery.Text,
+		Priority:   strings.ToLower(delivery.Priority.String()),
+		Links:      delivery.Links,
+		Attachment: delivery.Attachment,
+		EventID:    delivery.EventID,
+		Action:     string(delivery.Action),
+		CreatedAt:  delivery.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	return postJSON(ctx, w.client, delivery.Destination, body, map[string]string{
+		SignatureHeader: "sha256=" + Sign(w.secret, body),
+		DeliveryHeader:  strconv.Itoa(delivery.ID),
+	})
+}
+
+// Sign is the hex encoded HMAC-SHA256 of the body, receivers compare it against the signature header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// postJSON posts the body and fails on anything but a 2xx response
+func postJSON(ctx context.Context, client *http.Client, destination string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, destination, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tomatobot")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, util.TruncateString(string(respBody), 200, "..."))
+	}
+
+	return nil
+}
+
+func validateURL(destination string, schemes ...string) error {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return err
+	}
+
+	for _, scheme := range schemes {
+		if u.Scheme == scheme && u.Host != "" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s is not a %s url", destination, strings.Join(schemes, " or "))
+}
+
+func positiveOr(value time.Duration, fallback time.Duration) time.Duration {
+	if value > 0 {
+		return value
+	}
+
+	return fallback
+}
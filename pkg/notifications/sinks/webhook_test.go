@@ -0,0 +1,127 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"github.com/tomato3017/tomatobot/pkg/config"
+	"github.com/tomato3017/tomatobot/pkg/notifications"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef"
+
+type capturedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newCaptureServer(t *testing.T, status int) (*httptest.Server, chan capturedRequest) {
+	t.Helper()
+
+	requests := make(chan capturedRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests <- capturedRequest{header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, requests
+}
+
+func testDelivery(destination string) notifications.Delivery {
+	return notifications.Delivery{
+		ID:          42,
+		Destination: destination,
+		Topic:       "ci.builds.failed",
+		Text:        "main is red",
+		Priority:    notifications.PriorityWarning,
+		Links:       []notifications.Button{{Text: "Build", URL: "https://ci.example.com/42"}},
+		CreatedAt:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWebhookSink_Send(t *testing.T) {
+	server, requests := newCaptureServer(t, http.StatusNoContent)
+	sink := NewWebhookSink(config.WebhookSink{Secret: testSecret})
+
+	require.NoError(t, sink.Send(context.Background(), testDelivery(server.URL)))
+
+	req := <-requests
+	require.Equal(t, "application/json", req.header.Get("Content-Type"))
+	require.Equal(t, "42", req.header.Get(DeliveryHeader))
+	require.Equal(t, "sha256="+Sign(testSecret, req.body), req.header.Get(SignatureHeader))
+
+	payload := webhookPayload{}
+	require.NoError(t, json.Unmarshal(req.body, &payload))
+	require.Equal(t, "ci.builds.failed", payload.Topic)
+	require.Equal(t, "main is red", payload.Text)
+	require.Equal(t, "warning", payload.Priority)
+	require.Len(t, payload.Links, 1)
+}
+
+func TestWebhookSink_Send_ErrorStatus(t *testing.T) {
+	server, _ := newCaptureServer(t, http.StatusBadGateway)
+	sink := NewWebhookSink(config.WebhookSink{Secret: testSecret})
+
+	err := sink.Send(context.Background(), testDelivery(server.URL))
+	require.ErrorContains(t, err, "502")
+}
+
+func TestWebhookSink_ValidateDestination(t *testing.T) {
+	sink := NewWebhookSink(config.WebhookSink{Secret: testSecret})
+
+	require.NoError(t, sink.ValidateDestination("https://example.com/hook"))
+	require.NoError(t, sink.ValidateDestination("http://10.0.0.1:8080/hook"))
+	require.Error(t, sink.ValidateDestination("ftp://example.com"))
+	require.Error(t, sink.ValidateDestination("example.com/hook"))
+}
+
+func TestChatWebhookSink_Send(t *testing.T) {
+	tests := []struct {
+		name  string
+		sink  *ChatWebhookSink
+		field string
+	}{
+		{name: "slack", sink: NewSlackSink(config.ChatWebhookSink{}), field: "text"},
+		{name: "discord", sink: NewDiscordSink(config.ChatWebhookSink{}), field: "content"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newCaptureServer(t, http.StatusOK)
+
+			require.NoError(t, tt.sink.Send(context.Background(), testDelivery(server.URL)))
+
+			payload := map[string]string{}
+			require.NoError(t, json.Unmarshal((<-requests).body, &payload))
+			require.Equal(t, "[Warning] ci.builds.failed\nmain is red\nBuild: https://ci.example.com/42", payload[tt.field])
+		})
+	}
+}
+
+func TestChatWebhookSink_Send_Truncates(t *testing.T) {
+	server, requests := newCaptureServer(t, http.StatusOK)
+	delivery := testDelivery(server.URL)
+	delivery.Text = strings.Repeat("a", 3000)
+
+	require.NoError(t, NewDiscordSink(config.ChatWebhookSink{}).Send(context.Background(), delivery))
+
+	payload := map[string]string{}
+	require.NoError(t, json.Unmarshal((<-requests).body, &payload))
+	require.Len(t, payload["content"], discordMaxTextLength)
+}
+
+func TestChatWebhookSink_ValidateDestination(t *testing.T) {
+	sink := NewSlackSink(config.ChatWebhookSink{})
+
+	require.NoError(t, sink.ValidateDestination("https://hooks.slack.com/services/T000/B000/XXXX"))
+	require.Error(t, sink.ValidateDestination("http://hooks.slack.com/services/T000/B000/XXXX"))
+}
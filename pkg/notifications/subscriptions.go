@@ -0,0 +1,313 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/rs/zerolog"
+	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
+	"github.com/uptrace/bun"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscription is a subscriber along with its compiled topic pattern
+type subscription struct {
+	Subscriber
+	topicRegex *regexp.Regexp
+}
+
+// subscriptionRepo stores the subscriptions. The database is the source of truth, every change is written there
+// first and only then applied to the in-memory view, which is indexed by id and by chat and can be rebuilt from the
+// database at any time with Reload.
+type subscriptionRepo struct {
+	dbConn bun.IDB
+	logger zerolog.Logger
+
+	lck    sync.RWMutex
+	byId   map[uuid.UUID]subscription
+	byChat map[int64]map[uuid.UUID]struct{}
+
+	// topicCache holds the subscribers matching each concrete topic, cleared on every change
+	topicCache *ttlcache.Cache[string, []Subscriber]
+}
+
+func newSubscriptionRepo(dbConn bun.IDB, topicCache *ttlcache.Cache[string, []Subscriber], logger zerolog.Logger) *subscriptionRepo {
+	return &subscriptionRepo{
+		dbConn:     dbConn,
+		logger:     logger,
+		byId:       make(map[uuid.UUID]subscription),
+		byChat:     make(map[int64]map[uuid.UUID]struct{}),
+		topicCache: topicCache,
+	}
+}
+
+// compileTopicPattern turns a subscription pattern into its regex, * matches anything
+func compileTopicPattern(pattern string) (*regexp.Regexp, error) {
+	tokenizedStr := strings.ReplaceAll(pattern, "*", "<star>")
+	escapedString := regexp.QuoteMeta(tokenizedStr)
+	finalPattern := strings.ReplaceAll(escapedString, "<star>", ".*")
+
+	re, err := regexp.Compile(finalPattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile regex: %w", err)
+	}
+
+	return re, nil
+}
+
+func subscriberFromDb(dbSub dbmodels.Subscriptions) (Subscriber, error) {
+	digest, err := ParseDigestSchedule(dbSub.Digest)
+
+	return Subscriber{
+		ID:           dbSub.ID,
+		ChatId:       dbSub.ChatID,
+		TopicPattern: dbSub.TopicPattern,
+		MinPriority:  Priority(dbSub.MinPriority),
+		Digest:       digest,
+		MutedUntil:   dbSub.MutedUntil,
+		ExpiresAt:    dbSub.ExpiresAt,
+		CreatedBy:    dbSub.CreatedBy,
+		Sink:         dbSub.Sink,
+		Destination:  dbSub.Destination,
+	}, err
+}
+
+// Reload replaces the in-memory view with the subscriptions in the database
+func (r *subscriptionRepo) Reload(ctx context.Context) error {
+	dbSubs := make([]dbmodels.Subscriptions, 0)
+	if err := r.dbConn.NewSelect().Model(&dbSubs).Scan(ctx); err != nil {
+		return fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+
+	subs := make([]subscription, 0, len(dbSubs))
+	for _, dbSub := range dbSubs {
+		sub, err := subscriberFromDb(dbSub)
+		if err != nil {
+			r.logger.Error().Err(err).Msgf("invalid digest schedule for subscription %s, delivering immediately", dbSub.ID)
+		}
+
+		re, err := compileTopicPattern(sub.TopicPattern)
+		if err != nil {
+			r.logger.Error().Err(err).Msgf("skipping subscription %s with invalid pattern %s", sub.ID, sub.TopicPattern)
+			continue
+		}
+
+		subs = append(subs, subscription{Subscriber: sub, topicRegex: re})
+	}
+
+	r.lck.Lock()
+	defer r.lck.Unlock()
+
+	r.byId = make(map[uuid.UUID]subscription, len(subs))
+	r.byChat = make(map[int64]map[uuid.UUID]struct{})
+	for _, sub := range subs {
+		r.index(sub)
+	}
+	r.invalidate()
+	r.logger.Debug().Msgf("Loaded %d subscriptions", len(subs))
+
+	return nil
+}
+
+// Add stores a new subscription, assigning it an id if it has none
+func (r *subscriptionRepo) Add(ctx context.Context, sub Subscriber) (Subscriber, error) {
+	re, err := compileTopicPattern(sub.TopicPattern)
+	if err != nil {
+		return sub, err
+	}
+
+	r.lck.Lock()
+	defer r.lck.Unlock()
+
+	dbSub := sub.DbModel()
+	sub.ID = dbSub.ID
+	if _, err := r.dbConn.NewInsert().Model(dbSub).Exec(ctx); err != nil {
+		if isUniqueViolation(err) {
+			return sub, ErrSubExists
+		}
+		return sub, fmt.Errorf("failed to insert subscription: %w", err)
+	}
+
+	r.index(subscription{Subscriber: sub, topicRegex: re})
+	r.invalidate()
+
+	return sub, nil
+}
+
+// Remove deletes the chat's subscription. Returns false if the chat has no subscription with that id.
+func (r *subscriptionRepo) Remove(ctx context.Context, id uuid.UUID, chatId int64) (bool, error) {
+	if id == uuid.Nil {
+		return false, fmt.Errorf("invalid subscription id")
+	}
+
+	r.lck.Lock()
+	defer r.lck.Unlock()
+
+	// deleting by the database rather than the in-memory view means a subscription the view somehow lost still goes
+	result, err := r.dbConn.NewDelete().Model((*dbmodels.Subscriptions)(nil)).
+		Where("id = ?", id).
+		Where("chat_id = ?", chatId).
+		Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete subscription: %w", err)
+	}
+
+	if sub, ok := r.byId[id]; ok && sub.ChatId == chatId {
+		r.unindex(id)
+		r.invalidate()
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to count deleted subscriptions: %w", err)
+	}
+
+	return removed > 0, nil
+}
+
+// RemoveAll deletes every subscription of the chat, returning the removed subscriptions
+func (r *subscriptionRepo) RemoveAll(ctx context.Context, chatId int64) ([]Subscriber, error) {
+	r.lck.Lock()
+	defer r.lck.Unlock()
+
+	_, err := r.dbConn.NewDelete().Model((*dbmodels.Subscriptions)(nil)).
+		Where("chat_id = ?", chatId).
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete subscriptions: %w", err)
+	}
+
+	removed := make([]Subscriber, 0, len(r.byChat[chatId]))
+	for id := range r.byChat[chatId] {
+		removed = append(removed, r.byId[id].Subscriber)
+		r.unindex(id)
+	}
+	r.invalidate()
+
+	return removed, nil
+}
+
+// SetMutedUntil mutes the chat's subscription until the given time, the zero time unmutes it
+func (r *subscriptionRepo) SetMutedUntil(ctx context.Context, id uuid.UUID, chatId int64, until time.Time) error {
+	r.lck.Lock()
+	defer r.lck.Unlock()
+
+	sub, ok := r.byId[id]
+	if !ok || sub.ChatId != chatId {
+		return ErrSubNotFound
+	}
+
+	query := r.dbConn.NewUpdate().Model((*dbmodels.Subscriptions)(nil)).
+		Where("id = ?", id).
+		Where("chat_id = ?", chatId)
+	if until.IsZero() {
+		query = query.Set("muted_until = NULL")
+	} else {
+		query = query.Set("muted_until = ?", until)
+	}
+
+	if _, err := query.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	sub.MutedUntil = until
+	r.byId[id] = sub
+	r.invalidate()
+
+	return nil
+}
+
+func (r *subscriptionRepo) Get(id uuid.UUID) (Subscriber, bool) {
+	r.lck.RLock()
+	defer r.lck.RUnlock()
+
+	sub, ok := r.byId[id]
+	return sub.Subscriber, ok
+}
+
+func (r *subscriptionRepo) All() []Subscriber {
+	r.lck.RLock()
+	defer r.lck.RUnlock()
+
+	subs := make([]Subscriber, 0, len(r.byId))
+	for _, sub := range r.byId {
+		subs = append(subs, sub.Subscriber)
+	}
+
+	return subs
+}
+
+// Expired returns the subscriptions whose expiry has passed
+func (r *subscriptionRepo) Expired(now time.Time) []Subscriber {
+	r.lck.RLock()
+	defer r.lck.RUnlock()
+
+	expired := make([]Subscriber, 0)
+	for _, sub := range r.byId {
+		if !sub.ExpiresAt.IsZero() && !now.Before(sub.ExpiresAt) {
+			expired = append(expired, sub.Subscriber)
+		}
+	}
+
+	return expired
+}
+
+// MatchTopic returns the subscriptions whose pattern matches the concrete topic
+func (r *subscriptionRepo) MatchTopic(topic string) []Subscriber {
+	r.lck.RLock()
+	defer r.lck.RUnlock()
+
+	if cacheEntry := r.topicCache.Get(topic); cacheEntry != nil {
+		r.logger.Trace().Msgf("Cache hit for topic: %s", topic)
+		return cacheEntry.Value()
+	}
+
+	r.logger.Trace().Msgf("Cache miss for topic: %s", topic)
+	subscribers := make([]Subscriber, 0)
+	for _, sub := range r.byId {
+		if sub.topicRegex.MatchString(topic) {
+			subscribers = append(subscribers, sub.Subscriber)
+		}
+	}
+
+	r.logger.Trace().Msgf("Setting cache for topic: %s TO: %+v", topic, subscribers)
+	r.topicCache.Set(topic, subscribers, ttlcache.DefaultTTL)
+
+	return subscribers
+}
+
+func (r *subscriptionRepo) index(sub subscription) {
+	r.byId[sub.ID] = sub
+	if r.byChat[sub.ChatId] == nil {
+		r.byChat[sub.ChatId] = make(map[uuid.UUID]struct{})
+	}
+	r.byChat[sub.ChatId][sub.ID] = struct{}{}
+}
+
+func (r *subscriptionRepo) unindex(id uuid.UUID) {
+	sub, ok := r.byId[id]
+	if !ok {
+		return
+	}
+
+	delete(r.byId, id)
+	delete(r.byChat[sub.ChatId], id)
+	if len(r.byChat[sub.ChatId]) == 0 {
+		delete(r.byChat, sub.ChatId)
+	}
+}
+
+func (r *subscriptionRepo) invalidate() {
+	r.logger.Trace().Msgf("Invalidating subscription cache")
+	r.topicCache.DeleteAll()
+}
+
+func isUniqueViolation(err error) bool {
+	// sqlite and postgres respectively
+	return strings.Contains(err.Error(), "UNIQUE constraint failed") ||
+		strings.Contains(err.Error(), "duplicate key value violates unique constraint")
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	dbmodels "github.com/tomato3017/tomatobot/pkg/bot/models/db"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 	"github.com/uptrace/bun/migrate"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -155,6 +157,645 @@ func MigrateDbSchema(ctx context.Context, db *bun.DB) (int, error) {
 		},
 	})
 
+	migrations.Add(migrate.Migration{
+		Name: "00007_add_min_priority_to_subscriptions",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			err := db.NewSelect().Model((*dbmodels.Subscriptions)(nil)).Column("min_priority").Limit(1).Scan(ctx)
+			if err == nil || strings.Contains(err.Error(), "no rows in result set") {
+				return nil
+			}
+
+			_, err = db.NewAddColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("min_priority INTEGER NOT NULL DEFAULT 0").Exec(ctx)
+
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("min_priority").Exec(ctx)
+
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00008_create_quiet_hours_tables",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().
+				Model((*dbmodels.NotificationsQuietHours)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewCreateTable().
+				Model((*dbmodels.NotificationsDeferred)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().
+				Model((*dbmodels.NotificationsDeferred)(nil)).
+				IfExists().
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewDropTable().
+				Model((*dbmodels.NotificationsQuietHours)(nil)).
+				IfExists().
+				Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00009_create_outbox_table",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().
+				Model((*dbmodels.NotificationsOutbox)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewCreateIndex().
+				Model((*dbmodels.NotificationsOutbox)(nil)).
+				Index("notifications_outbox_status_next_attempt_at_idx").
+				IfNotExists().
+				Column("status", "next_attempt_at").
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().
+				Model((*dbmodels.NotificationsOutbox)(nil)).
+				IfExists().
+				Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00010_add_digest_to_subscriptions",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			err := db.NewSelect().Model((*dbmodels.Subscriptions)(nil)).Column("digest").Limit(1).Scan(ctx)
+			if err != nil && !strings.Contains(err.Error(), "no rows in result set") {
+				_, err = db.NewAddColumn().
+					Model((*dbmodels.Subscriptions)(nil)).
+					ColumnExpr("digest VARCHAR NOT NULL DEFAULT ''").Exec(ctx)
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err = db.NewCreateTable().
+				Model((*dbmodels.NotificationsDigestItems)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().
+				Model((*dbmodels.NotificationsDigestItems)(nil)).
+				IfExists().
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewDropColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("digest").Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00011_add_options_to_outbox",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			err := db.NewSelect().Model((*dbmodels.NotificationsOutbox)(nil)).Column("options").Limit(1).Scan(ctx)
+			if err == nil || strings.Contains(err.Error(), "no rows in result set") {
+				return nil
+			}
+
+			_, err = db.NewAddColumn().
+				Model((*dbmodels.NotificationsOutbox)(nil)).
+				ColumnExpr("options VARCHAR NOT NULL DEFAULT '{}'").Exec(ctx)
+
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropColumn().
+				Model((*dbmodels.NotificationsOutbox)(nil)).
+				ColumnExpr("options").Exec(ctx)
+
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00012_create_sent_events_table",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().
+				Model((*dbmodels.NotificationsSentEvents)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().
+				Model((*dbmodels.NotificationsSentEvents)(nil)).
+				IfExists().
+				Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00013_add_mute_and_expiry_to_subscriptions",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			err := db.NewSelect().Model((*dbmodels.Subscriptions)(nil)).Column("muted_until").Limit(1).Scan(ctx)
+			if err == nil || strings.Contains(err.Error(), "no rows in result set") {
+				return nil
+			}
+
+			_, err = db.NewAddColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("muted_until TIMESTAMP").Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewAddColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("expires_at TIMESTAMP").Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("expires_at").Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewDropColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("muted_until").Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00014_create_topic_stats_table",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().
+				Model((*dbmodels.NotificationsTopicStats)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().
+				Model((*dbmodels.NotificationsTopicStats)(nil)).
+				IfExists().
+				Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00015_add_created_by_to_subscriptions",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			err := db.NewSelect().Model((*dbmodels.Subscriptions)(nil)).Column("created_by").Limit(1).Scan(ctx)
+			if err == nil || strings.Contains(err.Error(), "no rows in result set") {
+				return nil
+			}
+
+			_, err = db.NewAddColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("created_by BIGINT NOT NULL DEFAULT 0").Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("created_by").Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00016_add_sink_to_subscriptions",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			err := db.NewSelect().Model((*dbmodels.Subscriptions)(nil)).Column("sink").Limit(1).Scan(ctx)
+			if err == nil || strings.Contains(err.Error(), "no rows in result set") {
+				return nil
+			}
+
+			_, err = db.NewAddColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("sink VARCHAR NOT NULL DEFAULT ''").Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewAddColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("destination VARCHAR NOT NULL DEFAULT ''").Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("destination").Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewDropColumn().
+				Model((*dbmodels.Subscriptions)(nil)).
+				ColumnExpr("sink").Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00017_add_sink_to_notifications_outbox",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			err := db.NewSelect().Model((*dbmodels.NotificationsOutbox)(nil)).Column("sink").Limit(1).Scan(ctx)
+			if err == nil || strings.Contains(err.Error(), "no rows in result set") {
+				return nil
+			}
+
+			_, err = db.NewAddColumn().
+				Model((*dbmodels.NotificationsOutbox)(nil)).
+				ColumnExpr("sink VARCHAR NOT NULL DEFAULT ''").Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewAddColumn().
+				Model((*dbmodels.NotificationsOutbox)(nil)).
+				ColumnExpr("destination VARCHAR NOT NULL DEFAULT ''").Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropColumn().
+				Model((*dbmodels.NotificationsOutbox)(nil)).
+				ColumnExpr("destination").Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewDropColumn().
+				Model((*dbmodels.NotificationsOutbox)(nil)).
+				ColumnExpr("sink").Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00018_create_history_table",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().
+				Model((*dbmodels.NotificationsHistory)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewCreateIndex().
+				Model((*dbmodels.NotificationsHistory)(nil)).
+				Index("notifications_history_chat_id_created_at_idx").
+				IfNotExists().
+				Column("chat_id", "created_at").
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.NewCreateIndex().
+				Model((*dbmodels.NotificationsHistory)(nil)).
+				Index("notifications_history_outbox_id_idx").
+				IfNotExists().
+				Column("outbox_id").
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().
+				Model((*dbmodels.NotificationsHistory)(nil)).
+				IfExists().
+				Exec(ctx)
+			return err
+		},
+	})
+
+	// Weather locations were keyed by US zip code, they are now keyed by country and postal code or coordinates
+	migrations.Add(migrate.Migration{
+		Name: "00019_add_location_key_to_weather_polling",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			err := db.NewSelect().Model((*dbmodels.WeatherPollingLocations)(nil)).Column("location_key").Limit(1).Scan(ctx)
+			if err == nil || strings.Contains(err.Error(), "no rows in result set") {
+				return nil
+			}
+
+			return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+				if _, err := tx.ExecContext(ctx, "ALTER TABLE weather_polling_locations RENAME COLUMN zip_code TO location_key"); err != nil {
+					return err
+				}
+
+				_, err := tx.NewAddColumn().
+					Model((*dbmodels.WeatherPollingLocations)(nil)).
+					ColumnExpr("postal_code VARCHAR NOT NULL DEFAULT ''").Exec(ctx)
+				if err != nil {
+					return err
+				}
+
+				_, err = tx.NewUpdate().
+					Model((*dbmodels.WeatherPollingLocations)(nil)).
+					Set("postal_code = location_key").
+					Set("location_key = 'us-' || location_key").
+					Where("1 = 1").
+					Exec(ctx)
+				if err != nil {
+					return err
+				}
+
+				return rewriteWeatherTopics(ctx, tx, legacyWeatherTopicRegex, "weather.us-$1$2")
+			})
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+				_, err := tx.NewUpdate().
+					Model((*dbmodels.WeatherPollingLocations)(nil)).
+					Set("location_key = postal_code").
+					Where("location_key = 'us-' || postal_code").
+					Exec(ctx)
+				if err != nil {
+					return err
+				}
+
+				if _, err := tx.ExecContext(ctx, "ALTER TABLE weather_polling_locations RENAME COLUMN location_key TO zip_code"); err != nil {
+					return err
+				}
+
+				_, err = tx.NewDropColumn().
+					Model((*dbmodels.WeatherPollingLocations)(nil)).
+					ColumnExpr("postal_code").Exec(ctx)
+				if err != nil {
+					return err
+				}
+
+				return rewriteWeatherTopics(ctx, tx, usWeatherTopicRegex, "weather.$1$2")
+			})
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00020_create_weather_chat_settings_table",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().
+				Model((*dbmodels.WeatherChatSettings)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().
+				Model((*dbmodels.WeatherChatSettings)(nil)).
+				IfExists().
+				Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00021_create_weather_briefings_table",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().
+				Model((*dbmodels.WeatherBriefings)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().
+				Model((*dbmodels.WeatherBriefings)(nil)).
+				IfExists().
+				Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00022_create_weather_active_alerts_table",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().
+				Model((*dbmodels.WeatherActiveAlerts)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().
+				Model((*dbmodels.WeatherActiveAlerts)(nil)).
+				IfExists().
+				Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00023_create_weather_api_usage_table",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().
+				Model((*dbmodels.WeatherAPIUsage)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().
+				Model((*dbmodels.WeatherAPIUsage)(nil)).
+				IfExists().
+				Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00024_create_weather_alert_settings_table",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().
+				Model((*dbmodels.WeatherAlertSettings)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().
+				Model((*dbmodels.WeatherAlertSettings)(nil)).
+				IfExists().
+				Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00025_create_notifications_inbox_table",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().
+				Model((*dbmodels.NotificationsInbox)(nil)).
+				IfNotExists().
+				Exec(ctx)
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropTable().
+				Model((*dbmodels.NotificationsInbox)(nil)).
+				IfExists().
+				Exec(ctx)
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00026_add_repeats_to_notifications_history",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			err := db.NewSelect().Model((*dbmodels.NotificationsHistory)(nil)).Column("repeats").Limit(1).Scan(ctx)
+			if err == nil || strings.Contains(err.Error(), "no rows in result set") {
+				return nil
+			}
+
+			_, err = db.NewAddColumn().
+				Model((*dbmodels.NotificationsHistory)(nil)).
+				ColumnExpr("repeats INTEGER NOT NULL DEFAULT 0").Exec(ctx)
+
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropColumn().
+				Model((*dbmodels.NotificationsHistory)(nil)).
+				ColumnExpr("repeats").Exec(ctx)
+
+			return err
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00027_add_missed_to_weather_active_alerts",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			err := db.NewSelect().Model((*dbmodels.WeatherActiveAlerts)(nil)).Column("missed").Limit(1).Scan(ctx)
+			if err == nil || strings.Contains(err.Error(), "no rows in result set") {
+				return nil
+			}
+
+			_, err = db.NewAddColumn().
+				Model((*dbmodels.WeatherActiveAlerts)(nil)).
+				ColumnExpr("missed INTEGER NOT NULL DEFAULT 0").Exec(ctx)
+
+			return err
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewDropColumn().
+				Model((*dbmodels.WeatherActiveAlerts)(nil)).
+				ColumnExpr("missed").Exec(ctx)
+
+			return err
+		},
+	})
+
+	// A chat can route the same pattern to telegram and to sinks, so the unique key covers the whole target
+	migrations.Add(migrate.Migration{
+		Name: "00028_add_target_to_subscriptions_unique_key",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+				if db.Dialect().Name() != dialect.PG {
+					// sqlite can't drop a table constraint, the table is rebuilt from the model instead
+					return rebuildSubscriptionsTable(ctx, tx)
+				}
+
+				for _, query := range []string{
+					"ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_chat_id_topic_pattern_key",
+					"ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_target_topic_pattern_key",
+					"ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_target_topic_pattern_key UNIQUE (chat_id, topic_pattern, sink, destination)",
+				} {
+					if _, err := tx.ExecContext(ctx, query); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+				if db.Dialect().Name() == dialect.PG {
+					if _, err := tx.ExecContext(ctx, "ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_target_topic_pattern_key"); err != nil {
+						return err
+					}
+				}
+
+				_, err := tx.NewCreateIndex().
+					Model((*dbmodels.Subscriptions)(nil)).
+					Index("subscriptions_chat_id_topic_pattern_key").
+					Unique().
+					Column("chat_id", "topic_pattern").
+					IfNotExists().
+					Exec(ctx)
+
+				return err
+			})
+		},
+	})
+
+	migrations.Add(migrate.Migration{
+		Name: "00029_add_data_and_attempts_to_notifications_inbox",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			err := db.NewSelect().Model((*dbmodels.NotificationsInbox)(nil)).Column("data").Limit(1).Scan(ctx)
+			if err == nil || strings.Contains(err.Error(), "no rows in result set") {
+				return nil
+			}
+
+			for _, column := range []string{
+				"data VARCHAR NOT NULL DEFAULT ''",
+				"attempts INTEGER NOT NULL DEFAULT 0",
+				"next_attempt_at TIMESTAMP",
+			} {
+				_, err := db.NewAddColumn().
+					Model((*dbmodels.NotificationsInbox)(nil)).
+					ColumnExpr(column).Exec(ctx)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		Down: func(ctx context.Context, db *bun.DB) error {
+			for _, column := range []string{"data", "attempts", "next_attempt_at"} {
+				_, err := db.NewDropColumn().
+					Model((*dbmodels.NotificationsInbox)(nil)).
+					ColumnExpr(column).Exec(ctx)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	})
+
 	ctx, cf := context.WithTimeout(ctx, 30*time.Second)
 	defer cf()
 
@@ -171,3 +812,52 @@ func MigrateDbSchema(ctx context.Context, db *bun.DB) (int, error) {
 
 	return len(mGroup.Migrations), nil
 }
+
+var (
+	legacyWeatherTopicRegex = regexp.MustCompile(`^weather\.(\d{5})(\.|$)`)
+	usWeatherTopicRegex     = regexp.MustCompile(`^weather\.us-(\d{5})(\.|$)`)
+)
+
+// rewriteWeatherTopics moves the weather subscriptions matching the regex onto the replacement topic pattern
+func rewriteWeatherTopics(ctx context.Context, tx bun.Tx, topicRegex *regexp.Regexp, replacement string) error {
+	subs := make([]dbmodels.Subscriptions, 0)
+	if err := tx.NewSelect().Model(&subs).Where("topic_pattern LIKE ?", "weather.%").Scan(ctx); err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		if !topicRegex.MatchString(sub.TopicPattern) {
+			continue
+		}
+
+		_, err := tx.NewUpdate().Model((*dbmodels.Subscriptions)(nil)).
+			Set("topic_pattern = ?", topicRegex.ReplaceAllString(sub.TopicPattern, replacement)).
+			Where("id = ?", sub.ID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rebuildSubscriptionsTable recreates the subscriptions table from the model, keeping its rows
+func rebuildSubscriptionsTable(ctx context.Context, tx bun.Tx) error {
+	const columns = "id, chat_id, topic_pattern, min_priority, digest, muted_until, expires_at, created_by, sink, destination"
+
+	if _, err := tx.ExecContext(ctx, "ALTER TABLE subscriptions RENAME TO subscriptions_old"); err != nil {
+		return err
+	}
+
+	if _, err := tx.NewCreateTable().Model((*dbmodels.Subscriptions)(nil)).Exec(ctx); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO subscriptions ("+columns+") SELECT "+columns+" FROM subscriptions_old"); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, "DROP TABLE subscriptions_old")
+	return err
+}
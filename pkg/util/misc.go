@@ -3,6 +3,9 @@ package util
 import (
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/exp/constraints"
 )
@@ -40,3 +43,18 @@ func FirstNonZero[T NonZeroable](values ...T) T {
 	}
 	return zero
 }
+
+// ParseDuration is time.ParseDuration that also accepts whole days and weeks, e.g. 3d or 2w
+func ParseDuration(raw string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if count, ok := strings.CutSuffix(raw, suffix); ok {
+			n, err := strconv.Atoi(count)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %s", raw)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	return time.ParseDuration(raw)
+}
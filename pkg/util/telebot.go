@@ -40,3 +40,31 @@ func EscapeStrings(a ...interface{}) []interface{} {
 	}
 	return escaped
 }
+
+// TelegramMaxMessageLength is the maximum number of characters telegram accepts in a single message
+const TelegramMaxMessageLength = 4096
+
+// SplitMessage splits text into chunks no longer than limit characters, preferring to break on newlines.
+func SplitMessage(text string, limit int) []string {
+	chunks := make([]string, 0)
+	runes := []rune(text)
+
+	for len(runes) > limit {
+		cut := limit
+		for i := limit; i > limit/2; i-- {
+			if runes[i-1] == '\n' {
+				cut = i
+				break
+			}
+		}
+
+		chunks = append(chunks, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+
+	if len(runes) > 0 {
+		chunks = append(chunks, string(runes))
+	}
+
+	return chunks
+}